@@ -0,0 +1,158 @@
+//go:build js && wasm
+
+// Command ooo-view-wasm exposes the renderers to JavaScript, so a page
+// holding a JSON export (e.g. /events.json of 'ooo-view serve') can filter
+// it and switch formats without asking the server again. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o ooo-view.wasm ./cmd/ooo-view-wasm
+//
+// and load it with wasm_exec.js from the Go distribution. It defines a
+// global oooView object:
+//
+//	oooView.formats()             // ["csv", "grid", "ics", "json"]
+//	oooView.render(json, options) // {output: "..."} or {error: "..."}
+//
+// options may set format (default "grid"), minDuration (e.g. "48h" or "2d"),
+// timezone (an IANA name, default UTC), person (keeps the people whose
+// email contains it) and from and to (dates narrowing the window).
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall/js"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+func main() {
+	js.Global().Set("oooView", js.ValueOf(map[string]any{
+		"formats": js.FuncOf(formats),
+		"render":  js.FuncOf(renderExport),
+	}))
+	select {}
+}
+
+func formats(this js.Value, args []js.Value) any {
+	names := render.Names()
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return values
+}
+
+func renderExport(this js.Value, args []js.Value) any {
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return result("", fmt.Errorf("the first argument must be the JSON export"))
+	}
+	options := js.Undefined()
+	if len(args) > 1 {
+		options = args[1]
+	}
+	var b strings.Builder
+	err := rerender(&b, args[0].String(), options)
+	return result(b.String(), err)
+}
+
+// rerender re-runs the export in data through the library with the options
+// set from JavaScript and renders the result to b.
+func rerender(b *strings.Builder, data string, options js.Value) error {
+	p, err := render.ParseJSON([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	format := option(options, "format", "grid")
+	timeZone := option(options, "timezone", "UTC")
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone: %v", err)
+	}
+	timeMin, timeMax := p.TimeMin, p.TimeMax
+	if from := option(options, "from", ""); from != "" {
+		if timeMin, err = time.ParseInLocation("2006-01-02", from, loc); err != nil {
+			return fmt.Errorf("invalid from date: %v", err)
+		}
+	}
+	if to := option(options, "to", ""); to != "" {
+		if timeMax, err = time.ParseInLocation("2006-01-02", to, loc); err != nil {
+			return fmt.Errorf("invalid to date: %v", err)
+		}
+		timeMax = timeMax.AddDate(0, 0, 1)
+	}
+	minDuration := time.Duration(0)
+	if s := option(options, "minDuration", ""); s != "" {
+		if minDuration, err = ooo.ParseDuration(s); err != nil {
+			return fmt.Errorf("invalid minDuration: %v", err)
+		}
+	}
+
+	client, err := ooo.New(
+		ooo.WithProvider(p),
+		ooo.WithGroup(p.Group),
+		ooo.WithWindow(timeMin, timeMax),
+		ooo.WithMinDuration(minDuration),
+		ooo.WithTimeZone(timeZone),
+	)
+	if err != nil {
+		return err
+	}
+	ds, err := client.Fetch(context.Background())
+	if err != nil {
+		return err
+	}
+	ds.FetchedAt = p.FetchedAt
+	ds.Unavailable = p.Unavailable
+	ds.NoData = p.NoData
+	if person := option(options, "person", ""); person != "" {
+		filterPeople(ds, person)
+	}
+	return client.Render(b, format)
+}
+
+// filterPeople keeps the members, and their absences, whose email contains
+// substr.
+func filterPeople(ds *ooo.Dataset, substr string) {
+	substr = strings.ToLower(substr)
+	match := func(person string) bool {
+		return strings.Contains(strings.ToLower(person), substr)
+	}
+	members := ds.Members[:0]
+	for _, m := range ds.Members {
+		if match(m) {
+			members = append(members, m)
+		}
+	}
+	ds.Members = members
+	absences := ds.Absences[:0]
+	for _, a := range ds.Absences {
+		if match(a.Person) {
+			absences = append(absences, a)
+		}
+	}
+	ds.Absences = absences
+}
+
+// option returns the string property name of options, or def if it's unset.
+func option(options js.Value, name, def string) string {
+	if options.Type() != js.TypeObject {
+		return def
+	}
+	v := options.Get(name)
+	if v.IsUndefined() || v.IsNull() {
+		return def
+	}
+	return v.String()
+}
+
+func result(output string, err error) any {
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return map[string]any{"output": output}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+func accessCheckCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	fs.StringVar(&cfg.TimeZone, "timezone", cfg.TimeZone, "Time zone for the group lookup")
+	fs.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "Maximum number of calendars checked in parallel")
+	addHTTPFlags(fs, cfg)
+	addAuthFlags(fs, cfg)
+
+	return func(ctx context.Context, s *session, args []string) error {
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		if s.cfg.FreebusyOnly {
+			return usageErrorf("access-check needs to try reading events; drop --freebusy-only")
+		}
+		provider, err := s.googleProvider(ctx)
+		if err != nil {
+			return err
+		}
+		groupEmail := resolveGroup(group, s.cfg.GroupAliases)
+		timeMin := time.Now()
+		timeMax := timeMin.AddDate(0, 0, 7)
+		members, err := provider.Members(ctx, groupEmail, timeMin, timeMax, s.cfg.TimeZone)
+		if err != nil {
+			return err
+		}
+
+		type result struct {
+			access ooo.Access
+			role   string
+			err    error
+		}
+		results := make([]result, len(members))
+		sem := make(chan struct{}, s.cfg.Concurrency)
+		var wg sync.WaitGroup
+		for i, person := range members {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(r *result, person string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				r.access, r.role, r.err = provider.CheckAccess(ctx, person, timeMin, timeMax)
+			}(&results[i], person)
+		}
+		wg.Wait()
+
+		counts := make(map[ooo.Access]int)
+		failed := 0
+		for i, person := range members {
+			r := results[i]
+			switch {
+			case r.err != nil:
+				failed++
+				fmt.Printf("%-30s error: %v\n", person, r.err)
+			case r.role != "":
+				counts[r.access]++
+				fmt.Printf("%-30s %-9s (%s)\n", person, r.access, r.role)
+			default:
+				counts[r.access]++
+				fmt.Printf("%-30s %s\n", person, r.access)
+			}
+		}
+
+		fmt.Printf("\n%d of %d calendars can be read in full", counts[ooo.AccessEvents], len(members))
+		if n := counts[ooo.AccessFreebusy]; n > 0 {
+			fmt.Printf(", %d only show free/busy", n)
+		}
+		if n := counts[ooo.AccessNone]; n > 0 {
+			fmt.Printf(", %d aren't shared", n)
+		}
+		fmt.Println(".")
+		if counts[ooo.AccessFreebusy]+counts[ooo.AccessNone] > 0 {
+			fmt.Println("Out-of-office events are only visible to users who can see event details. Have the owners share their calendar with")
+			fmt.Println("\"See all event details\", or set the organization's default sharing to it in the Google Workspace admin console.")
+			fmt.Println("Until then --freebusy-only infers absences from long busy blocks.")
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d calendars could not be checked", failed)
+		}
+		if n := counts[ooo.AccessFreebusy] + counts[ooo.AccessNone]; n > 0 {
+			return fmt.Errorf("%d calendars can't be read in full", n)
+		}
+		return nil
+	}
+}
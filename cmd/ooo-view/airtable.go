@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+const (
+	airtableAPI = "https://api.airtable.com/v0/"
+	// airtableBatch is the most records one create, update or delete
+	// request takes
+	airtableBatch = 10
+	// airtableInterval keeps under the limit of 5 requests per second per
+	// base
+	airtableInterval = 220 * time.Millisecond
+)
+
+// airtableFields are the export's fields that can be mapped to columns,
+// with the column names used by default.
+var airtableFields = map[string]string{
+	"key":     "Key",
+	"group":   "Group",
+	"person":  "Person",
+	"start":   "Start",
+	"end":     "End",
+	"all_day": "All day",
+	"summary": "Summary",
+	"kind":    "Kind",
+}
+
+// airtableConfig is the airtable section of the config file.
+type airtableConfig struct {
+	Base  string `yaml:"base"`
+	Table string `yaml:"table"`
+	// Fields maps the export's fields to the table's columns; an empty
+	// column leaves the field out
+	Fields map[string]string `yaml:"fields"`
+}
+
+// columns returns the column of every mapped field.
+func (c airtableConfig) columns() map[string]string {
+	columns := make(map[string]string, len(airtableFields))
+	for field, column := range airtableFields {
+		columns[field] = column
+	}
+	for field, column := range c.Fields {
+		if column == "" {
+			delete(columns, field)
+		} else {
+			columns[field] = column
+		}
+	}
+	return columns
+}
+
+func airtableCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	base := fs.String("base", "", "ID of the Airtable base, e.g. appXXXXXXXXXXXXXX (default: airtable.base from the config file)")
+	table := fs.String("table", "", "Name or ID of the table (default: airtable.table from the config file)")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		at := s.cfg.Airtable
+		if *base != "" {
+			at.Base = *base
+		}
+		if *table != "" {
+			at.Table = *table
+		}
+		if at.Base == "" || at.Table == "" {
+			return usageErrorf("set the base and table with --base and --table or in the airtable section of the config file")
+		}
+		token := os.Getenv("AIRTABLE_TOKEN")
+		if token == "" {
+			return fmt.Errorf("set AIRTABLE_TOKEN to a personal access token with the data.records:read and data.records:write scopes")
+		}
+
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+		if ds.Partial || len(ds.Unavailable) > 0 {
+			return fmt.Errorf("not syncing incomplete data, as the absences of the calendars missing would be deleted")
+		}
+
+		client := &airtableClient{http: newHTTPClient(s.cfg), token: token, table: airtableAPI + url.PathEscape(at.Base) + "/" + url.PathEscape(at.Table)}
+		stats, err := syncAirtable(ctx, client, at.columns(), ds)
+		s.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: "airtable", Destination: "airtable:" + at.Base + "/" + at.Table, Events: len(ds.Absences), Error: errorString(err)})
+		if err != nil {
+			return err
+		}
+		logFor("airtable").Info("table synced", "created", stats.created, "updated", stats.updated, "deleted", stats.deleted, "unchanged", stats.unchanged)
+		return nil
+	}
+}
+
+type airtableStats struct {
+	created, updated, deleted, unchanged int
+}
+
+// syncAirtable makes the table mirror ds: records of absences are created
+// or updated, matched by the key column, and those of absences no longer
+// in ds are deleted. With a group column, only the group's records are
+// touched.
+func syncAirtable(ctx context.Context, c *airtableClient, columns map[string]string, ds *ooo.Dataset) (airtableStats, error) {
+	var stats airtableStats
+	keyColumn, ok := columns["key"]
+	if !ok {
+		return stats, fmt.Errorf("the key field must be mapped to a column")
+	}
+	filter := ""
+	if column, ok := columns["group"]; ok {
+		filter = fmt.Sprintf("{%s}='%s'", column, strings.ReplaceAll(ds.Group, "'", `\'`))
+	}
+	existing, err := c.list(ctx, filter)
+	if err != nil {
+		return stats, err
+	}
+	byKey := make(map[string]airtableRecord, len(existing))
+	var deletes []string
+	for _, rec := range existing {
+		key, _ := rec.Fields[keyColumn].(string)
+		if _, dup := byKey[key]; dup || key == "" {
+			deletes = append(deletes, rec.ID)
+			continue
+		}
+		byKey[key] = rec
+	}
+
+	var creates, updates []airtableRecord
+	for _, e := range render.Events(ds) {
+		id := e.ID
+		if id == "" {
+			id = e.Start
+		}
+		values := map[string]any{
+			"key":     e.Person + "/" + id,
+			"group":   ds.Group,
+			"person":  e.Person,
+			"start":   e.Start,
+			"end":     e.End,
+			"all_day": e.AllDay,
+			"summary": e.Summary,
+			"kind":    string(e.Kind),
+		}
+		fields := make(map[string]any, len(columns))
+		for field, column := range columns {
+			fields[column] = values[field]
+		}
+		key := values["key"].(string)
+		rec, ok := byKey[key]
+		delete(byKey, key)
+		switch {
+		case !ok:
+			creates = append(creates, airtableRecord{Fields: fields})
+		case !sameFields(rec.Fields, fields):
+			updates = append(updates, airtableRecord{ID: rec.ID, Fields: fields})
+		default:
+			stats.unchanged++
+		}
+	}
+	for _, rec := range byKey {
+		deletes = append(deletes, rec.ID)
+	}
+	sort.Strings(deletes)
+
+	for i := 0; i < len(creates); i += airtableBatch {
+		if err := c.write(ctx, http.MethodPost, creates[i:min(i+airtableBatch, len(creates))]); err != nil {
+			return stats, err
+		}
+		stats.created += min(airtableBatch, len(creates)-i)
+	}
+	for i := 0; i < len(updates); i += airtableBatch {
+		if err := c.write(ctx, http.MethodPatch, updates[i:min(i+airtableBatch, len(updates))]); err != nil {
+			return stats, err
+		}
+		stats.updated += min(airtableBatch, len(updates)-i)
+	}
+	for i := 0; i < len(deletes); i += airtableBatch {
+		if err := c.delete(ctx, deletes[i:min(i+airtableBatch, len(deletes))]); err != nil {
+			return stats, err
+		}
+		stats.deleted += min(airtableBatch, len(deletes)-i)
+	}
+	return stats, nil
+}
+
+// sameFields reports whether the record's columns already hold the values
+// in fields. Airtable leaves out empty and unchecked cells and writes dates
+// in its own format, so those are compared by meaning.
+func sameFields(current, fields map[string]any) bool {
+	for column, want := range fields {
+		got, ok := current[column]
+		switch want := want.(type) {
+		case bool:
+			if b, _ := got.(bool); b != want {
+				return false
+			}
+		case string:
+			s, _ := got.(string)
+			if !ok && want == "" {
+				continue
+			}
+			if s != want && !sameTime(s, want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameTime reports whether a and b are the same instant, written as dates
+// (midnight UTC, as Airtable stores them) or timestamps.
+func sameTime(a, b string) bool {
+	ta, okA := parseAirtableTime(a)
+	tb, okB := parseAirtableTime(b)
+	return okA && okB && ta.Equal(tb)
+}
+
+func parseAirtableTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// airtableRecord is a row of the table.
+type airtableRecord struct {
+	ID     string         `json:"id,omitempty"`
+	Fields map[string]any `json:"fields"`
+}
+
+// airtableClient calls the Airtable Web API for one table.
+type airtableClient struct {
+	http  *http.Client
+	token string
+	table string
+	last  time.Time
+}
+
+// list returns the records matching filter, a formula, or all of them.
+func (c *airtableClient) list(ctx context.Context, filter string) ([]airtableRecord, error) {
+	var records []airtableRecord
+	offset := ""
+	for {
+		query := url.Values{"pageSize": {"100"}}
+		if filter != "" {
+			query.Set("filterByFormula", filter)
+		}
+		if offset != "" {
+			query.Set("offset", offset)
+		}
+		var page struct {
+			Records []airtableRecord `json:"records"`
+			Offset  string           `json:"offset"`
+		}
+		if err := c.do(ctx, http.MethodGet, c.table+"?"+query.Encode(), nil, &page); err != nil {
+			return nil, fmt.Errorf("unable to list Airtable records: %v", err)
+		}
+		records = append(records, page.Records...)
+		if page.Offset == "" {
+			return records, nil
+		}
+		offset = page.Offset
+	}
+}
+
+// write creates (POST) or updates (PATCH) records.
+func (c *airtableClient) write(ctx context.Context, method string, records []airtableRecord) error {
+	body, err := json.Marshal(struct {
+		Records  []airtableRecord `json:"records"`
+		Typecast bool             `json:"typecast"`
+	}{records, true})
+	if err != nil {
+		return err
+	}
+	if err := c.do(ctx, method, c.table, body, nil); err != nil {
+		return fmt.Errorf("unable to write Airtable records: %v", err)
+	}
+	return nil
+}
+
+func (c *airtableClient) delete(ctx context.Context, ids []string) error {
+	query := url.Values{"records[]": ids}
+	if err := c.do(ctx, http.MethodDelete, c.table+"?"+query.Encode(), nil, nil); err != nil {
+		return fmt.Errorf("unable to delete Airtable records: %v", err)
+	}
+	return nil
+}
+
+// do sends one request, paced to the API's rate limit, and decodes the
+// response into out if it's not nil. Rate limited requests are retried
+// after the 30 seconds Airtable asks for.
+func (c *airtableClient) do(ctx context.Context, method, target string, body []byte, out any) error {
+	for attempt := 0; ; attempt++ {
+		if wait := airtableInterval - time.Since(c.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		c.last = time.Now()
+
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < 2 {
+			logFor("airtable").Warn("rate limited, retrying in 30s")
+			select {
+			case <-time.After(30 * time.Second):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(data, out)
+	}
+}
+
+// validateAirtableFields checks that fields only maps known fields.
+func validateAirtableFields(fields map[string]string) error {
+	for field := range fields {
+		if _, ok := airtableFields[field]; !ok {
+			names := make([]string, 0, len(airtableFields))
+			for name := range airtableFields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown field '%s'; use one of %s", field, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// coverageAlerter posts to a webhook when a refresh finds a coming working
+// day whose coverage dropped below the threshold, e.g. because someone
+// booked time off. Each day is alerted once until it recovers.
+type coverageAlerter struct {
+	threshold percent
+	webhook   string
+	client    *http.Client
+	audit     *auditLog
+	// handovers, if set, plans the handovers of the days alerted on, whose
+	// invites are attached to the alert
+	handovers *handoverConfig
+
+	// alerted holds the days below the threshold at the last refresh; nil
+	// before the first, whose days are taken as known
+	alerted map[string]bool
+}
+
+// alertInvite is a handover invite attached to an alert.
+type alertInvite struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Summary     string `json:"summary"`
+	Content     string `json:"content"`
+}
+
+// alertDay is a day below the threshold, as sent to the webhook.
+type alertDay struct {
+	Date     string   `json:"date"`
+	Coverage float64  `json:"coverage"`
+	Out      []string `json:"out"`
+}
+
+// check compares the coverage of the days from today on with the last
+// refresh and posts the days that newly dropped below the threshold.
+func (a *coverageAlerter) check(ctx context.Context, ds *ooo.Dataset, loc *time.Location) error {
+	today := time.Now().In(loc).Format("2006-01-02")
+	below := make(map[string]bool)
+	var fresh []alertDay
+	var gaps []coverageDay
+	for _, d := range coverage(ds, loc) {
+		if d.day < today || d.coverage >= float64(a.threshold) {
+			continue
+		}
+		below[d.day] = true
+		if a.alerted != nil && !a.alerted[d.day] {
+			fresh = append(fresh, alertDay{Date: d.day, Coverage: d.coverage, Out: d.out})
+			gaps = append(gaps, d)
+		}
+	}
+	first := a.alerted == nil
+	a.alerted = below
+	if first {
+		if len(below) > 0 {
+			logFor("alerts").Info("coverage already below the threshold; alerting on new drops only", "group", ds.Group, "days", len(below))
+		}
+		return nil
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	var invites []alertInvite
+	if a.handovers != nil {
+		for _, h := range planHandovers(ds, loc, gaps, *a.handovers, time.Now()) {
+			var buf bytes.Buffer
+			if err := render.HandoverInvite(&buf, h); err != nil {
+				return err
+			}
+			invites = append(invites, alertInvite{Filename: handoverFileName(h), ContentType: "text/calendar; method=REQUEST", Summary: h.Summary(), Content: buf.String()})
+		}
+	}
+	err := a.post(ctx, ds.Group, fresh, invites)
+	host := a.webhook
+	if u, parseErr := url.Parse(a.webhook); parseErr == nil {
+		host = u.Host
+	}
+	a.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: "alert", Destination: host, Events: len(fresh), Error: errorString(err)})
+	if err != nil {
+		// Try again on the next refresh
+		for _, d := range fresh {
+			delete(a.alerted, d.Date)
+		}
+		return err
+	}
+	logFor("alerts").Info("coverage alert sent", "group", ds.Group, "days", len(fresh))
+	return nil
+}
+
+// post sends the days to the webhook as a Slack message, with the days and
+// handover invites alongside for other receivers.
+func (a *coverageAlerter) post(ctx context.Context, group string, days []alertDay, invites []alertInvite) error {
+	lines := []string{fmt.Sprintf("Coverage of %s dropped below %s:", group, a.threshold.String())}
+	for _, d := range days {
+		date, _ := time.Parse("2006-01-02", d.Date)
+		lines = append(lines, fmt.Sprintf("• %s: %.0f%% in, out: %s", date.Format("Mon Jan 2"), d.Coverage*100, strings.Join(d.Out, ", ")))
+	}
+	for _, invite := range invites {
+		lines = append(lines, "• "+invite.Summary)
+	}
+	err := postWebhook(ctx, a.client, a.webhook, struct {
+		Text      string        `json:"text"`
+		Group     string        `json:"group"`
+		Threshold float64       `json:"threshold"`
+		Days      []alertDay    `json:"days"`
+		Invites   []alertInvite `json:"invites,omitempty"`
+	}{strings.Join(lines, "\n"), group, float64(a.threshold), days, invites})
+	if err != nil {
+		return fmt.Errorf("unable to send alert: %v", err)
+	}
+	return nil
+}
+
+// postWebhook posts payload as JSON to webhook.
+func postWebhook(ctx context.Context, client *http.Client, webhook string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// archiveKeepAll is how long every archived snapshot is kept; older ones
+// are thinned to the last of each day until the retention ends.
+const archiveKeepAll = 7 * 24 * time.Hour
+
+// archiveTimeFormat names the archived snapshots by fetch time.
+const archiveTimeFormat = "20060102T150405Z"
+
+// archiveDir returns the directory of the group's archived snapshots.
+func archiveDir(group string) (string, error) {
+	data, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "archive", unsafeFileChars.ReplaceAllString(group, "_")), nil
+}
+
+// archivedSnapshot is a snapshot in the archive.
+type archivedSnapshot struct {
+	path      string
+	fetchedAt time.Time
+}
+
+// archiveDataset adds ds to its group's archive, gzipped, unless it's
+// cached or partial data, and prunes the snapshots past retention (0 keeps
+// them all). Snapshots are never rewritten.
+func archiveDataset(ds *ooo.Dataset, retention time.Duration) error {
+	if ds.Stale || ds.Partial {
+		return nil
+	}
+	dir, err := archiveDir(ds.Group)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create archive directory: %v", err)
+	}
+
+	path := filepath.Join(dir, ds.FetchedAt.UTC().Format(archiveTimeFormat)+".json.gz")
+	// Snapshots hold other people's events
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to archive snapshot: %v", err)
+	}
+	zw := gzip.NewWriter(f)
+	err = json.NewEncoder(zw).Encode(ds)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("unable to archive snapshot: %v", err)
+	}
+	return pruneArchive(dir, time.Now(), retention)
+}
+
+// listArchive returns the snapshots in dir, oldest first.
+func listArchive(dir string) ([]archivedSnapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read archive: %v", err)
+	}
+	var snapshots []archivedSnapshot
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json.gz")
+		if !ok {
+			continue
+		}
+		fetchedAt, err := time.Parse(archiveTimeFormat, name)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, archivedSnapshot{path: filepath.Join(dir, e.Name()), fetchedAt: fetchedAt})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].fetchedAt.Before(snapshots[j].fetchedAt) })
+	return snapshots, nil
+}
+
+// pruneArchive removes the snapshots in dir older than retention, and of
+// those older than archiveKeepAll all but the last of each day.
+func pruneArchive(dir string, now time.Time, retention time.Duration) error {
+	snapshots, err := listArchive(dir)
+	if err != nil {
+		return err
+	}
+	lastOfDay := make(map[string]time.Time)
+	for _, snap := range snapshots {
+		lastOfDay[snap.fetchedAt.Format("2006-01-02")] = snap.fetchedAt
+	}
+	for _, snap := range snapshots {
+		age := now.Sub(snap.fetchedAt)
+		expired := retention > 0 && age > retention
+		thinned := age > archiveKeepAll && !lastOfDay[snap.fetchedAt.Format("2006-01-02")].Equal(snap.fetchedAt)
+		if !expired && !thinned {
+			continue
+		}
+		if err := os.Remove(snap.path); err != nil {
+			return fmt.Errorf("unable to prune archive: %v", err)
+		}
+	}
+	return nil
+}
+
+// readArchived reads an archived snapshot, with its days placed in loc.
+func readArchived(path string, loc *time.Location) (*ooo.Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read archived snapshot: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archived snapshot %s: %v", path, err)
+	}
+	var ds ooo.Dataset
+	if err := json.NewDecoder(zr).Decode(&ds); err != nil {
+		return nil, fmt.Errorf("invalid archived snapshot %s: %v", path, err)
+	}
+	ds.Location = loc
+	return &ds, nil
+}
+
+func historyCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	asOf := fs.String("as-of", "", "Show the plan as it was on this date, like 2025-06-01, from the last snapshot archived by then")
+	format := fs.String("format", "grid", "Output format: "+strings.Join(render.Names(), ", "))
+
+	return func(ctx context.Context, s *session, args []string) error {
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+		renderer, ok := render.Lookup(*format)
+		if !ok {
+			return usageErrorf("unknown format '%s'", *format)
+		}
+		groupEmail := resolveGroup(group, s.cfg.GroupAliases)
+		dir, err := archiveDir(groupEmail)
+		if err != nil {
+			return err
+		}
+		snapshots, err := listArchive(dir)
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			return fmt.Errorf("no archived snapshots of %s; ooo-view serve archives one on every refresh", groupEmail)
+		}
+
+		// Without --as-of, list what there is
+		if *asOf == "" {
+			for _, snap := range snapshots {
+				fmt.Println(snap.fetchedAt.In(loc).Format("Mon Jan 2 2006 15:04"))
+			}
+			return nil
+		}
+
+		day, err := time.ParseInLocation("2006-01-02", *asOf, loc)
+		if err != nil {
+			return usageErrorf("invalid --as-of '%s', expected a date like 2025-06-01", *asOf)
+		}
+		// The plan on a day is the last one fetched before the day was over
+		end := day.AddDate(0, 0, 1)
+		i := sort.Search(len(snapshots), func(i int) bool { return !snapshots[i].fetchedAt.Before(end) })
+		if i == 0 {
+			return fmt.Errorf("the archive of %s starts %s, after %s", groupEmail, snapshots[0].fetchedAt.In(loc).Format("Mon Jan 2 2006"), *asOf)
+		}
+		ds, err := readArchived(snapshots[i-1].path, loc)
+		if err != nil {
+			return err
+		}
+		s.audit.Record(auditRecord{Event: "calendar_read", Group: groupEmail, Source: "archive"})
+
+		if *format == "grid" {
+			fmt.Printf("\nAs of %s, fetched %s\n", day.Format("Mon Jan 2 2006"), ds.FetchedAt.In(loc).Format("Mon Jan 2 15:04"))
+		}
+		return renderer.Render(os.Stdout, ds)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// auditRecord is one line of the audit log.
+type auditRecord struct {
+	Time time.Time `json:"time"`
+	// User is the local account running ooo-view, Identity the Google
+	// account it signed in as, if known
+	User     string `json:"user"`
+	Identity string `json:"identity,omitempty"`
+	Command  string `json:"command"`
+	// Event is api_request, calendar_read, export, ingest, serve or token
+	Event     string `json:"event"`
+	Operation string `json:"operation,omitempty"`
+	Calendar  string `json:"calendar,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Format    string `json:"format,omitempty"`
+	// Destination is where an export went: a file, stdout, or the client
+	// of a served request
+	Destination string `json:"destination,omitempty"`
+	Events      int    `json:"events,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// auditLog appends a JSON line for every API request, calendar read and
+// export to a file given with --audit-log. A nil *auditLog records nothing.
+type auditLog struct {
+	mu       sync.Mutex
+	f        *os.File
+	user     string
+	command  string
+	identity string
+}
+
+// openAuditLog opens the audit log at path for appending, creating it
+// readable only by the user.
+func openAuditLog(path, command string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %v", err)
+	}
+	username := ""
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	return &auditLog{f: f, user: username, command: command}, nil
+}
+
+// SetIdentity sets the Google account recorded from now on.
+func (a *auditLog) SetIdentity(identity string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.identity = identity
+}
+
+// Record appends rec, filling in the time, user, identity and command.
+func (a *auditLog) Record(rec auditRecord) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec.Time = time.Now().UTC()
+	rec.User = a.user
+	rec.Identity = a.identity
+	rec.Command = a.command
+	line, err := json.Marshal(rec)
+	if err == nil {
+		_, err = a.f.Write(append(line, '\n'))
+	}
+	if err != nil {
+		logFor("audit").Warn("could not write audit record", "event", rec.Event, "error", err)
+	}
+}
+
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.f.Close()
+}
+
+// errorString returns err's message, or "" for nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// accountEmail returns the Google account signed in, the ID of its primary
+// calendar, or "" if it can't be read, e.g. with a free/busy-only token.
+func accountEmail(ctx context.Context, srv *calendar.Service) string {
+	cal, err := srv.Calendars.Get("primary").Context(ctx).Do()
+	if err != nil {
+		logFor("audit").Debug("could not look up the signed-in account", "error", err)
+		return ""
+	}
+	return cal.Id
+}
+
+// sourceName names where absences are read from: google or a plugin.
+func sourceName(cfg Config) string {
+	if cfg.Provider == "" {
+		return "google"
+	}
+	return cfg.Provider
+}
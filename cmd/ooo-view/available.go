@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+func availableCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	on := fs.String("on", "", "Date to check, like 2025-10-15 (default: today)")
+	format := fs.String("format", "text", "Output format: text or json")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) == 0 {
+			return usageErrorf("expected the email addresses of one or more people")
+		}
+		if *format != "text" && *format != "json" {
+			return usageErrorf("invalid --format '%s'; use text or json", *format)
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+		now := time.Now().In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		date := today
+		if *on != "" {
+			if date, err = time.ParseInLocation("2006-01-02", *on, loc); err != nil {
+				return usageErrorf("invalid --on '%s', expected a date like 2025-10-15", *on)
+			}
+		}
+		var people []string
+		for _, person := range args {
+			person = s.cfg.Identities.Canonical(strings.ToLower(person))
+			if !slices.Contains(people, person) {
+				people = append(people, person)
+			}
+		}
+
+		// The nearest date everyone is in is searched for within the
+		// window around the date
+		s.at = date
+		timeMin, timeMax := s.window()
+		provider, err := s.source(ctx)
+		if err != nil {
+			return err
+		}
+		ds, err := fetchPeopleDataset(ctx, provider, "", s.cfg.Identities.Expand(people), timeMin, timeMax, loc, s.cfg, s.timings, s.audit)
+		var unavailableErr *ooo.UnavailableError
+		if errors.As(err, &unavailableErr) {
+			logFor("fetch").Warn("some calendars are unavailable", "calendars", len(unavailableErr.People), "deadline", unavailableErr.Deadline)
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		if unavailableErr != nil {
+			ds.Unavailable = unavailableErr.People
+		}
+		ds.MergeIdentities(s.cfg.Identities)
+		ds.FilterByMinDuration(s.cfg.MinDuration)
+		ds.FilterByResponseStatus(splitList(s.cfg.ResponseStatus))
+		ds.FilterByKinds(s.kinds())
+		if s.cfg.FetchWorkWeeks {
+			ds.WorkWeeks = ooo.FetchWorkWeeks(ctx, provider, ds.Members)
+		}
+		s.applyWorkWeeks(ds)
+		if s.cfg.DayBoundary == dayBoundaryPerPerson {
+			ds.TimeZones = ooo.FetchTimeZones(ctx, provider, ds.Members)
+		}
+		unknown := make(map[string]bool)
+		for _, person := range ds.WithoutData() {
+			unknown[person] = true
+		}
+
+		daysOut := ooo.AbsentDays(ds, loc)
+		available := func(person string, day time.Time) string {
+			switch {
+			case unknown[person]:
+				return "unknown"
+			case !ds.WorkWeek(person).Works(day.Weekday()) || daysOut[person][day.Format("2006-01-02")]:
+				return "no"
+			default:
+				return "yes"
+			}
+		}
+		everyone := func(day time.Time) bool {
+			for _, person := range people {
+				if available(person, day) != "yes" {
+					return false
+				}
+			}
+			return true
+		}
+
+		// Search outwards from the date, earlier dates first on ties,
+		// leaving out the past
+		nearest := ""
+		first := today
+		if timeMin.After(first) {
+			first = timeMin.In(loc)
+		}
+		for i := 0; nearest == ""; i++ {
+			later, earlier := date.AddDate(0, 0, i), date.AddDate(0, 0, -i)
+			if later.After(timeMax) && earlier.Before(first) {
+				break
+			}
+			if !earlier.Before(first) && everyone(earlier) {
+				nearest = earlier.Format("2006-01-02")
+			} else if !later.Before(first) && later.Before(timeMax) && everyone(later) {
+				nearest = later.Format("2006-01-02")
+			}
+		}
+
+		if *format == "json" {
+			result := struct {
+				Date      string            `json:"date"`
+				Available map[string]string `json:"available"`
+				Everyone  bool              `json:"everyone"`
+				Nearest   string            `json:"nearest,omitempty"`
+			}{date.Format("2006-01-02"), make(map[string]string), everyone(date), nearest}
+			for _, person := range people {
+				result.Available[person] = available(person, date)
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(result)
+		}
+		for _, person := range people {
+			fmt.Printf("%s\t%s\n", person, available(person, date))
+		}
+		if nearest == "" {
+			nearest = "none"
+		}
+		fmt.Printf("nearest\t%s\n", nearest)
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+func changesCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	since := fs.String("since", "7d", "How far back to list changes, e.g. 7d, 2w or 36h")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) > 1 {
+			return usageErrorf("expected at most one group email or alias")
+		}
+		age, err := ooo.ParseDuration(*since)
+		if err != nil {
+			return usageErrorf("invalid --since: %v", err)
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+
+		store, err := openEventStore(s.cfg.CacheTTL, false)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		// Changes are recorded per person, so a group is the members it had
+		// when last fetched
+		var people []string
+		if len(args) == 1 {
+			group := resolveGroup(args[0], s.cfg.GroupAliases)
+			if people, err = store.GroupMembers(group); err != nil {
+				return err
+			}
+			if people == nil {
+				return fmt.Errorf("%s hasn't been fetched yet; run ooo-view view %s first", group, args[0])
+			}
+		}
+		changes, err := store.Changes(time.Now().Add(-age), people)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			fmt.Printf("No changes to absences in the last %s.\n", *since)
+			return nil
+		}
+
+		for _, c := range changes {
+			line := fmt.Sprintf("%s  %-30s %-9s %s", render.DateTime(c.DetectedAt.In(loc)), c.Person, c.Kind, changeDates(c.Person, c.Event, loc))
+			if c.Previous != nil {
+				line += " (was " + changeDates(c.Person, c.Previous, loc) + ")"
+			}
+			if !s.cfg.RedactSummaries && c.Event.Summary != "" {
+				line += "  " + c.Event.Summary
+			}
+			fmt.Println(line)
+		}
+		return nil
+	}
+}
+
+// changeDates describes when the absence of a changed event is.
+func changeDates(person string, event *calendar.Event, loc *time.Location) string {
+	a, err := ooo.FromEvent(person, event, loc)
+	if err != nil {
+		return "unknown dates"
+	}
+	return absenceDates(a, loc)
+}
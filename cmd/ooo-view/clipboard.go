@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/klaasmeinke/ooo-view/pkg/auth"
+)
+
+// The --copy formats: the output as is, or in a Markdown code block that
+// keeps the grid's columns aligned when pasted into Slack or an issue.
+const (
+	copyPlain    = "plain"
+	copyMarkdown = "markdown"
+)
+
+// copyFlag is --copy, which may be given without a value for plain, or as
+// --copy=markdown.
+type copyFlag string
+
+func (c *copyFlag) String() string { return string(*c) }
+
+func (c *copyFlag) Set(s string) error {
+	switch s {
+	case "true", copyPlain:
+		*c = copyPlain
+	case "false":
+		*c = ""
+	case copyMarkdown:
+		*c = copyMarkdown
+	default:
+		return fmt.Errorf("use --copy for plain text or --copy=markdown")
+	}
+	return nil
+}
+
+func (c *copyFlag) IsBoolFlag() bool { return true }
+
+// addCopyFlag registers --copy.
+func addCopyFlag(fs *flag.FlagSet) *copyFlag {
+	c := new(copyFlag)
+	fs.Var(c, "copy", "Also copy the output to the clipboard; --copy=markdown puts it in a Markdown code block, e.g. for Slack")
+	return c
+}
+
+// output returns where the command writes: stdout, and with --copy also the
+// returned buffer for toClipboard.
+func (c *copyFlag) output() (io.Writer, *bytes.Buffer) {
+	if *c == "" {
+		return os.Stdout, nil
+	}
+	var buf bytes.Buffer
+	return io.MultiWriter(os.Stdout, &buf), &buf
+}
+
+// toClipboard puts what was written to buf onto the clipboard, formatted
+// as asked. Without --copy, buf is nil and it does nothing.
+func (c *copyFlag) toClipboard(buf *bytes.Buffer) error {
+	if buf == nil {
+		return nil
+	}
+	text := strings.Trim(buf.String(), "\n")
+	if *c == copyMarkdown {
+		text = "```\n" + text + "\n```"
+	}
+	if err := writeClipboard(text + "\n"); err != nil {
+		return err
+	}
+	logFor("cli").Info("copied the output to the clipboard")
+	return nil
+}
+
+// writeClipboard puts text onto the system clipboard with the platform's
+// tool: pbcopy on macOS, PowerShell on Windows and under WSL, and
+// wl-copy, xclip or xsel elsewhere.
+func writeClipboard(text string) error {
+	// PowerShell reads stdin in the console's code page unless told
+	// otherwise
+	powershell := []string{"-NoProfile", "-NonInteractive", "-Command", "[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())"}
+	var candidates [][]string
+	switch {
+	case runtime.GOOS == "darwin":
+		candidates = append(candidates, []string{"pbcopy"})
+	case runtime.GOOS == "windows":
+		candidates = append(candidates, append([]string{"powershell"}, powershell...))
+	case runtime.GOOS == "linux" && auth.IsWSL():
+		candidates = append(candidates, append([]string{"powershell.exe"}, powershell...))
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append(candidates, []string{"wl-copy"})
+	}
+	candidates = append(candidates,
+		[]string{"xclip", "-selection", "clipboard"},
+		[]string{"xsel", "--clipboard", "--input"},
+	)
+
+	var errs []string
+	for _, args := range candidates {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v: %s", args[0], err, strings.TrimSpace(stderr.String())))
+			continue
+		}
+		logFor("cli").Debug("copied to the clipboard", "command", args[0])
+		return nil
+	}
+	return fmt.Errorf("unable to copy to the clipboard; install wl-copy, xclip or xsel (%s)", strings.Join(errs, "; "))
+}
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/klaasmeinke/ooo-view/pkg/auth"
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// command is one ooo-view subcommand.
+type command struct {
+	name    string
+	args    string // positional arguments shown in the usage line
+	summary string
+
+	// setup registers the command's flags on fs and returns the function
+	// that runs the command once they are parsed.
+	setup func(fs *flag.FlagSet, cfg *Config) runFunc
+
+	// remember makes a successful run the one a bare `ooo-view` repeats
+	remember bool
+}
+
+type runFunc func(ctx context.Context, s *session, args []string) error
+
+// defaultCommand runs when the first argument isn't a command name, so that
+// `ooo-view team@example.com` keeps working.
+const defaultCommand = "view"
+
+var commands = []*command{
+	{name: "view", args: "<group-email|alias>", summary: "Show a weekly calendar of OOO events", setup: viewCommand, remember: true},
+	{name: "today", args: "<group-email|alias>", summary: "List who is out of office today", setup: todayCommand, remember: true},
+	{name: "day", args: "<yyyy-mm-dd> <group-email|alias>", summary: "List who is out on a day, with their absences and the coverage", setup: dayCommand},
+	{name: "available", args: "<person-email>...", summary: "Check whether people are in on a date, and the nearest date they all are", setup: availableCommand},
+	{name: "meetings", args: "--calendar <id> <group-email|alias>", summary: "List upcoming meetings whose organizer or required attendees are out", setup: meetingsCommand},
+	{name: "check-request", args: "<person-email> <from>..<to> [group-email|alias]", summary: "Show how an absence someone asks for would leave the coverage, and who else is out", setup: checkRequestCommand},
+	{name: "export", args: "<group-email|alias> | site [groups]", summary: "Export OOO events as JSON, CSV or iCalendar, or a static site", setup: exportCommand, remember: true},
+	{name: "serve", args: "<group-email|alias> | tokens create <name>|list|revoke <id>", summary: "Serve the calendar over HTTP and keep it up to date", setup: serveCommand},
+	{name: "proxy", args: "", summary: "Fetch groups for other ooo-view users pointed at it with --remote", setup: proxyCommand},
+	{name: "airtable", args: "<group-email|alias>", summary: "Mirror OOO events into an Airtable table", setup: airtableCommand},
+	{name: "sheets", args: "<group-email|alias>", summary: "Keep a Google Sheet up to date with OOO events", setup: sheetsCommand},
+	{name: "render", args: "--from-json <file|-> [group-email|alias]", summary: "Render a JSON export again in any format, without fetching", setup: renderCommand},
+	{name: "snapshot", args: "<group-email|alias>", summary: "Save the fetched dataset to a JSON file", setup: snapshotCommand},
+	{name: "history", args: "<group-email|alias>", summary: "Show the plan as it was on a past date, from the snapshots serve archives", setup: historyCommand},
+	{name: "report", args: "[payroll] <group-email|alias>", summary: "Summarize days out per person and per week", setup: reportCommand, remember: true},
+	{name: "setup", args: "", summary: "Interactively set up credentials and defaults", setup: setupCommand},
+	{name: "access-check", args: "<group-email|alias>", summary: "Check whose calendars you can read in full or only as free/busy", setup: accessCheckCommand},
+	{name: "doctor", args: "[group-email|alias]", summary: "Check the setup and print how to fix problems", setup: doctorCommand},
+	{name: "auth", args: "login|logout|status", summary: "Manage the stored Google credentials", setup: authCommand},
+	{name: "version", args: "", summary: "Print version and build information", setup: versionCommand},
+	{name: "self-update", args: "", summary: "Download and install the latest release", setup: selfUpdateCommand},
+	{name: "paths", args: "", summary: "Show where config, cache and snapshots are stored", setup: pathsCommand},
+	{name: "groups", args: "[list]", summary: "Pick a default group from yours, or list the aliases", setup: groupsCommand},
+	{name: "db", args: "vacuum|stats", summary: "Maintain the local event store", setup: dbCommand},
+	{name: "changes", args: "[group-email|alias]", summary: "List absences added, moved or cancelled since recent fetches", setup: changesCommand},
+	{name: "providers", args: "", summary: "List the sources of absences, including plugins", setup: providersCommand},
+	{name: "schema", args: "", summary: "Print the JSON Schema of the JSON export", setup: schemaCommand},
+	{name: "validate", args: "<file.json>", summary: "Check a JSON export against the schema", setup: validateCommand},
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// usageError reports wrong command arguments. main prints the command's
+// usage after it.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, a ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+func defaultConfig() Config {
+	// Get system's local timezone
+	localTZ, err := time.LoadLocation("Local")
+	if err != nil {
+		exitWithError(fmt.Errorf("unable to get local timezone: %v", err))
+	}
+
+	return Config{
+		WeeksAhead:     8,
+		MinDuration:    24 * time.Hour,
+		TimeZone:       localTZ.String(), // Use system's local timezone
+		Concurrency:    10,
+		RequestTimeout: 30 * time.Second,
+		CacheTTL:       10 * time.Minute,
+		HTTPTimeout:    60 * time.Second,
+		KeepAlive:      true,
+		HTTP2:          true,
+		LogFormat:      "text",
+		ResponseStatus: "accepted,tentative,needsAction",
+		Batch:          true,
+		DayBoundary:    dayBoundaryShared,
+		Reconcile:      string(ooo.ReconcileFlag),
+		DateStyle:      string(render.DateStyleUS),
+		Clock:          clock24h,
+	}
+}
+
+// parseCommandFlags builds the configuration for cmd in layers, each
+// overriding the one before: built-in defaults, the config file, OOO_*
+// environment variables and command-line flags. It also returns the
+// command's positional arguments.
+func parseCommandFlags(cmd *command, args []string) (Config, runFunc, *flag.FlagSet, []string) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet(serviceName+" "+cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		printCommandUsage(fs.Output(), cmd, fs)
+	}
+	run := cmd.setup(fs, &cfg)
+	fs.BoolVar(&cfg.Quiet, "quiet", cfg.Quiet, "Only print the command's output and errors")
+	fs.BoolVar(&cfg.Verbose, "verbose", cfg.Verbose, "Show per-person fetch progress and API details")
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "Format of log records on stderr: text or json")
+	fs.StringVar(&cfg.DateStyle, "date-style", cfg.DateStyle, "How dates are written in week headers and absences: us (Sep 1 - Sep 7), eu (1 Sep - 7 Sep) or iso (2025-09-01 – 2025-09-07)")
+	fs.StringVar(&cfg.Clock, "clock", cfg.Clock, "Write times as 24h (15:04) or 12h (3:04 PM)")
+	fs.BoolVar(&cfg.ShowTimeZones, "show-time-zones", cfg.ShowTimeZones, "Follow times with their time zone's abbreviation, like 15:04 CEST")
+	fs.StringVar(&cfg.AuditLog, "audit-log", cfg.AuditLog, "Append a JSON line for every API request, calendar read and export to this file")
+	configPath := fs.String("config", "", "Path to the config file (default: ~/.config/ooo-view/config.yaml)")
+	if cmd.remember {
+		fs.BoolVar(&cfg.NoRemember, "no-remember", cfg.NoRemember, "Don't save this query for a bare 'ooo-view' to repeat")
+	}
+	fs.Parse(args)
+	// Accept flags after positional arguments too, e.g. `auth logout --secret`
+	var positional []string
+	for fs.NArg() > 0 {
+		positional = append(positional, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+
+	// Apply OOO_* environment variables, then the config file, to every
+	// setting whose flag wasn't given
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	if err := applyEnv(fs, setFlags); err != nil {
+		exitWithError(err)
+	}
+	path := *configPath
+	if path == "" {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			exitWithError(err)
+		}
+	}
+	cfg, err := loadConfig(cfg, setFlags, path, *configPath != "")
+	if err != nil {
+		exitWithError(err)
+	}
+	// The formats are global, so they're set once rather than on every
+	// reload of the config file
+	dateStyle, _ := render.ParseDateStyle(cfg.DateStyle)
+	render.SetFormats(render.Formats{Dates: dateStyle, Clock12h: cfg.Clock == clock12h, TimeZones: cfg.ShowTimeZones})
+
+	return cfg, run, fs, positional
+}
+
+// loadConfig applies the config file at path to cfg, which holds the
+// settings of the flags and environment, and checks the result. A missing
+// file is only an error if explicit. The config can read the file again
+// with reload.
+func loadConfig(cfg Config, setFlags map[string]bool, path string, explicit bool) (Config, error) {
+	// Kept to read the file again on top of
+	flagCfg := cfg
+	fileCfg, err := readConfigFile(path, explicit)
+	if err != nil {
+		return Config{}, err
+	}
+	fileCfg.apply(&cfg, setFlags)
+	cfg.ConfigPath = path
+
+	if cfg.Concurrency < 1 {
+		return Config{}, fmt.Errorf("--concurrency must be at least 1")
+	}
+	for _, status := range splitList(cfg.ResponseStatus) {
+		if !slices.Contains(ooo.ResponseStatuses, status) {
+			return Config{}, fmt.Errorf("invalid --response-status '%s'; use one of %s", status, strings.Join(ooo.ResponseStatuses, ", "))
+		}
+	}
+	if cfg.FetchDeadline < 0 {
+		return Config{}, fmt.Errorf("--fetch-deadline can't be negative")
+	}
+	if cfg.TokenMaxIdleDays < 0 {
+		return Config{}, fmt.Errorf("--token-max-idle-days can't be negative")
+	}
+	for _, name := range splitList(cfg.Types) {
+		if _, err := ooo.ParseKind(name); err != nil {
+			return Config{}, fmt.Errorf("invalid --types: %v", err)
+		}
+	}
+	if _, err := ooo.ParseReconciliation(cfg.Reconcile); err != nil {
+		return Config{}, fmt.Errorf("invalid --reconcile: %v", err)
+	}
+	if setFlags["weeks"] && setFlags["range"] {
+		return Config{}, fmt.Errorf("--weeks and --range can't be combined")
+	}
+	if cfg.Range != "" {
+		if _, _, err := rangeWindow(cfg.Range, cfg.Ranges, time.Now()); err != nil {
+			return Config{}, fmt.Errorf("invalid --range: %v", err)
+		}
+	}
+	if cfg.DayBoundary != dayBoundaryShared && cfg.DayBoundary != dayBoundaryPerPerson {
+		return Config{}, fmt.Errorf("--day-boundary must be %s or %s", dayBoundaryShared, dayBoundaryPerPerson)
+	}
+	rosterPath, explicitRoster := cfg.RosterPath, cfg.RosterPath != ""
+	if rosterPath == "" {
+		if rosterPath, err = defaultRosterPath(); err != nil {
+			return Config{}, err
+		}
+	}
+	if cfg.Roster, err = readRoster(rosterPath, explicitRoster, cfg.Identities); err != nil {
+		return Config{}, err
+	}
+	if cfg.Manager != "" {
+		cfg.Manager = cfg.Identities.Canonical(strings.ToLower(strings.TrimSpace(cfg.Manager)))
+		// Without a roster, reporting lines are read from the Directory
+		if cfg.Roster == nil {
+			cfg.DirectoryAccess = true
+		}
+	} else if cfg.DirectReports {
+		return Config{}, fmt.Errorf("--direct-reports needs --manager")
+	}
+	switch cfg.GroupBy {
+	case "":
+	case groupByLocation:
+		// The offices are read from the Directory
+		cfg.DirectoryAccess = true
+	case groupByTeam, groupByManager:
+		if cfg.Roster == nil {
+			return Config{}, fmt.Errorf("--group-by %s needs a roster; see --roster", cfg.GroupBy)
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid --group-by '%s'; use %s, %s or %s", cfg.GroupBy, groupByLocation, groupByTeam, groupByManager)
+	}
+	if _, err := render.ParseDateStyle(cfg.DateStyle); err != nil {
+		return Config{}, fmt.Errorf("invalid --date-style: %v", err)
+	}
+	if cfg.Clock != clock24h && cfg.Clock != clock12h {
+		return Config{}, fmt.Errorf("--clock must be %s or %s", clock24h, clock12h)
+	}
+	if cfg.Quiet && cfg.Verbose {
+		return Config{}, fmt.Errorf("--quiet and --verbose can't be combined")
+	}
+	if cfg.Remote != "" {
+		if u, err := url.Parse(cfg.Remote); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return Config{}, fmt.Errorf("--remote must be an http or https URL")
+		}
+		if cfg.Provider != "" && cfg.Provider != "google" {
+			return Config{}, fmt.Errorf("--remote and --provider can't be combined; the proxy chooses the provider")
+		}
+	}
+
+	// CALENDAR_TIMEZONE is the older name of OOO_TIMEZONE
+	if tz := os.Getenv("CALENDAR_TIMEZONE"); tz != "" && !setFlags["timezone"] {
+		cfg.TimeZone = tz
+	}
+	if group := os.Getenv("OOO_GROUP"); group != "" {
+		cfg.DefaultGroup = group
+	}
+
+	cfg.reload = func() (Config, error) {
+		return loadConfig(flagCfg, setFlags, path, explicit)
+	}
+	return cfg, nil
+}
+
+// envName is the environment variable for a flag, e.g. OOO_MIN_DURATION for
+// --min-duration.
+func envName(flagName string) string {
+	return "OOO_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets every flag that wasn't given on the command line from its
+// environment variable, and records it in setFlags so the config file
+// doesn't override it.
+func applyEnv(fs *flag.FlagSet, setFlags map[string]bool) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || setFlags[f.Name] {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || value == "" {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, envName(f.Name), setErr)
+			return
+		}
+		setFlags[f.Name] = true
+	})
+	return err
+}
+
+// addWindowFlags registers the flags that choose the range of weeks shown.
+func addWindowFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.IntVar(&cfg.WeeksAhead, "weeks", cfg.WeeksAhead, "Number of weeks ahead to check")
+	fs.StringVar(&cfg.Range, "range", cfg.Range, "Named window to check instead of --weeks: this-week, next-week, this-month, next-month, this-quarter, next-quarter, this-year, next-year, one from ranges in the config file, or dates like 2025-07-01..2025-09-30")
+}
+
+// addFetchFlags registers the flags of every command that fetches events.
+func addFetchFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.Var((*durationFlag)(&cfg.MinDuration), "min-duration", "Minimum duration of out-of-office events to show (e.g., 4h, 1d, 3d, 1w); all-day events count 24h per day")
+	fs.Var((*daysFlag)(&cfg.MinDuration), "min-days", "Minimum number of days of out-of-office events to show, like --min-duration")
+	fs.StringVar(&cfg.TimeZone, "timezone", cfg.TimeZone, "Time zone for calendar display")
+	fs.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "Maximum number of calendars fetched in parallel")
+	fs.DurationVar(&cfg.RequestTimeout, "request-timeout", cfg.RequestTimeout, "Timeout for each calendar API request")
+	fs.DurationVar(&cfg.FetchDeadline, "fetch-deadline", cfg.FetchDeadline, "Give up on the calendars not fetched within this time, or that time out or fail transiently, and show them as unavailable (0 waits for all)")
+	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", cfg.CacheTTL, "How long fetched events are reused from the local cache")
+	fs.BoolVar(&cfg.NoCache, "no-cache", cfg.NoCache, "Don't read or write the local event cache")
+	fs.BoolVar(&cfg.Refresh, "refresh", cfg.Refresh, "Ignore cached events but update the cache with fresh results")
+	fs.BoolVar(&cfg.Offline, "offline", cfg.Offline, "Show the last cached data without contacting the API")
+	fs.BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "Print the calendars and API requests a fetch would make instead of fetching")
+	fs.BoolVar(&cfg.NoProgress, "no-progress", cfg.NoProgress, "Don't show fetch progress on stderr")
+	fs.StringVar(&cfg.Provider, "provider", cfg.Provider, "Source of absences: google, or NAME for the ooo-view-provider-NAME plugin on the PATH; several, comma-separated, are combined")
+	fs.StringVar(&cfg.Reconcile, "reconcile", cfg.Reconcile, "What to do with an absence combined providers report with different dates: flag the days they disagree on, keep the longest, or prefer:NAME to keep that provider's")
+	fs.StringVar(&cfg.ResponseStatus, "response-status", cfg.ResponseStatus, "Comma-separated responses (accepted, tentative, needsAction, declined) of events a person is invited to that count as absences")
+	fs.StringVar(&cfg.Types, "types", cfg.Types, "Comma-separated types of absences to show: ooo for out-of-office events, busy for blocks inferred from free/busy (default: all). Holidays come from --holiday-calendars instead")
+	fs.BoolVar(&cfg.NoFreebusyFallback, "no-freebusy-fallback", cfg.NoFreebusyFallback, "Show the calendars whose events can't be read as without data, instead of with their busy blocks")
+	fs.BoolVar(&cfg.RedactSummaries, "redact-summaries", cfg.RedactSummaries, "Leave event titles out of every output, showing only OOO")
+	fs.Var(&cfg.Assume, "assume", "Add a hypothetical absence, like bob@example.com:2025-08-04..2025-08-15, to explore a scenario; repeatable, and bob@ is enough for a member")
+	fs.StringVar(&cfg.HolidayCalendars, "holiday-calendars", cfg.HolidayCalendars, "Comma-separated IDs of public holiday calendars, e.g. en.dutch#holiday@group.v.calendar.google.com, to show holidays and flag the bridge days between them and weekends")
+	fs.StringVar(&cfg.RosterPath, "roster", cfg.RosterPath, "YAML file of people with their team, role and manager, for --group-by team or manager and groups like team:NAME or reports:EMAIL (default: roster.yaml next to the config file)")
+	fs.StringVar(&cfg.Manager, "manager", cfg.Manager, "Show only the people reporting to this manager, directly or through other managers, from the roster or else the Directory; with no group, everyone reporting to them in the roster")
+	fs.BoolVar(&cfg.DirectReports, "direct-reports", cfg.DirectReports, "With --manager, show only the manager's direct reports")
+	fs.StringVar(&cfg.Milestones, "milestones", cfg.Milestones, "YAML file of milestones, like releases and code freezes, to overlay with the key people out on them")
+	fs.BoolVar(&cfg.FetchWorkWeeks, "fetch-work-weeks", cfg.FetchWorkWeeks, "Ask the provider which days each member works, to shade the others (provider plugins only)")
+	fs.StringVar(&cfg.DayBoundary, "day-boundary", cfg.DayBoundary, "Bucket absences into days in --timezone for everyone (shared), or in each member's own time zone from their Calendar settings (per-person)")
+	fs.StringVar(&cfg.SecondaryCalendars, "secondary-calendars", cfg.SecondaryCalendars, "Comma-separated names of calendars in your calendar list, e.g. Absences, whose events count as absences of their attendees")
+	fs.StringVar(&cfg.Remote, "remote", cfg.Remote, "Fetch from the ooo-view proxy at this URL (e.g., https://ooo.example.com) instead of signing in to Google")
+	fs.StringVar(&cfg.RemoteToken, "remote-token", cfg.RemoteToken, "Token the proxy given with --remote asks for; best set as OOO_REMOTE_TOKEN")
+	addHTTPFlags(fs, cfg)
+	addAuthFlags(fs, cfg)
+	fs.BoolVar(&cfg.Timings, "timings", cfg.Timings, "Print how long each phase of the run took")
+	fs.StringVar(&cfg.PprofAddr, "pprof", cfg.PprofAddr, "Serve pprof profiles on this address while running (e.g., :6060)")
+}
+
+// addHTTPFlags registers the flags that tune the connection to Google.
+func addHTTPFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.DurationVar(&cfg.HTTPTimeout, "http-timeout", cfg.HTTPTimeout, "Timeout for a single HTTP request to Google")
+	fs.BoolVar(&cfg.KeepAlive, "keep-alive", cfg.KeepAlive, "Reuse HTTP connections between requests")
+	fs.BoolVar(&cfg.HTTP2, "http2", cfg.HTTP2, "Use HTTP/2 when the server supports it")
+	fs.BoolVar(&cfg.Batch, "batch", cfg.Batch, "Combine the calendars' event requests into batch requests")
+}
+
+// addAuthFlags registers the flags of the OAuth flow.
+func addAuthFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.BoolVar(&cfg.NoBrowser, "no-browser", cfg.NoBrowser, "Print the authorization URL and a QR code instead of opening a browser")
+	fs.BoolVar(&cfg.FreebusyOnly, "freebusy-only", cfg.FreebusyOnly, "Only request free/busy access and infer absences from long busy blocks instead of reading events")
+	fs.StringVar(&cfg.ServiceAccount, "service-account", cfg.ServiceAccount, "Sign in as the service account with this JSON key file instead of the user")
+	fs.StringVar(&cfg.Impersonate, "impersonate", cfg.Impersonate, "With --service-account, act as this user through domain-wide delegation")
+	addCredentialFlags(fs, cfg)
+}
+
+// addCredentialFlags registers the flags that choose where credentials are
+// stored.
+func addCredentialFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.BoolVar(&cfg.NoKeyring, "no-keyring", cfg.NoKeyring, "Store the client secret and token in files in the config directory instead of the system keyring")
+	fs.StringVar(&cfg.TokenFile, "token-file", cfg.TokenFile, "Store the OAuth token in this file instead of the system keyring")
+	fs.IntVar(&cfg.TokenMaxIdleDays, "token-max-idle-days", cfg.TokenMaxIdleDays, "Delete the stored OAuth token after this many days without use, forcing a new sign-in (0 keeps it)")
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  ooo-view <command> [options] [arguments]")
+	fmt.Fprintln(w, "  ooo-view [options] <group-email|alias>   (same as 'ooo-view view')")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nRun 'ooo-view help <command>' for the options of a command.")
+	fmt.Fprintln(w, "\nExample:")
+	fmt.Fprintln(w, "  ooo-view view --weeks 8 group-id@example.com")
+}
+
+func printCommandUsage(w io.Writer, cmd *command, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintf(w, "  ooo-view %s [options] %s\n", cmd.name, cmd.args)
+	fmt.Fprintf(w, "\n%s.\n", cmd.summary)
+	fmt.Fprintln(w, "\nOptions:")
+	fs.PrintDefaults()
+}
+
+func viewCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	limits := addThresholdFlags(fs, cfg)
+	fs.StringVar(&cfg.GroupBy, "group-by", cfg.GroupBy, "Show the members in sections: location groups them by office from the Directory, with the coverage of each region, and team or manager by their team or manager in the roster, checking the --fail-* thresholds per section")
+	watch := fs.Duration("watch", 0, "Redraw the calendar every interval (e.g., 5m) until interrupted, highlighting the days that changed in the last few refreshes")
+	clipboard := addCopyFlag(fs)
+
+	return func(ctx context.Context, s *session, args []string) error {
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		if *watch < 0 {
+			return usageErrorf("--watch can't be negative")
+		}
+		if *watch > 0 {
+			if *clipboard != "" {
+				return usageErrorf("--copy and --watch can't be combined")
+			}
+			return watchGroup(ctx, s, group, *watch)
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+
+		// Display combined calendar view
+		stopRender := s.timings.Phase("render")
+		_, renderSpan := tracer.Start(ctx, "render")
+		out, copied := clipboard.output()
+		render.StaleBanner(out, ds)
+		switch s.cfg.GroupBy {
+		case groupByLocation:
+			render.GridByOffice(out, ds)
+		case groupByTeam:
+			render.GridByTeam(out, ds)
+		case groupByManager:
+			render.GridByManager(out, ds)
+		default:
+			render.Grid(out, ds)
+		}
+		renderSpan.End()
+		stopRender()
+		if err := clipboard.toClipboard(copied); err != nil {
+			logFor("cli").Warn("could not copy the output", "error", err)
+		}
+		switch s.cfg.GroupBy {
+		case groupByTeam:
+			return limits.checkSections(ds, loc, render.TeamSections(ds))
+		case groupByManager:
+			return limits.checkSections(ds, loc, render.ManagerSections(ds))
+		}
+		return limits.check(ds, loc)
+	}
+}
+
+func snapshotCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	dir := fs.String("dir", "", "Directory to write the snapshot to (default: <data dir>/ooo-view/snapshots, see 'ooo-view paths')")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+
+		path, err := writeSnapshot(*dir, ds)
+		s.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: "snapshot", Destination: path, Events: len(ds.Absences), Error: errorString(err)})
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+// writeSnapshot saves ds as JSON in dir, named after the group and fetch
+// time, and returns the file's path.
+func writeSnapshot(dir string, ds *ooo.Dataset) (string, error) {
+	if dir == "" {
+		data, err := dataDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(data, "snapshots")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("unable to create snapshot directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(ds, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal snapshot: %v", err)
+	}
+	name := fmt.Sprintf("%s-%s.json", unsafeFileChars.ReplaceAllString(ds.Group, "_"), ds.FetchedAt.UTC().Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+	// Snapshots hold other people's events
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("unable to write snapshot: %v", err)
+	}
+	return path, nil
+}
+
+func authCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addHTTPFlags(fs, cfg)
+	addAuthFlags(fs, cfg)
+	secret := fs.Bool("secret", false, "With logout, also remove the stored client secret")
+	fs.BoolVar(&cfg.SheetsAccess, "sheets", cfg.SheetsAccess, "With login, also allow writing to Google Sheets, for the sheets command")
+	fs.BoolVar(&cfg.DirectoryAccess, "directory", cfg.DirectoryAccess, "With login, also allow reading work locations from the Directory, for --group-by location and --manager")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 1 {
+			return usageErrorf("expected 'login', 'logout' or 'status'")
+		}
+
+		creds, err := newCredentialStore(s.cfg)
+		if err != nil {
+			return err
+		}
+		switch args[0] {
+		case "login":
+			ctx = context.WithValue(ctx, oauth2.HTTPClient, newHTTPClient(s.cfg))
+			oauthConfig, err := getConfig(ctx, creds, s.cfg)
+			if err != nil {
+				return fmt.Errorf("unable to get client config: %v", err)
+			}
+			tok, err := getToken(ctx, oauthConfig, creds, s.cfg)
+			if err != nil {
+				return fmt.Errorf("unable to get token: %v", err)
+			}
+			fmt.Printf("Logged in. Token valid until %s.\n", tok.Expiry.Local().Format("Mon Jan 2 15:04"))
+		case "logout":
+			if err := creds.Delete(auth.TokenKey); err != nil {
+				logFor("auth").Warn("could not delete OAuth token", "error", err)
+			} else {
+				fmt.Println("OAuth token has been removed.")
+			}
+			if *secret {
+				if err := creds.Delete(auth.ClientSecretKey); err != nil {
+					logFor("auth").Warn("could not delete client secret", "error", err)
+				} else {
+					fmt.Println("Client secret has been removed.")
+				}
+			}
+		case "status":
+			if _, err := creds.Get(auth.ClientSecretKey); err != nil {
+				fmt.Println("Client secret: not stored")
+			} else {
+				fmt.Printf("Client secret: stored in %s\n", creds.Where(auth.ClientSecretKey))
+			}
+			printTokenStatus(creds, s.cfg.TokenMaxIdleDays)
+		default:
+			return usageErrorf("unknown auth command '%s'", args[0])
+		}
+		return nil
+	}
+}
+
+func printTokenStatus(creds *auth.CredentialStore, maxIdleDays int) {
+	token, err := auth.LoadToken(creds)
+	if errors.Is(err, auth.ErrNotStored) {
+		fmt.Println("OAuth token:   not stored")
+		return
+	}
+	if err != nil {
+		fmt.Println("OAuth token:   unreadable, run 'ooo-view auth login'")
+		return
+	}
+	if token.Expiry.After(time.Now()) {
+		fmt.Printf("OAuth token:   valid until %s\n", token.Expiry.Local().Format("Mon Jan 2 15:04"))
+	} else {
+		fmt.Println("OAuth token:   expired, run 'ooo-view auth login'")
+	}
+	if !token.LastUsed.IsZero() {
+		fmt.Printf("Last used:     %s\n", token.LastUsed.Local().Format("Mon Jan 2 15:04"))
+		if maxIdleDays > 0 {
+			fmt.Printf("Deleted after: %s unless used again\n", token.LastUsed.AddDate(0, 0, maxIdleDays).Local().Format("Mon Jan 2 15:04"))
+		}
+	}
+}
+
+func groupsCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addHTTPFlags(fs, cfg)
+	addAuthFlags(fs, cfg)
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) == 0 {
+			return pickGroup(ctx, s, newPrompter())
+		}
+		return runGroupsCommand(args, s.cfg)
+	}
+}
+
+func dbCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	return func(ctx context.Context, s *session, args []string) error {
+		return runDBCommand(args, s.cfg)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
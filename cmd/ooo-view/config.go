@@ -0,0 +1,418 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// fileConfig mirrors config.yaml. Fields are pointers so that keys missing
+// from the file can be told apart from zero values.
+type fileConfig struct {
+	Weeks          *int      `yaml:"weeks,omitempty"`
+	MinDuration    *duration `yaml:"min_duration,omitempty"`
+	TimeZone       *string   `yaml:"timezone,omitempty"`
+	Concurrency    *int      `yaml:"concurrency,omitempty"`
+	RequestTimeout *duration `yaml:"request_timeout,omitempty"`
+	CacheTTL       *duration `yaml:"cache_ttl,omitempty"`
+	HTTPTimeout    *duration `yaml:"http_timeout,omitempty"`
+	KeepAlive      *bool     `yaml:"keep_alive,omitempty"`
+	HTTP2          *bool     `yaml:"http2,omitempty"`
+	LogFormat      *string   `yaml:"log_format,omitempty"`
+	NoKeyring      *bool     `yaml:"no_keyring,omitempty"`
+	TokenFile      *string   `yaml:"token_file,omitempty"`
+	Provider       *string   `yaml:"provider,omitempty"`
+	// Reconcile is flag, longest or prefer:NAME
+	Reconcile *string `yaml:"reconcile,omitempty"`
+	// DateStyle is us, eu or iso, and Clock 24h or 12h
+	DateStyle     *string `yaml:"date_style,omitempty"`
+	Clock         *string `yaml:"clock,omitempty"`
+	ShowTimeZones *bool   `yaml:"show_time_zones,omitempty"`
+	// Plugins limits the requests to provider plugins, by name
+	Plugins map[string]pluginConfig `yaml:"plugins,omitempty"`
+
+	// TokenMaxIdleDays deletes the stored token after that many days
+	// without use
+	TokenMaxIdleDays *int `yaml:"token_max_idle_days,omitempty"`
+	// AuditLog records data access as JSON lines in this file
+	AuditLog *string `yaml:"audit_log,omitempty"`
+	// FetchDeadline gives up on calendars not fetched in time
+	FetchDeadline *duration `yaml:"fetch_deadline,omitempty"`
+	Batch         *bool     `yaml:"batch,omitempty"`
+
+	// SecondaryCalendars names calendars whose events count as absences
+	SecondaryCalendars []string `yaml:"secondary_calendars,omitempty"`
+	// ResponseStatus lists the responses whose events count as absences
+	ResponseStatus []string `yaml:"response_status,omitempty"`
+	FetchWorkWeeks *bool    `yaml:"fetch_work_weeks,omitempty"`
+	FreebusyOnly   *bool    `yaml:"freebusy_only,omitempty"`
+	// NoFreebusyFallback leaves out the calendars whose events can't be
+	// read rather than showing their busy blocks
+	NoFreebusyFallback *bool `yaml:"no_freebusy_fallback,omitempty"`
+	// RedactSummaries leaves event titles out of every output
+	RedactSummaries *bool `yaml:"redact_summaries,omitempty"`
+	// WorkWeeks maps people to the days they work, e.g. [mon, tue, wed]
+	WorkWeeks map[string][]string `yaml:"work_weeks,omitempty"`
+
+	// Identities maps people to their other addresses, e.g. of an old
+	// domain or a personal calendar, so that they're shown once
+	Identities map[string][]string `yaml:"identities,omitempty"`
+
+	// DefaultGroup is used when no group is given on the command line
+	DefaultGroup *string `yaml:"default_group,omitempty"`
+
+	// Groups maps short aliases to group emails, e.g. eng: eng@example.com
+	Groups map[string]string `yaml:"groups,omitempty"`
+
+	// Airtable configures the airtable command
+	Airtable *airtableConfig `yaml:"airtable,omitempty"`
+	// Payroll shapes the CSV of report payroll
+	Payroll *payrollConfig `yaml:"payroll,omitempty"`
+	// Handovers plans the handover invites of coverage gaps
+	Handovers *handoverConfig `yaml:"handovers,omitempty"`
+	// Schedule lists the digests and exports serve runs
+	Schedule []scheduleEntry `yaml:"schedule,omitempty"`
+	// Range is the window shown instead of weeks, and Ranges names more
+	// windows, like summer
+	Range  *string                `yaml:"range,omitempty"`
+	Ranges map[string]rangeConfig `yaml:"ranges,omitempty"`
+	// Sheets configures the sheets command
+	Sheets *sheetsConfig `yaml:"sheets,omitempty"`
+
+	// ServiceAccount is the key file of a service account to sign in as,
+	// acting as the Impersonate user
+	ServiceAccount *string `yaml:"service_account,omitempty"`
+	Impersonate    *string `yaml:"impersonate,omitempty"`
+	// Remote is the URL of an ooo-view proxy to fetch from
+	Remote *string `yaml:"remote,omitempty"`
+	// DayBoundary is shared or per-person
+	DayBoundary *string `yaml:"day_boundary,omitempty"`
+	// HolidayCalendars are the IDs of public holiday calendars, and
+	// Holidays maps more dates to the name of their holiday
+	HolidayCalendars []string          `yaml:"holiday_calendars,omitempty"`
+	Holidays         map[string]string `yaml:"holidays,omitempty"`
+	// Roster is the roster.yaml of teams, roles and managers
+	Roster *string `yaml:"roster,omitempty"`
+	// Manager limits every group to the reports of this manager, directly
+	// with DirectReports
+	Manager       *string `yaml:"manager,omitempty"`
+	DirectReports *bool   `yaml:"direct_reports,omitempty"`
+	// Milestones is a file of dates to overlay, like releases
+	Milestones *string `yaml:"milestones,omitempty"`
+	// Types lists the kinds of absences to show, e.g. [ooo]
+	Types []string `yaml:"types,omitempty"`
+	// Regions maps regions to their offices, for --group-by location
+	Regions map[string][]string `yaml:"regions,omitempty"`
+}
+
+// pluginConfig is how often a provider plugin may be run.
+type pluginConfig struct {
+	// RateLimit is the most requests a minute
+	RateLimit int `yaml:"rate_limit,omitempty"`
+	// CacheTTL is how long answers are reused (default: --cache-ttl)
+	CacheTTL *duration `yaml:"cache_ttl,omitempty"`
+}
+
+// duration is a time.Duration written as a Go duration string in YAML,
+// e.g. "48h", or as days or weeks, e.g. "3d".
+type duration time.Duration
+
+func (d *duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	parsed, err := ooo.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+func (d duration) MarshalYAML() (any, error) {
+	return formatDuration(time.Duration(d)), nil
+}
+
+// durationFlag is a time.Duration flag that also accepts days and weeks,
+// e.g. "3d" or "1w".
+type durationFlag time.Duration
+
+func (d *durationFlag) String() string { return formatDuration(time.Duration(*d)) }
+
+func (d *durationFlag) Set(s string) error {
+	parsed, err := ooo.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = durationFlag(parsed)
+	return nil
+}
+
+// daysFlag sets a time.Duration as a number of days.
+type daysFlag time.Duration
+
+func (d *daysFlag) String() string {
+	return strconv.FormatFloat(time.Duration(*d).Hours()/24, 'f', -1, 64)
+}
+
+func (d *daysFlag) Set(s string) error {
+	days, err := strconv.ParseFloat(s, 64)
+	if err != nil || days < 0 {
+		return fmt.Errorf("expected a number of days")
+	}
+	*d = daysFlag(days * float64(24*time.Hour))
+	return nil
+}
+
+// assumption is a hypothetical absence given with --assume: person is out
+// from the first to the last date, both included.
+type assumption struct {
+	person      string
+	first, last string
+}
+
+// assumeFlag collects --assume values like bob@example.com:2025-08-04..2025-08-15,
+// repeated or separated by commas.
+type assumeFlag []assumption
+
+func (a *assumeFlag) String() string {
+	values := make([]string, len(*a))
+	for i, as := range *a {
+		values[i] = as.person + ":" + as.first + ".." + as.last
+	}
+	return strings.Join(values, ",")
+}
+
+func (a *assumeFlag) Set(s string) error {
+	for _, value := range splitList(s) {
+		person, dates, ok := strings.Cut(value, ":")
+		if !ok || person == "" {
+			return fmt.Errorf("expected a person and dates like bob@example.com:2025-08-04..2025-08-15")
+		}
+		from, to, err := parseDateRange(dates, time.UTC)
+		if err != nil {
+			return err
+		}
+		*a = append(*a, assumption{strings.ToLower(person), from.Format("2006-01-02"), to.Format("2006-01-02")})
+	}
+	return nil
+}
+
+// formatDuration formats whole days as e.g. "3d", and other durations like
+// time.Duration does.
+func formatDuration(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	}
+	return d.String()
+}
+
+// defaultConfigPath returns the location of config.yaml in the user's
+// config directory, e.g. ~/.config/ooo-view/config.yaml on Linux.
+func defaultConfigPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// readConfigFile parses the config file at path. A missing file is only an
+// error if the path was given explicitly.
+func readConfigFile(path string, explicit bool) (*fileConfig, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return &fileConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %v", err)
+	}
+	defer f.Close()
+
+	var fc fileConfig
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	for person, days := range fc.WorkWeeks {
+		if _, err := ooo.ParseWorkWeek(days); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: work week of %s: %v", path, person, err)
+		}
+	}
+	if _, err := ooo.ParseIdentities(fc.Identities); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: identities: %v", path, err)
+	}
+	if _, err := ooo.ParseHolidays(fc.Holidays, time.UTC); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: holidays: %v", path, err)
+	}
+	for name, plugin := range fc.Plugins {
+		if plugin.RateLimit < 0 {
+			return nil, fmt.Errorf("invalid config file %s: plugins: the rate limit of %s can't be negative", path, name)
+		}
+	}
+	if fc.Payroll != nil {
+		if err := validatePayrollConfig(*fc.Payroll); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: payroll: %v", path, err)
+		}
+	}
+	if fc.Handovers != nil {
+		if err := validateHandoverConfig(*fc.Handovers); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: handovers: %v", path, err)
+		}
+	}
+	if err := validateSchedule(fc.Schedule); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: schedule: %v", path, err)
+	}
+	if err := validateRanges(fc.Ranges); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: ranges: %v", path, err)
+	}
+	if fc.Airtable != nil {
+		if err := validateAirtableFields(fc.Airtable.Fields); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: airtable: %v", path, err)
+		}
+	}
+	return &fc, nil
+}
+
+// apply copies the settings present in the file onto cfg, except for those
+// whose flag was set explicitly on the command line.
+func (fc *fileConfig) apply(cfg *Config, setFlags map[string]bool) {
+	setInt := func(flagName string, dst *int, v *int) {
+		if v != nil && !setFlags[flagName] {
+			*dst = *v
+		}
+	}
+	setString := func(flagName string, dst *string, v *string) {
+		if v != nil && !setFlags[flagName] {
+			*dst = *v
+		}
+	}
+	setBool := func(flagName string, dst *bool, v *bool) {
+		if v != nil && !setFlags[flagName] {
+			*dst = *v
+		}
+	}
+	setDuration := func(flagName string, dst *time.Duration, v *duration) {
+		if v != nil && !setFlags[flagName] {
+			*dst = time.Duration(*v)
+		}
+	}
+
+	setInt("weeks", &cfg.WeeksAhead, fc.Weeks)
+	// --weeks replaces a range from the file
+	if !setFlags["weeks"] {
+		setString("range", &cfg.Range, fc.Range)
+	}
+	cfg.Ranges = fc.Ranges
+	// --min-days is another way to set --min-duration
+	if !setFlags["min-days"] {
+		setDuration("min-duration", &cfg.MinDuration, fc.MinDuration)
+	}
+	setString("timezone", &cfg.TimeZone, fc.TimeZone)
+	setInt("concurrency", &cfg.Concurrency, fc.Concurrency)
+	setDuration("request-timeout", &cfg.RequestTimeout, fc.RequestTimeout)
+	setDuration("cache-ttl", &cfg.CacheTTL, fc.CacheTTL)
+	setDuration("http-timeout", &cfg.HTTPTimeout, fc.HTTPTimeout)
+	setBool("keep-alive", &cfg.KeepAlive, fc.KeepAlive)
+	setBool("http2", &cfg.HTTP2, fc.HTTP2)
+	setString("log-format", &cfg.LogFormat, fc.LogFormat)
+	setString("date-style", &cfg.DateStyle, fc.DateStyle)
+	setString("clock", &cfg.Clock, fc.Clock)
+	setBool("show-time-zones", &cfg.ShowTimeZones, fc.ShowTimeZones)
+	setBool("no-keyring", &cfg.NoKeyring, fc.NoKeyring)
+	setString("token-file", &cfg.TokenFile, fc.TokenFile)
+	setInt("token-max-idle-days", &cfg.TokenMaxIdleDays, fc.TokenMaxIdleDays)
+	setString("provider", &cfg.Provider, fc.Provider)
+	setString("reconcile", &cfg.Reconcile, fc.Reconcile)
+	setString("audit-log", &cfg.AuditLog, fc.AuditLog)
+	setDuration("fetch-deadline", &cfg.FetchDeadline, fc.FetchDeadline)
+	setBool("batch", &cfg.Batch, fc.Batch)
+	setString("service-account", &cfg.ServiceAccount, fc.ServiceAccount)
+	setString("impersonate", &cfg.Impersonate, fc.Impersonate)
+	setString("remote", &cfg.Remote, fc.Remote)
+	setString("day-boundary", &cfg.DayBoundary, fc.DayBoundary)
+	setString("milestones", &cfg.Milestones, fc.Milestones)
+	setString("roster", &cfg.RosterPath, fc.Roster)
+	setString("manager", &cfg.Manager, fc.Manager)
+	setBool("direct-reports", &cfg.DirectReports, fc.DirectReports)
+	if fc.SecondaryCalendars != nil && !setFlags["secondary-calendars"] {
+		cfg.SecondaryCalendars = strings.Join(fc.SecondaryCalendars, ",")
+	}
+	if fc.ResponseStatus != nil && !setFlags["response-status"] {
+		cfg.ResponseStatus = strings.Join(fc.ResponseStatus, ",")
+	}
+	if fc.HolidayCalendars != nil && !setFlags["holiday-calendars"] {
+		cfg.HolidayCalendars = strings.Join(fc.HolidayCalendars, ",")
+	}
+	cfg.Holidays = fc.Holidays
+	if fc.Types != nil && !setFlags["types"] {
+		cfg.Types = strings.Join(fc.Types, ",")
+	}
+
+	setBool("fetch-work-weeks", &cfg.FetchWorkWeeks, fc.FetchWorkWeeks)
+	setBool("freebusy-only", &cfg.FreebusyOnly, fc.FreebusyOnly)
+	setBool("no-freebusy-fallback", &cfg.NoFreebusyFallback, fc.NoFreebusyFallback)
+	setBool("redact-summaries", &cfg.RedactSummaries, fc.RedactSummaries)
+	if len(fc.WorkWeeks) > 0 {
+		cfg.WorkWeeks = make(map[string]ooo.WorkWeek, len(fc.WorkWeeks))
+		for person, days := range fc.WorkWeeks {
+			cfg.WorkWeeks[strings.ToLower(person)], _ = ooo.ParseWorkWeek(days)
+		}
+	}
+
+	if len(fc.Regions) > 0 {
+		cfg.Regions = make(map[string]string)
+		for region, offices := range fc.Regions {
+			for _, office := range offices {
+				cfg.Regions[office] = region
+			}
+		}
+	}
+	if len(fc.Identities) > 0 {
+		// Validated when the file was read
+		cfg.Identities, _ = ooo.ParseIdentities(fc.Identities)
+	}
+
+	if fc.DefaultGroup != nil {
+		cfg.DefaultGroup = *fc.DefaultGroup
+	}
+	cfg.GroupAliases = fc.Groups
+	cfg.Plugins = fc.Plugins
+	if fc.Payroll != nil {
+		cfg.Payroll = *fc.Payroll
+	}
+	if fc.Handovers != nil {
+		cfg.Handovers = *fc.Handovers
+		cfg.Handovers.Backups = make(map[string]string, len(fc.Handovers.Backups))
+		for person, backup := range fc.Handovers.Backups {
+			cfg.Handovers.Backups[cfg.Identities.Canonical(strings.ToLower(person))] = cfg.Identities.Canonical(strings.ToLower(backup))
+		}
+	}
+	cfg.Schedule = fc.Schedule
+	if fc.Airtable != nil {
+		cfg.Airtable = *fc.Airtable
+	}
+	if fc.Sheets != nil {
+		cfg.Sheets = *fc.Sheets
+	}
+}
+
+// resolveGroup returns the group email for an alias defined in the config,
+// or the argument itself if it isn't an alias.
+func resolveGroup(arg string, aliases map[string]string) string {
+	if email, ok := aliases[arg]; ok {
+		return email
+	}
+	return arg
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+
+	"github.com/klaasmeinke/ooo-view/pkg/auth"
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// checkStatus is the outcome of one doctor check.
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+	checkSkip checkStatus = "SKIP"
+)
+
+// doctor runs the checks in order and prints each result with a hint on how
+// to fix it.
+type doctor struct {
+	failed int
+
+	// Set by earlier checks for the later ones
+	creds       *auth.CredentialStore
+	keyringOK   bool
+	oauthConfig *oauth2.Config
+	token       *oauth2.Token
+	srv         *calendar.Service
+}
+
+func (d *doctor) report(name string, status checkStatus, detail, hint string) {
+	fmt.Printf("[%s] %-14s %s\n", status, name, detail)
+	if hint != "" && status != checkPass {
+		fmt.Printf("       %-14s -> %s\n", "", hint)
+	}
+	if status == checkFail {
+		d.failed++
+	}
+}
+
+func doctorCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	fs.StringVar(&cfg.TimeZone, "timezone", cfg.TimeZone, "Time zone to check")
+	addHTTPFlags(fs, cfg)
+	addCredentialFlags(fs, cfg)
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) > 1 {
+			return usageErrorf("expected at most one group email or alias")
+		}
+		group := s.cfg.DefaultGroup
+		if len(args) == 1 {
+			group = args[0]
+		}
+
+		creds, err := newCredentialStore(s.cfg)
+		if err != nil {
+			return err
+		}
+		d := &doctor{creds: creds}
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newHTTPClient(s.cfg))
+		d.checkConfig(s.cfg)
+		d.checkTimezone(s.cfg)
+		d.checkCredentials()
+		d.checkToken(ctx)
+		d.checkAPI(ctx, s.cfg)
+		d.checkGroup(ctx, s.cfg, group)
+		d.checkTerminal()
+
+		if d.failed > 0 {
+			return fmt.Errorf("%d checks failed", d.failed)
+		}
+		return nil
+	}
+}
+
+func (d *doctor) checkConfig(cfg Config) {
+	if _, err := os.Stat(cfg.ConfigPath); err != nil {
+		d.report("Config file", checkWarn, "not found at "+cfg.ConfigPath+", using defaults", "Run 'ooo-view setup' to create one")
+		return
+	}
+	d.report("Config file", checkPass, cfg.ConfigPath, "")
+}
+
+func (d *doctor) checkTimezone(cfg Config) {
+	if _, err := time.LoadLocation(cfg.TimeZone); err != nil {
+		d.report("Time zone", checkFail, fmt.Sprintf("'%s' is not a valid time zone", cfg.TimeZone),
+			"Use an IANA name like Europe/Amsterdam in --timezone, CALENDAR_TIMEZONE or the config file")
+		return
+	}
+	d.report("Time zone", checkPass, cfg.TimeZone, "")
+}
+
+func (d *doctor) checkCredentials() {
+	if d.creds.File(auth.ClientSecretKey) != "" && d.creds.File(auth.TokenKey) != "" {
+		d.keyringOK = true
+		d.report("Keyring", checkSkip, "not used with --no-keyring", "")
+	} else if _, err := keyring.Get(serviceName, auth.ClientSecretKey); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		d.report("Keyring", checkFail, err.Error(),
+			"Make sure a keyring service is running (e.g. gnome-keyring or KWallet on Linux), or use --no-keyring")
+		d.report("Client secret", checkSkip, "keyring unavailable", "")
+		return
+	} else {
+		d.keyringOK = true
+		d.report("Keyring", checkPass, "available", "")
+	}
+
+	secret, err := d.creds.Get(auth.ClientSecretKey)
+	if errors.Is(err, auth.ErrNotStored) {
+		d.report("Client secret", checkFail, "not stored in "+d.creds.Where(auth.ClientSecretKey), "Run 'ooo-view setup'")
+		return
+	}
+	if err != nil {
+		d.report("Client secret", checkFail, err.Error(), "")
+		return
+	}
+
+	config, err := google.ConfigFromJSON([]byte(secret), auth.Scopes...)
+	if err != nil {
+		d.report("Client secret", checkFail, "unreadable: "+err.Error(), "Run 'ooo-view setup' to store it again")
+		return
+	}
+	d.oauthConfig = config
+	d.report("Client secret", checkPass, "stored", "")
+}
+
+// tokenInfoURL reports the scopes granted to an access token.
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+func (d *doctor) checkToken(ctx context.Context) {
+	if !d.keyringOK {
+		d.report("OAuth token", checkSkip, "keyring unavailable", "")
+		return
+	}
+	tokenJSON, err := d.creds.Get(auth.TokenKey)
+	if err != nil {
+		d.report("OAuth token", checkFail, "not stored in "+d.creds.Where(auth.TokenKey), "Run 'ooo-view auth login'")
+		return
+	}
+	var token oauth2.Token
+	if err := json.Unmarshal([]byte(tokenJSON), &token); err != nil {
+		d.report("OAuth token", checkFail, "unreadable", "Run 'ooo-view auth login'")
+		return
+	}
+	if !token.Expiry.After(time.Now()) {
+		d.report("OAuth token", checkFail, "expired at "+token.Expiry.Local().Format("Mon Jan 2 15:04"), "Run 'ooo-view auth login'")
+		return
+	}
+	d.token = &token
+	d.report("OAuth token", checkPass, "valid until "+token.Expiry.Local().Format("Mon Jan 2 15:04"), "")
+
+	// Ask Google which scopes the token grants
+	client, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenInfoURL+"?access_token="+url.QueryEscape(token.AccessToken), nil)
+	if err != nil {
+		d.report("Token scopes", checkFail, err.Error(), "")
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		d.report("Token scopes", checkFail, "unable to reach Google: "+err.Error(), "Check your network connection and proxy settings")
+		return
+	}
+	defer resp.Body.Close()
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&info) != nil {
+		d.report("Token scopes", checkFail, "token was rejected ("+resp.Status+")", "Run 'ooo-view auth login'")
+		return
+	}
+	for _, scope := range strings.Fields(info.Scope) {
+		if scope == calendar.CalendarReadonlyScope || scope == calendar.CalendarScope {
+			d.report("Token scopes", checkPass, info.Scope, "")
+			return
+		}
+	}
+	d.report("Token scopes", checkFail, "missing "+calendar.CalendarReadonlyScope,
+		"Run 'ooo-view auth logout' and 'ooo-view auth login' to grant calendar access")
+}
+
+func (d *doctor) checkAPI(ctx context.Context, cfg Config) {
+	if d.oauthConfig == nil || d.token == nil {
+		d.report("Calendar API", checkSkip, "needs a client secret and token", "")
+		return
+	}
+	apiClient := d.oauthConfig.Client(ctx, d.token)
+	apiClient.Timeout = cfg.HTTPTimeout
+	srv, err := calendar.NewService(ctx, option.WithHTTPClient(apiClient))
+	if err != nil {
+		d.report("Calendar API", checkFail, err.Error(), "")
+		return
+	}
+	if _, err := srv.CalendarList.List().MaxResults(1).Context(ctx).Do(); err != nil {
+		d.report("Calendar API", checkFail, err.Error(),
+			"Check that the Google Calendar API is enabled for your Cloud project and that you can reach googleapis.com")
+		return
+	}
+	d.srv = srv
+	d.report("Calendar API", checkPass, "reachable", "")
+}
+
+func (d *doctor) checkGroup(ctx context.Context, cfg Config, group string) {
+	if group == "" {
+		d.report("Group", checkSkip, "no group given and no default group", "Pass a group or set default_group to check access")
+		return
+	}
+	if d.srv == nil {
+		d.report("Group", checkSkip, "needs the Calendar API", "")
+		return
+	}
+	groupEmail := resolveGroup(group, cfg.GroupAliases)
+	now := time.Now()
+	members, err := ooo.NewGoogleProvider(d.srv, nil).Members(ctx, groupEmail, now, now.Add(24*time.Hour), cfg.TimeZone)
+	switch {
+	case errors.Is(err, ooo.ErrGroupNotFound):
+		d.report("Group", checkFail, err.Error(), "Check the address, or run 'ooo-view groups' to pick one of yours")
+		return
+	case errors.Is(err, ooo.ErrAuthExpired):
+		d.report("Group", checkFail, err.Error(), "Run 'ooo-view auth logout' and 'ooo-view auth login' to sign in again")
+		return
+	case err != nil:
+		d.report("Group", checkFail, err.Error(), "Check the address and that you can see the group members' calendars")
+		return
+	}
+	d.report("Group", checkPass, fmt.Sprintf("%s: %d calendars visible", groupEmail, len(members)), "")
+}
+
+func (d *doctor) checkTerminal() {
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		d.report("Terminal", checkWarn, "stdout is not a terminal", "Fine for pipes and cron; the grid is plain text")
+	} else if term := os.Getenv("TERM"); term == "" || term == "dumb" {
+		d.report("Terminal", checkWarn, "TERM is not set", "Set TERM so that the browser prompt and QR code display correctly")
+	} else {
+		d.report("Terminal", checkPass, term, "")
+	}
+
+	locale := os.Getenv("LC_ALL")
+	if locale == "" {
+		locale = os.Getenv("LC_CTYPE")
+	}
+	if locale == "" {
+		locale = os.Getenv("LANG")
+	}
+	if upper := strings.ToUpper(locale); !strings.Contains(upper, "UTF-8") && !strings.Contains(upper, "UTF8") {
+		d.report("Unicode", checkWarn, "locale is not UTF-8", "The QR code printed by --no-browser needs a UTF-8 terminal")
+		return
+	}
+	d.report("Unicode", checkPass, locale, "")
+}
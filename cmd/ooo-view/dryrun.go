@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// errDryRun stops a command after --dry-run printed the plan. execute turns
+// it into a successful exit.
+var errDryRun = errors.New("dry run")
+
+// planGroup prints which calendars a fetch of the group would query, for
+// which window, and how many API requests that takes. Members are taken from
+// the event cache when it knows the group; otherwise the group is expanded
+// with free/busy requests, the only calls a dry run makes.
+func (s *session) planGroup(ctx context.Context, w io.Writer, groupEmail string) error {
+	timeMin, timeMax := s.window()
+	store := s.eventStore()
+
+	var members []string
+	source := ""
+	if store != nil {
+		if cached, err := store.GroupMembers(groupEmail); err == nil && len(cached) > 0 {
+			members = cached
+			source = "from the event cache"
+		}
+	}
+	// A fetch expands the group with one query, plus batches for members
+	// beyond the expansion limit
+	freebusyRequests := 1 + (max(len(members)-ooo.GroupExpansionMax, 0)+ooo.FreebusyBatchSize-1)/ooo.FreebusyBatchSize
+	if people, ok := s.cfg.Roster.Group(groupEmail); ok {
+		// The roster resolves the group without asking the provider
+		members = append([]string{}, people...)
+		source = "from the roster"
+		freebusyRequests = 0
+	}
+	if members == nil {
+		provider, err := s.source(ctx)
+		if err != nil {
+			return err
+		}
+		members, err = provider.Members(ctx, groupEmail, timeMin, timeMax, s.cfg.TimeZone)
+		if err != nil {
+			return err
+		}
+		source = "listed by " + ooo.SourceOf(provider)
+		if multi, ok := provider.(*ooo.MultiProvider); ok {
+			source = "listed by " + ooo.SourceOf(multi.Providers[0])
+		}
+		if google, ok := provider.(*ooo.GoogleProvider); ok {
+			// Members is the provider's only call so far
+			freebusyRequests = google.Requests()
+			source = fmt.Sprintf("expanded with %d free/busy requests", freebusyRequests)
+		}
+	}
+
+	fmt.Fprintf(w, "Dry run for %s\n", groupEmail)
+	fmt.Fprintf(w, "%-14s %s to %s (%s)\n", "Window:", timeMin.Format("Mon Jan 2 2006"), timeMax.Format("Mon Jan 2 2006"), s.cfg.TimeZone)
+	fmt.Fprintf(w, "%-14s %s\n", "Min duration:", formatDuration(s.cfg.MinDuration))
+	fmt.Fprintf(w, "%-14s %d, %s\n\n", "Members:", len(members), source)
+
+	fetches := 0
+	fetch := "events.list"
+	combined := len(splitList(s.cfg.Provider)) > 1
+	switch {
+	case combined:
+		fetch = "ask " + strings.Join(splitList(s.cfg.Provider), ", ")
+	case s.usesPlugin():
+		fetch = "run " + ooo.PluginPrefix + s.cfg.Provider
+	case s.cfg.FreebusyOnly:
+		fetch = "busy blocks from the group query"
+	}
+	fmt.Fprintf(w, "%-40s %s\n", "Calendar", "Plan")
+	for _, person := range members {
+		plan := fetch
+		if store != nil {
+			if _, ok := store.Get(person, timeMin, timeMax); ok {
+				plan = "cached"
+			}
+		}
+		if plan != "cached" {
+			fetches++
+		}
+		fmt.Fprintf(w, "%-40s %s\n", person, plan)
+	}
+
+	if combined {
+		fmt.Fprintf(w, "\nA fetch would ask each of the %d providers for the events of every calendar, %d calendars at a time.\n", len(splitList(s.cfg.Provider)), min(s.cfg.Concurrency, max(fetches, 1)))
+		return nil
+	}
+	if s.usesPlugin() {
+		fmt.Fprintf(w, "\nA fetch would run the plugin %d times, %d at a time.\n", fetches+1, min(s.cfg.Concurrency, max(fetches, 1)))
+		return nil
+	}
+	if s.cfg.FreebusyOnly {
+		fmt.Fprintf(w, "\nA fetch would make %d freebusy.query requests and read no events.\n", freebusyRequests)
+		return nil
+	}
+	fmt.Fprintf(w, "\nA fetch would make %d freebusy.query and at least %d events.list requests (one per %d events per calendar), %d at a time.\n",
+		freebusyRequests, fetches, ooo.EventsPageSize, min(s.cfg.Concurrency, max(fetches, 1)))
+	if !s.cfg.NoFreebusyFallback {
+		fmt.Fprintln(w, "Calendars whose events can't be read would show their busy blocks instead.")
+	}
+	if s.cfg.Batch && fetches > 0 {
+		perBatch := min(s.cfg.Concurrency, ooo.BatchMax)
+		fmt.Fprintf(w, "Their first pages would be sent in about %d batch requests of up to %d.\n", (fetches+perBatch-1)/perBatch, perBatch)
+	}
+	if names := splitList(s.cfg.SecondaryCalendars); len(names) > 0 {
+		fmt.Fprintf(w, "It would also list your calendars and the events of those named %s.\n", strings.Join(names, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+func exportCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	format := fs.String("format", "json", "Output format: "+strings.Join(render.Names(), ", "))
+	output := fs.String("output", "-", "File to write to, or - for stdout")
+	out := fs.String("out", "public", "With site, the directory to write the site to")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		// `export site [group...]` writes a static site instead of one file
+		if len(args) > 0 && args[0] == "site" {
+			groups := s.siteGroups(args[1:])
+			if len(groups) == 0 {
+				return usageErrorf("no groups for the site; pass them after 'site', or set groups or default_group in the config file")
+			}
+			return exportSite(ctx, s, *out, groups)
+		}
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		renderer, ok := render.Lookup(*format)
+		if !ok {
+			return usageErrorf("unknown format '%s'", *format)
+		}
+
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+		if ds.Stale {
+			logFor("export").Warn("exporting cached data", "fetched_at", ds.FetchedAt.Local().Format("Mon Jan 2 15:04"))
+		}
+		if ds.Partial {
+			logFor("export").Warn("exporting partial data", "calendars", len(ds.Members))
+		}
+
+		destination := *output
+		if destination == "-" {
+			destination = "stdout"
+		}
+		err = writeExport(renderer, ds, *output)
+		s.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: *format, Destination: destination, Events: len(ds.Absences), Error: errorString(err)})
+		return err
+	}
+}
+
+// siteGroups returns the groups given for the site, or else every alias in
+// the config file, or else the default group.
+func (s *session) siteGroups(args []string) []string {
+	if len(args) > 0 {
+		return args
+	}
+	aliases := make([]string, 0, len(s.cfg.GroupAliases))
+	for alias := range s.cfg.GroupAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	if len(aliases) == 0 && s.cfg.DefaultGroup != "" {
+		aliases = append(aliases, s.cfg.DefaultGroup)
+	}
+	return aliases
+}
+
+// writeExport renders ds to output, a file or - for stdout.
+func writeExport(renderer render.Renderer, ds *ooo.Dataset, output string) error {
+	if output == "-" {
+		return renderer.Render(os.Stdout, ds)
+	}
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to create export file: %v", err)
+	}
+	if err := renderer.Render(f, ds); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dayKinds returns the kind of absence of each person on each date, as
+// "2006-01-02" in loc, leaving out the days they don't work. An OOO event
+// wins over a busy block on the same day.
+func dayKinds(ds *ooo.Dataset, loc *time.Location) map[string]map[string]ooo.Kind {
+	kinds := make(map[string]map[string]ooo.Kind)
+	for _, a := range ds.Absences {
+		for _, d := range a.Days(ds.DayLocation(a.Person, loc)) {
+			if week, ok := ds.WorkWeeks[a.Person]; ok && !week.Works(d.Weekday()) {
+				continue
+			}
+			if kinds[a.Person] == nil {
+				kinds[a.Person] = make(map[string]ooo.Kind)
+			}
+			key := d.Format("2006-01-02")
+			if kinds[a.Person][key] != ooo.KindOutOfOffice {
+				kinds[a.Person][key] = a.Kind
+			}
+		}
+	}
+	return kinds
+}
+
+// dayCell is how tables show person's day: OOO, BSY for a busy block, - for
+// a day they don't work, or empty.
+func dayCell(ds *ooo.Dataset, kinds map[string]map[string]ooo.Kind, person string, day time.Time) string {
+	if week, ok := ds.WorkWeeks[person]; ok && !week.Works(day.Weekday()) {
+		return "-"
+	}
+	switch kinds[person][day.Format("2006-01-02")] {
+	case "":
+		return ""
+	case ooo.KindBusy:
+		return "BSY"
+	default:
+		return "OOO"
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// feedTokensFile holds the tokens of the consumers of served feeds and the
+// key their URLs are signed with, in the config directory.
+const feedTokensFile = "feed-tokens.json"
+
+// feedTokenFile is the content of feedTokensFile.
+type feedTokenFile struct {
+	Key    []byte      `json:"key"`
+	Tokens []feedToken `json:"tokens"`
+}
+
+// feedToken is one consumer's access to the served feeds.
+type feedToken struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	// Expires is zero for tokens that don't expire
+	Expires time.Time `json:"expires,omitempty"`
+	Revoked bool      `json:"revoked,omitempty"`
+}
+
+// readFeedTokens reads the tokens at path, or none if there's no file yet.
+func readFeedTokens(path string) (*feedTokenFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &feedTokenFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read feed tokens: %v", err)
+	}
+	var f feedTokenFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid feed tokens file %s: %v", path, err)
+	}
+	return &f, nil
+}
+
+// write saves the tokens at path, readable only by the user.
+func (f *feedTokenFile) write(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode feed tokens: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create config directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write feed tokens: %v", err)
+	}
+	return nil
+}
+
+// create adds a token for name, valid for ttl or forever if ttl is 0, and
+// returns it with the signed string consumers put in their URLs.
+func (f *feedTokenFile) create(name string, ttl time.Duration) (feedToken, string, error) {
+	if len(f.Key) == 0 {
+		f.Key = make([]byte, 32)
+		if _, err := rand.Read(f.Key); err != nil {
+			return feedToken{}, "", fmt.Errorf("unable to create signing key: %v", err)
+		}
+	}
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return feedToken{}, "", fmt.Errorf("unable to create token: %v", err)
+	}
+	t := feedToken{ID: hex.EncodeToString(id), Name: name, Created: time.Now().UTC().Truncate(time.Second)}
+	if ttl > 0 {
+		t.Expires = t.Created.Add(ttl)
+	}
+	f.Tokens = append(f.Tokens, t)
+	return t, f.sign(t), nil
+}
+
+// sign returns the string for t: its ID and expiry, and their signature.
+func (f *feedTokenFile) sign(t feedToken) string {
+	var expires int64
+	if !t.Expires.IsZero() {
+		expires = t.Expires.Unix()
+	}
+	payload := t.ID + "." + strconv.FormatInt(expires, 10)
+	mac := hmac.New(sha256.New, f.Key)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the token s was signed for if it's valid, unexpired and
+// not revoked.
+func (f *feedTokenFile) verify(s string, now time.Time) (*feedToken, error) {
+	id, rest, _ := strings.Cut(s, ".")
+	for i := range f.Tokens {
+		t := &f.Tokens[i]
+		if t.ID != id {
+			continue
+		}
+		if len(f.Key) == 0 || !hmac.Equal([]byte(f.sign(*t)), []byte(id+"."+rest)) {
+			return nil, fmt.Errorf("invalid token")
+		}
+		if t.Revoked {
+			return nil, fmt.Errorf("token revoked")
+		}
+		if !t.Expires.IsZero() && now.After(t.Expires) {
+			return nil, fmt.Errorf("token expired")
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("invalid token")
+}
+
+// feedTokenChecker verifies the tokens of requests to the served feeds,
+// reading the file again when it changes, so that tokens created or revoked
+// while serving take effect right away.
+type feedTokenChecker struct {
+	path string
+
+	mu       sync.Mutex
+	modified time.Time
+	tokens   *feedTokenFile
+}
+
+// check returns the name of the consumer of token, or an error if it isn't
+// valid.
+func (c *feedTokenChecker) check(token string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, err := os.Stat(c.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("unable to read feed tokens: %v", err)
+	}
+	if c.tokens == nil || (info != nil && !info.ModTime().Equal(c.modified)) {
+		tokens, err := readFeedTokens(c.path)
+		if err != nil {
+			return "", err
+		}
+		c.tokens = tokens
+		if info != nil {
+			c.modified = info.ModTime()
+		}
+	}
+	t, err := c.tokens.verify(token, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return t.Name, nil
+}
+
+// feedTokensPath is where the feed tokens are kept.
+func feedTokensPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, feedTokensFile), nil
+}
+
+// feedTokensCommand runs `serve tokens create|list|revoke`.
+func feedTokensCommand(s *session, args []string, ttl time.Duration, addr string) error {
+	if len(args) == 0 {
+		return usageErrorf("expected tokens create <name>, tokens list or tokens revoke <id>")
+	}
+	path, err := feedTokensPath()
+	if err != nil {
+		return err
+	}
+	tokens, err := readFeedTokens(path)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) != 2 {
+			return usageErrorf("expected tokens create <name>")
+		}
+		t, signed, err := tokens.create(args[1], ttl)
+		if err != nil {
+			return err
+		}
+		if err := tokens.write(path); err != nil {
+			return err
+		}
+		s.audit.Record(auditRecord{Event: "token", Operation: "create", Destination: t.Name})
+		fmt.Printf("Created token %s for %s", t.ID, t.Name)
+		if !t.Expires.IsZero() {
+			fmt.Printf(", valid until %s", t.Expires.Local().Format("Mon Jan 2 2006 15:04"))
+		}
+		fmt.Printf(".\n\n  http://%s/calendar.ics?token=%s\n  http://%s/events.json?token=%s\n\n", addr, signed, addr, signed)
+		fmt.Println("The token is shown only once. Serve with --require-token to turn away requests without one.")
+	case "list":
+		if len(args) != 1 {
+			return usageErrorf("expected tokens list")
+		}
+		if len(tokens.Tokens) == 0 {
+			fmt.Println("No feed tokens; create one with: ooo-view serve tokens create <name>")
+			return nil
+		}
+		now := time.Now()
+		for _, t := range tokens.Tokens {
+			status := "never expires"
+			switch {
+			case t.Revoked:
+				status = "revoked"
+			case !t.Expires.IsZero() && now.After(t.Expires):
+				status = "expired " + t.Expires.Local().Format("2006-01-02")
+			case !t.Expires.IsZero():
+				status = "expires " + t.Expires.Local().Format("2006-01-02")
+			}
+			fmt.Printf("%s  %-24s created %s  %s\n", t.ID, t.Name, t.Created.Local().Format("2006-01-02"), status)
+		}
+	case "revoke":
+		if len(args) != 2 {
+			return usageErrorf("expected tokens revoke <id>")
+		}
+		found := false
+		for i := range tokens.Tokens {
+			if tokens.Tokens[i].ID == args[1] || tokens.Tokens[i].Name == args[1] {
+				tokens.Tokens[i].Revoked = true
+				found = true
+				s.audit.Record(auditRecord{Event: "token", Operation: "revoke", Destination: tokens.Tokens[i].Name})
+				fmt.Printf("Revoked token %s of %s.\n", tokens.Tokens[i].ID, tokens.Tokens[i].Name)
+			}
+		}
+		if !found {
+			return fmt.Errorf("no feed token %s; see ooo-view serve tokens list", args[1])
+		}
+		return tokens.write(path)
+	default:
+		return usageErrorf("unknown tokens command '%s', expected create, list or revoke", args[0])
+	}
+	return nil
+}
+
+// feedConsumerKey is the context key of the name of the token a request was
+// let in with.
+type feedConsumerKey struct{}
+
+// requireFeedToken turns away requests without a valid token, passed as
+// ?token= or as a bearer token.
+func requireFeedToken(tokens *feedTokenChecker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		name, err := tokens.check(token)
+		if err != nil {
+			logFor("serve").Warn("request turned away", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), feedConsumerKey{}, name)))
+	})
+}
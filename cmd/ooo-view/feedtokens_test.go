@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedTokenVerify(t *testing.T) {
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	f := &feedTokenFile{
+		Key: []byte("0123456789abcdef0123456789abcdef"),
+		Tokens: []feedToken{
+			{ID: "a1", Name: "alice", Expires: now.Add(time.Hour)},
+			{ID: "b2", Name: "bob"},
+			{ID: "c3", Name: "carol", Expires: now.Add(-time.Hour)},
+			{ID: "d4", Name: "dave", Revoked: true},
+		},
+	}
+	other := &feedTokenFile{Key: []byte("another key, not the one served.."), Tokens: f.Tokens}
+	tampered := func(s string) string {
+		// flip the last character of the signature
+		last := s[len(s)-1]
+		if last == 'A' {
+			return s[:len(s)-1] + "B"
+		}
+		return s[:len(s)-1] + "A"
+	}
+	unexpiring := f.Tokens[0]
+	unexpiring.Expires = time.Time{}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{name: "valid", token: f.sign(f.Tokens[0]), want: "alice"},
+		{name: "valid without expiry", token: f.sign(f.Tokens[1]), want: "bob"},
+		{name: "expired", token: f.sign(f.Tokens[2]), want: "token expired"},
+		{name: "revoked", token: f.sign(f.Tokens[3]), want: "token revoked"},
+		{name: "tampered signature", token: tampered(f.sign(f.Tokens[0])), want: "invalid token"},
+		{name: "expiry removed", token: f.sign(unexpiring), want: "invalid token"},
+		{name: "signed with another secret", token: other.sign(f.Tokens[0]), want: "invalid token"},
+		{name: "unknown token", token: f.sign(feedToken{ID: "e5"}), want: "invalid token"},
+		{name: "empty", token: "", want: "invalid token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			token, err := f.verify(tt.token, now)
+			if err != nil {
+				got = err.Error()
+			} else {
+				got = token.Name
+			}
+			if got != tt.want {
+				t.Errorf("verify(%q) = %q, want %q", tt.token, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedTokenCreate(t *testing.T) {
+	f := &feedTokenFile{}
+	created, signed, err := f.create("alice", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Key) == 0 {
+		t.Fatal("create didn't make a signing key")
+	}
+	if created.Expires.Sub(created.Created) != time.Hour {
+		t.Errorf("token valid from %s until %s, want an hour", created.Created, created.Expires)
+	}
+	token, err := f.verify(signed, created.Created)
+	if err != nil || token.Name != "alice" {
+		t.Errorf("verify of a created token = %v, %v, want alice", token, err)
+	}
+	if _, err := f.verify(signed, created.Expires.Add(time.Second)); err == nil {
+		t.Error("created token still valid after its ttl")
+	}
+
+	_, forever, err := f.create("bob", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Tokens[1].Expires.IsZero() {
+		t.Errorf("token created without ttl expires %s", f.Tokens[1].Expires)
+	}
+	if _, err := f.verify(forever, time.Now().AddDate(10, 0, 0)); err != nil {
+		t.Errorf("token created without ttl: %v", err)
+	}
+}
+
+func TestRequireFeedToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), feedTokensFile)
+	f := &feedTokenFile{}
+	_, alice, err := f.create("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.write(path); err != nil {
+		t.Fatal(err)
+	}
+	handler := requireFeedToken(&feedTokenChecker{path: path}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _ := r.Context().Value(feedConsumerKey{}).(string)
+		w.Write([]byte(name))
+	}))
+	// get requests the feed with token as ?token= or, if bearer, in the
+	// Authorization header, and returns the status and the consumer let in
+	get := func(token string, bearer bool) (int, string) {
+		r := httptest.NewRequest(http.MethodGet, "/calendar.ics", nil)
+		if bearer {
+			r.Header.Set("Authorization", "Bearer "+token)
+		} else if token != "" {
+			r.URL.RawQuery = "token=" + token
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			return w.Code, ""
+		}
+		return w.Code, w.Body.String()
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		bearer     bool
+		wantStatus int
+		want       string
+	}{
+		{name: "query token", token: alice, wantStatus: http.StatusOK, want: "alice"},
+		{name: "bearer token", token: alice, bearer: true, wantStatus: http.StatusOK, want: "alice"},
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "invalid query token", token: "a.0.b", wantStatus: http.StatusUnauthorized},
+		{name: "invalid bearer token", token: "a.0.b", bearer: true, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, name := get(tt.token, tt.bearer)
+			if status != tt.wantStatus || name != tt.want {
+				t.Errorf("got %d %q, want %d %q", status, name, tt.wantStatus, tt.want)
+			}
+		})
+	}
+
+	t.Run("query token wins over the header", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/calendar.ics?token="+alice, nil)
+		r.Header.Set("Authorization", "Bearer a.0.b")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("got %d, want %d", w.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("reloads when the file changes", func(t *testing.T) {
+		_, bob, err := f.create("bob", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Tokens[0].Revoked = true
+		if err := f.write(path); err != nil {
+			t.Fatal(err)
+		}
+		// the rewrite may land within the file system's mtime resolution
+		modified := time.Now().Add(time.Minute)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		if status, _ := get(alice, false); status != http.StatusUnauthorized {
+			t.Errorf("revoked token got %d, want %d", status, http.StatusUnauthorized)
+		}
+		if status, name := get(bob, false); status != http.StatusOK || name != "bob" {
+			t.Errorf("new token got %d %q, want %d %q", status, name, http.StatusOK, "bob")
+		}
+	})
+
+	t.Run("keeps the tokens while the file is unchanged", func(t *testing.T) {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// revoke every token without touching the modification time
+		if err := os.WriteFile(path, []byte(strings.ReplaceAll(string(data), `"name"`, `"revoked": true, "name"`)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+		if status, _ := get(f.sign(f.Tokens[1]), false); status != http.StatusOK {
+			t.Errorf("got %d, want the cached tokens to let bob in", status)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// outputGitHubActions is the --output value for GitHub Actions workflows.
+const outputGitHubActions = "gh-actions"
+
+// outputMode is the --output flag of commands with thresholds.
+type outputMode string
+
+func (m *outputMode) String() string { return string(*m) }
+
+func (m *outputMode) Set(s string) error {
+	if s != outputGitHubActions {
+		return fmt.Errorf("expected %s", outputGitHubActions)
+	}
+	*m = outputMode(s)
+	return nil
+}
+
+// writeGitHubActions reports the coverage of ds to a GitHub Actions job: a
+// Markdown table of the days someone is out goes to the job summary, the
+// lowest coverage (in percent) and the number of days with a conflict are
+// set as the coverage and conflicts step outputs, and each violation is
+// printed to w as a warning annotation.
+func writeGitHubActions(w io.Writer, ds *ooo.Dataset, days []coverageDay, violations []string) error {
+	lowest, conflicts := 1.0, 0
+	for _, d := range days {
+		lowest = min(lowest, d.coverage)
+		if len(d.out) > 1 {
+			conflicts++
+		}
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "## Absences in %s\n\n", ds.Group)
+	fmt.Fprintf(&summary, "%s to %s: lowest coverage %.0f%%, %d day(s) with more than one person out.\n\n",
+		ds.TimeMin.Format("Jan 2"), ds.TimeMax.Format("Jan 2 2006"), lowest*100, conflicts)
+	if noData := ds.WithoutData(); len(noData) > 0 {
+		fmt.Fprintf(&summary, "No data for %s.\n\n", strings.Join(noData, ", "))
+	}
+	if len(days) > 0 {
+		summary.WriteString("| Day | Out | Coverage |\n|---|---|---:|\n")
+		for _, d := range days {
+			fmt.Fprintf(&summary, "| %s | %s | %.0f%% |\n", d.day, strings.Join(d.out, ", "), d.coverage*100)
+		}
+		summary.WriteString("\n")
+	}
+	if len(violations) > 0 {
+		summary.WriteString("### Violations\n\n")
+		for _, v := range violations {
+			fmt.Fprintf(&summary, "- %s\n", v)
+		}
+		summary.WriteString("\n")
+	}
+	if err := appendToEnvFile("GITHUB_STEP_SUMMARY", summary.String()); err != nil {
+		return err
+	}
+	outputs := fmt.Sprintf("coverage=%.0f\nconflicts=%d\n", lowest*100, conflicts)
+	if err := appendToEnvFile("GITHUB_OUTPUT", outputs); err != nil {
+		return err
+	}
+
+	for _, v := range violations {
+		fmt.Fprintf(w, "::warning title=Staffing::%s\n", escapeAnnotation(v))
+	}
+	return nil
+}
+
+// appendToEnvFile appends text to the file named by the environment
+// variable, which GitHub Actions sets for every step. Outside of a workflow
+// it does nothing.
+func appendToEnvFile(name, text string) error {
+	path := os.Getenv(name)
+	if path == "" {
+		logFor("cli").Warn("not running in GitHub Actions, skipping", "variable", name)
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", name, err)
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write %s: %v", name, err)
+	}
+	return f.Close()
+}
+
+// escapeAnnotation escapes a workflow command's message.
+func escapeAnnotation(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/cloudidentity/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// memberGroup is a Google Group the signed-in user belongs to.
+type memberGroup struct {
+	Email string
+	Name  string
+}
+
+// userGroups lists the groups email belongs to, directly or through other
+// groups, using the Cloud Identity API.
+func userGroups(ctx context.Context, srv *cloudidentity.Service, email string) ([]memberGroup, error) {
+	query := fmt.Sprintf("member_key_id == '%s' && 'cloudidentity.googleapis.com/groups.discussion_forum' in labels", email)
+	var groups []memberGroup
+	err := srv.Groups.Memberships.SearchTransitiveGroups("groups/-").Query(query).Context(ctx).
+		Pages(ctx, func(resp *cloudidentity.SearchTransitiveGroupsResponse) error {
+			for _, m := range resp.Memberships {
+				if m.GroupKey == nil || m.GroupKey.Id == "" {
+					continue
+				}
+				groups = append(groups, memberGroup{Email: m.GroupKey.Id, Name: m.DisplayName})
+			}
+			return nil
+		})
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+			return nil, fmt.Errorf("unable to list your groups: %v\nThe token may predate the groups permission; run 'ooo-view auth logout' and 'ooo-view auth login' to grant it", err)
+		}
+		return nil, fmt.Errorf("unable to list your groups: %v", err)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Email < groups[j].Email })
+	return groups, nil
+}
+
+// pickGroup lists the groups the user belongs to and the configured aliases,
+// and saves the one picked as default_group in the config file.
+func pickGroup(ctx context.Context, s *session, p *prompter) error {
+	provider, err := s.googleProvider(ctx)
+	if err != nil {
+		return err
+	}
+	calendars, err := provider.Calendars(ctx)
+	if err != nil {
+		return err
+	}
+	// The primary calendar's ID is the user's email
+	email := ""
+	for _, entry := range calendars {
+		if entry.Primary {
+			email = entry.Id
+		}
+	}
+	if email == "" {
+		return fmt.Errorf("unable to find your primary calendar")
+	}
+
+	srv, err := s.cloudIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	groups, err := userGroups(ctx, srv, email)
+	if err != nil {
+		return err
+	}
+
+	fc, err := readConfigFile(s.cfg.ConfigPath, false)
+	if err != nil {
+		return err
+	}
+	var values, options []string
+	for _, g := range groups {
+		values = append(values, g.Email)
+		if g.Name != "" && !strings.EqualFold(g.Name, g.Email) {
+			options = append(options, fmt.Sprintf("%s (%s)", g.Email, g.Name))
+		} else {
+			options = append(options, g.Email)
+		}
+	}
+	aliases := make([]string, 0, len(fc.Groups))
+	for alias := range fc.Groups {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		values = append(values, alias)
+		options = append(options, fmt.Sprintf("%s (alias for %s)", alias, fc.Groups[alias]))
+	}
+	if len(values) == 0 {
+		fmt.Fprintf(p.out, "%s isn't a member of any group and no aliases are configured.\n", email)
+		return nil
+	}
+
+	def := len(values) - 1
+	for i, value := range values {
+		if value == s.cfg.DefaultGroup {
+			def = i
+		}
+	}
+	choice, err := p.choose(ctx, "Which group should be shown when none is given?", options, def)
+	if err != nil {
+		return err
+	}
+	fc.DefaultGroup = &values[choice]
+	if err := writeConfigFile(s.cfg.ConfigPath, fc); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Default group set to %s in %s.\n", values[choice], s.cfg.ConfigPath)
+	return nil
+}
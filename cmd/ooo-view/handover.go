@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// handoverConfig is the handovers section of the config file, for the
+// invites planned when coverage drops below --fail-if-coverage-below or
+// --alert-coverage-below.
+type handoverConfig struct {
+	// Backups maps people to who covers for them; without one, the
+	// colleague on their team in the most during the leave covers
+	Backups map[string]string `yaml:"backups"`
+	// Time is when handovers start on the last day before a leave, like
+	// 16:00, and Length how long they last
+	Time   string   `yaml:"time"`
+	Length duration `yaml:"length"`
+}
+
+// validateHandoverConfig checks the time, the length and the backups'
+// addresses.
+func validateHandoverConfig(c handoverConfig) error {
+	if c.Time != "" {
+		if _, err := time.Parse("15:04", c.Time); err != nil {
+			return fmt.Errorf("invalid time '%s', expected a time like 16:00", c.Time)
+		}
+	}
+	if c.Length < 0 || time.Duration(c.Length) > 8*time.Hour {
+		return fmt.Errorf("the length must be between 0 and 8h")
+	}
+	for person, backup := range c.Backups {
+		if !strings.Contains(person, "@") || !strings.Contains(backup, "@") {
+			return fmt.Errorf("backups must map email addresses, not '%s: %s'", person, backup)
+		}
+	}
+	return nil
+}
+
+// start returns the handover's start on day.
+func (c handoverConfig) start(day time.Time) time.Time {
+	at, err := time.Parse("15:04", c.Time)
+	if err != nil {
+		at = time.Date(0, 1, 1, 16, 0, 0, 0, time.UTC)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, day.Location())
+}
+
+func (c handoverConfig) length() time.Duration {
+	if c.Length == 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(c.Length)
+}
+
+// handoverLookback is how many days before a leave a handover is looked
+// for.
+const handoverLookback = 14
+
+// planHandovers returns a handover for every leave of the people out on
+// the gap days: with their backup, or else the colleague on their team in
+// the roster, or in the group, who is in on most of the days they're out.
+// It's set on the last day before the leave that both are in, if that is
+// still to come.
+func planHandovers(ds *ooo.Dataset, loc *time.Location, gaps []coverageDay, c handoverConfig, now time.Time) []render.Handover {
+	out := ooo.AbsentDays(ds, loc)
+	away := func(person string, day time.Time) bool {
+		if _, ok := ds.Holiday(day); ok {
+			return true
+		}
+		return out[person][day.Format("2006-01-02")] || !ds.WorkWeek(person).Works(day.Weekday())
+	}
+	firstDay := time.Date(ds.TimeMin.Year(), ds.TimeMin.Month(), ds.TimeMin.Day(), 0, 0, 0, 0, loc)
+	lastDay := time.Date(ds.TimeMax.Year(), ds.TimeMax.Month(), ds.TimeMax.Day(), 0, 0, 0, 0, loc)
+
+	planned := make(map[string]bool)
+	var handovers []render.Handover
+	for _, gap := range gaps {
+		day, _ := time.ParseInLocation("2006-01-02", gap.day, loc)
+		for _, person := range gap.out {
+			// The leave runs over the days off and holidays around the gap
+			first, last := day, day
+			for d := day.AddDate(0, 0, -1); !d.Before(firstDay) && away(person, d); d = d.AddDate(0, 0, -1) {
+				if out[person][d.Format("2006-01-02")] {
+					first = d
+				}
+			}
+			for d := day.AddDate(0, 0, 1); !d.After(lastDay) && away(person, d); d = d.AddDate(0, 0, 1) {
+				if out[person][d.Format("2006-01-02")] {
+					last = d
+				}
+			}
+			key := person + "/" + first.Format("2006-01-02")
+			if planned[key] {
+				continue
+			}
+			planned[key] = true
+
+			backup := c.Backups[person]
+			if backup == "" {
+				backup = coveringColleague(ds, person, first, last, away)
+			}
+			if backup == "" {
+				logFor("handovers").Warn("nobody is in to hand over to", "person", person, "leave", first.Format("2006-01-02"))
+				continue
+			}
+			var start time.Time
+			for i, d := 1, first.AddDate(0, 0, -1); i <= handoverLookback; i, d = i+1, d.AddDate(0, 0, -1) {
+				if away(person, d) || away(backup, d) {
+					continue
+				}
+				start = c.start(d)
+				break
+			}
+			if start.IsZero() || start.Before(now) {
+				logFor("handovers").Info("too late for a handover", "person", person, "leave", first.Format("2006-01-02"))
+				continue
+			}
+			handovers = append(handovers, render.Handover{
+				From:       person,
+				To:         backup,
+				FromName:   personName(ds, person),
+				ToName:     personName(ds, backup),
+				Start:      start,
+				End:        start.Add(c.length()),
+				LeaveFirst: first,
+				LeaveLast:  last,
+				Reason:     fmt.Sprintf("Coverage drops to %.0f%% on %s.", gap.coverage*100, render.Day(day)),
+			})
+		}
+	}
+	return handovers
+}
+
+// coveringColleague returns the member on person's team in the roster, or
+// in the whole group, who is in on the most of the days person works from
+// first to last, or "" if nobody is in on any.
+func coveringColleague(ds *ooo.Dataset, person string, first, last time.Time, away func(string, time.Time) bool) string {
+	candidates := ds.Members
+	if entry, ok := ds.Roster[person]; ok && entry.Team != "" {
+		if team := ds.Subset(ds.Roster.Team(entry.Team)); len(team.Members) > 1 {
+			candidates = team.Members
+		}
+	}
+	best, bestDays := "", 0
+	for _, candidate := range candidates {
+		if candidate == person {
+			continue
+		}
+		days := 0
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			if ds.WorkWeek(person).Works(d.Weekday()) && !away(candidate, d) {
+				days++
+			}
+		}
+		// Members are sorted, so ties go to the first
+		if days > bestDays {
+			best, bestDays = candidate, days
+		}
+	}
+	return best
+}
+
+// handoverFileName returns the file name of h's invite.
+func handoverFileName(h render.Handover) string {
+	return "handover-" + unsafeFileChars.ReplaceAllString(h.From, "_") + "-" + h.LeaveFirst.Format("2006-01-02") + ".ics"
+}
+
+// writeHandoverInvites writes an invite for each handover to dir,
+// replacing those of the same leaves.
+func writeHandoverInvites(dir string, handovers []render.Handover) error {
+	if len(handovers) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create invite directory: %v", err)
+	}
+	for _, h := range handovers {
+		var buf bytes.Buffer
+		if err := render.HandoverInvite(&buf, h); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, handoverFileName(h))
+		// Invites name people and their leave
+		if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+			return fmt.Errorf("unable to write invite: %v", err)
+		}
+		logFor("handovers").Info("handover invite written", "path", path, "summary", h.Summary())
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// newHTTPClient builds the single HTTP client shared by the OAuth flow and
+// the Calendar service. Idle connections are kept per host for every worker
+// so parallel fetches reuse connections instead of re-dialing. Requests are
+// traced when telemetry is configured.
+func newHTTPClient(cfg Config) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+		DisableKeepAlives:     !cfg.KeepAlive,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.Concurrency,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if !cfg.HTTP2 {
+		// A non-nil empty map disables the transport's automatic HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &http.Client{
+		Transport: otelhttp.NewTransport(transport),
+		Timeout:   cfg.HTTPTimeout,
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// releaseDownloadURL is where the assets of the GitHub releases are
+// downloaded from.
+const releaseDownloadURL = "https://github.com/klaasmeinke/ooo-view/releases"
+
+// releasePlatforms are the platforms each release carries a binary for.
+var releasePlatforms = []string{
+	"darwin/amd64",
+	"darwin/arm64",
+	"linux/amd64",
+	"linux/arm64",
+	"windows/amd64",
+	"windows/arm64",
+}
+
+//go:embed install/*.tmpl
+var installScripts embed.FS
+
+var installTemplates = template.Must(template.ParseFS(installScripts, "install/*.tmpl"))
+
+// installScript is what the install scripts are filled in with.
+type installScript struct {
+	Version string
+	OS      string
+	Arch    string
+	URL     string
+	Asset   string
+}
+
+// pseudoVersion matches the versions Go gives untagged commits, e.g.
+// v0.0.0-20250102150405-abcdef123456.
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}$`)
+
+// isReleaseVersion reports whether v is the tag of a release rather than
+// that of a development or modified build.
+func isReleaseVersion(v string) bool {
+	_, ok := parseVersion(v)
+	return ok && !strings.Contains(v, "+") && !pseudoVersion.MatchString(v)
+}
+
+// writeInstallScript writes a script that installs, or upgrades to, the
+// running version of ooo-view on platform, a shell script or a PowerShell
+// one for Windows. Development builds install the latest release instead.
+func writeInstallScript(w io.Writer, platform string) error {
+	if platform == "" {
+		platform = runtime.GOOS + "/" + runtime.GOARCH
+	}
+	supported := false
+	for _, p := range releasePlatforms {
+		supported = supported || p == platform
+	}
+	if !supported {
+		return usageErrorf("no release binary for '%s', expected one of %s", platform, strings.Join(releasePlatforms, ", "))
+	}
+	goos, arch, _ := strings.Cut(platform, "/")
+
+	script := installScript{Version: currentVersion().Version, OS: goos, Arch: arch}
+	script.Asset = fmt.Sprintf("%s_%s_%s", serviceName, goos, arch)
+	if isReleaseVersion(script.Version) {
+		script.URL = releaseDownloadURL + "/download/" + script.Version
+	} else {
+		script.Version = "(latest)"
+		script.URL = releaseDownloadURL + "/latest/download"
+	}
+	name := "install.sh.tmpl"
+	if goos == "windows" {
+		script.Asset += ".exe"
+		name = "install.ps1.tmpl"
+	}
+	return installTemplates.ExecuteTemplate(w, name, script)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lastRun is the previous query, replayed by a bare `ooo-view`.
+type lastRun struct {
+	Args []string  `json:"args"` // command name, flags and group as typed
+	At   time.Time `json:"at"`
+}
+
+func lastRunPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "last-run.json"), nil
+}
+
+// loadLastRun returns the arguments of the previous remembered run, or nil
+// if there is none.
+func loadLastRun() []string {
+	path, err := lastRunPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var run lastRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		logFor("cli").Debug("ignoring unreadable last run", "path", path, "error", err)
+		return nil
+	}
+	return run.Args
+}
+
+// saveLastRun records args for the next bare `ooo-view`.
+func saveLastRun(args []string) error {
+	path, err := lastRunPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(lastRun{Args: args, At: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal last run: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create data directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to save last run: %v", err)
+	}
+	return nil
+}
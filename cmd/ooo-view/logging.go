@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// logLevel is the minimum level of the default logger. Diagnostics go to
+// stderr through slog; the command's own output (the grid, exports, reports)
+// is written to stdout and never filtered.
+var logLevel = new(slog.LevelVar)
+
+// setupLogging installs the default logger writing to stderr. The text format
+// leaves out timestamps for interactive use; the json format keeps them for
+// log collectors.
+func setupLogging(format string) error {
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: logLevel,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level: logLevel,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				// Durations as seconds rather than nanoseconds
+				if a.Value.Kind() == slog.KindDuration {
+					return slog.Float64(a.Key, a.Value.Duration().Seconds())
+				}
+				return a
+			},
+		})
+	default:
+		return fmt.Errorf("unknown log format '%s', expected text or json", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// logFor returns the default logger tagged with the part of the program that
+// logs, e.g. "api", "store" or "serve".
+func logFor(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+// setLogLevel applies --quiet and --verbose: quiet leaves only errors,
+// verbose adds per-person progress and API details.
+func setLogLevel(cfg Config) {
+	switch {
+	case cfg.Quiet:
+		logLevel.Set(slog.LevelError)
+	case cfg.Verbose:
+		logLevel.Set(slog.LevelDebug)
+	default:
+		logLevel.Set(slog.LevelInfo)
+	}
+}
+
+// exitInterrupted is the exit status after Ctrl+C or SIGTERM, following the
+// shell's 128+SIGINT convention.
+const exitInterrupted = 130
+
+// exitWithError logs err, with a hint for the failures the user can fix,
+// and exits with status 1, or exitThreshold when a --fail-* condition was
+// violated.
+func exitWithError(err error) {
+	if errors.Is(err, context.Canceled) {
+		slog.Info("interrupted")
+		os.Exit(exitInterrupted)
+	}
+	slog.Error("command failed", "error", err)
+	switch {
+	case errors.Is(err, ooo.ErrAuthExpired):
+		fmt.Fprintln(os.Stderr, "Run 'ooo-view auth logout' and 'ooo-view auth login' to sign in again.")
+	case errors.Is(err, ooo.ErrNoAccess):
+		fmt.Fprintln(os.Stderr, "Check that the calendars are shared with you, or run 'ooo-view doctor'. If you can only see free/busy information, try --freebusy-only.")
+	case errors.Is(err, ooo.ErrGroupNotFound):
+		fmt.Fprintln(os.Stderr, "Run 'ooo-view groups' to pick from the groups you belong to.")
+	}
+	var thresholdErr *thresholdError
+	if errors.As(err, &thresholdErr) {
+		os.Exit(exitThreshold)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/klaasmeinke/ooo-view/pkg/auth"
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+const serviceName = "ooo-view"
+
+type Config struct {
+	WeeksAhead     int
+	MinDuration    time.Duration
+	TimeZone       string
+	Concurrency    int
+	RequestTimeout time.Duration
+	CacheTTL       time.Duration
+	NoCache        bool
+	Refresh        bool
+	Offline        bool
+	HTTPTimeout    time.Duration
+	KeepAlive      bool
+	HTTP2          bool
+	NoBrowser      bool
+	NoKeyring      bool
+	NoProgress     bool
+	NoRemember     bool
+	DryRun         bool
+	TokenFile      string
+	Provider       string
+	Quiet          bool
+	Verbose        bool
+	LogFormat      string
+	Timings        bool
+	PprofAddr      string
+	GroupAliases   map[string]string
+	DefaultGroup   string
+	ConfigPath     string
+	// reload reads the config file again, keeping the flags and
+	// environment this config was made from
+	reload func() (Config, error)
+
+	// DateStyle is us, eu or iso, and Clock 24h or 12h; ShowTimeZones
+	// adds the zone to times
+	DateStyle     string
+	Clock         string
+	ShowTimeZones bool
+
+	// SecondaryCalendars is a comma-separated list of calendar names
+	SecondaryCalendars string
+	// ResponseStatus is a comma-separated list of the responses whose
+	// events are shown
+	ResponseStatus string
+	// FetchWorkWeeks asks the provider for the days each member works, and
+	// WorkWeeks sets them per person
+	FetchWorkWeeks bool
+	WorkWeeks      map[string]ooo.WorkWeek
+	// FreebusyOnly infers absences from busy blocks, with a token that can't
+	// read events
+	FreebusyOnly bool
+	// NoFreebusyFallback fails the calendars whose events can't be read
+	// instead of reading their free/busy
+	NoFreebusyFallback bool
+	// RedactSummaries removes event titles from every output
+	RedactSummaries bool
+	// TokenMaxIdleDays deletes the stored token after that many days
+	// without use; 0 keeps it
+	TokenMaxIdleDays int
+	// AuditLog is the file every API request, calendar read and export is
+	// recorded in
+	AuditLog string
+	// FetchDeadline gives up on the calendars not fetched in time; 0 waits
+	// for all of them
+	FetchDeadline time.Duration
+	// Batch sends the event requests of concurrent fetches together
+	Batch bool
+	// Airtable is the table the airtable command mirrors absences into
+	Airtable airtableConfig
+	// Payroll shapes the CSV of report payroll
+	Payroll payrollConfig
+	// Handovers plans the handover invites of coverage gaps
+	Handovers handoverConfig
+	// Schedule lists the digests and exports serve runs
+	Schedule []scheduleEntry
+	// Range names the window to show instead of WeeksAhead, one of the
+	// built-in ranges or of Ranges
+	Range  string
+	Ranges map[string]rangeConfig
+	// SheetsAccess also asks for access to Google Sheets when signing in,
+	// and Sheets is the spreadsheet the sheets command keeps up to date
+	SheetsAccess bool
+	Sheets       sheetsConfig
+	// ServiceAccount is the JSON key file of a service account to sign in
+	// as instead of the user, acting as Impersonate if set
+	ServiceAccount string
+	Impersonate    string
+	// Remote is the URL of an ooo-view proxy to fetch from instead of
+	// Google, sending RemoteToken if set
+	Remote      string
+	RemoteToken string
+
+	// DayBoundary is dayBoundaryShared or dayBoundaryPerPerson
+	DayBoundary string
+	// Types is a comma-separated list of the kinds of absences to show, by
+	// their short names; empty shows all
+	Types string
+
+	// Identities maps the other addresses of people to the one they're
+	// shown as
+	Identities ooo.Identities
+
+	// GroupBy is empty or one of the groupBy values; groupByLocation reads
+	// the offices from the Directory with DirectoryAccess
+	GroupBy         string
+	DirectoryAccess bool
+	// Regions maps offices to the region they're part of
+	Regions map[string]string
+
+	// Assume adds hypothetical absences to every dataset
+	Assume assumeFlag
+
+	// HolidayCalendars is a comma-separated list of the IDs of public
+	// holiday calendars, and Holidays holds more holidays by date
+	HolidayCalendars string
+	Holidays         map[string]string
+
+	// RosterPath is the roster.yaml describing teams, roles and managers,
+	// read into Roster
+	RosterPath string
+	Roster     ooo.Roster
+
+	// Manager limits the people shown to those reporting to this manager,
+	// in the roster or else the Directory: directly with DirectReports, or
+	// through other managers
+	Manager       string
+	DirectReports bool
+
+	// Milestones is a YAML file of dates, like releases and freezes, to
+	// overlay on the absences
+	Milestones string
+
+	// Plugins limits the requests to provider plugins, by name
+	Plugins map[string]pluginConfig
+
+	// Reconcile is the policy for absences several providers report with
+	// different dates: flag, longest or prefer:NAME
+	Reconcile string
+}
+
+// The --clock values.
+const (
+	clock24h = "24h"
+	clock12h = "12h"
+)
+
+// The --day-boundary values: days are bucketed in --timezone for everyone,
+// or in each member's own time zone.
+const (
+	dayBoundaryShared    = "shared"
+	dayBoundaryPerPerson = "per-person"
+)
+
+// The --group-by values: members are shown by office, or by their team or
+// manager in the roster.
+const (
+	groupByLocation = "location"
+	groupByTeam     = "team"
+	groupByManager  = "manager"
+)
+
+// getConfig returns the OAuth client config, asking for the client secret if
+// none is stored yet. With --freebusy-only it only requests the free/busy
+// scope, and with cfg.SheetsAccess access to spreadsheets too, and with
+// cfg.DirectoryAccess to the Directory.
+func getConfig(ctx context.Context, creds *auth.CredentialStore, cfg Config) (*oauth2.Config, error) {
+	// Try to get the stored client secret
+	clientSecret, err := creds.Get(auth.ClientSecretKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "First time setup. No Google OAuth client secret is stored yet.")
+		if clientSecret, err = promptClientSecret(ctx, newPrompter(), creds); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(os.Stderr, "Run 'ooo-view setup' later to choose a default group and other defaults.")
+	}
+	return auth.ParseClientSecret(clientSecret, scopes(cfg)...)
+}
+
+// scopes returns the OAuth scopes to request for cfg.
+func scopes(cfg Config) []string {
+	scopes := auth.Scopes
+	if cfg.FreebusyOnly {
+		scopes = auth.FreebusyScopes
+	}
+	if cfg.SheetsAccess {
+		scopes = append(slices.Clone(scopes), auth.SheetsScope)
+	}
+	if cfg.DirectoryAccess {
+		scopes = append(slices.Clone(scopes), auth.DirectoryScope)
+	}
+	return scopes
+}
+
+// getToken returns the stored token or signs in, printing the URL to stderr
+// with --no-browser.
+func getToken(ctx context.Context, config *oauth2.Config, creds *auth.CredentialStore, cfg Config) (*oauth2.Token, error) {
+	return auth.Token(ctx, config, creds, auth.TokenOptions{
+		NoBrowser: cfg.NoBrowser,
+		Out:       os.Stderr,
+		ReadLine:  readLine,
+		MaxIdle:   time.Duration(cfg.TokenMaxIdleDays) * 24 * time.Hour,
+	})
+}
+
+// newCredentialStore returns the credential store selected by --no-keyring
+// and --token-file.
+func newCredentialStore(cfg Config) (*auth.CredentialStore, error) {
+	c := &auth.CredentialStore{NoKeyring: cfg.NoKeyring, TokenFile: expandHome(cfg.TokenFile)}
+	if c.NoKeyring {
+		dir, err := configDir()
+		if err != nil {
+			return nil, err
+		}
+		c.Dir = dir
+	}
+	return c, nil
+}
+
+// openEventStore opens the event store in the cache directory.
+func openEventStore(ttl time.Duration, refresh bool) (*ooo.Store, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return ooo.OpenStore(filepath.Join(dir, "events.db"), ttl, refresh)
+}
+
+// fetchGroupDataset resolves the group's members and fetches their OOO
+// events into a dataset, converting each calendar's events as they arrive.
+// With a *ooo.PartialError or *ooo.UnavailableError, the dataset of the
+// calendars fetched comes with it.
+func fetchGroupDataset(ctx context.Context, p ooo.Provider, groupEmail string, timeMin, timeMax time.Time, loc *time.Location, cfg Config, timings *runTimings, audit *auditLog) (*ooo.Dataset, error) {
+	// A proxy's events are looked up in its response for this group
+	if remote, ok := p.(*remoteProvider); ok {
+		p = remote.forGroup(groupEmail)
+	}
+	// Get free/busy information
+	stop := timings.Phase("freebusy")
+	people, ok := cfg.Roster.Group(groupEmail)
+	var err error
+	if !ok {
+		people, err = p.Members(ctx, groupEmail, timeMin, timeMax, cfg.TimeZone)
+	}
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	// The other addresses of the members are fetched too, to be merged
+	// into them once stored
+	people = cfg.Identities.Expand(people)
+	return fetchPeopleDataset(ctx, p, groupEmail, people, timeMin, timeMax, loc, cfg, timings, audit)
+}
+
+// fetchPeopleDataset fetches the OOO events of people into a dataset for
+// the group, like fetchGroupDataset.
+func fetchPeopleDataset(ctx context.Context, p ooo.Provider, groupEmail string, people []string, timeMin, timeMax time.Time, loc *time.Location, cfg Config, timings *runTimings, audit *auditLog) (*ooo.Dataset, error) {
+	defer timings.Phase("event fetch")()
+	prog := startProgress(cfg, "fetched calendars", len(people))
+	defer prog.Stop()
+	stream := ooo.StreamEvents(ctx, p, people, timeMin, timeMax, ooo.FetchOptions{
+		Concurrency:    cfg.Concurrency,
+		RequestTimeout: cfg.RequestTimeout,
+		Deadline:       cfg.FetchDeadline,
+		MinDuration:    cfg.MinDuration,
+		TimeZone:       cfg.TimeZone,
+		OnFetch: func(person string, took time.Duration, err error) {
+			timings.Fetch(person, took)
+			prog.Inc()
+			audit.Record(auditRecord{Event: "calendar_read", Calendar: person, Group: groupEmail, Source: sourceName(cfg), Error: errorString(err)})
+		},
+	})
+	ds, err := ooo.NewDatasetFromStream(groupEmail, timeMin, timeMax, stream, loc)
+	if ds != nil {
+		ds.SetSource(ooo.SourceOf(p))
+	}
+	return ds, err
+}
+
+// runDBCommand implements the `db vacuum|stats` maintenance commands for the
+// local event store.
+func runDBCommand(args []string, cfg Config) error {
+	if len(args) != 1 || (args[0] != "vacuum" && args[0] != "stats") {
+		return usageErrorf("expected 'vacuum' or 'stats'")
+	}
+
+	store, err := openEventStore(cfg.CacheTTL, false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if args[0] == "vacuum" {
+		if err := store.Vacuum(); err != nil {
+			return err
+		}
+		fmt.Println("Event store vacuumed.")
+	}
+
+	stats, err := store.Stats()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Path:          %s\n", stats.Path)
+	fmt.Printf("Size:          %.1f KiB\n", float64(stats.SizeBytes)/1024)
+	fmt.Printf("People:        %d\n", stats.People)
+	fmt.Printf("Events:        %d\n", stats.Events)
+	fmt.Printf("Fetches:       %d\n", stats.Fetches)
+	fmt.Printf("Groups:        %d\n", stats.Groups)
+	fmt.Printf("Changes:       %d\n", stats.Changes)
+	if !stats.OldestFetch.IsZero() {
+		fmt.Printf("Oldest fetch:  %s\n", stats.OldestFetch.Local().Format("Mon Jan 2 15:04"))
+		fmt.Printf("Newest fetch:  %s\n", stats.NewestFetch.Local().Format("Mon Jan 2 15:04"))
+	}
+	return nil
+}
+
+// runGroupsCommand implements `groups list`, showing the group aliases
+// defined in the config file.
+func runGroupsCommand(args []string, cfg Config) error {
+	if len(args) != 1 || args[0] != "list" {
+		return usageErrorf("expected no arguments or 'list'")
+	}
+
+	if len(cfg.GroupAliases) == 0 {
+		fmt.Println("No group aliases defined. Add a 'groups' section to your config file.")
+		return nil
+	}
+
+	aliases := make([]string, 0, len(cfg.GroupAliases))
+	for alias := range cfg.GroupAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		fmt.Printf("%-20s %s\n", alias, cfg.GroupAliases[alias])
+	}
+	return nil
+}
+
+func main() {
+	setupLogging("text")
+
+	args := os.Args[1:]
+	// A bare `ooo-view` repeats the last remembered query
+	replay := len(args) == 0
+	if replay {
+		args = loadLastRun()
+	}
+	cmd := findCommand(defaultCommand)
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			if len(args) > 1 {
+				if cmd := findCommand(args[1]); cmd != nil {
+					parseCommandFlags(cmd, []string{"-h"})
+				}
+			}
+			printUsage(os.Stdout)
+			return
+		}
+		if named := findCommand(args[0]); named != nil {
+			cmd, args = named, args[1:]
+		}
+	}
+	typed := append([]string{cmd.name}, args...)
+	cfg, run, fs, args := parseCommandFlags(cmd, args)
+	if replay && len(typed) == 1 && cfg.DefaultGroup == "" {
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := setupLogging(cfg.LogFormat); err != nil {
+		exitWithError(err)
+	}
+	setLogLevel(cfg)
+	if replay && len(typed) > 1 {
+		logFor("cli").Info("repeating the last query", "command", strings.Join(typed, " "))
+	}
+
+	err := execute(cmd, cfg, run, args)
+	var thresholdErr *thresholdError
+	if cmd.remember && !cfg.NoRemember && (err == nil || errors.As(err, &thresholdErr)) {
+		if err := saveLastRun(typed); err != nil {
+			logFor("cli").Warn("unable to remember this query", "error", err)
+		}
+	}
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
+// execute runs the command with signal handling, profiling and telemetry set
+// up around it.
+func execute(cmd *command, cfg Config, run runFunc, args []string) error {
+	// Create a context that can be cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle Ctrl+C
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		logFor("cli").Info("shutting down gracefully")
+		cancel()
+	}()
+
+	if cfg.PprofAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(cfg.PprofAddr, nil); err != nil {
+				logFor("cli").Warn("pprof server stopped", "error", err)
+			}
+		}()
+	}
+
+	shutdownTelemetry, err := setupTelemetry(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to set up telemetry: %v", err)
+	}
+	defer shutdownTelemetry(context.Background())
+
+	ctx, span := tracer.Start(ctx, serviceName+" "+cmd.name)
+
+	s := &session{cfg: cfg}
+	defer s.Close()
+	if cfg.AuditLog != "" {
+		if s.audit, err = openAuditLog(cfg.AuditLog, cmd.name); err != nil {
+			endSpan(span, err)
+			return err
+		}
+	}
+	if cfg.Timings {
+		s.timings = &runTimings{}
+		defer s.timings.Print(os.Stderr)
+	}
+
+	err = run(ctx, s, args)
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	// Partial results were shown after an interruption; still exit as
+	// interrupted
+	if err == nil && s.partial {
+		err = ctx.Err()
+	}
+	endSpan(span, err)
+	return err
+}
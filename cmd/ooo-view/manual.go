@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// manualAbsence is an absence submitted to POST /api/v1/absences by another
+// system, like an HR tool or a bot. Start and End are both dates, like
+// 2025-09-01, for an all-day absence including End, or both RFC 3339 times
+// for a timed one.
+type manualAbsence struct {
+	// ID is the submitter's ID of the absence; submitting it again replaces
+	// it. It's made up if not given.
+	ID      string `json:"id"`
+	Person  string `json:"person"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Summary string `json:"summary,omitempty"`
+	// Kind is ooo (the default) or busy
+	Kind     string    `json:"kind,omitempty"`
+	Received time.Time `json:"received"`
+}
+
+// maxManualDays is the longest a submitted absence may last, to catch
+// mistyped years.
+const maxManualDays = 366
+
+// absence returns m as an absence, with dates placed in loc.
+func (m manualAbsence) absence(loc *time.Location) (ooo.Absence, error) {
+	kind := ooo.KindOutOfOffice
+	if m.Kind != "" {
+		var err error
+		if kind, err = ooo.ParseKind(m.Kind); err != nil {
+			return ooo.Absence{}, err
+		}
+	}
+	a := ooo.Absence{Person: m.Person, Kind: kind, Summary: m.Summary, Source: ooo.SourceManual, Ref: m.ID}
+
+	first, firstErr := time.ParseInLocation("2006-01-02", m.Start, loc)
+	last, lastErr := time.ParseInLocation("2006-01-02", m.End, loc)
+	if firstErr == nil && lastErr == nil {
+		if last.Before(first) {
+			return ooo.Absence{}, fmt.Errorf("end %s is before start %s", m.End, m.Start)
+		}
+		a.Start, a.End, a.AllDay = first, last.AddDate(0, 0, 1), true
+	} else {
+		start, startErr := time.Parse(time.RFC3339, m.Start)
+		end, endErr := time.Parse(time.RFC3339, m.End)
+		if startErr != nil || endErr != nil {
+			return ooo.Absence{}, fmt.Errorf("start and end must both be dates like 2025-09-01 or both times like 2025-09-01T09:00:00Z")
+		}
+		if !end.After(start) {
+			return ooo.Absence{}, fmt.Errorf("end %s isn't after start %s", m.End, m.Start)
+		}
+		a.Start, a.End = start.In(loc), end.In(loc)
+	}
+	if a.End.Sub(a.Start) > maxManualDays*24*time.Hour+time.Hour {
+		return ooo.Absence{}, fmt.Errorf("absences can last at most %d days", maxManualDays)
+	}
+	return a, nil
+}
+
+// validate checks a submitted absence, of one of members. Its person is
+// expected in canonical form.
+func (m manualAbsence) validate(members []string, loc *time.Location) error {
+	if len(m.ID) > 128 || strings.ContainsAny(m.ID, "/?# \t\n") {
+		return fmt.Errorf("the id must be at most 128 characters, without spaces, /, ? or #")
+	}
+	if !strings.Contains(m.Person, "@") {
+		return fmt.Errorf("person must be an email address")
+	}
+	if !slices.Contains(members, m.Person) {
+		return fmt.Errorf("%s isn't a member of the group", m.Person)
+	}
+	if utf8.RuneCountInString(m.Summary) > 500 {
+		return fmt.Errorf("the summary must be at most 500 characters")
+	}
+	_, err := m.absence(loc)
+	return err
+}
+
+// manualAbsencesPath returns the file of the absences submitted for group.
+func manualAbsencesPath(group string) (string, error) {
+	data, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "manual", unsafeFileChars.ReplaceAllString(group, "_")+".json"), nil
+}
+
+// readManualAbsences reads the absences submitted for group, or none if
+// there's no file yet.
+func readManualAbsences(group string) ([]manualAbsence, error) {
+	path, err := manualAbsencesPath(group)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read submitted absences: %v", err)
+	}
+	var absences []manualAbsence
+	if err := json.Unmarshal(data, &absences); err != nil {
+		return nil, fmt.Errorf("invalid submitted absences file %s: %v", path, err)
+	}
+	return absences, nil
+}
+
+// writeManualAbsences saves the absences submitted for group, readable only
+// by the user.
+func writeManualAbsences(group string, absences []manualAbsence) error {
+	path, err := manualAbsencesPath(group)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(absences, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode submitted absences: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create data directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write submitted absences: %v", err)
+	}
+	return nil
+}
+
+// applyManualAbsences adds the absences submitted for the group to ds,
+// before they're filtered (see filterAbsences). Datasets of a proxy or a
+// JSON export are left alone, as they carry their own.
+func (s *session) applyManualAbsences(ds *ooo.Dataset) {
+	if s.remote != nil || s.export != nil {
+		return
+	}
+	submitted, err := readManualAbsences(ds.Group)
+	if err != nil {
+		logFor("ingest").Warn("could not add submitted absences", "group", ds.Group, "error", err)
+		return
+	}
+	absences := make([]ooo.Absence, 0, len(submitted))
+	for _, m := range submitted {
+		a, err := m.absence(ds.Location)
+		if err != nil {
+			logFor("ingest").Warn("skipping invalid submitted absence", "id", m.ID, "error", err)
+			continue
+		}
+		absences = append(absences, a)
+	}
+	ds.SetManual(absences)
+}
+
+// maxIngestBody is the largest request body POST /api/v1/absences reads.
+const maxIngestBody = 1 << 20
+
+// handleAbsences serves /api/v1/absences for requests with token as a
+// bearer token: POST submits one absence or an array of them, and DELETE
+// /api/v1/absences/<id> withdraws one.
+func (gs *groupServer) handleAbsences(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			logFor("ingest").Warn("request turned away", "path", r.URL.Path, "remote", r.RemoteAddr)
+			http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+			return
+		}
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/absences"), "/")
+		switch {
+		case r.Method == http.MethodPost && id == "":
+			gs.postAbsences(w, r)
+		case r.Method == http.MethodDelete && id != "":
+			gs.deleteAbsence(w, r, id)
+		case id == "":
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		default:
+			w.Header().Set("Allow", http.MethodDelete)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// postAbsences validates the submitted absences and, if all are valid,
+// stores them and adds them to the served dataset.
+func (gs *groupServer) postAbsences(w http.ResponseWriter, r *http.Request) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBody)).Decode(&raw); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	var submitted []manualAbsence
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		err = dec.Decode(&submitted)
+	} else {
+		var m manualAbsence
+		err = dec.Decode(&m)
+		submitted = []manualAbsence{m}
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid absence: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Second)
+	var problems []string
+	for i := range submitted {
+		m := &submitted[i]
+		m.Person = gs.session.cfg.Identities.Canonical(strings.ToLower(strings.TrimSpace(m.Person)))
+		m.Received = now
+		if m.ID == "" {
+			id := make([]byte, 8)
+			if _, err := rand.Read(id); err != nil {
+				http.Error(w, "unable to create ID", http.StatusInternalServerError)
+				return
+			}
+			m.ID = hex.EncodeToString(id)
+		}
+		if err := m.validate(gs.ds.Members, gs.ds.Location); err != nil {
+			problems = append(problems, fmt.Sprintf("absence %d: %v", i+1, err))
+		}
+	}
+	if len(problems) > 0 {
+		http.Error(w, strings.Join(problems, "\n"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = gs.updateManual(func(stored []manualAbsence) []manualAbsence {
+		for _, m := range submitted {
+			i := slices.IndexFunc(stored, func(s manualAbsence) bool { return s.ID == m.ID })
+			if i >= 0 {
+				stored[i] = m
+			} else {
+				stored = append(stored, m)
+			}
+		}
+		return stored
+	})
+	gs.session.audit.Record(auditRecord{Event: "ingest", Group: gs.ds.Group, Operation: "create", Destination: r.RemoteAddr, Events: len(submitted), Error: errorString(err)})
+	if err != nil {
+		logFor("ingest").Warn("could not store submitted absences", "error", err)
+		http.Error(w, "unable to store the absences", http.StatusInternalServerError)
+		return
+	}
+	logFor("ingest").Info("absences submitted", "group", gs.ds.Group, "absences", len(submitted), "remote", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(submitted)
+}
+
+// deleteAbsence withdraws the submitted absence with id.
+func (gs *groupServer) deleteAbsence(w http.ResponseWriter, r *http.Request, id string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	found := false
+	err := gs.updateManual(func(stored []manualAbsence) []manualAbsence {
+		return slices.DeleteFunc(stored, func(s manualAbsence) bool {
+			found = found || s.ID == id
+			return s.ID == id
+		})
+	})
+	gs.session.audit.Record(auditRecord{Event: "ingest", Group: gs.ds.Group, Operation: "delete", Destination: r.RemoteAddr, Error: errorString(err)})
+	switch {
+	case err != nil:
+		logFor("ingest").Warn("could not withdraw submitted absence", "id", id, "error", err)
+		http.Error(w, "unable to withdraw the absence", http.StatusInternalServerError)
+	case !found:
+		http.Error(w, "no absence "+id, http.StatusNotFound)
+	default:
+		logFor("ingest").Info("absence withdrawn", "group", gs.ds.Group, "id", id, "remote", r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// updateManual applies update to the stored absences of the group and
+// serves the dataset again with the result. gs.mu must be held.
+func (gs *groupServer) updateManual(update func([]manualAbsence) []manualAbsence) error {
+	stored, err := readManualAbsences(gs.ds.Group)
+	if err != nil {
+		return err
+	}
+	if err := writeManualAbsences(gs.ds.Group, update(stored)); err != nil {
+		return err
+	}
+	gs.ds = gs.refilter(gs.ds)
+	return nil
+}
+
+// refilter returns a copy of ds with its fetched absences and those
+// submitted for the group now filtered again like in loadGroup. Handlers
+// may still be writing ds, so it's left alone. gs.mu must be held, so that
+// no submission is missed.
+func (gs *groupServer) refilter(ds *ooo.Dataset) *ooo.Dataset {
+	s := gs.session
+	refiltered := *ds
+	refiltered.Members = slices.Clone(ds.Members)
+	refiltered.Absences = slices.Clone(ds.Fetched)
+	s.filterAbsences(&refiltered)
+	s.applyAssumptions(&refiltered, gs.loc)
+	// A --manager slice of the group only has its members' absences
+	refiltered.Absences = slices.DeleteFunc(refiltered.Absences, func(a ooo.Absence) bool {
+		return !slices.Contains(refiltered.Members, a.Person)
+	})
+	if s.cfg.RedactSummaries {
+		refiltered.RedactSummaries()
+	}
+	return &refiltered
+}
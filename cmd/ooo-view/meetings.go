@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+func meetingsCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	calendars := fs.String("calendar", "", "Comma-separated IDs of the calendars with the meetings to check, e.g. the team's shared calendar")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		ids := splitList(*calendars)
+		if len(ids) == 0 {
+			return usageErrorf("expected --calendar with the meetings to check")
+		}
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+		provider, err := s.source(ctx)
+		if err != nil {
+			return err
+		}
+		if !ooo.Supports[ooo.MeetingProvider](provider) {
+			return fmt.Errorf("the provider %s can't list meetings", ooo.SourceOf(provider))
+		}
+		mp := provider.(ooo.MeetingProvider)
+
+		// Only meetings still to come can be cancelled or delegated
+		timeMin, timeMax := s.window()
+		if now := time.Now(); now.After(timeMin) {
+			timeMin = now
+		}
+		var meetings []ooo.Meeting
+		for _, id := range ids {
+			found, err := mp.Meetings(ctx, id, timeMin, timeMax)
+			s.audit.Record(auditRecord{Event: "calendar_read", Group: ds.Group, Calendar: id, Source: "meetings", Events: len(found), Error: errorString(err)})
+			if err != nil {
+				return fmt.Errorf("unable to list the meetings on %s: %v", id, err)
+			}
+			meetings = append(meetings, found...)
+		}
+		conflicts := ooo.MeetingConflicts(ds, meetings, s.cfg.Identities)
+
+		render.StaleBanner(os.Stdout, ds)
+		if len(conflicts) == 0 {
+			fmt.Printf("Nobody in %s is out for any of the %d meetings until %s.\n", ds.Group, len(meetings), render.Day(timeMax.In(loc)))
+			return nil
+		}
+		organizerOut := 0
+		for _, c := range conflicts {
+			m := c.Meeting
+			title := m.Summary
+			if title == "" || s.cfg.RedactSummaries {
+				title = "(no title)"
+			}
+			if m.RecurringID != "" {
+				title += " (recurring)"
+			}
+			fmt.Printf("%-22s %s\n", render.DateTime(m.Start.In(loc)), title)
+			if c.OrganizerOut {
+				organizerOut++
+				fmt.Printf("%-22s organizer %s is out: cancel or delegate\n", "", m.Organizer)
+			}
+			fmt.Printf("%-22s out: %s (of %d expected)\n", "", strings.Join(c.Out, ", "), expectedAt(m, s.cfg.Identities))
+		}
+		fmt.Printf("\n%d of %d meetings have people out, %d of them the organizer. Attendees outside %s aren't checked.\n", len(conflicts), len(meetings), organizerOut, ds.Group)
+		return nil
+	}
+}
+
+// expectedAt returns how many people are expected at m: the organizer and
+// the required attendees.
+func expectedAt(m ooo.Meeting, ids ooo.Identities) int {
+	people := map[string]bool{ids.Canonical(m.Organizer): true}
+	for _, person := range m.Required {
+		people[ids.Canonical(person)] = true
+	}
+	delete(people, "")
+	return len(people)
+}
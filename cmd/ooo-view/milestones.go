@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// milestoneEntry is a milestone in the file given with --milestones.
+type milestoneEntry struct {
+	Name string `yaml:"name"`
+	// Dates is a date like 2025-09-12, or a range like
+	// 2025-09-15..2025-09-19
+	Dates string `yaml:"dates"`
+	// Role names the People in reports, e.g. reviewers
+	Role   string   `yaml:"role,omitempty"`
+	People []string `yaml:"people,omitempty"`
+}
+
+// readMilestones parses the milestones file at path, with the dates at
+// midnight in loc.
+func readMilestones(path string, loc *time.Location, ids ooo.Identities) ([]ooo.Milestone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read milestones: %v", err)
+	}
+	defer f.Close()
+
+	var entries []milestoneEntry
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&entries); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid milestones file %s: %v", path, err)
+	}
+	milestones := make([]ooo.Milestone, 0, len(entries))
+	for _, e := range entries {
+		if e.Name == "" {
+			return nil, fmt.Errorf("invalid milestones file %s: a milestone has no name", path)
+		}
+		first, last, err := parseDateRange(e.Dates, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid milestones file %s: %s: %v", path, e.Name, err)
+		}
+		m := ooo.Milestone{Name: e.Name, First: first, Last: last, Role: e.Role}
+		for _, person := range e.People {
+			m.People = append(m.People, ids.Canonical(strings.ToLower(person)))
+		}
+		milestones = append(milestones, m)
+	}
+	return milestones, nil
+}
+
+// applyMilestones overlays the milestones of --milestones on ds.
+func (s *session) applyMilestones(ds *ooo.Dataset, loc *time.Location) error {
+	if s.cfg.Milestones == "" {
+		return nil
+	}
+	milestones, err := readMilestones(s.cfg.Milestones, loc, s.cfg.Identities)
+	if err != nil {
+		return err
+	}
+	ds.Milestones = milestones
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// configDir is ooo-view's directory for settings, e.g. ~/.config/ooo-view
+// on Linux.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate config directory: %v", err)
+	}
+	return filepath.Join(dir, serviceName), nil
+}
+
+// cacheDir is ooo-view's directory for data that can be fetched again, like
+// the event store, e.g. ~/.cache/ooo-view on Linux.
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate cache directory: %v", err)
+	}
+	return filepath.Join(dir, serviceName), nil
+}
+
+// dataDir is ooo-view's directory for data that should be kept, like
+// snapshots: $XDG_DATA_HOME/ooo-view or ~/.local/share/ooo-view on Unix,
+// and the same directory as the config elsewhere.
+func dataDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows", "darwin", "ios", "plan9":
+		return configDir()
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, serviceName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate data directory: %v", err)
+	}
+	return filepath.Join(home, ".local", "share", serviceName), nil
+}
+
+func pathsCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("paths takes no arguments")
+		}
+		cache, err := cacheDir()
+		if err != nil {
+			return err
+		}
+		data, err := dataDir()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-13s %s\n", "Config file", s.cfg.ConfigPath)
+		fmt.Printf("%-13s %s\n", "Cache", cache)
+		fmt.Printf("%-13s %s\n", "Event store", filepath.Join(cache, "events.db"))
+		fmt.Printf("%-13s %s\n", "Snapshots", filepath.Join(data, "snapshots"))
+		fmt.Printf("%-13s %s\n", "Archive", filepath.Join(data, "archive"))
+		fmt.Printf("%-13s %s\n", "Submitted", filepath.Join(data, "manual"))
+		fmt.Printf("%-13s system keyring, service %q\n", "Credentials", serviceName)
+		return nil
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// payrollConfig is the payroll section of the config file, shaping the CSV
+// of `report payroll` to what the payroll system imports.
+type payrollConfig struct {
+	// Columns are the CSV's columns in order, each a field under a header
+	Columns []payrollColumn `yaml:"columns"`
+	// Categories are matched in order against the titles of absences; the
+	// first with a keyword in the title is the absence's category, and
+	// DefaultCategory that of the others
+	Categories      []payrollCategory `yaml:"categories"`
+	DefaultCategory string            `yaml:"default_category"`
+	// DateFormat is like YYYY-MM-DD or DD.MM.YYYY
+	DateFormat string `yaml:"date_format"`
+	// Delimiter separates the columns, e.g. ";"
+	Delimiter string `yaml:"delimiter"`
+	// HoursPerDay is the length of a working day, for part days
+	HoursPerDay float64 `yaml:"hours_per_day"`
+}
+
+type payrollColumn struct {
+	Header string `yaml:"header"`
+	Field  string `yaml:"field"`
+}
+
+type payrollCategory struct {
+	Name     string   `yaml:"name"`
+	Keywords []string `yaml:"keywords"`
+}
+
+// payrollFields are the fields a payroll column can hold.
+var payrollFields = map[string]func(payrollDay, payrollConfig) string{
+	"email":    func(d payrollDay, _ payrollConfig) string { return d.person },
+	"name":     func(d payrollDay, _ payrollConfig) string { return d.name },
+	"team":     func(d payrollDay, _ payrollConfig) string { return d.team },
+	"date":     func(d payrollDay, c payrollConfig) string { return d.date.Format(c.dateLayout()) },
+	"days":     func(d payrollDay, _ payrollConfig) string { return formatAmount(d.days) },
+	"hours":    func(d payrollDay, c payrollConfig) string { return formatAmount(d.days * c.hoursPerDay()) },
+	"category": func(d payrollDay, _ payrollConfig) string { return d.category },
+	"kind":     func(d payrollDay, _ payrollConfig) string { return string(d.kind) },
+	"summary":  func(d payrollDay, _ payrollConfig) string { return d.summary },
+	"source":   func(d payrollDay, _ payrollConfig) string { return d.source },
+}
+
+// formatAmount formats days or hours without trailing zeros, e.g. 0.5.
+func formatAmount(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// defaultPayrollColumns are the columns without payroll.columns in the
+// config file.
+var defaultPayrollColumns = []payrollColumn{
+	{Header: "Email", Field: "email"},
+	{Header: "Name", Field: "name"},
+	{Header: "Date", Field: "date"},
+	{Header: "Days", Field: "days"},
+	{Header: "Category", Field: "category"},
+}
+
+// validatePayrollConfig checks that c only uses known fields and a
+// single-character delimiter.
+func validatePayrollConfig(c payrollConfig) error {
+	for _, column := range c.Columns {
+		if _, ok := payrollFields[column.Field]; !ok {
+			names := make([]string, 0, len(payrollFields))
+			for name := range payrollFields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown field '%s'; use one of %s", column.Field, strings.Join(names, ", "))
+		}
+	}
+	if c.Delimiter != "" && utf8.RuneCountInString(c.Delimiter) != 1 {
+		return fmt.Errorf("the delimiter must be one character")
+	}
+	if c.HoursPerDay < 0 || c.HoursPerDay > 24 {
+		return fmt.Errorf("hours_per_day must be between 0 and 24")
+	}
+	return nil
+}
+
+func (c payrollConfig) columns() []payrollColumn {
+	if len(c.Columns) == 0 {
+		return defaultPayrollColumns
+	}
+	return c.Columns
+}
+
+// dateLayout returns the Go layout of DateFormat.
+func (c payrollConfig) dateLayout() string {
+	if c.DateFormat == "" {
+		return "2006-01-02"
+	}
+	return strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02").Replace(c.DateFormat)
+}
+
+func (c payrollConfig) hoursPerDay() float64 {
+	if c.HoursPerDay == 0 {
+		return 8
+	}
+	return c.HoursPerDay
+}
+
+// category returns the category of an absence by its title.
+func (c payrollConfig) category(a ooo.Absence) string {
+	summary := strings.ToLower(a.Summary)
+	for _, category := range c.Categories {
+		for _, keyword := range category.Keywords {
+			if keyword != "" && strings.Contains(summary, strings.ToLower(keyword)) {
+				return category.Name
+			}
+		}
+	}
+	if c.DefaultCategory == "" {
+		return "Leave"
+	}
+	return c.DefaultCategory
+}
+
+// payrollDay is a working day a person is out, and the share of it.
+type payrollDay struct {
+	person, name, team string
+	date               time.Time
+	days               float64
+	category           string
+	kind               ooo.Kind
+	summary, source    string
+}
+
+// payrollDays returns the working days people are out in the month starting
+// at monthStart, by person and date. Holidays aren't counted. A day counts
+// in full for an all-day absence, and otherwise for the hours out rounded up
+// to half days; of several absences on a day, the longest decides.
+func payrollDays(ds *ooo.Dataset, loc *time.Location, monthStart time.Time, c payrollConfig) []payrollDay {
+	month := monthStart.Format("2006-01")
+	byKey := make(map[string]payrollDay)
+	for _, a := range ds.Absences {
+		dayLoc := ds.DayLocation(a.Person, loc)
+		for _, d := range a.Days(dayLoc) {
+			if d.Format("2006-01") != month || !ds.WorkWeek(a.Person).Works(d.Weekday()) {
+				continue
+			}
+			if _, ok := ds.Holiday(d); ok {
+				continue
+			}
+			share := 1.0
+			if !a.AllDay {
+				start, end := a.Start, a.End
+				if start.Before(d) {
+					start = d
+				}
+				if dayEnd := d.AddDate(0, 0, 1); end.After(dayEnd) {
+					end = dayEnd
+				}
+				share = math.Min(1, math.Ceil(end.Sub(start).Hours()/c.hoursPerDay()*2)/2)
+			}
+			key := a.Person + "/" + d.Format("2006-01-02")
+			if prev, ok := byKey[key]; ok && prev.days >= share {
+				continue
+			}
+			entry := ds.Roster[a.Person]
+			byKey[key] = payrollDay{
+				person:   a.Person,
+				name:     entry.Name,
+				team:     entry.Team,
+				date:     d,
+				days:     share,
+				category: c.category(a),
+				kind:     a.Kind,
+				summary:  a.Summary,
+				source:   a.Source,
+			}
+		}
+	}
+
+	days := make([]payrollDay, 0, len(byKey))
+	for _, d := range byKey {
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].person != days[j].person {
+			return days[i].person < days[j].person
+		}
+		return days[i].date.Before(days[j].date)
+	})
+	return days
+}
+
+// writePayroll writes the days as CSV with the configured columns.
+func writePayroll(w io.Writer, days []payrollDay, c payrollConfig) error {
+	cw := csv.NewWriter(w)
+	if c.Delimiter != "" {
+		cw.Comma, _ = utf8.DecodeRuneInString(c.Delimiter)
+	}
+	columns := c.columns()
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		row[i] = column.Header
+	}
+	cw.Write(row)
+	for _, d := range days {
+		for i, column := range columns {
+			row[i] = payrollFields[column.Field](d, c)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("unable to write CSV: %v", err)
+	}
+	return nil
+}
+
+// payrollReport writes the payroll CSV of the group for month, like
+// 2025-08, or the current month if empty.
+func payrollReport(ctx context.Context, s *session, args []string, month, output string) error {
+	group, err := s.groupArg(args)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(s.cfg.TimeZone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone: %v", err)
+	}
+	var monthStart time.Time
+	if month == "" {
+		now := time.Now().In(loc)
+		monthStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	} else if monthStart, err = time.ParseInLocation("2006-01", month, loc); err != nil {
+		return usageErrorf("invalid --month '%s', expected a month like 2025-08", month)
+	}
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	// Fetch the weeks the month touches
+	s.at = monthStart
+	s.cfg.WeeksAhead = int(monthEnd.Sub(monthStart).Hours()/24)/7 + 1
+	ds, err := s.loadGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	if ds.Stale {
+		logFor("export").Warn("exporting cached data", "fetched_at", ds.FetchedAt.Local().Format("Mon Jan 2 15:04"))
+	}
+	if ds.Partial {
+		logFor("export").Warn("exporting partial data", "calendars", len(ds.Members))
+	}
+	if monthStart.Before(ds.TimeMin) || monthEnd.After(ds.TimeMax.Add(time.Second)) {
+		logFor("export").Warn("the data doesn't cover the whole month", "month", monthStart.Format("2006-01"))
+	}
+
+	days := payrollDays(ds, loc, monthStart, s.cfg.Payroll)
+	destination := output
+	if output == "-" {
+		destination = "stdout"
+		err = writePayroll(os.Stdout, days, s.cfg.Payroll)
+	} else {
+		err = writePayrollFile(output, days, s.cfg.Payroll)
+	}
+	s.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: "payroll", Destination: destination, Events: len(days), Error: errorString(err)})
+	return err
+}
+
+func writePayrollFile(path string, days []payrollDay, c payrollConfig) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to create export file: %v", err)
+	}
+	if err := writePayroll(f, days, c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progress shows a spinner with a done/total count on stderr while calendars
+// are fetched. A nil *progress does nothing, so callers don't need to check
+// whether it is enabled.
+type progress struct {
+	w     io.Writer
+	label string
+	total int
+	done  atomic.Int64
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// startProgress starts a progress line for total items, unless stderr isn't
+// a terminal or the configuration asks for quiet, verbose or JSON output.
+func startProgress(cfg Config, label string, total int) *progress {
+	if cfg.NoProgress || cfg.Quiet || cfg.Verbose || cfg.LogFormat == "json" || total == 0 {
+		return nil
+	}
+	if info, err := os.Stderr.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	p := &progress{w: os.Stderr, label: label, total: total, stop: make(chan struct{})}
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+func (p *progress) run() {
+	defer p.wg.Done()
+	spinner := `|/-\`
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		fmt.Fprintf(p.w, "\r%c %s %d/%d", spinner[i%len(spinner)], p.label, p.done.Load(), p.total)
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			// Clear the line for the output that follows
+			fmt.Fprint(p.w, "\r\033[K")
+			return
+		}
+	}
+}
+
+// Inc counts one finished item.
+func (p *progress) Inc() {
+	if p != nil {
+		p.done.Add(1)
+	}
+}
+
+// Stop removes the progress line.
+func (p *progress) Stop() {
+	if p != nil {
+		close(p.stop)
+		p.wg.Wait()
+	}
+}
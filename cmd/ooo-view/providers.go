@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// providersCommand lists the sources --provider accepts: Google Calendar and
+// the plugins found on the PATH.
+func providersCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("expected no arguments")
+		}
+		fmt.Printf("%-20s %s\n", "google", "Google Calendar (built in)")
+		for _, p := range ooo.Plugins() {
+			if limit := s.cfg.Plugins[p.Name].RateLimit; limit > 0 {
+				fmt.Printf("%-20s %s (at most %d requests a minute)\n", p.Name, p.Path, limit)
+				continue
+			}
+			fmt.Printf("%-20s %s\n", p.Name, p.Path)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// proxyMaxWindow is the longest window a client can ask the proxy for.
+const proxyMaxWindow = 53 * 7 * 24 * time.Hour
+
+// proxyMaxEntries is how many datasets of a group and window the proxy
+// keeps; the least recently asked for go first.
+const proxyMaxEntries = 256
+
+func proxyCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addFetchFlags(fs, cfg)
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to listen on")
+	clientToken := fs.String("client-token", "", "Only answer clients sending this token, given to them as OOO_REMOTE_TOKEN; best set as OOO_CLIENT_TOKEN")
+	allowGroups := fs.String("allow-groups", "", "Comma-separated group emails clients may ask for (default: any the account can read)")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) > 0 {
+			return usageErrorf("proxy takes no arguments")
+		}
+		if *clientToken == "" {
+			logFor("proxy").Warn("no --client-token set, anyone who can reach the proxy can read the absences")
+		}
+
+		// Sign in before serving, so that a broken setup fails right away
+		provider, err := s.source(ctx)
+		if err != nil {
+			return err
+		}
+		// Fetches run in the background, next to the request log
+		s.cfg.NoProgress = true
+		ps := &proxyServer{session: s, provider: provider, token: *clientToken, datasets: make(map[string]*list.Element), recent: list.New()}
+		for _, group := range splitList(*allowGroups) {
+			ps.allowed = append(ps.allowed, resolveGroup(group, s.cfg.GroupAliases))
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/groups/", ps.handleGroup)
+		server := &http.Server{
+			Addr:              *addr,
+			Handler:           otelhttp.NewHandler(logRequests(mux), "proxy"),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		logFor("proxy").Info("serving", "source", sourceName(s.cfg), "url", "http://"+*addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("unable to serve: %v", err)
+		}
+		return nil
+	}
+}
+
+// proxyServer fetches groups for clients using --remote, reusing a group's
+// dataset for the cache TTL so that clients asking for the same window
+// share one fetch.
+type proxyServer struct {
+	session  *session
+	provider ooo.Provider
+	token    string
+	allowed  []string // empty allows every group
+
+	mu       sync.Mutex
+	datasets map[string]*list.Element // of recent, by key
+	recent   *list.List               // *proxyEntry, most recently used first
+}
+
+// proxyEntry is the dataset of a group and window. Its mutex is held while
+// it's fetched.
+type proxyEntry struct {
+	key string
+	mu  sync.Mutex
+	ds  *ooo.Dataset
+}
+
+// handleGroup serves GET /v1/groups/<group>?time_min=...&time_max=... with
+// the group's JSON export for the window.
+func (ps *proxyServer) handleGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ps.token != "" {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(ps.token)) != 1 {
+			http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+			return
+		}
+	}
+	group := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/v1/groups/"))
+	if group == "" || strings.Contains(group, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if len(ps.allowed) > 0 && !slices.Contains(ps.allowed, group) {
+		http.Error(w, "group not served by this proxy", http.StatusForbidden)
+		return
+	}
+	timeMin, errMin := time.Parse(time.RFC3339, r.URL.Query().Get("time_min"))
+	timeMax, errMax := time.Parse(time.RFC3339, r.URL.Query().Get("time_max"))
+	if errMin != nil || errMax != nil || !timeMax.After(timeMin) {
+		http.Error(w, "time_min and time_max must be RFC 3339 times, time_max after time_min", http.StatusBadRequest)
+		return
+	}
+	timeMin, timeMax = proxyWindow(timeMin, timeMax)
+	if timeMax.Sub(timeMin) > proxyMaxWindow {
+		http.Error(w, "time_min and time_max must be less than a year apart", http.StatusBadRequest)
+		return
+	}
+
+	// Other clients may wait for the same fetch, so it outlives this one
+	ds, err := ps.dataset(context.WithoutCancel(r.Context()), group, timeMin, timeMax)
+	if err != nil {
+		ps.session.audit.Record(auditRecord{Event: "serve", Group: group, Operation: r.URL.Path, Destination: r.RemoteAddr, Error: errorString(err)})
+		logFor("proxy").Warn("could not fetch group", "group", group, "error", err)
+		switch {
+		case errors.Is(err, ooo.ErrGroupNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ooo.ErrNoAccess):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = render.JSON(w, ds)
+	if err != nil {
+		logFor("proxy").Warn("could not write response", "path", r.URL.Path, "error", err)
+	}
+	ps.session.audit.Record(auditRecord{Event: "serve", Group: group, Operation: r.URL.Path, Destination: r.RemoteAddr, Events: len(ds.Absences), Error: errorString(err)})
+}
+
+// proxyWindow widens a window to whole days in UTC, from midnight of the
+// first to the end of the last like ooo.Window, so that clients asking at
+// other times of day or in other time zones share a dataset.
+func proxyWindow(timeMin, timeMax time.Time) (time.Time, time.Time) {
+	first := timeMin.UTC().Truncate(24 * time.Hour)
+	// A time_max of midnight ends the day before
+	last := timeMax.UTC().Add(-time.Second).Truncate(24 * time.Hour)
+	return first, last.Add(24*time.Hour - time.Second)
+}
+
+// dataset returns the group's dataset for the window, fetching it if there
+// is none younger than the cache TTL.
+func (ps *proxyServer) dataset(ctx context.Context, group string, timeMin, timeMax time.Time) (*ooo.Dataset, error) {
+	key := group + "|" + timeMin.UTC().Format(time.RFC3339) + "|" + timeMax.UTC().Format(time.RFC3339)
+	ps.mu.Lock()
+	elem, ok := ps.datasets[key]
+	if ok {
+		ps.recent.MoveToFront(elem)
+	} else {
+		elem = ps.recent.PushFront(&proxyEntry{key: key})
+		ps.datasets[key] = elem
+		if ps.recent.Len() > proxyMaxEntries {
+			// A fetch still running for the oldest finishes for its clients
+			oldest := ps.recent.Remove(ps.recent.Back()).(*proxyEntry)
+			delete(ps.datasets, oldest.key)
+		}
+	}
+	entry := elem.Value.(*proxyEntry)
+	ps.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.ds != nil && time.Since(entry.ds.FetchedAt) < ps.session.cfg.CacheTTL {
+		return entry.ds, nil
+	}
+	ds, err := ps.fetch(ctx, group, timeMin, timeMax)
+	if err != nil {
+		// Clients are better off with older data than none
+		if entry.ds != nil && ooo.IsUnavailable(err) {
+			return entry.ds, nil
+		}
+		return nil, err
+	}
+	entry.ds = ds
+	return ds, nil
+}
+
+// fetch gets the group's absences in the window from the provider. Filters
+// like --min-duration are left to the clients, except for
+// --redact-summaries, which the clients can't be trusted with.
+func (ps *proxyServer) fetch(ctx context.Context, group string, timeMin, timeMax time.Time) (*ooo.Dataset, error) {
+	s := ps.session
+	ds, err := fetchGroupDataset(ctx, ps.provider, group, timeMin, timeMax, time.UTC, s.cfg, nil, s.audit)
+	var unavailableErr *ooo.UnavailableError
+	if errors.As(err, &unavailableErr) {
+		logFor("fetch").Warn("some calendars are unavailable", "group", group, "calendars", len(unavailableErr.People))
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if unavailableErr != nil {
+		ds.Unavailable = unavailableErr.People
+	}
+	ds.MergeIdentities(s.cfg.Identities)
+	if s.cfg.RedactSummaries {
+		ds.RedactSummaries()
+	}
+	return ds, nil
+}
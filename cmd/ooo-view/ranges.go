@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rangeConfig is a window named under ranges in the config file. From and
+// To are dates like 2025-06-15, or like 06-15 for every year.
+type rangeConfig struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// builtinRanges are the windows --range knows without the config file, by
+// the first day they cover as of today. They end on the day before the
+// next one's start.
+var builtinRanges = map[string]func(today time.Time) (first, next time.Time){
+	"this-week": func(t time.Time) (time.Time, time.Time) {
+		monday := t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+		return monday, monday.AddDate(0, 0, 7)
+	},
+	"next-week": func(t time.Time) (time.Time, time.Time) {
+		monday := t.AddDate(0, 0, 7-(int(t.Weekday())+6)%7)
+		return monday, monday.AddDate(0, 0, 7)
+	},
+	"this-month": func(t time.Time) (time.Time, time.Time) {
+		first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(0, 1, 0)
+	},
+	"next-month": func(t time.Time) (time.Time, time.Time) {
+		first := time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(0, 1, 0)
+	},
+	"this-quarter": func(t time.Time) (time.Time, time.Time) {
+		first := time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(0, 3, 0)
+	},
+	"next-quarter": func(t time.Time) (time.Time, time.Time) {
+		first := time.Date(t.Year(), (t.Month()-1)/3*3+4, 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(0, 3, 0)
+	},
+	"this-year": func(t time.Time) (time.Time, time.Time) {
+		first := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(1, 0, 0)
+	},
+	"next-year": func(t time.Time) (time.Time, time.Time) {
+		first := time.Date(t.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(1, 0, 0)
+	},
+}
+
+// rangeNames returns the names --range accepts, sorted.
+func rangeNames(ranges map[string]rangeConfig) []string {
+	names := make([]string, 0, len(builtinRanges)+len(ranges))
+	for name := range builtinRanges {
+		names = append(names, name)
+	}
+	for name := range ranges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateRanges checks the ranges of the config file.
+func validateRanges(ranges map[string]rangeConfig) error {
+	for name, r := range ranges {
+		if _, ok := builtinRanges[name]; ok {
+			return fmt.Errorf("%s is a built-in range", name)
+		}
+		if _, _, err := r.window(time.Now()); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// window returns the first and last day of r as of now. A range of every
+// year is this year's, or next year's once it's over, and may run into
+// the next year, like 12-15 to 01-06.
+func (r rangeConfig) window(now time.Time) (time.Time, time.Time, error) {
+	if first, err := time.Parse("2006-01-02", r.From); err == nil {
+		last, err := time.Parse("2006-01-02", r.To)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to '%s'; with a year in from, expected a date like 2025-09-15", r.To)
+		}
+		if last.Before(first) {
+			return time.Time{}, time.Time{}, fmt.Errorf("to %s is before from %s", r.To, r.From)
+		}
+		return first, last, nil
+	}
+	from, err := time.Parse("01-02", r.From)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from '%s', expected a date like 2025-06-15, or 06-15 for every year", r.From)
+	}
+	to, err := time.Parse("01-02", r.To)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to '%s', expected a date like 09-15, like from", r.To)
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	first := time.Date(today.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	last := time.Date(today.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	if last.Before(first) {
+		last = last.AddDate(1, 0, 0)
+	}
+	// Still in last year's, if it runs over the new year
+	if prev := last.AddDate(-1, 0, 0); !prev.Before(today) {
+		return first.AddDate(-1, 0, 0), prev, nil
+	}
+	if last.Before(today) {
+		first, last = first.AddDate(1, 0, 0), last.AddDate(1, 0, 0)
+	}
+	return first, last, nil
+}
+
+// rangeWindow returns the window of --range as of now: a built-in or
+// configured name, or dates like 2025-07-01..2025-09-30. Like ooo.Window,
+// it runs from midnight UTC of the first day to the end of the last.
+func rangeWindow(name string, ranges map[string]rangeConfig, now time.Time) (time.Time, time.Time, error) {
+	var first, last time.Time
+	if from, to, ok := strings.Cut(name, ".."); ok {
+		var err error
+		if first, last, err = (rangeConfig{From: from, To: to}).window(now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	} else if r, ok := ranges[name]; ok {
+		var err error
+		if first, last, err = r.window(now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	} else if builtin, ok := builtinRanges[name]; ok {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		var next time.Time
+		first, next = builtin(today)
+		last = next.AddDate(0, 0, -1)
+	} else {
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown range '%s'; use one of %s, or dates like 2025-07-01..2025-09-30", name, strings.Join(rangeNames(ranges), ", "))
+	}
+	return first, time.Date(last.Year(), last.Month(), last.Day(), 23, 59, 59, 0, time.UTC), nil
+}
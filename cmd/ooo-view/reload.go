@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// reloadSignals returns a channel that receives SIGHUP, the signal to read
+// the config file again, until ctx is done. Signals don't reach it on
+// Windows.
+func reloadSignals(ctx context.Context) <-chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		<-ctx.Done()
+		signal.Stop(ch)
+	}()
+	return ch
+}
+
+// fixedSettings returns the settings the sign-in, the provider, the HTTP
+// client, the cache and the logs were set up with when the first group was
+// fetched, by config key. A reload leaves them as they were.
+func fixedSettings(cfg Config) map[string]any {
+	return map[string]any{
+		"service_account":      cfg.ServiceAccount,
+		"impersonate":          cfg.Impersonate,
+		"token_file":           cfg.TokenFile,
+		"no_keyring":           cfg.NoKeyring,
+		"provider":             cfg.Provider,
+		"remote":               cfg.Remote,
+		"plugins":              cfg.Plugins,
+		"secondary_calendars":  cfg.SecondaryCalendars,
+		"freebusy_only":        cfg.FreebusyOnly,
+		"no_freebusy_fallback": cfg.NoFreebusyFallback,
+		"batch":                cfg.Batch,
+		"cache_ttl":            cfg.CacheTTL,
+		"http_timeout":         cfg.HTTPTimeout,
+		"keep_alive":           cfg.KeepAlive,
+		"http2":                cfg.HTTP2,
+		"audit_log":            cfg.AuditLog,
+		"log_format":           cfg.LogFormat,
+		"date_style":           cfg.DateStyle,
+		"clock":                cfg.Clock,
+		"show_time_zones":      cfg.ShowTimeZones,
+		"default_group":        cfg.DefaultGroup,
+		// A manager without a roster is looked up in the Directory
+		"manager": cfg.DirectoryAccess,
+	}
+}
+
+// reloadConfig reads the config file again, for serve and watch on SIGHUP,
+// and uses it from the next fetch on. If the file is invalid, the last
+// config is kept. The settings in fixedSettings only change with a
+// restart, which is logged.
+func (s *session) reloadConfig() error {
+	cfg, err := s.cfg.reload()
+	if err != nil {
+		return err
+	}
+	// Set by the command rather than the config
+	cfg.NoProgress = s.cfg.NoProgress
+
+	before := fixedSettings(s.cfg)
+	var changed []string
+	for key, value := range fixedSettings(cfg) {
+		if !reflect.DeepEqual(value, before[key]) {
+			changed = append(changed, key)
+		}
+	}
+	slices.Sort(changed)
+	s.cfg = cfg
+	logFor("config").Info("reloaded the config file", "path", cfg.ConfigPath)
+	if len(changed) > 0 {
+		logFor("config").Warn("some settings only change with a restart", "settings", strings.Join(changed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// remoteProvider fetches groups from an ooo-view proxy, which signs in to
+// Google once for everyone, instead of from Google. Each group is one
+// request; its events are then served from the response.
+type remoteProvider struct {
+	client *http.Client
+	base   string
+	token  string
+
+	mu     sync.Mutex
+	groups map[string]*render.JSONProvider
+}
+
+// remoteGroup is the remoteProvider for the fetch of one group, whose
+// events are looked up in the proxy's response for that group alone.
+type remoteGroup struct {
+	*remoteProvider
+	group string
+}
+
+// maxRemoteResponse is the largest response of the proxy read, the export
+// of a big group over a year with room to spare.
+const maxRemoteResponse = 64 << 20
+
+// forGroup returns p for the fetch of groupEmail.
+func (p *remoteProvider) forGroup(groupEmail string) *remoteGroup {
+	return &remoteGroup{remoteProvider: p, group: groupEmail}
+}
+
+var (
+	_ ooo.Provider = (*remoteProvider)(nil)
+	_ ooo.Provider = (*remoteGroup)(nil)
+)
+
+func newRemoteProvider(cfg Config) *remoteProvider {
+	return &remoteProvider{
+		client: newHTTPClient(cfg),
+		base:   strings.TrimSuffix(cfg.Remote, "/"),
+		token:  cfg.RemoteToken,
+		groups: make(map[string]*render.JSONProvider),
+	}
+}
+
+// Source names the proxy as the source of absences.
+func (p *remoteProvider) Source() string {
+	return "remote"
+}
+
+// Members fetches the group's dataset for the window from the proxy and
+// returns the members it has data for.
+func (p *remoteProvider) Members(ctx context.Context, groupEmail string, timeMin, timeMax time.Time, timezone string) ([]string, error) {
+	query := url.Values{
+		"time_min": {timeMin.Format(time.RFC3339)},
+		"time_max": {timeMax.Format(time.RFC3339)},
+	}
+	target := p.base + "/v1/groups/" + url.PathEscape(groupEmail) + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote URL: %v", err)
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the proxy: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteResponse+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the proxy's response: %w", err)
+	}
+	if len(data) > maxRemoteResponse {
+		return nil, fmt.Errorf("the proxy's response is larger than %d MB", maxRemoteResponse>>20)
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("the proxy refused the request; set its token with --remote-token or OOO_REMOTE_TOKEN")
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &ooo.FetchError{Kind: ooo.ErrGroupNotFound, Subject: groupEmail}
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, &ooo.FetchError{Kind: ooo.ErrNoAccess, Subject: groupEmail, Err: fmt.Errorf("%s", strings.TrimSpace(string(data)))}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("proxy error: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	group, err := render.ParseJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response from the proxy: %v", err)
+	}
+	p.mu.Lock()
+	p.groups[groupEmail] = group
+	p.mu.Unlock()
+	return group.Members(ctx, groupEmail, timeMin, timeMax, timezone)
+}
+
+// OutOfOfficeEvents fails: the proxy only serves whole groups, so events
+// are looked up through forGroup.
+func (p *remoteProvider) OutOfOfficeEvents(ctx context.Context, person string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	return nil, errors.New("the proxy only serves the absences of groups")
+}
+
+// OutOfOfficeEvents returns person's events from the proxy's response for
+// the group, which Members fetched.
+func (g *remoteGroup) OutOfOfficeEvents(ctx context.Context, person string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	g.mu.Lock()
+	group, ok := g.groups[g.group]
+	g.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("group '%s' wasn't fetched from the proxy", g.group)
+	}
+	return group.OutOfOfficeEvents(ctx, person, timeMin, timeMax)
+}
+
+// annotate copies what the proxy knows about the group's dataset that a
+// fetch through the provider doesn't carry: when it was fetched from
+// Google, and whose calendars were unavailable or couldn't be read.
+func (p *remoteProvider) annotate(ds *ooo.Dataset) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if group, ok := p.groups[ds.Group]; ok {
+		ds.FetchedAt = group.FetchedAt
+		ds.Unavailable = append(ds.Unavailable, group.Unavailable...)
+		for person, reason := range group.NoData {
+			if ds.NoData == nil {
+				ds.NoData = make(map[string]string)
+			}
+			ds.NoData[person] = reason
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+func todayCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addFetchFlags(fs, cfg)
+	clipboard := addCopyFlag(fs)
+
+	return func(ctx context.Context, s *session, args []string) error {
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+
+		// The current week is enough to find everyone who is out today
+		s.cfg.WeeksAhead, s.cfg.Range = 0, ""
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().In(loc)
+		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+
+		backAt, allDay := outBetween(ds, dayStart, dayEnd)
+
+		out, copied := clipboard.output()
+		render.StaleBanner(out, ds)
+		if len(backAt) == 0 {
+			fmt.Fprintln(out, "Nobody is out of office today.")
+		}
+
+		people := make([]string, 0, len(backAt))
+		for person := range backAt {
+			people = append(people, person)
+		}
+		sort.Strings(people)
+		for _, person := range people {
+			back := backAt[person].In(loc)
+			if allDay[person] {
+				fmt.Fprintf(out, "%-30s back %s\n", person, render.Day(back))
+			} else {
+				fmt.Fprintf(out, "%-30s back %s\n", person, render.DateTime(back))
+			}
+		}
+		if err := clipboard.toClipboard(copied); err != nil {
+			logFor("cli").Warn("could not copy the output", "error", err)
+		}
+		return nil
+	}
+}
+
+// outBetween returns when each person out between dayStart and dayEnd is
+// back, and whether the absence they're back from is all-day.
+func outBetween(ds *ooo.Dataset, dayStart, dayEnd time.Time) (map[string]time.Time, map[string]bool) {
+	backAt := make(map[string]time.Time)
+	allDay := make(map[string]bool)
+	for _, a := range ds.Absences {
+		if !a.Start.Before(dayEnd) || !a.End.After(dayStart) {
+			continue
+		}
+		if a.End.After(backAt[a.Person]) {
+			backAt[a.Person] = a.End
+			allDay[a.Person] = a.AllDay
+		}
+	}
+	return backAt, allDay
+}
+
+func dayCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addFetchFlags(fs, cfg)
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) == 0 {
+			return usageErrorf("expected a date like 2025-12-24")
+		}
+		group, err := s.groupArg(args[1:])
+		if err != nil {
+			return err
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+		dayStart, err := time.ParseInLocation("2006-01-02", args[0], loc)
+		if err != nil {
+			return usageErrorf("invalid date '%s', expected a date like 2025-12-24", args[0])
+		}
+		dayEnd := dayStart.AddDate(0, 0, 1)
+
+		// The week of the day is enough
+		s.at = dayStart
+		s.cfg.WeeksAhead = 0
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+		if dayStart.Before(ds.TimeMin) || !dayStart.Before(ds.TimeMax) {
+			return fmt.Errorf("the data for %s doesn't cover %s", ds.Group, args[0])
+		}
+
+		var absences []ooo.Absence
+		for _, a := range ds.Absences {
+			if a.Start.Before(dayEnd) && a.End.After(dayStart) {
+				absences = append(absences, a)
+			}
+		}
+		sort.Slice(absences, func(i, j int) bool {
+			if absences[i].Person != absences[j].Person {
+				return absences[i].Person < absences[j].Person
+			}
+			return absences[i].Start.Before(absences[j].Start)
+		})
+		key := dayStart.Format("2006-01-02")
+		var out []string
+		for person, days := range ooo.AbsentDays(ds, loc) {
+			if days[key] {
+				out = append(out, person)
+			}
+		}
+
+		render.StaleBanner(os.Stdout, ds)
+		fmt.Printf("%s, %s\n", dayStart.Format("Monday Jan 2 2006"), ds.Group)
+		if working := len(ds.WorkingMembers(dayStart)); working == 0 {
+			fmt.Println("Nobody in the group works on this day.")
+		} else {
+			fmt.Printf("%d of %d working members out, coverage %.0f%%\n", len(out), working, dayCoverage(ds, dayStart, out)*100)
+		}
+		if noData := ds.WithoutData(); len(noData) > 0 {
+			fmt.Printf("No data for %s.\n", strings.Join(noData, ", "))
+		}
+		if len(absences) == 0 {
+			fmt.Println("\nNobody is out of office.")
+			return nil
+		}
+		fmt.Println()
+		for _, a := range absences {
+			if a.Summary != "" {
+				fmt.Printf("%-30s %-28s %s\n", a.Person, absenceDates(a, loc), a.Summary)
+			} else {
+				fmt.Printf("%-30s %s\n", a.Person, absenceDates(a, loc))
+			}
+			if note := declineNote(a); note != "" {
+				fmt.Printf("%-30s %s\n", "", note)
+			}
+		}
+		return nil
+	}
+}
+
+// absenceDates describes when the absence is, e.g. "Dec 22 – Jan 2" for
+// all-day absences and "Dec 24 13:00 – 17:00" for others.
+func absenceDates(a ooo.Absence, loc *time.Location) string {
+	start, end := a.Start.In(loc), a.End.In(loc)
+	if a.AllDay {
+		last := end.AddDate(0, 0, -1)
+		if !last.After(start) {
+			return render.Date(start)
+		}
+		return render.Date(start) + " – " + render.Date(last)
+	}
+	if start.Format("2006-01-02") == end.Format("2006-01-02") {
+		return render.Date(start) + " " + render.Clock(start) + " – " + render.Clock(end)
+	}
+	return render.Date(start) + " " + render.Clock(start) + " – " + render.Date(end) + " " + render.Clock(end)
+}
+
+// declineNote describes how an absence answers invitations, with its
+// decline message, e.g. `declines all meetings: "Back on the 4th"`, or
+// returns "" if it doesn't decline them.
+func declineNote(a ooo.Absence) string {
+	var note string
+	switch a.AutoDecline {
+	case ooo.AutoDeclineAll:
+		note = "declines all meetings"
+	case ooo.AutoDeclineNew:
+		note = "declines new invitations"
+	default:
+		return ""
+	}
+	if message := strings.Join(strings.Fields(a.DeclineMessage), " "); message != "" {
+		note += fmt.Sprintf(": %q", message)
+	}
+	return note
+}
+
+func reportCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	limits := addThresholdFlags(fs, cfg)
+	overlaps := fs.Bool("overlaps", false, "Also show, for every pair of people, how many days they are both out")
+	weekdays := fs.Bool("weekdays", false, "Also show on which weekdays the absences fall, for the group and per person")
+	month := fs.String("month", "", "With payroll, the month to list, like 2025-08 (default: the current month)")
+	output := fs.String("output", "-", "With payroll, the file to write the CSV to, or - for stdout")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		// `report payroll [group]` writes the days out in a month as CSV
+		if len(args) > 0 && args[0] == "payroll" {
+			return payrollReport(ctx, s, args[1:], *month, *output)
+		}
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+
+		daysOut := ooo.AbsentDays(ds, loc)
+		render.StaleBanner(os.Stdout, ds)
+
+		// Working days out per person, most first
+		people := make([]string, 0, len(daysOut))
+		for person := range daysOut {
+			people = append(people, person)
+		}
+		sort.Slice(people, func(i, j int) bool {
+			if len(daysOut[people[i]]) != len(daysOut[people[j]]) {
+				return len(daysOut[people[i]]) > len(daysOut[people[j]])
+			}
+			return people[i] < people[j]
+		})
+		fmt.Printf("\n%-30s %8s\n", "Person", "Days out")
+		for _, person := range people {
+			fmt.Printf("%-30s %8d\n", person, len(daysOut[person]))
+		}
+
+		// People and person-days out per week
+		fmt.Printf("\n%-24s %10s %12s\n", "Week", "People out", "Person-days")
+		for week := ds.TimeMin; week.Before(ds.TimeMax); week = week.AddDate(0, 0, 7) {
+			weekEnd := week.AddDate(0, 0, 6)
+			var peopleOut, personDays int
+			for _, days := range daysOut {
+				n := 0
+				for d := week; !d.After(weekEnd); d = d.AddDate(0, 0, 1) {
+					if days[d.Format("2006-01-02")] {
+						n++
+					}
+				}
+				if n > 0 {
+					peopleOut++
+					personDays += n
+				}
+			}
+			fmt.Printf("%-24s %10d %12d\n", render.WeekRange(week, weekEnd), peopleOut, personDays)
+		}
+		printMilestones(ds, loc)
+		printBridgeDays(ds, loc)
+		if *weekdays {
+			printWeekdays(daysOut)
+		}
+		if *overlaps {
+			sort.Strings(people)
+			printOverlaps(people, daysOut)
+		}
+		fmt.Println()
+		return limits.check(ds, loc)
+	}
+}
+
+// printMilestones lists the milestones within the window with the key
+// people out on them.
+func printMilestones(ds *ooo.Dataset, loc *time.Location) {
+	header := false
+	for _, m := range ds.Milestones {
+		days := m.Days(ds, loc)
+		if len(days) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Println("\nMilestones")
+			header = true
+		}
+		fmt.Println(render.MilestoneSummary(m, days))
+	}
+}
+
+// printBridgeDays lists the bridge days within the window with the people
+// out on them.
+func printBridgeDays(ds *ooo.Dataset, loc *time.Location) {
+	bridges := ooo.BridgeDays(ds, loc)
+	if len(bridges) == 0 {
+		return
+	}
+	fmt.Println("\nBridge days")
+	for _, b := range bridges {
+		line := render.BridgeSummary(b)
+		if len(b.Out) > 0 {
+			line += " (" + strings.Join(b.Out, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+}
+
+// weekdayOrder lists the weekdays from Monday.
+var weekdayOrder = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// printWeekdays writes a histogram of the days out by weekday for the whole
+// group, then a row of counts per person, to show e.g. whether Fridays are
+// a poor day for a recurring meeting.
+func printWeekdays(daysOut map[string]map[string]bool) {
+	perPerson := make(map[string]map[time.Weekday]int, len(daysOut))
+	total := make(map[time.Weekday]int)
+	for person, days := range daysOut {
+		perPerson[person] = make(map[time.Weekday]int)
+		for day := range days {
+			d, _ := time.Parse("2006-01-02", day)
+			perPerson[person][d.Weekday()]++
+			total[d.Weekday()]++
+		}
+	}
+
+	most := 0
+	for _, n := range total {
+		most = max(most, n)
+	}
+	fmt.Printf("\nDays out by weekday\n")
+	if most == 0 {
+		fmt.Println("Nobody is out in this window.")
+		return
+	}
+	const width = 40
+	for _, wd := range weekdayOrder {
+		bar := strings.Repeat("█", (total[wd]*width+most-1)/most)
+		fmt.Printf("%-4s %5d %s\n", wd.String()[:3], total[wd], bar)
+	}
+
+	people := make([]string, 0, len(perPerson))
+	for person := range perPerson {
+		people = append(people, person)
+	}
+	sort.Strings(people)
+	fmt.Printf("\n%-30s", "Person")
+	for _, wd := range weekdayOrder {
+		fmt.Printf(" %4s", wd.String()[:3])
+	}
+	fmt.Println()
+	for _, person := range people {
+		fmt.Printf("%-30s", person)
+		for _, wd := range weekdayOrder {
+			if n := perPerson[person][wd]; n > 0 {
+				fmt.Printf(" %4d", n)
+			} else {
+				fmt.Printf(" %4s", ".")
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// printOverlaps writes a matrix of the days each pair of people is out
+// together, with the columns numbered after the rows to keep it narrow, and
+// the pairs that overlap, most days first.
+func printOverlaps(people []string, daysOut map[string]map[string]bool) {
+	if len(people) < 2 {
+		fmt.Println("\nNo two people are out in this window.")
+		return
+	}
+
+	type pair struct {
+		a, b string
+		days int
+	}
+	var pairs []pair
+	overlap := make([][]int, len(people))
+	for i := range people {
+		overlap[i] = make([]int, len(people))
+	}
+	for i, a := range people {
+		for j := i + 1; j < len(people); j++ {
+			n := 0
+			for day := range daysOut[a] {
+				if daysOut[people[j]][day] {
+					n++
+				}
+			}
+			overlap[i][j], overlap[j][i] = n, n
+			if n > 0 {
+				pairs = append(pairs, pair{a, people[j], n})
+			}
+		}
+	}
+
+	fmt.Printf("\nDays out together\n%-34s", "")
+	for i := range people {
+		fmt.Printf(" %3d", i+1)
+	}
+	fmt.Println()
+	for i, person := range people {
+		fmt.Printf("%3d %-30s", i+1, person)
+		for j := range people {
+			switch {
+			case i == j:
+				fmt.Printf(" %3s", "-")
+			case overlap[i][j] == 0:
+				fmt.Printf(" %3s", ".")
+			default:
+				fmt.Printf(" %3d", overlap[i][j])
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(pairs) == 0 {
+		fmt.Println("\nNobody is out at the same time as anyone else.")
+		return
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].days > pairs[j].days })
+	fmt.Println()
+	for _, p := range pairs {
+		fmt.Printf("%-30s %-30s %3d %s\n", p.a, p.b, p.days, plural(p.days, "day", "days"))
+	}
+}
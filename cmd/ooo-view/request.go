@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+func checkRequestCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addFetchFlags(fs, cfg)
+	var minCoverage percent
+	fs.Var(&minCoverage, "fail-if-coverage-below", "Exit with status 3 if the request leaves fewer than this share of the group in on one of its days (e.g., 50%)")
+	failOnConflicts := fs.Bool("fail-on-conflicts", false, "Exit with status 3 if anyone else is out on one of the request's days")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) < 2 {
+			return usageErrorf("expected a person's email and dates like 2025-07-07..2025-07-18")
+		}
+		group, err := s.groupArg(args[2:])
+		if err != nil {
+			return err
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+		person := s.cfg.Identities.Canonical(strings.ToLower(args[0]))
+		from, to, err := parseDateRange(args[1], loc)
+		if err != nil {
+			return usageErrorf("%v", err)
+		}
+
+		// Fetch the weeks the request covers
+		s.at = from
+		s.cfg.WeeksAhead = int(to.Sub(from).Hours()/24)/7 + 1
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+		member := slices.Contains(ds.Members, person)
+		daysOut := ooo.AbsentDays(ds, loc)
+		if !member {
+			// Count them as one of the group for the coverage
+			ds.Members = append(ds.Members, person)
+		}
+
+		render.StaleBanner(os.Stdout, ds)
+		fmt.Printf("%s off %s – %s, %s\n", person, from.Format("Mon Jan 2"), to.Format("Mon Jan 2 2006"), ds.Group)
+		if !member {
+			fmt.Printf("%s isn't a member of %s; counted as one.\n", person, ds.Group)
+		}
+		if noData := ds.WithoutData(); len(noData) > 0 {
+			fmt.Printf("No data for %s.\n", strings.Join(noData, ", "))
+		}
+		fmt.Println()
+
+		var violations []string
+		requested, conflicts := 0, 0
+		lowest, lowestDay := 1.0, time.Time{}
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			if !ds.WorkWeek(person).Works(day.Weekday()) {
+				continue
+			}
+			requested++
+			key := day.Format("2006-01-02")
+			var others []string
+			for _, other := range ds.Members {
+				if other != person && daysOut[other][key] {
+					others = append(others, other)
+				}
+			}
+			sort.Strings(others)
+			after := append(slices.Clone(others), person)
+			before := others
+			if daysOut[person][key] {
+				before = after
+			}
+			coverage := dayCoverage(ds, day, after)
+
+			line := fmt.Sprintf("%-11s coverage %3.0f%% → %3.0f%%", day.Format("Mon Jan 2"), dayCoverage(ds, day, before)*100, coverage*100)
+			if daysOut[person][key] {
+				line += "   already out"
+			}
+			if len(others) > 0 {
+				conflicts++
+				line += "   also out: " + strings.Join(others, ", ")
+			}
+			fmt.Println(line)
+
+			if coverage < lowest {
+				lowest, lowestDay = coverage, day
+			}
+			if minCoverage > 0 && coverage < float64(minCoverage) {
+				violations = append(violations, fmt.Sprintf("%s coverage %.0f%% is below %s", key, coverage*100, minCoverage.String()))
+			}
+			if *failOnConflicts && len(others) > 0 {
+				violations = append(violations, fmt.Sprintf("%s conflict: %s", key, strings.Join(others, ", ")))
+			}
+		}
+
+		if requested == 0 {
+			fmt.Printf("%s doesn't work on any of these days.\n", person)
+			return nil
+		}
+		fmt.Printf("\n%d working %s. Lowest coverage %.0f%% on %s.", requested, plural(requested, "day", "days"), lowest*100, lowestDay.Format("Mon Jan 2"))
+		if conflicts > 0 {
+			fmt.Printf(" Others are out on %d of them.\n", conflicts)
+		} else {
+			fmt.Println(" Nobody else is out.")
+		}
+		if len(violations) > 0 {
+			return &thresholdError{violations: violations}
+		}
+		return nil
+	}
+}
+
+// parseDateRange parses dates like 2025-07-07..2025-07-18, both included,
+// or a single date.
+func parseDateRange(s string, loc *time.Location) (time.Time, time.Time, error) {
+	first, last, found := strings.Cut(s, "..")
+	if !found {
+		last = first
+	}
+	from, err := time.ParseInLocation("2006-01-02", first, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid dates '%s', expected dates like 2025-07-07..2025-07-18", s)
+	}
+	to, err := time.ParseInLocation("2006-01-02", last, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid dates '%s', expected dates like 2025-07-07..2025-07-18", s)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid dates '%s': the last is before the first", s)
+	}
+	return from, to, nil
+}
+
+// plural returns one or many depending on n.
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
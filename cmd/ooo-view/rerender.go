@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// renderCommand renders a JSON export again, through the same filters and
+// views as a fetch, without contacting any provider.
+func renderCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addFetchFlags(fs, cfg)
+	fromJSON := fs.String("from-json", "", "JSON export to render, as written by export --format json, or - for stdin")
+	format := fs.String("format", "grid", "Output format: "+strings.Join(render.Names(), ", "))
+	output := fs.String("output", "-", "File to write to, or - for stdout")
+	fs.StringVar(&cfg.GroupBy, "group-by", cfg.GroupBy, "With the grid format, show the members in sections by team or manager in the roster")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if *fromJSON == "" {
+			return usageErrorf("expected --from-json with a file, or - for stdin")
+		}
+		if s.cfg.Remote != "" || s.cfg.Offline {
+			return usageErrorf("--from-json can't be combined with --remote or --offline")
+		}
+		renderer, ok := render.Lookup(*format)
+		if !ok {
+			return usageErrorf("unknown format '%s'", *format)
+		}
+		if s.cfg.GroupBy != "" && *format != "grid" {
+			return usageErrorf("--group-by only applies to the grid format")
+		}
+		if s.cfg.GroupBy == groupByLocation {
+			return usageErrorf("--group-by location needs the Directory; use team or manager")
+		}
+
+		name := *fromJSON
+		var data []byte
+		var err error
+		if name == "-" {
+			name = "stdin"
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %v", name, err)
+		}
+		if s.export, err = render.ParseJSON(data); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		// The export's own group unless another is asked for, like
+		// team:NAME of the roster
+		group := s.export.Group
+		if len(args) > 0 {
+			if group, err = s.groupArg(args); err != nil {
+				return err
+			}
+		}
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return err
+		}
+
+		switch s.cfg.GroupBy {
+		case groupByTeam:
+			renderer = render.RendererFunc(func(w io.Writer, ds *ooo.Dataset) error {
+				render.GridByTeam(w, ds)
+				return nil
+			})
+		case groupByManager:
+			renderer = render.RendererFunc(func(w io.Writer, ds *ooo.Dataset) error {
+				render.GridByManager(w, ds)
+				return nil
+			})
+		}
+		destination := *output
+		if destination == "-" {
+			destination = "stdout"
+		}
+		err = writeExport(renderer, ds, *output)
+		s.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: *format, Destination: destination, Events: len(ds.Absences), Error: errorString(err)})
+		return err
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// rosterEntry is a person in roster.yaml.
+type rosterEntry struct {
+	Email   string `yaml:"email"`
+	Name    string `yaml:"name,omitempty"`
+	Team    string `yaml:"team,omitempty"`
+	Role    string `yaml:"role,omitempty"`
+	Manager string `yaml:"manager,omitempty"`
+	// Weight is how much the person counts toward coverage (default: 1)
+	Weight *float64 `yaml:"weight,omitempty"`
+}
+
+// defaultRosterPath returns the location of roster.yaml next to
+// config.yaml.
+func defaultRosterPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "roster.yaml"), nil
+}
+
+// readRoster parses the roster at path, with every address replaced by the
+// one its person is shown as. A missing file is only an error if the path
+// was given explicitly.
+func readRoster(path string, explicit bool, ids ooo.Identities) (ooo.Roster, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read roster: %v", err)
+	}
+	defer f.Close()
+
+	var entries []rosterEntry
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&entries); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid roster %s: %v", path, err)
+	}
+	roster := make(ooo.Roster, len(entries))
+	for _, e := range entries {
+		email := ids.Canonical(strings.ToLower(strings.TrimSpace(e.Email)))
+		if email == "" {
+			return nil, fmt.Errorf("invalid roster %s: an entry has no email", path)
+		}
+		if _, ok := roster[email]; ok {
+			return nil, fmt.Errorf("invalid roster %s: %s is listed twice", path, email)
+		}
+		manager := e.Manager
+		if manager != "" {
+			manager = ids.Canonical(strings.ToLower(strings.TrimSpace(manager)))
+		}
+		entry := ooo.RosterEntry{Name: e.Name, Team: e.Team, Role: e.Role, Manager: manager}
+		if e.Weight != nil {
+			if *e.Weight <= 0 || *e.Weight > 100 {
+				return nil, fmt.Errorf("invalid roster %s: the weight of %s must be above 0 and at most 100", path, email)
+			}
+			entry.Weight = *e.Weight
+		}
+		roster[email] = entry
+	}
+	return roster, nil
+}
+
+// personName returns the name of person in the roster, or their email
+// address.
+func personName(ds *ooo.Dataset, person string) string {
+	if entry, ok := ds.Roster[person]; ok && entry.Name != "" {
+		return entry.Name
+	}
+	return person
+}
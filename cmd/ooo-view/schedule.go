@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// scheduleEntry is a job under schedule in the config file, which serve
+// runs on the served group: posting a digest of who is out in the coming
+// week, or exporting the group to a directory.
+type scheduleEntry struct {
+	// Every is day, month, for its first day, or a weekday like monday
+	Every string `yaml:"every"`
+	// At is the time in --timezone, like 08:00; midnight if empty
+	At string `yaml:"at,omitempty"`
+	// Post is the webhook the digest is posted to, like a Slack incoming
+	// webhook; ${VAR} is read from the environment, to keep the URL out of
+	// the file
+	Post string `yaml:"post,omitempty"`
+	// Export is the format the group is written in to a new file in Dir
+	Export string `yaml:"export,omitempty"`
+	Dir    string `yaml:"dir,omitempty"`
+}
+
+// validateSchedule checks when each job runs and what it does.
+func validateSchedule(jobs []scheduleEntry) error {
+	for i, job := range jobs {
+		if job.Every != "day" && job.Every != "month" {
+			if week, err := ooo.ParseWorkWeek([]string{job.Every}); err != nil || len(week) != 1 {
+				return fmt.Errorf("job %d: invalid every '%s', expected day, month or a weekday", i+1, job.Every)
+			}
+		}
+		if job.At != "" {
+			if _, err := time.Parse("15:04", job.At); err != nil {
+				return fmt.Errorf("job %d: invalid at '%s', expected a time like 08:00", i+1, job.At)
+			}
+		}
+		switch {
+		case (job.Post == "") == (job.Export == ""):
+			return fmt.Errorf("job %d: expected either post or export", i+1)
+		case job.Export != "":
+			if _, ok := render.Lookup(job.Export); !ok {
+				return fmt.Errorf("job %d: unknown export format '%s'; use one of %s", i+1, job.Export, strings.Join(render.Names(), ", "))
+			}
+			if job.Dir == "" {
+				return fmt.Errorf("job %d: export needs a dir", i+1)
+			}
+		case job.Dir != "":
+			return fmt.Errorf("job %d: dir only goes with export", i+1)
+		}
+	}
+	return nil
+}
+
+// next returns the first time after t that the job is due, in loc, or zero
+// for an invalid job.
+func (job scheduleEntry) next(t time.Time, loc *time.Location) time.Time {
+	// Checked when the file was read; midnight if empty
+	at, _ := time.Parse("15:04", job.At)
+	t = t.In(loc)
+	for i := 0; i <= 31; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, at.Hour(), at.Minute(), 0, 0, loc)
+		if day.After(t) && job.on(day) {
+			return day
+		}
+	}
+	return time.Time{}
+}
+
+// on reports whether the job runs on day.
+func (job scheduleEntry) on(day time.Time) bool {
+	switch job.Every {
+	case "day":
+		return true
+	case "month":
+		return day.Day() == 1
+	}
+	week, err := ooo.ParseWorkWeek([]string{job.Every})
+	return err == nil && len(week) == 1 && day.Weekday() == week[0]
+}
+
+// runSchedule runs the jobs under schedule in the config file on the latest
+// dataset until ctx is done. The schedule is read again at least every
+// minute, so that reloading the config file changes it.
+func (gs *groupServer) runSchedule(ctx context.Context, client *http.Client) {
+	last := time.Now()
+	for {
+		gs.mu.RLock()
+		jobs, loc := gs.session.cfg.Schedule, gs.loc
+		gs.mu.RUnlock()
+
+		wait := time.Minute
+		for _, job := range jobs {
+			if due := job.next(last, loc); !due.IsZero() && time.Until(due) < wait {
+				wait = time.Until(due)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		now := time.Now()
+		for _, job := range jobs {
+			if due := job.next(last, loc); due.IsZero() || due.After(now) {
+				continue
+			}
+			if err := gs.runJob(ctx, client, job, loc); err != nil {
+				logFor("schedule").Warn("scheduled job failed", "every", job.Every, "at", job.At, "error", err)
+			}
+		}
+		last = now
+	}
+}
+
+// runJob posts the digest or writes the export of job.
+func (gs *groupServer) runJob(ctx context.Context, client *http.Client, job scheduleEntry, loc *time.Location) error {
+	gs.mu.RLock()
+	ds := gs.ds
+	gs.mu.RUnlock()
+	today := time.Now().In(loc)
+
+	if job.Export != "" {
+		// Checked when the file was read
+		renderer, _ := render.Lookup(job.Export)
+		ext := job.Export
+		if ext == "grid" {
+			ext = "txt"
+		}
+		dir := expandHome(job.Dir)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("unable to create export directory: %v", err)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.%s", unsafeFileChars.ReplaceAllString(ds.Group, "_"), today.Format("2006-01-02"), ext))
+		err := writeExport(renderer, ds, path)
+		gs.session.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: job.Export, Destination: path, Events: len(ds.Absences), Error: errorString(err)})
+		if err != nil {
+			return err
+		}
+		logFor("schedule").Info("group exported", "group", ds.Group, "file", path)
+		return nil
+	}
+
+	// The working days of the coming week on which someone is out
+	from, until := today.Format("2006-01-02"), today.AddDate(0, 0, 7).Format("2006-01-02")
+	var days []alertDay
+	lines := []string{fmt.Sprintf("Out of %s in the coming week:", ds.Group)}
+	for _, d := range coverage(ds, loc) {
+		if d.day < from || d.day >= until {
+			continue
+		}
+		days = append(days, alertDay{Date: d.day, Coverage: d.coverage, Out: d.out})
+		date, _ := time.Parse("2006-01-02", d.day)
+		lines = append(lines, fmt.Sprintf("• %s: %.0f%% in, out: %s", date.Format("Mon Jan 2"), d.coverage*100, strings.Join(d.out, ", ")))
+	}
+	if len(days) == 0 {
+		lines = []string{fmt.Sprintf("Everyone in %s is in the coming week.", ds.Group)}
+	}
+	webhook := os.ExpandEnv(job.Post)
+	err := postWebhook(ctx, client, webhook, struct {
+		Text  string     `json:"text"`
+		Group string     `json:"group"`
+		Days  []alertDay `json:"days"`
+	}{strings.Join(lines, "\n"), ds.Group, days})
+	host := webhook
+	if u, parseErr := url.Parse(webhook); parseErr == nil {
+		host = u.Host
+	}
+	gs.session.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: "digest", Destination: host, Events: len(days), Error: errorString(err)})
+	if err != nil {
+		return fmt.Errorf("unable to send digest: %v", err)
+	}
+	logFor("schedule").Info("digest sent", "group", ds.Group, "days", len(days))
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestScheduleNext(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wednesday June 4 2025, 09:30 in Amsterdam
+	wednesday := time.Date(2025, 6, 4, 9, 30, 0, 0, amsterdam)
+
+	tests := []struct {
+		name  string
+		job   scheduleEntry
+		after time.Time
+		want  string
+	}{
+		{name: "daily later today", job: scheduleEntry{Every: "day", At: "17:00"}, after: wednesday, want: "2025-06-04 17:00"},
+		{name: "daily tomorrow", job: scheduleEntry{Every: "day", At: "08:00"}, after: wednesday, want: "2025-06-05 08:00"},
+		{name: "not again at the time itself", job: scheduleEntry{Every: "day", At: "09:30"}, after: wednesday, want: "2025-06-05 09:30"},
+		{name: "midnight without a time", job: scheduleEntry{Every: "day"}, after: wednesday, want: "2025-06-05 00:00"},
+		{name: "weekday", job: scheduleEntry{Every: "monday", At: "08:00"}, after: wednesday, want: "2025-06-09 08:00"},
+		{name: "short weekday", job: scheduleEntry{Every: "wed", At: "08:00"}, after: wednesday, want: "2025-06-11 08:00"},
+		{name: "first of the month", job: scheduleEntry{Every: "month", At: "07:00"}, after: wednesday, want: "2025-07-01 07:00"},
+		{name: "first of the next year", job: scheduleEntry{Every: "month"}, after: time.Date(2025, 12, 1, 0, 0, 0, 0, amsterdam), want: "2026-01-01 00:00"},
+		{name: "in the time zone", job: scheduleEntry{Every: "day", At: "08:00"}, after: time.Date(2025, 6, 4, 5, 0, 0, 0, time.UTC), want: "2025-06-04 08:00"},
+		{name: "across the change to summer time", job: scheduleEntry{Every: "sunday", At: "08:00"}, after: time.Date(2025, 3, 29, 12, 0, 0, 0, amsterdam), want: "2025-03-30 08:00"},
+		{name: "invalid", job: scheduleEntry{Every: "fortnight"}, after: wednesday, want: "0001-01-01 00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.job.next(tt.after, amsterdam)
+			if !got.IsZero() {
+				got = got.In(amsterdam)
+			}
+			if got.Format("2006-01-02 15:04") != tt.want {
+				t.Errorf("next(%s) = %s, want %s", tt.after, got.Format("2006-01-02 15:04"), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		name string
+		job  scheduleEntry
+		want string
+	}{
+		{name: "digest", job: scheduleEntry{Every: "monday", At: "08:00", Post: "${OOO_DIGEST_WEBHOOK}"}},
+		{name: "export", job: scheduleEntry{Every: "month", Export: "csv", Dir: "/reports"}},
+		{name: "invalid every", job: scheduleEntry{Every: "weekly", Post: "https://example.com"}, want: "job 1: invalid every 'weekly', expected day, month or a weekday"},
+		{name: "invalid time", job: scheduleEntry{Every: "day", At: "8am", Post: "https://example.com"}, want: "job 1: invalid at '8am', expected a time like 08:00"},
+		{name: "nothing to do", job: scheduleEntry{Every: "day"}, want: "job 1: expected either post or export"},
+		{name: "post and export", job: scheduleEntry{Every: "day", Post: "https://example.com", Export: "csv", Dir: "/reports"}, want: "job 1: expected either post or export"},
+		{name: "export without dir", job: scheduleEntry{Every: "day", Export: "csv"}, want: "job 1: export needs a dir"},
+		{name: "dir without export", job: scheduleEntry{Every: "day", Post: "https://example.com", Dir: "/reports"}, want: "job 1: dir only goes with export"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if err := validateSchedule([]scheduleEntry{tt.job}); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("validateSchedule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// schemaCommand prints the JSON Schema of `export --format json`.
+func schemaCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	version := fs.Int("version", render.SchemaVersion, "Version of the schema to print, for consumers of older exports")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("expected no arguments")
+		}
+		schema, ok := render.JSONSchemaVersion(*version)
+		if !ok {
+			return usageErrorf("unknown schema version %d, expected 1 to %d", *version, render.SchemaVersion)
+		}
+		_, err := os.Stdout.Write(schema)
+		return err
+	}
+}
+
+// validateCommand checks a JSON export, or - for stdin, against the schema.
+func validateCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 1 {
+			return usageErrorf("expected one JSON file, or - for stdin")
+		}
+		name := args[0]
+		var data []byte
+		var err error
+		if name == "-" {
+			name = "stdin"
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %v", name, err)
+		}
+
+		err = render.ValidateJSON(data)
+		version := render.DocumentVersion(data)
+		if version == 0 {
+			version = render.SchemaVersion
+		}
+		var schemaErr *render.SchemaError
+		if errors.As(err, &schemaErr) {
+			for _, problem := range schemaErr.Problems {
+				fmt.Println(problem)
+			}
+			return fmt.Errorf("%s doesn't match schema version %d (%d problems)", name, version, len(schemaErr.Problems))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		fmt.Printf("%s matches schema version %d\n", name, version)
+		return nil
+	}
+}
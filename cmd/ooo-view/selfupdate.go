@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// checksumsAsset is the release asset listing the SHA-256 of every binary,
+// in the `sha256sum` format, and signatureAsset its minisign signature.
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = checksumsAsset + ".minisig"
+)
+
+// releaseKey is the minisign public key the releases' checksums are signed
+// with: the second line of its minisign.pub, like RWQ... Builds without it
+// can't self-update. Release builds may also set it with
+//
+//	go build -ldflags "-X main.releaseKey=RWQ..."
+var releaseKey = ""
+
+// maxBinarySize bounds the download of a release binary.
+const maxBinarySize = 200 << 20
+
+// binaryAssetName is the name of the release binary for this platform,
+// e.g. ooo-view_linux_amd64 or ooo-view_windows_amd64.exe.
+func binaryAssetName() string {
+	name := fmt.Sprintf("%s_%s_%s", serviceName, runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func selfUpdateCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	check := fs.Bool("check", false, "Only report whether an update is available")
+	force := fs.Bool("force", false, "Reinstall even if the latest release is not newer")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("self-update takes no arguments")
+		}
+
+		client := &http.Client{Timeout: 5 * time.Minute}
+		current := currentVersion().Version
+		latest, err := latestRelease(ctx, client)
+		if err != nil {
+			return err
+		}
+		if !newerVersion(latest.TagName, current) && !*force {
+			fmt.Printf("ooo-view %s is the latest version.\n", current)
+			return nil
+		}
+		if *check {
+			fmt.Printf("ooo-view %s is available (running %s). Run 'ooo-view self-update' to install it.\n", latest.TagName, current)
+			return nil
+		}
+
+		if releaseKey == "" {
+			return fmt.Errorf("this build has no release signing key to verify %s with; download the release from GitHub instead", latest.TagName)
+		}
+		binaryURL, checksumsURL, signatureURL := "", "", ""
+		for _, asset := range latest.Assets {
+			switch asset.Name {
+			case binaryAssetName():
+				binaryURL = asset.BrowserDownloadURL
+			case checksumsAsset:
+				checksumsURL = asset.BrowserDownloadURL
+			case signatureAsset:
+				signatureURL = asset.BrowserDownloadURL
+			}
+		}
+		if binaryURL == "" {
+			return fmt.Errorf("release %s has no binary for %s/%s", latest.TagName, runtime.GOOS, runtime.GOARCH)
+		}
+		if checksumsURL == "" {
+			return fmt.Errorf("release %s has no %s, refusing to install an unverified binary", latest.TagName, checksumsAsset)
+		}
+		if signatureURL == "" {
+			return fmt.Errorf("release %s has no %s, refusing to install an unverified binary", latest.TagName, signatureAsset)
+		}
+
+		logFor("update").Info("downloading release", "version", latest.TagName, "asset", binaryAssetName())
+		checksums, err := download(ctx, client, checksumsURL, 1<<20)
+		if err != nil {
+			return err
+		}
+		// The checksums come from the same release as the binary, so only
+		// the signature shows they're the maintainers'
+		signature, err := download(ctx, client, signatureURL, 4<<10)
+		if err != nil {
+			return err
+		}
+		if err := verifyMinisign(releaseKey, checksums, signature); err != nil {
+			return fmt.Errorf("%s of release %s: %v", checksumsAsset, latest.TagName, err)
+		}
+		want, err := findChecksum(checksums, binaryAssetName())
+		if err != nil {
+			return err
+		}
+		binary, err := download(ctx, client, binaryURL, maxBinarySize)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(binary)
+		if got := hex.EncodeToString(sum[:]); got != want {
+			return fmt.Errorf("checksum mismatch for %s: got %s, want %s", binaryAssetName(), got, want)
+		}
+
+		path, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("unable to locate the running executable: %v", err)
+		}
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return fmt.Errorf("unable to locate the running executable: %v", err)
+		}
+		if err := replaceExecutable(path, binary); err != nil {
+			return err
+		}
+		fmt.Printf("Updated %s from %s to %s.\n", path, current, latest.TagName)
+		return nil
+	}
+}
+
+func download(ctx context.Context, client *http.Client, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %v", url, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("download of %s is larger than expected", url)
+	}
+	return data, nil
+}
+
+// findChecksum returns the hex SHA-256 listed for name in a sha256sum file.
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s lists no checksum for %s", checksumsAsset, name)
+}
+
+// verifyMinisign checks sig, a minisign signature file, of data against
+// key, the base64 line of a minisign public key. Both the legacy and the
+// prehashed signatures are accepted, and the trusted comment has to be
+// signed as well.
+func verifyMinisign(key string, data, sig []byte) error {
+	rawKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(rawKey) != 2+8+ed25519.PublicKeySize || string(rawKey[:2]) != "Ed" {
+		return errors.New("invalid release signing key")
+	}
+	keyID, publicKey := rawKey[2:10], ed25519.PublicKey(rawKey[10:])
+
+	lines := strings.Split(strings.ReplaceAll(string(sig), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed signature")
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(rawSig) != 2+8+ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	if !bytes.Equal(rawSig[2:10], keyID) {
+		return errors.New("signed with another key than the release signing key")
+	}
+	signed := data
+	switch string(rawSig[:2]) {
+	case "Ed":
+	case "ED":
+		sum := blake2b.Sum512(data)
+		signed = sum[:]
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", rawSig[:2])
+	}
+	signature := rawSig[10:]
+	if !ed25519.Verify(publicKey, signed, signature) {
+		return errors.New("invalid signature")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	trusted := append(append([]byte(nil), signature...), strings.TrimPrefix(lines[2], "trusted comment: ")...)
+	if !ed25519.Verify(publicKey, trusted, globalSig) {
+		return errors.New("invalid signature of the trusted comment")
+	}
+	return nil
+}
+
+// replaceExecutable atomically swaps the binary at path for data, keeping
+// its permissions. Windows can't overwrite a running executable, so the old
+// one is moved aside first.
+func replaceExecutable(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to read the running executable: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+serviceName+"-update-*")
+	if err != nil {
+		return fmt.Errorf("unable to write the new executable: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write the new executable: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write the new executable: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0111); err != nil {
+		return fmt.Errorf("unable to make the new executable runnable: %v", err)
+	}
+
+	old := ""
+	if runtime.GOOS == "windows" {
+		old = path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return fmt.Errorf("unable to move the old executable aside: %v", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		// Put the old executable back rather than leave none
+		if old != "" {
+			if restoreErr := os.Rename(old, path); restoreErr != nil {
+				return fmt.Errorf("unable to replace the executable: %v; the old one is left at %s: %v", err, old, restoreErr)
+			}
+		}
+		return fmt.Errorf("unable to replace the executable: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestVerifyMinisign(t *testing.T) {
+	// a fixed key pair, as minisign -G would write it
+	privateKey := ed25519.NewKeyFromSeed([]byte("ooo-view release signing key 32b"))
+	keyID := []byte{0x1f, 0x2e, 0x3d, 0x4c, 0x5b, 0x6a, 0x79, 0x88}
+	publicKey := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), privateKey.Public().(ed25519.PublicKey)...))
+	otherKeyID := []byte{0x88, 0x79, 0x6a, 0x5b, 0x4c, 0x3d, 0x2e, 0x1f}
+
+	checksums := []byte("3b5d5c3712955042212316173ccf37be800b2ffdc7d8c1e3bdcafd1d5a4a1e44  ooo-view_linux_amd64.tar.gz\n")
+	const trustedComment = "timestamp:1748851200\tfile:checksums.txt\thashed"
+
+	// sign returns the signature file of data as minisign -S writes it,
+	// prehashed with algorithm "ED" or legacy with "Ed"
+	sign := func(algorithm string, id, data []byte, comment string) []byte {
+		signed := data
+		if algorithm == "ED" {
+			sum := blake2b.Sum512(data)
+			signed = sum[:]
+		}
+		signature := ed25519.Sign(privateKey, signed)
+		globalSig := ed25519.Sign(privateKey, append(append([]byte(nil), signature...), comment...))
+		return []byte("untrusted comment: signature from minisign secret key\n" +
+			base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), id...), signature...)) + "\n" +
+			"trusted comment: " + comment + "\n" +
+			base64.StdEncoding.EncodeToString(globalSig) + "\n")
+	}
+	prehashed := sign("ED", keyID, checksums, trustedComment)
+	legacy := sign("Ed", keyID, checksums, trustedComment)
+	// withComment replaces the trusted comment of sig, keeping its signature
+	withComment := func(sig []byte, comment string) []byte {
+		lines := strings.Split(string(sig), "\n")
+		lines[2] = "trusted comment: " + comment
+		return []byte(strings.Join(lines, "\n"))
+	}
+	// relabel changes the algorithm of sig, keeping its signature
+	relabel := func(sig []byte, algorithm string) []byte {
+		lines := strings.Split(string(sig), "\n")
+		raw, _ := base64.StdEncoding.DecodeString(lines[1])
+		lines[1] = base64.StdEncoding.EncodeToString(append([]byte(algorithm), raw[2:]...))
+		return []byte(strings.Join(lines, "\n"))
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		data []byte
+		sig  []byte
+		want string
+	}{
+		{name: "prehashed", key: publicKey, data: checksums, sig: prehashed},
+		{name: "legacy", key: publicKey, data: checksums, sig: legacy},
+		{name: "CRLF line endings", key: publicKey, data: checksums, sig: []byte(strings.ReplaceAll(string(prehashed), "\n", "\r\n"))},
+		{name: "another key ID", key: publicKey, data: checksums, sig: sign("ED", otherKeyID, checksums, trustedComment), want: "signed with another key than the release signing key"},
+		{name: "modified checksums", key: publicKey, data: append([]byte("0"), checksums[1:]...), sig: prehashed, want: "invalid signature"},
+		{name: "modified trusted comment", key: publicKey, data: checksums, sig: withComment(prehashed, "timestamp:1748851200\tfile:other.txt\thashed"), want: "invalid signature of the trusted comment"},
+		{name: "prehashed signature labelled legacy", key: publicKey, data: checksums, sig: relabel(prehashed, "Ed"), want: "invalid signature"},
+		{name: "legacy signature labelled prehashed", key: publicKey, data: checksums, sig: relabel(legacy, "ED"), want: "invalid signature"},
+		{name: "unknown algorithm", key: publicKey, data: checksums, sig: sign("Ex", keyID, checksums, trustedComment), want: `unsupported signature algorithm "Ex"`},
+		{name: "missing trusted comment", key: publicKey, data: checksums, sig: []byte(strings.Join(strings.Split(string(prehashed), "\n")[:2], "\n")), want: "malformed signature"},
+		{name: "invalid key", key: "RWQ=", data: checksums, sig: prehashed, want: "invalid release signing key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if err := verifyMinisign(tt.key, tt.data, tt.sig); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("verifyMinisign() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+func serveCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to listen on")
+	interval := fs.Duration("interval", 15*time.Minute, "How often the events are fetched again")
+	var alertBelow percent
+	fs.Var(&alertBelow, "alert-coverage-below", "Post to --alert-webhook when a refresh finds a coming working day on which fewer than this share of the group is in (e.g., 50%)")
+	handoverInvites := fs.Bool("alert-handover-invites", false, "Attach a handover invite for each leave of the days alerted on to the alert, planned with handovers in the config file")
+	webhook := fs.String("alert-webhook", "", "URL of a Slack incoming webhook, or another webhook taking JSON, for coverage alerts; best set as OOO_ALERT_WEBHOOK")
+	requireToken := fs.Bool("require-token", false, "Only answer requests with a token created with serve tokens create, passed as ?token= or as a bearer token")
+	tokenExpiry := durationFlag(90 * 24 * time.Hour)
+	fs.Var(&tokenExpiry, "expires", "With tokens create, how long the token is valid, e.g. 30d; 0 for no expiry")
+	noArchive := fs.Bool("no-archive", false, "Don't archive a snapshot on every refresh for ooo-view history")
+	retention := durationFlag(90 * 24 * time.Hour)
+	fs.Var(&retention, "archive-retention", "How long archived snapshots are kept, e.g. 365d; 0 keeps them forever")
+	ingestToken := fs.String("ingest-token", "", "Accept absences POSTed to /api/v1/absences by other systems sending this bearer token; best set as OOO_INGEST_TOKEN")
+	slackToken := fs.String("slack-bot-token", "", "Bot token of a Slack app to serve its App Home and reminders from /slack/events and /slack/interactions; best set as OOO_SLACK_BOT_TOKEN")
+	slackSecret := fs.String("slack-signing-secret", "", "Signing secret of the Slack app, to verify its requests; best set as OOO_SLACK_SIGNING_SECRET")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) > 0 && args[0] == "tokens" {
+			return feedTokensCommand(s, args[1:], time.Duration(tokenExpiry), *addr)
+		}
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		if *interval <= 0 {
+			return usageErrorf("--interval must be positive")
+		}
+		if (alertBelow > 0) != (*webhook != "") {
+			return usageErrorf("--alert-coverage-below and --alert-webhook go together")
+		}
+		if *handoverInvites && *webhook == "" {
+			return usageErrorf("--alert-handover-invites needs --alert-webhook")
+		}
+		if (*slackToken != "") != (*slackSecret != "") {
+			return usageErrorf("--slack-bot-token and --slack-signing-secret go together")
+		}
+		loc, err := time.LoadLocation(s.cfg.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+
+		// Refreshes run in the background, next to the request log
+		s.cfg.NoProgress = true
+		gs := &groupServer{session: s, group: group, loc: loc, archive: !*noArchive, retention: time.Duration(retention)}
+		if *webhook != "" {
+			gs.alerts = &coverageAlerter{threshold: alertBelow, webhook: *webhook, client: newHTTPClient(s.cfg), audit: s.audit}
+			if *handoverInvites {
+				gs.alerts.handovers = &s.cfg.Handovers
+			}
+		}
+		if *slackToken != "" {
+			if gs.slack, err = newSlackApp(gs, *slackToken, *slackSecret); err != nil {
+				return err
+			}
+		}
+		// SIGHUP reloads the config file rather than stopping the server
+		reload := reloadSignals(ctx)
+		if err := gs.refresh(ctx); err != nil {
+			return err
+		}
+		go gs.refreshEvery(ctx, *interval, reload)
+		go gs.runSchedule(ctx, newHTTPClient(s.cfg))
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", gs.handle(func(w http.ResponseWriter, ds *ooo.Dataset) error {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			render.StaleBanner(w, ds)
+			render.Grid(w, ds)
+			return nil
+		}))
+		mux.HandleFunc("/events.json", gs.handle(func(w http.ResponseWriter, ds *ooo.Dataset) error {
+			w.Header().Set("Content-Type", "application/json")
+			return render.JSON(w, ds)
+		}))
+		mux.HandleFunc("/calendar.ics", gs.handle(func(w http.ResponseWriter, ds *ooo.Dataset) error {
+			w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+			return render.ICS(w, ds)
+		}))
+
+		var handler http.Handler = mux
+		if *requireToken {
+			path, err := feedTokensPath()
+			if err != nil {
+				return err
+			}
+			handler = requireFeedToken(&feedTokenChecker{path: path}, mux)
+		}
+		// Submitters authenticate with the ingest token, and Slack by
+		// signing its requests, rather than with a feed token
+		if *ingestToken != "" || gs.slack != nil {
+			root := http.NewServeMux()
+			if *ingestToken != "" {
+				root.Handle("/api/v1/absences", gs.handleAbsences(*ingestToken))
+				root.Handle("/api/v1/absences/", gs.handleAbsences(*ingestToken))
+			}
+			if gs.slack != nil {
+				root.HandleFunc("/slack/events", gs.slack.handleEvents)
+				root.HandleFunc("/slack/interactions", gs.slack.handleInteractions)
+			}
+			root.Handle("/", handler)
+			handler = root
+		}
+
+		server := &http.Server{
+			Addr:              *addr,
+			Handler:           otelhttp.NewHandler(logRequests(handler), "serve"),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		logFor("serve").Info("serving", "group", gs.group, "url", "http://"+*addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("unable to serve: %v", err)
+		}
+		return nil
+	}
+}
+
+// groupServer keeps the latest dataset of one group for the HTTP handlers.
+type groupServer struct {
+	session *session
+	group   string
+	loc     *time.Location
+	// alerts, if set, is told about every refreshed dataset
+	alerts *coverageAlerter
+	// slack, if set, serves the Slack app and sends its reminders on
+	// every refresh
+	slack *slackApp
+	// archive keeps a snapshot of every refreshed dataset for retention
+	archive   bool
+	retention time.Duration
+
+	mu sync.RWMutex
+	ds *ooo.Dataset
+}
+
+func (gs *groupServer) refresh(ctx context.Context) error {
+	ds, err := gs.session.loadGroup(ctx, gs.group)
+	if err != nil {
+		return err
+	}
+	// Absences submitted since loadGroup read them are added again under
+	// the lock the submissions hold
+	gs.mu.Lock()
+	ds = gs.refilter(ds)
+	gs.ds = ds
+	gs.mu.Unlock()
+	if gs.archive {
+		if err := archiveDataset(ds, gs.retention); err != nil {
+			logFor("serve").Warn("could not archive snapshot", "group", gs.group, "error", err)
+		}
+	}
+	if gs.alerts != nil {
+		if err := gs.alerts.check(ctx, ds, gs.loc); err != nil {
+			logFor("alerts").Warn("could not send coverage alert", "group", gs.group, "error", err)
+		}
+	}
+	if gs.slack != nil {
+		gs.slack.checkReminders(ctx, ds, gs.loc)
+	}
+	return nil
+}
+
+// refreshEvery fetches the group again every interval until ctx is done,
+// and right away with the new config when reload receives. On failure the
+// previous dataset keeps being served.
+func (gs *groupServer) refreshEvery(ctx context.Context, interval time.Duration, reload <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-reload:
+			if err := gs.reload(); err != nil {
+				logFor("serve").Warn("could not reload the config file, keeping the last one", "error", err)
+				continue
+			}
+		case <-ctx.Done():
+			return
+		}
+		if err := gs.refresh(ctx); err != nil {
+			logFor("serve").Warn("could not refresh", "group", gs.group, "error", err)
+		}
+	}
+}
+
+// reload reads the config file again. The handlers read the config under
+// gs.mu, and refreshes happen on the goroutine calling reload, so neither
+// sees it change halfway.
+func (gs *groupServer) reload() error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	s := gs.session
+	last := s.cfg
+	if err := s.reloadConfig(); err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(s.cfg.TimeZone)
+	if err != nil {
+		s.cfg = last
+		return fmt.Errorf("invalid timezone: %v", err)
+	}
+	gs.loc = loc
+	return nil
+}
+
+// handle adapts a writer of the current dataset to an http.HandlerFunc.
+func (gs *groupServer) handle(write func(w http.ResponseWriter, ds *ooo.Dataset) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		gs.mu.RLock()
+		ds := gs.ds
+		gs.mu.RUnlock()
+
+		err := write(w, ds)
+		if err != nil {
+			logFor("serve").Warn("could not write response", "path", r.URL.Path, "error", err)
+		}
+		destination := r.RemoteAddr
+		if consumer, ok := r.Context().Value(feedConsumerKey{}).(string); ok {
+			destination = consumer + " (" + r.RemoteAddr + ")"
+		}
+		gs.session.audit.Record(auditRecord{Event: "serve", Group: ds.Group, Operation: r.URL.Path, Destination: destination, Events: len(ds.Absences), Error: errorString(err)})
+	}
+}
+
+// logRequests logs every request with its status and duration.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logFor("serve").Info("request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	})
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/cloudidentity/v1"
+	"google.golang.org/api/option"
+
+	"github.com/klaasmeinke/ooo-view/pkg/auth"
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// session holds the state shared by everything a single command run does:
+// the configuration, the event store and the authenticated provider. The
+// store and provider are set up on first use.
+type session struct {
+	cfg     Config
+	timings *runTimings
+
+	store       *ooo.Store // nil when the cache is disabled
+	storeOpened bool
+	provider    *ooo.GoogleProvider
+	apiClient   *http.Client // authenticated, set with provider
+	audit       *auditLog    // nil without --audit-log
+
+	// remote is the proxy given with --remote, set up on first use
+	remote *remoteProvider
+	// export, if set, is the JSON export read by render, which replaces
+	// the provider and window
+	export *render.JSONProvider
+	// plugins are the provider plugins by name, kept for their rate
+	// limits and caches
+	plugins map[string]*ooo.ExecProvider
+
+	// at is a time in the first week fetched, if not the current one
+	at time.Time
+
+	// partial is set when a fetch was interrupted and its partial results
+	// returned
+	partial bool
+}
+
+// eventStore returns the local event store, or nil if it is disabled or
+// can't be opened.
+func (s *session) eventStore() *ooo.Store {
+	if s.storeOpened {
+		return s.store
+	}
+	s.storeOpened = true
+	// The store only caches Google Calendar events, which the proxy caches
+	// with --remote
+	if s.cfg.NoCache || s.usesPlugin() || s.cfg.FreebusyOnly || s.cfg.Remote != "" || s.export != nil {
+		return nil
+	}
+
+	store, err := openEventStore(s.cfg.CacheTTL, s.cfg.Refresh)
+	if err != nil {
+		logFor("store").Warn("event cache disabled", "error", err)
+		return nil
+	}
+	s.store = store
+	return store
+}
+
+// usesPlugin reports whether --provider names a plugin instead of Google.
+func (s *session) usesPlugin() bool {
+	return s.cfg.Provider != "" && s.cfg.Provider != "google"
+}
+
+// source returns the provider chosen with --provider, or the proxy given
+// with --remote.
+func (s *session) source(ctx context.Context) (ooo.Provider, error) {
+	if s.export != nil {
+		return s.export, nil
+	}
+	if s.cfg.Remote != "" {
+		if s.remote == nil {
+			s.remote = newRemoteProvider(s.cfg)
+		}
+		return s.remote, nil
+	}
+	names := splitList(s.cfg.Provider)
+	if len(names) > 1 {
+		multi := &ooo.MultiProvider{}
+		for _, name := range names {
+			p, err := s.namedProvider(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			multi.Providers = append(multi.Providers, p)
+		}
+		return multi, nil
+	}
+	if s.usesPlugin() {
+		return s.plugin(s.cfg.Provider)
+	}
+	return s.googleProvider(ctx)
+}
+
+// namedProvider returns Google for "google" and the plugin called name
+// otherwise.
+func (s *session) namedProvider(ctx context.Context, name string) (ooo.Provider, error) {
+	if name == "google" {
+		return s.googleProvider(ctx)
+	}
+	return s.plugin(name)
+}
+
+// plugin returns the provider plugin called name, limited as configured
+// under plugins. Its cache lasts --cache-ttl unless configured otherwise,
+// and is off with --no-cache or --refresh.
+func (s *session) plugin(name string) (*ooo.ExecProvider, error) {
+	if p, ok := s.plugins[name]; ok {
+		return p, nil
+	}
+	p, err := ooo.FindPlugin(name)
+	if err != nil {
+		return nil, err
+	}
+	limits := s.cfg.Plugins[name]
+	p.RateLimit = limits.RateLimit
+	p.CacheTTL = s.cfg.CacheTTL
+	if limits.CacheTTL != nil {
+		p.CacheTTL = time.Duration(*limits.CacheTTL)
+	}
+	if s.cfg.NoCache || s.cfg.Refresh {
+		p.CacheTTL = 0
+	}
+	if s.plugins == nil {
+		s.plugins = make(map[string]*ooo.ExecProvider)
+	}
+	s.plugins[name] = p
+	return p, nil
+}
+
+// googleProvider authenticates and returns the Calendar API provider.
+func (s *session) googleProvider(ctx context.Context) (*ooo.GoogleProvider, error) {
+	if s.provider != nil {
+		return s.provider, nil
+	}
+
+	// Share one tuned HTTP client between the OAuth flow and the Calendar API
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, newHTTPClient(s.cfg))
+
+	stopAuth := s.timings.Phase("auth")
+	apiClient, err := s.authorizedClient(ctx)
+	stopAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	apiClient.Timeout = s.cfg.HTTPTimeout
+	calService, err := calendar.NewService(ctx, option.WithHTTPClient(apiClient))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create calendar service: %v", err)
+	}
+
+	s.apiClient = apiClient
+	s.provider = ooo.NewGoogleProvider(calService, s.eventStore())
+	s.provider.SecondaryCalendars = splitList(s.cfg.SecondaryCalendars)
+	s.provider.FreebusyOnly = s.cfg.FreebusyOnly
+	s.provider.FreebusyFallback = !s.cfg.NoFreebusyFallback
+	if s.cfg.Batch {
+		s.provider.BatchClient = apiClient
+	}
+	if s.cfg.DirectoryAccess {
+		if s.provider.Directory, err = admin.NewService(ctx, option.WithHTTPClient(apiClient)); err != nil {
+			return nil, fmt.Errorf("unable to create directory service: %v", err)
+		}
+	}
+	if s.audit != nil {
+		s.audit.SetIdentity(accountEmail(ctx, calService))
+		s.provider.OnRequest = func(operation, calendarId string, err error) {
+			s.audit.Record(auditRecord{Event: "api_request", Operation: operation, Calendar: calendarId, Error: errorString(err)})
+		}
+	}
+	return s.provider, nil
+}
+
+// authorizedClient returns an HTTP client signed in as the service account
+// given with --service-account, or else as the user, signing in if needed.
+func (s *session) authorizedClient(ctx context.Context) (*http.Client, error) {
+	if s.cfg.ServiceAccount != "" {
+		return auth.ServiceAccountClient(ctx, expandHome(s.cfg.ServiceAccount), s.cfg.Impersonate, scopes(s.cfg)...)
+	}
+	creds, err := newCredentialStore(s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	oauthConfig, err := getConfig(ctx, creds, s.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get client config: %v", err)
+	}
+	tok, err := getToken(ctx, oauthConfig, creds, s.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get token: %v", err)
+	}
+	return oauthConfig.Client(ctx, tok), nil
+}
+
+// cloudIdentity returns the Cloud Identity API, authenticated like the
+// provider.
+func (s *session) cloudIdentity(ctx context.Context) (*cloudidentity.Service, error) {
+	if _, err := s.googleProvider(ctx); err != nil {
+		return nil, err
+	}
+	srv, err := cloudidentity.NewService(ctx, option.WithHTTPClient(s.apiClient))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cloud identity service: %v", err)
+	}
+	return srv, nil
+}
+
+// groupArg returns the group named on the command line, or the configured
+// default group if none was given.
+func (s *session) groupArg(args []string) (string, error) {
+	switch {
+	case len(args) == 1:
+		return args[0], nil
+	case len(args) == 0 && s.cfg.DefaultGroup != "":
+		return s.cfg.DefaultGroup, nil
+	case len(args) == 0 && s.cfg.Manager != "" && s.cfg.Roster != nil:
+		// The manager's slice of the roster is the group
+		return ooo.RosterReportsPrefix + s.cfg.Manager, nil
+	case len(args) == 0:
+		return "", usageErrorf("no group given; pass a group email or alias, or set default_group in the config file or OOO_GROUP")
+	default:
+		return "", usageErrorf("expected one group email or alias")
+	}
+}
+
+// window returns the window of weeks to fetch, starting with the current
+// week unless at is set, or the window of --range.
+func (s *session) window() (time.Time, time.Time) {
+	if s.export != nil {
+		return s.export.TimeMin, s.export.TimeMax
+	}
+	if s.at.IsZero() && s.cfg.Range != "" {
+		// Checked with the flags
+		first, last, _ := rangeWindow(s.cfg.Range, s.cfg.Ranges, time.Now())
+		return first, last
+	}
+	if s.at.IsZero() {
+		return ooo.Window(time.Now(), s.cfg.WeeksAhead)
+	}
+	return ooo.Window(s.at, s.cfg.WeeksAhead)
+}
+
+// loadGroup fetches the OOO events of the group (or alias) for the configured
+// window. With --offline, or when the API is unavailable, it returns the
+// group's last stored dataset instead.
+func (s *session) loadGroup(ctx context.Context, group string) (ds *ooo.Dataset, err error) {
+	groupEmail := resolveGroup(group, s.cfg.GroupAliases)
+	ctx, span := tracer.Start(ctx, "load group", trace.WithAttributes(attribute.String("group", groupEmail)))
+	defer func() { endSpan(span, err) }()
+
+	store := s.eventStore()
+	if s.cfg.DryRun {
+		if err := s.planGroup(ctx, os.Stdout, groupEmail); err != nil {
+			return nil, err
+		}
+		return nil, errDryRun
+	}
+	loc, err := time.LoadLocation(s.cfg.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %v", err)
+	}
+	if s.cfg.Offline {
+		if store == nil {
+			return nil, fmt.Errorf("--offline needs the local event cache")
+		}
+		s.audit.Record(auditRecord{Event: "calendar_read", Group: groupEmail, Source: "cache"})
+		return s.cachedGroup(store, groupEmail, loc)
+	}
+
+	provider, err := s.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timeMin, timeMax := s.window()
+	ds, err = fetchGroupDataset(ctx, provider, groupEmail, timeMin, timeMax, loc, s.cfg, s.timings, s.audit)
+	if google, ok := provider.(*ooo.GoogleProvider); ok {
+		logFor("api").Debug(google.UsageSummary())
+	}
+	// After Ctrl+C, show what was fetched before it
+	var partialErr *ooo.PartialError
+	if errors.As(err, &partialErr) {
+		logFor("fetch").Warn("fetch cancelled, showing partial results", "fetched", partialErr.Fetched, "total", partialErr.Total)
+		s.partial = true
+		err = nil
+	}
+	// Calendars given up on at the deadline are shown as unavailable
+	var unavailableErr *ooo.UnavailableError
+	if errors.As(err, &unavailableErr) {
+		logFor("fetch").Warn("some calendars are unavailable", "calendars", len(unavailableErr.People), "deadline", unavailableErr.Deadline)
+		err = nil
+	}
+	if err != nil {
+		// Fall back to the last cached dataset if the API can't be reached
+		if store != nil && ooo.IsUnavailable(err) {
+			logFor("fetch").Warn("falling back to cached data", "error", err)
+			if ds, cacheErr := s.cachedGroup(store, groupEmail, loc); cacheErr == nil {
+				return ds, nil
+			}
+		}
+		return nil, err
+	}
+
+	// The store only records complete memberships
+	if store != nil && partialErr == nil && unavailableErr == nil {
+		if err := store.PutGroup(groupEmail, timeMin, timeMax, ds.Members); err != nil {
+			logFor("store").Warn("could not store group members", "group", groupEmail, "error", err)
+		}
+	}
+
+	if unavailableErr != nil {
+		ds.Unavailable = unavailableErr.People
+	}
+	ds.MergeIdentities(s.cfg.Identities)
+	s.filterAbsences(ds)
+	if s.cfg.FetchWorkWeeks {
+		if !ooo.Supports[ooo.WorkWeekProvider](provider) {
+			logFor("fetch").Warn("the provider doesn't know work weeks; set them with work_weeks in the config file", "provider", ooo.SourceOf(provider))
+		}
+		ds.WorkWeeks = ooo.FetchWorkWeeks(ctx, provider, ds.Members)
+	}
+	s.applyWorkWeeks(ds)
+	s.applyAssumptions(ds, loc)
+	if err := s.applyMilestones(ds, loc); err != nil {
+		return nil, err
+	}
+	if calendars := splitList(s.cfg.HolidayCalendars); len(calendars) > 0 {
+		if !ooo.Supports[ooo.HolidayProvider](provider) {
+			logFor("fetch").Warn("the provider can't read holiday calendars; set holidays in the config file", "provider", ooo.SourceOf(provider))
+		}
+		ds.AddHolidays(ooo.FetchHolidays(ctx, provider, calendars, timeMin, timeMax, loc))
+	}
+	s.applyHolidays(ds, loc)
+	ds.Roster = s.cfg.Roster
+	if s.cfg.DayBoundary == dayBoundaryPerPerson {
+		if !ooo.Supports[ooo.TimeZoneProvider](provider) {
+			logFor("fetch").Warn("the provider doesn't know time zones; bucketing days in --timezone", "provider", ooo.SourceOf(provider))
+		}
+		ds.TimeZones = ooo.FetchTimeZones(ctx, provider, ds.Members)
+	}
+	if s.cfg.GroupBy == groupByLocation {
+		if !ooo.Supports[ooo.OfficeProvider](provider) {
+			logFor("fetch").Warn("the provider doesn't know offices", "provider", ooo.SourceOf(provider))
+		}
+		s.fetchOffices(ctx, provider, ds)
+	}
+	if ds, err = s.applyManager(ctx, provider, ds); err != nil {
+		return nil, err
+	}
+	if s.cfg.RedactSummaries {
+		ds.RedactSummaries()
+	}
+	ds.Partial = partialErr != nil
+	if s.remote != nil {
+		s.remote.annotate(ds)
+	}
+	if s.export != nil {
+		ds.FetchedAt = s.export.FetchedAt
+		ds.Unavailable = s.export.Unavailable
+		ds.NoData = s.export.NoData
+	}
+	return ds, nil
+}
+
+// cachedGroup returns the group's last stored dataset, filtered like a fresh
+// fetch.
+func (s *session) cachedGroup(store *ooo.Store, groupEmail string, loc *time.Location) (*ooo.Dataset, error) {
+	ds, err := store.LastDataset(groupEmail, loc)
+	if err != nil {
+		return nil, err
+	}
+	ds.MergeIdentities(s.cfg.Identities)
+	s.filterAbsences(ds)
+	s.applyWorkWeeks(ds)
+	s.applyAssumptions(ds, loc)
+	if err := s.applyMilestones(ds, loc); err != nil {
+		return nil, err
+	}
+	s.applyHolidays(ds, loc)
+	ds.Roster = s.cfg.Roster
+	if ds, err = s.applyManager(context.Background(), nil, ds); err != nil {
+		return nil, err
+	}
+	if s.cfg.RedactSummaries {
+		ds.RedactSummaries()
+	}
+	return ds, nil
+}
+
+// filterAbsences adds the absences submitted for the group to the fetched
+// ones, so that they're reconciled and filtered alike, then applies
+// --reconcile, --min-duration, --response-status and --types. The absences
+// as fetched are kept in ds.Fetched.
+func (s *session) filterAbsences(ds *ooo.Dataset) {
+	ds.Fetched = slices.Clone(ds.Absences)
+	s.applyManualAbsences(ds)
+	ds.Reconcile(s.reconciliation())
+	ds.FilterByMinDuration(s.cfg.MinDuration)
+	ds.FilterByResponseStatus(splitList(s.cfg.ResponseStatus))
+	ds.FilterByKinds(s.kinds())
+}
+
+// fetchOffices sets the offices of the members, placed in the regions of the
+// config file.
+func (s *session) fetchOffices(ctx context.Context, provider ooo.Provider, ds *ooo.Dataset) {
+	ds.Offices = ooo.FetchOffices(ctx, provider, ds.Members)
+	for person, office := range ds.Offices {
+		if region, ok := s.cfg.Regions[office.Name]; ok {
+			office.Region = region
+			ds.Offices[person] = office
+		}
+	}
+}
+
+// applyAssumptions adds the hypothetical absences of --assume. A person
+// given as e.g. bob@ is the member whose address starts with it.
+func (s *session) applyAssumptions(ds *ooo.Dataset, loc *time.Location) {
+	for _, as := range s.cfg.Assume {
+		person := s.cfg.Identities.Canonical(as.person)
+		if strings.HasSuffix(person, "@") {
+			var matches []string
+			for _, member := range ds.Members {
+				if strings.HasPrefix(member, person) {
+					matches = append(matches, member)
+				}
+			}
+			if len(matches) != 1 {
+				logFor("assume").Warn("not exactly one member matches; give the full address", "person", person, "matches", len(matches))
+				continue
+			}
+			person = matches[0]
+		}
+		first, _ := time.ParseInLocation("2006-01-02", as.first, loc)
+		last, _ := time.ParseInLocation("2006-01-02", as.last, loc)
+		if last.Before(ds.TimeMin) || !first.Before(ds.TimeMax) {
+			logFor("assume").Warn("the assumed absence is outside the window", "person", person, "first", as.first, "last", as.last)
+			continue
+		}
+		ds.AddAssumed(person, first, last)
+	}
+}
+
+// reconciliation returns the policy of --reconcile. A provider given to
+// prefer by name, like hr or google, stands for the source of its
+// absences.
+func (s *session) reconciliation() ooo.Reconciliation {
+	// Validated with the other flags
+	r, _ := ooo.ParseReconciliation(s.cfg.Reconcile)
+	switch {
+	case r.Policy != ooo.ReconcilePrefer:
+	case r.Source == "google":
+		r.Source = ooo.SourceGoogleCalendar
+	case !strings.Contains(r.Source, ":") && r.Source != ooo.SourceGoogleCalendar:
+		r.Source = (&ooo.ExecProvider{Name: r.Source}).Source()
+	}
+	return r
+}
+
+// kinds returns the kinds of absences chosen with --types.
+func (s *session) kinds() []ooo.Kind {
+	var kinds []ooo.Kind
+	for _, name := range splitList(s.cfg.Types) {
+		// Validated with the other flags
+		kind, _ := ooo.ParseKind(name)
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// applyWorkWeeks sets the work weeks from the config file, which take
+// precedence over those of the provider.
+func (s *session) applyWorkWeeks(ds *ooo.Dataset) {
+	for person, week := range s.cfg.WorkWeeks {
+		if ds.WorkWeeks == nil {
+			ds.WorkWeeks = make(map[string]ooo.WorkWeek)
+		}
+		ds.WorkWeeks[s.cfg.Identities.Canonical(person)] = week
+	}
+}
+
+// applyManager narrows ds to the people reporting to --manager, going by
+// the roster if there is one and otherwise asking provider, which is nil
+// offline.
+func (s *session) applyManager(ctx context.Context, provider ooo.Provider, ds *ooo.Dataset) (*ooo.Dataset, error) {
+	if s.cfg.Manager == "" {
+		return ds, nil
+	}
+	var reports []string
+	switch {
+	case s.cfg.Roster != nil && s.cfg.DirectReports:
+		reports = s.cfg.Roster.DirectReports(s.cfg.Manager)
+	case s.cfg.Roster != nil:
+		reports = s.cfg.Roster.Reports(s.cfg.Manager)
+	case provider == nil:
+		return nil, fmt.Errorf("--manager needs a roster offline; see --roster")
+	default:
+		people := append(append([]string(nil), ds.Members...), ds.WithoutData()...)
+		var err error
+		if reports, err = ooo.ReportsOf(ctx, provider, people, s.cfg.Manager, s.cfg.DirectReports); err != nil {
+			return nil, fmt.Errorf("unable to find the reports of %s: %v", s.cfg.Manager, err)
+		}
+	}
+	sub := ds.Subset(reports)
+	if len(sub.Members) == 0 && len(sub.WithoutData()) == 0 {
+		logFor("fetch").Warn("no one in the group reports to the manager", "manager", s.cfg.Manager)
+	}
+	return sub, nil
+}
+
+// applyHolidays adds the holidays of the config file within the window.
+func (s *session) applyHolidays(ds *ooo.Dataset, loc *time.Location) {
+	// Validated when the file was read
+	holidays, _ := ooo.ParseHolidays(s.cfg.Holidays, loc)
+	var inWindow []ooo.Holiday
+	for _, h := range holidays {
+		if !h.Date.Before(ds.TimeMin.AddDate(0, 0, -1)) && h.Date.Before(ds.TimeMax) {
+			inWindow = append(inWindow, h)
+		}
+	}
+	ds.AddHolidays(inWindow)
+}
+
+// Close releases the event store.
+func (s *session) Close() error {
+	s.audit.Close()
+	if s.store == nil {
+		return nil
+	}
+	return s.store.Close()
+}
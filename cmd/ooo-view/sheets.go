@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// sheetsConfig is the sheets section of the config file.
+type sheetsConfig struct {
+	Spreadsheet string `yaml:"spreadsheet"`
+	MatrixTab   string `yaml:"matrix_tab"`
+	EventsTab   string `yaml:"events_tab"`
+}
+
+func sheetsCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addWindowFlags(fs, cfg)
+	addFetchFlags(fs, cfg)
+	spreadsheet := fs.String("spreadsheet", "", "ID of the spreadsheet, from its URL (default: sheets.spreadsheet from the config file)")
+	interval := fs.Duration("interval", 15*time.Minute, "How often the events are fetched again and the sheet updated")
+	once := fs.Bool("once", false, "Update the sheet once and exit, e.g. from cron")
+	// Writing the sheet needs more than the read-only sign-in
+	cfg.SheetsAccess = true
+
+	return func(ctx context.Context, s *session, args []string) error {
+		group, err := s.groupArg(args)
+		if err != nil {
+			return err
+		}
+		sc := s.cfg.Sheets
+		if *spreadsheet != "" {
+			sc.Spreadsheet = *spreadsheet
+		}
+		if sc.Spreadsheet == "" {
+			return usageErrorf("set the spreadsheet with --spreadsheet or in the sheets section of the config file")
+		}
+		if sc.MatrixTab == "" {
+			sc.MatrixTab = "Matrix"
+		}
+		if sc.EventsTab == "" {
+			sc.EventsTab = "Events"
+		}
+		if *interval <= 0 {
+			return usageErrorf("--interval must be positive")
+		}
+
+		if _, err := s.googleProvider(ctx); err != nil {
+			return err
+		}
+		srv, err := sheets.NewService(ctx, option.WithHTTPClient(s.apiClient))
+		if err != nil {
+			return fmt.Errorf("unable to create sheets service: %v", err)
+		}
+
+		update := func() error {
+			ds, err := s.loadGroup(ctx, group)
+			if err != nil {
+				return err
+			}
+			err = syncSheet(ctx, srv, sc, ds)
+			s.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: "sheets", Destination: "sheets:" + sc.Spreadsheet, Events: len(ds.Absences), Error: errorString(err)})
+			if err != nil {
+				return err
+			}
+			logFor("sheets").Info("sheet updated", "group", ds.Group, "spreadsheet", sc.Spreadsheet, "absences", len(ds.Absences))
+			return nil
+		}
+		if err := update(); err != nil || *once {
+			return err
+		}
+
+		// Refreshes run in the background, next to the log
+		s.cfg.NoProgress = true
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// On failure the sheet keeps the previous data
+				if err := update(); err != nil {
+					logFor("sheets").Warn("could not update the sheet", "group", group, "error", err)
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// syncSheet replaces the contents of the matrix and events tabs, creating
+// them if needed, and clears what's left of the previous contents.
+func syncSheet(ctx context.Context, srv *sheets.Service, sc sheetsConfig, ds *ooo.Dataset) error {
+	grids, err := ensureTabs(ctx, srv, sc.Spreadsheet, sc.MatrixTab, sc.EventsTab)
+	if err != nil {
+		return sheetsError(err)
+	}
+	for tab, rows := range map[string][][]any{
+		sc.MatrixTab: matrixRows(ds),
+		sc.EventsTab: eventRows(ds),
+	} {
+		if err := writeTab(ctx, srv, sc.Spreadsheet, tab, grids[tab], rows); err != nil {
+			return sheetsError(err)
+		}
+	}
+	return nil
+}
+
+// ensureTabs adds the tabs missing from the spreadsheet, and returns the
+// size of those that already existed.
+func ensureTabs(ctx context.Context, srv *sheets.Service, spreadsheet string, tabs ...string) (map[string]*sheets.GridProperties, error) {
+	doc, err := srv.Spreadsheets.Get(spreadsheet).Fields("sheets.properties(title,gridProperties)").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	grids := make(map[string]*sheets.GridProperties)
+	for _, sheet := range doc.Sheets {
+		grids[sheet.Properties.Title] = sheet.Properties.GridProperties
+	}
+	var requests []*sheets.Request
+	for _, tab := range tabs {
+		if _, ok := grids[tab]; !ok {
+			requests = append(requests, &sheets.Request{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: tab}}})
+		}
+	}
+	if len(requests) == 0 {
+		return grids, nil
+	}
+	_, err = srv.Spreadsheets.BatchUpdate(spreadsheet, &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}).Context(ctx).Do()
+	return grids, err
+}
+
+// writeTab writes rows from the top left of tab, then clears the cells of
+// grid, its previous size, below and to the right of them, where removed
+// absences and days were.
+func writeTab(ctx context.Context, srv *sheets.Service, spreadsheet, tab string, grid *sheets.GridProperties, rows [][]any) error {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	name := "'" + strings.ReplaceAll(tab, "'", "''") + "'"
+	// RAW keeps titles like "=..." from being read as formulas
+	_, err := srv.Spreadsheets.Values.Update(spreadsheet, name+"!A1", &sheets.ValueRange{Values: rows}).
+		ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil || grid == nil {
+		return err
+	}
+	var stale []string
+	lastColumn := columnName(int(grid.ColumnCount))
+	if int(grid.RowCount) > len(rows) {
+		stale = append(stale, fmt.Sprintf("%s!A%d:%s%d", name, len(rows)+1, lastColumn, grid.RowCount))
+	}
+	if int(grid.ColumnCount) > width {
+		stale = append(stale, fmt.Sprintf("%s!%s1:%s%d", name, columnName(width+1), lastColumn, len(rows)))
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	_, err = srv.Spreadsheets.Values.BatchClear(spreadsheet, &sheets.BatchClearValuesRequest{Ranges: stale}).Context(ctx).Do()
+	return err
+}
+
+// matrixRows lays out ds with a row per member and a column per day. Cells
+// read OOO, BSY for busy blocks, - for days off and n/a for members whose
+// calendar couldn't be fetched.
+func matrixRows(ds *ooo.Dataset) [][]any {
+	loc := ds.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	first := time.Date(ds.TimeMin.Year(), ds.TimeMin.Month(), ds.TimeMin.Day(), 0, 0, 0, 0, loc)
+	var days []time.Time
+	for d := first; d.Format("2006-01-02") <= ds.TimeMax.Format("2006-01-02"); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	kinds := dayKinds(ds, loc)
+
+	header := []any{"Person (updated " + ds.FetchedAt.In(loc).Format("2006-01-02 15:04 MST") + ")"}
+	for _, d := range days {
+		header = append(header, d.Format("Mon 2006-01-02"))
+	}
+	rows := [][]any{header}
+	for _, person := range ds.Members {
+		row := []any{person}
+		for _, d := range days {
+			row = append(row, dayCell(ds, kinds, person, d))
+		}
+		rows = append(rows, row)
+	}
+	for _, person := range ds.Unavailable {
+		row := []any{person}
+		for range days {
+			row = append(row, "n/a")
+		}
+		rows = append(rows, row)
+	}
+	for _, person := range ds.NoDataMembers() {
+		row := []any{person}
+		for range days {
+			row = append(row, "???")
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// eventRows lists the absences like the CSV export.
+func eventRows(ds *ooo.Dataset) [][]any {
+	rows := [][]any{{"person", "start", "end", "all_day", "summary", "kind"}}
+	for _, e := range render.Events(ds) {
+		rows = append(rows, []any{e.Person, e.Start, e.End, e.AllDay, e.Summary, string(e.Kind)})
+	}
+	return rows
+}
+
+// columnName returns the letters of the nth column, e.g. 1 is A and 27 is AA.
+func columnName(n int) string {
+	name := ""
+	for ; n > 0; n = (n - 1) / 26 {
+		name = string(rune('A'+(n-1)%26)) + name
+	}
+	return name
+}
+
+// sheetsError explains errors caused by a token signed in without access
+// to Google Sheets.
+func sheetsError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == 403 && strings.Contains(strings.ToLower(apiErr.Message), "scope") {
+		return fmt.Errorf("the stored token can't write to Google Sheets; run 'ooo-view auth logout', then this command again to sign in with access to Sheets: %v", err)
+	}
+	if errors.As(err, &apiErr) && apiErr.Code == 404 {
+		return fmt.Errorf("spreadsheet not found or not shared with you: %v", err)
+	}
+	return fmt.Errorf("unable to update the sheet: %v", err)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// siteGroup is one group's tab of the static site.
+type siteGroup struct {
+	Name string // as given on the command line, e.g. an alias
+	Dir  string
+	ds   *ooo.Dataset
+}
+
+// siteWeek is a week page of a group.
+type siteWeek struct {
+	File  string
+	Title string
+	Days  []string
+	Rows  []siteRow
+	// Out is the number of people out that week
+	Out int
+}
+
+type siteRow struct {
+	Person string
+	Cells  []string
+}
+
+// exportSite writes a static site for groups to dir: an index with a tab per
+// group, and for each group a page per week, events.json and calendar.ics.
+// Links are relative, so the site can be published under any path.
+func exportSite(ctx context.Context, s *session, dir string, groups []string) error {
+	var tabs []*siteGroup
+	for _, group := range groups {
+		ds, err := s.loadGroup(ctx, group)
+		if err != nil {
+			return fmt.Errorf("%s: %w", group, err)
+		}
+		tabs = append(tabs, &siteGroup{Name: group, Dir: unsafeFileChars.ReplaceAllString(ds.Group, "_"), ds: ds})
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create site directory: %v", err)
+	}
+	if err := writeSitePage(filepath.Join(dir, "index.html"), "index", map[string]any{"Groups": tabs, "Base": ""}); err != nil {
+		return err
+	}
+	for _, tab := range tabs {
+		groupDir := filepath.Join(dir, tab.Dir)
+		if err := os.MkdirAll(groupDir, 0755); err != nil {
+			return fmt.Errorf("unable to create site directory: %v", err)
+		}
+		weeks := siteWeeks(tab.ds)
+		page := map[string]any{"Groups": tabs, "Base": "../", "Group": tab, "Weeks": weeks, "FetchedAt": tab.ds.FetchedAt.Format("Mon Jan 2 15:04 MST"), "Unavailable": tab.ds.Unavailable}
+		if err := writeSitePage(filepath.Join(groupDir, "index.html"), "group", page); err != nil {
+			return err
+		}
+		for i, week := range weeks {
+			page := map[string]any{"Groups": tabs, "Base": "../", "Group": tab, "Week": week}
+			if i > 0 {
+				page["Prev"] = weeks[i-1].File
+			}
+			if i < len(weeks)-1 {
+				page["Next"] = weeks[i+1].File
+			}
+			if err := writeSitePage(filepath.Join(groupDir, week.File), "week", page); err != nil {
+				return err
+			}
+		}
+		for name, renderer := range map[string]func(*os.File, *ooo.Dataset) error{
+			"events.json":  func(f *os.File, ds *ooo.Dataset) error { return render.JSON(f, ds) },
+			"calendar.ics": func(f *os.File, ds *ooo.Dataset) error { return render.ICS(f, ds) },
+		} {
+			f, err := os.Create(filepath.Join(groupDir, name))
+			if err != nil {
+				return fmt.Errorf("unable to write %s: %v", name, err)
+			}
+			if err := renderer(f, tab.ds); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+		s.audit.Record(auditRecord{Event: "export", Group: tab.ds.Group, Format: "site", Destination: groupDir, Events: len(tab.ds.Absences)})
+	}
+	return nil
+}
+
+// siteWeeks lays out ds week by week, Monday to Sunday, with a row for
+// everyone out that week and for the members without data.
+func siteWeeks(ds *ooo.Dataset) []siteWeek {
+	loc := ds.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	kinds := dayKinds(ds, loc)
+
+	start := time.Date(ds.TimeMin.Year(), ds.TimeMin.Month(), ds.TimeMin.Day(), 0, 0, 0, 0, loc)
+	for start.Weekday() != time.Monday {
+		start = start.AddDate(0, 0, -1)
+	}
+	lastDay := ds.TimeMax.Format("2006-01-02")
+
+	var weeks []siteWeek
+	for monday := start; monday.Format("2006-01-02") <= lastDay; monday = monday.AddDate(0, 0, 7) {
+		week := siteWeek{
+			File:  "week-" + monday.Format("2006-01-02") + ".html",
+			Title: monday.Format("Jan 2") + " – " + monday.AddDate(0, 0, 6).Format("Jan 2 2006"),
+		}
+		var people []string
+		for person, days := range kinds {
+			for i := 0; i < 7; i++ {
+				if days[monday.AddDate(0, 0, i).Format("2006-01-02")] != "" {
+					people = append(people, person)
+					break
+				}
+			}
+		}
+		sort.Strings(people)
+		week.Out = len(people)
+		for i := 0; i < 7; i++ {
+			week.Days = append(week.Days, monday.AddDate(0, 0, i).Format("Mon 2"))
+		}
+		for _, person := range people {
+			row := siteRow{Person: person}
+			for i := 0; i < 7; i++ {
+				row.Cells = append(row.Cells, dayCell(ds, kinds, person, monday.AddDate(0, 0, i)))
+			}
+			week.Rows = append(week.Rows, row)
+		}
+		for _, person := range ds.Unavailable {
+			week.Rows = append(week.Rows, siteRow{Person: person, Cells: []string{"n/a", "n/a", "n/a", "n/a", "n/a", "n/a", "n/a"}})
+		}
+		for _, person := range ds.NoDataMembers() {
+			week.Rows = append(week.Rows, siteRow{Person: person, Cells: []string{"???", "???", "???", "???", "???", "???", "???"}})
+		}
+		weeks = append(weeks, week)
+	}
+	return weeks
+}
+
+func writeSitePage(path, name string, data any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %v", path, err)
+	}
+	if err := siteTemplates.ExecuteTemplate(f, name, data); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+var siteTemplates = template.Must(template.New("").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}} – ooo-view</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+nav a { display: inline-block; padding: .4rem .8rem; border: 1px solid #ccc; border-bottom: none; border-radius: 4px 4px 0 0; text-decoration: none; color: #222; }
+nav a.active { background: #eee; font-weight: bold; }
+nav { border-bottom: 1px solid #ccc; margin-bottom: 1rem; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: .3rem .6rem; text-align: center; }
+td:first-child, th:first-child { text-align: left; }
+td.OOO { background: #f4b6b6; } td.BSY { background: #f7dfa5; } td.off { background: #eee; color: #888; } td.na { color: #888; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "tabs"}}<nav>{{$base := .Base}}{{$current := .Group}}{{range .Groups}}<a href="{{$base}}{{.Dir}}/index.html"{{if eq . $current}} class="active"{{end}}>{{.Name}}</a> {{end}}</nav>
+{{end}}
+
+{{define "index"}}{{template "head" "Absences"}}{{template "tabs" .}}
+<h1>Absences</h1>
+<ul>{{range .Groups}}<li><a href="{{.Dir}}/index.html">{{.Name}}</a></li>{{end}}</ul>
+</body>
+</html>
+{{end}}
+
+{{define "group"}}{{template "head" .Group.Name}}{{template "tabs" .}}
+<h1>{{.Group.Name}}</h1>
+<p>Fetched {{.FetchedAt}}. Download as <a href="events.json">JSON</a> or subscribe to the <a href="calendar.ics">calendar</a>.</p>
+{{if .Unavailable}}<p>No data for {{range $i, $p := .Unavailable}}{{if $i}}, {{end}}{{$p}}{{end}}.</p>{{end}}
+<ul>{{range .Weeks}}<li><a href="{{.File}}">{{.Title}}</a>{{if .Out}} ({{.Out}} out){{end}}</li>{{end}}</ul>
+</body>
+</html>
+{{end}}
+
+{{define "week"}}{{template "head" .Week.Title}}{{template "tabs" .}}
+<h1>{{.Group.Name}}: {{.Week.Title}}</h1>
+<p>{{with .Prev}}<a href="{{.}}">← previous week</a> {{end}}<a href="index.html">all weeks</a>{{with .Next}} <a href="{{.}}">next week →</a>{{end}}</p>
+{{if .Week.Rows}}<table>
+<tr><th></th>{{range .Week.Days}}<th>{{.}}</th>{{end}}</tr>
+{{range .Week.Rows}}<tr><td>{{.Person}}</td>{{range .Cells}}<td class="{{if eq . "-"}}off{{else if eq . "n/a"}}na{{else}}{{.}}{{end}}">{{.}}</td>{{end}}</tr>
+{{end}}</table>{{else}}<p>No OOO events.</p>{{end}}
+</body>
+</html>
+{{end}}
+`))
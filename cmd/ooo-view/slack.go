@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// slackAPIURL is the base of the Slack Web API.
+const slackAPIURL = "https://slack.com/api/"
+
+// slackMaxSkew is how old a request signed by Slack may be, so that a
+// captured request can't be replayed later.
+const slackMaxSkew = 5 * time.Minute
+
+// slackRanges are the weeks ahead the App Home's buttons show, the second
+// being the default.
+var slackRanges = []int{1, 2, 4}
+
+// slackApp serves the Slack app of serve: an App Home tab with the grid of
+// the user's team, buttons for how many weeks it shows, and reminders sent
+// when someone out of office is back.
+type slackApp struct {
+	gs            *groupServer
+	token         string
+	signingSecret string
+	client        *http.Client
+	audit         *auditLog
+
+	mu sync.Mutex
+	// emails caches the email addresses of Slack users by ID
+	emails map[string]string
+	// weeks holds the range each user picked
+	weeks     map[string]int
+	reminders []slackReminder
+}
+
+// slackReminder is a user's request to hear when person is back.
+type slackReminder struct {
+	// User is the Slack user ID, which is also their direct message channel
+	User    string    `json:"user"`
+	Person  string    `json:"person"`
+	Created time.Time `json:"created"`
+}
+
+// newSlackApp returns the Slack app of gs, with the reminders left from
+// earlier runs.
+func newSlackApp(gs *groupServer, token, signingSecret string) (*slackApp, error) {
+	a := &slackApp{
+		gs:            gs,
+		token:         token,
+		signingSecret: signingSecret,
+		client:        newHTTPClient(gs.session.cfg),
+		audit:         gs.session.audit,
+		emails:        make(map[string]string),
+		weeks:         make(map[string]int),
+	}
+	path, err := a.remindersPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Slack reminders: %v", err)
+	}
+	if err := json.Unmarshal(data, &a.reminders); err != nil {
+		return nil, fmt.Errorf("invalid Slack reminders file %s: %v", path, err)
+	}
+	return a, nil
+}
+
+// remindersPath returns the file of the group's pending reminders.
+func (a *slackApp) remindersPath() (string, error) {
+	data, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "slack", unsafeFileChars.ReplaceAllString(a.gs.group, "_")+".json"), nil
+}
+
+// saveReminders writes the pending reminders. a.mu must be held.
+func (a *slackApp) saveReminders() error {
+	path, err := a.remindersPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(a.reminders, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode Slack reminders: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create data directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write Slack reminders: %v", err)
+	}
+	return nil
+}
+
+// verify reads the body of a request from Slack and checks its signature.
+func (a *slackApp) verify(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIngestBody))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read request: %v", err)
+	}
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("missing timestamp")
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > slackMaxSkew || age < -slackMaxSkew {
+		return nil, fmt.Errorf("stale timestamp")
+	}
+	mac := hmac.New(sha256.New, []byte(a.signingSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get("X-Slack-Signature"))) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	return body, nil
+}
+
+// handleEvents answers Slack's Events API: the URL check when the app is
+// set up, and app_home_opened, which publishes the user's App Home.
+func (a *slackApp) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := a.verify(r)
+	if err != nil {
+		logFor("slack").Warn("request turned away", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+	var payload struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Event     struct {
+			Type string `json:"type"`
+			User string `json:"user"`
+			Tab  string `json:"tab"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	switch payload.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, payload.Challenge)
+	case "event_callback":
+		if payload.Event.Type == "app_home_opened" && payload.Event.Tab == "home" {
+			// Slack wants an answer within 3 seconds
+			go a.publishHome(payload.Event.User)
+		}
+	}
+}
+
+// handleInteractions answers the buttons and menus of the App Home.
+func (a *slackApp) handleInteractions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := a.verify(r)
+	if err != nil {
+		logFor("slack").Warn("request turned away", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Type string `json:"type"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		Actions []struct {
+			ActionID       string `json:"action_id"`
+			Value          string `json:"value"`
+			SelectedOption struct {
+				Value string `json:"value"`
+			} `json:"selected_option"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if payload.Type != "block_actions" {
+		return
+	}
+	user := payload.User.ID
+	for _, action := range payload.Actions {
+		switch {
+		case strings.HasPrefix(action.ActionID, "weeks_"):
+			weeks, _ := strconv.Atoi(action.Value)
+			if slices.Contains(slackRanges, weeks) {
+				a.mu.Lock()
+				a.weeks[user] = weeks
+				a.mu.Unlock()
+			}
+		case action.ActionID == "remind":
+			if err := a.addReminder(user, action.SelectedOption.Value); err != nil {
+				logFor("slack").Warn("could not add reminder", "user", user, "error", err)
+			}
+		}
+	}
+	go a.publishHome(user)
+}
+
+// addReminder has user told when person, a member, is back.
+func (a *slackApp) addReminder(user, person string) error {
+	a.gs.mu.RLock()
+	member := slices.Contains(a.gs.ds.Members, person)
+	a.gs.mu.RUnlock()
+	if !member {
+		return fmt.Errorf("%s isn't a member of the group", person)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, r := range a.reminders {
+		if r.User == user && r.Person == person {
+			return nil
+		}
+	}
+	a.reminders = append(a.reminders, slackReminder{User: user, Person: person, Created: time.Now().UTC().Truncate(time.Second)})
+	logFor("slack").Info("reminder added", "user", user, "person", person)
+	return a.saveReminders()
+}
+
+// checkReminders tells the users waiting for people who are back today,
+// on a day they work. Reminders that can't be sent are tried again on the
+// next refresh.
+func (a *slackApp) checkReminders(ctx context.Context, ds *ooo.Dataset, loc *time.Location) {
+	if ds.Stale {
+		return
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if _, ok := ds.Holiday(today); ok {
+		return
+	}
+	out := ooo.AbsentDays(ds, loc)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	kept := a.reminders[:0]
+	for _, r := range a.reminders {
+		if !slices.Contains(ds.Members, r.Person) {
+			logFor("slack").Info("dropping reminder of someone no longer in the group", "user", r.User, "person", r.Person)
+			continue
+		}
+		if out[r.Person][today.Format("2006-01-02")] || !ds.WorkWeek(r.Person).Works(today.Weekday()) {
+			kept = append(kept, r)
+			continue
+		}
+		err := a.call(ctx, "chat.postMessage", map[string]any{
+			"channel": r.User,
+			"text":    personName(ds, r.Person) + " is back today.",
+		}, nil)
+		a.audit.Record(auditRecord{Event: "export", Group: ds.Group, Format: "slack", Destination: r.User, Events: 1, Error: errorString(err)})
+		if err != nil {
+			logFor("slack").Warn("could not send reminder", "user", r.User, "person", r.Person, "error", err)
+			kept = append(kept, r)
+			continue
+		}
+		logFor("slack").Info("reminder sent", "user", r.User, "person", r.Person)
+	}
+	changed := len(kept) != len(a.reminders)
+	a.reminders = kept
+	if changed {
+		if err := a.saveReminders(); err != nil {
+			logFor("slack").Warn("could not save reminders", "error", err)
+		}
+	}
+}
+
+// publishHome publishes the App Home of user.
+func (a *slackApp) publishHome(user string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	email, err := a.email(ctx, user)
+	if err != nil {
+		// Show the whole group instead of the user's team
+		logFor("slack").Warn("could not look up Slack user; add the users:read.email scope", "user", user, "error", err)
+	}
+	a.gs.mu.RLock()
+	ds := a.gs.ds
+	a.gs.mu.RUnlock()
+
+	view := a.homeView(ds, email, user)
+	err = a.call(ctx, "views.publish", map[string]any{"user_id": user, "view": view}, nil)
+	a.audit.Record(auditRecord{Event: "serve", Group: ds.Group, Operation: "slack home", Destination: user, Events: len(ds.Absences), Error: errorString(err)})
+	if err != nil {
+		logFor("slack").Warn("could not publish App Home", "user", user, "error", err)
+	}
+}
+
+// email returns the email address of a Slack user.
+func (a *slackApp) email(ctx context.Context, user string) (string, error) {
+	a.mu.Lock()
+	email, ok := a.emails[user]
+	a.mu.Unlock()
+	if ok {
+		return email, nil
+	}
+	var info struct {
+		User struct {
+			Profile struct {
+				Email string `json:"email"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+	if err := a.call(ctx, "users.info", url.Values{"user": {user}}, &info); err != nil {
+		return "", err
+	}
+	// The config may be reloaded meanwhile
+	a.gs.mu.RLock()
+	email = a.gs.session.cfg.Identities.Canonical(strings.ToLower(info.User.Profile.Email))
+	a.gs.mu.RUnlock()
+	a.mu.Lock()
+	a.emails[user] = email
+	a.mu.Unlock()
+	return email, nil
+}
+
+// homeView returns the App Home of user, whose email address is email, or
+// empty if unknown: the grid of their team in the roster, or of the whole
+// group, for the weeks they picked, who is out today, and their reminders.
+func (a *slackApp) homeView(ds *ooo.Dataset, email, user string) map[string]any {
+	a.mu.Lock()
+	weeks, ok := a.weeks[user]
+	if !ok {
+		weeks = slackRanges[1]
+	}
+	var waiting []string
+	for _, r := range a.reminders {
+		if r.User == user {
+			waiting = append(waiting, personName(ds, r.Person))
+		}
+	}
+	a.mu.Unlock()
+
+	title := ds.Group
+	if entry, ok := ds.Roster[email]; ok && entry.Team != "" {
+		if team := ds.Subset(ds.Roster.Team(entry.Team)); len(team.Members) > 0 {
+			ds, title = team, entry.Team
+		}
+	}
+	loc := ds.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	shown := *ds
+	start, end := ooo.Window(now, weeks-1)
+	if start.After(shown.TimeMin) {
+		shown.TimeMin = start
+	}
+	if end.Before(shown.TimeMax) {
+		shown.TimeMax = end
+	}
+	var grid bytes.Buffer
+	render.StaleBanner(&grid, &shown)
+	render.Grid(&grid, &shown)
+
+	var buttons []any
+	for _, n := range slackRanges {
+		label := fmt.Sprintf("%d weeks", n)
+		if n == 1 {
+			label = "1 week"
+		}
+		button := map[string]any{
+			"type":      "button",
+			"action_id": fmt.Sprintf("weeks_%d", n),
+			"value":     strconv.Itoa(n),
+			"text":      slackText("plain_text", label),
+		}
+		if n == weeks {
+			button["style"] = "primary"
+		}
+		buttons = append(buttons, button)
+	}
+	blocks := []any{
+		map[string]any{"type": "header", "text": slackText("plain_text", "Out of office: "+title)},
+		map[string]any{"type": "actions", "elements": buttons},
+	}
+	for _, chunk := range slackCodeBlocks(grid.String()) {
+		blocks = append(blocks, map[string]any{"type": "section", "text": slackText("mrkdwn", chunk)})
+	}
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	backAt, allDay := outBetween(ds, dayStart, dayStart.AddDate(0, 0, 1))
+	if len(backAt) > 0 {
+		people := make([]string, 0, len(backAt))
+		for person := range backAt {
+			people = append(people, person)
+		}
+		slices.Sort(people)
+		lines := []string{"*Out today*"}
+		var options []any
+		for _, person := range people {
+			back := render.DateTime(backAt[person].In(loc))
+			if allDay[person] {
+				back = render.Day(backAt[person].In(loc))
+			}
+			lines = append(lines, fmt.Sprintf("• %s, back %s", personName(ds, person), back))
+			// Slack allows 100 options
+			if len(options) < 100 {
+				options = append(options, map[string]any{"text": slackText("plain_text", personName(ds, person)), "value": person})
+			}
+		}
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": slackText("mrkdwn", strings.Join(lines, "\n")),
+			"accessory": map[string]any{
+				"type":        "static_select",
+				"action_id":   "remind",
+				"placeholder": slackText("plain_text", "Remind me when … is back"),
+				"options":     options,
+			},
+		})
+	}
+	if len(waiting) > 0 {
+		blocks = append(blocks, map[string]any{"type": "context", "elements": []any{
+			slackText("mrkdwn", "You'll get a message when "+strings.Join(waiting, ", ")+" is back."),
+		}})
+	}
+	blocks = append(blocks, map[string]any{"type": "context", "elements": []any{
+		slackText("mrkdwn", "Fetched "+render.DateTime(ds.FetchedAt.In(loc))),
+	}})
+	return map[string]any{"type": "home", "blocks": blocks}
+}
+
+// slackText returns a text object of Block Kit.
+func slackText(kind, text string) map[string]any {
+	t := map[string]any{"type": kind, "text": text}
+	if kind == "plain_text" {
+		t["emoji"] = true
+	}
+	return t
+}
+
+// slackCodeBlocks splits text into code blocks that each fit in a section,
+// which holds up to 3000 characters, breaking between lines.
+func slackCodeBlocks(text string) []string {
+	const limit = 3000 - len("```\n\n```")
+	var chunks []string
+	var chunk strings.Builder
+	for _, line := range strings.SplitAfter(strings.TrimRight(text, "\n"), "\n") {
+		if chunk.Len() > 0 && chunk.Len()+len(line) > limit {
+			chunks = append(chunks, "```\n"+chunk.String()+"```")
+			chunk.Reset()
+		}
+		if len(line) > limit {
+			line = line[:limit]
+		}
+		chunk.WriteString(line)
+	}
+	if chunk.Len() > 0 {
+		chunks = append(chunks, "```\n"+chunk.String()+"\n```")
+	}
+	return chunks
+}
+
+// call calls a Slack Web API method with params, JSON or a form for
+// url.Values, and decodes the response into out if not nil.
+func (a *slackApp) call(ctx context.Context, method string, params any, out any) error {
+	var body io.Reader
+	contentType := "application/json; charset=utf-8"
+	if form, ok := params.(url.Values); ok {
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("unable to encode %s request: %v", method, err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackAPIURL+method, body)
+	if err != nil {
+		return fmt.Errorf("unable to call %s: %v", method, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to call %s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIngestBody))
+	if err != nil {
+		return fmt.Errorf("unable to read %s response: %v", method, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unable to call %s: %s", method, resp.Status)
+	}
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("invalid %s response: %v", method, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("%s failed: %s", method, result.Error)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("invalid %s response: %v", method, err)
+		}
+	}
+	return nil
+}
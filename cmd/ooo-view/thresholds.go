@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// exitThreshold is the exit code when a --fail-* condition is violated, so
+// scripts can tell it apart from errors (1) and usage mistakes (2).
+const exitThreshold = 3
+
+// thresholdError reports the conditions a dataset violated.
+type thresholdError struct {
+	violations []string
+}
+
+func (e *thresholdError) Error() string {
+	return "thresholds violated: " + strings.Join(e.violations, "; ")
+}
+
+// percent is a flag value like "50%" or "50", stored as a fraction.
+type percent float64
+
+func (p *percent) String() string {
+	if *p == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*p)*100, 'f', -1, 64) + "%"
+}
+
+func (p *percent) Set(s string) error {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || v < 0 || v > 100 {
+		return fmt.Errorf("expected a percentage from 0 to 100")
+	}
+	*p = percent(v / 100)
+	return nil
+}
+
+// thresholds are the conditions that make a command exit with exitThreshold.
+type thresholds struct {
+	minCoverage     percent
+	failOnConflicts bool
+	output          outputMode
+	// handoverDir, if set, gets a handover invite for the leaves of the
+	// days below minCoverage
+	handoverDir string
+	handovers   *handoverConfig
+}
+
+// addThresholdFlags registers the --fail-* flags of commands that show a
+// group's absences.
+func addThresholdFlags(fs *flag.FlagSet, cfg *Config) *thresholds {
+	t := &thresholds{handovers: &cfg.Handovers}
+	fs.Var(&t.minCoverage, "fail-if-coverage-below", "Exit with status 3 if fewer than this share of the group is in on any working day (e.g., 50%)")
+	fs.BoolVar(&t.failOnConflicts, "fail-on-conflicts", false, "Exit with status 3 if two or more people are out on the same working day")
+	fs.StringVar(&t.handoverDir, "handover-invites", "", "With --fail-if-coverage-below, write an invite to this directory for a handover before each leave on a day below it")
+	fs.Var(&t.output, "output", "Also report to a CI system: gh-actions writes a job summary, sets the coverage and conflicts step outputs and annotates violations")
+	return t
+}
+
+// coverageDay is a working day on which someone is out.
+type coverageDay struct {
+	day string
+	out []string
+	// coverage is the share of the members working that day who are in,
+	// by their weight in the roster
+	coverage float64
+}
+
+// coverage returns the working days in the dataset on which someone is out,
+// in order.
+func coverage(ds *ooo.Dataset, loc *time.Location) []coverageDay {
+	outByDay := make(map[string][]string)
+	for person, days := range ooo.AbsentDays(ds, loc) {
+		for day := range days {
+			outByDay[day] = append(outByDay[day], person)
+		}
+	}
+	days := make([]coverageDay, 0, len(outByDay))
+	for day, out := range outByDay {
+		sort.Strings(out)
+		date, _ := time.ParseInLocation("2006-01-02", day, loc)
+		days = append(days, coverageDay{day: day, out: out, coverage: dayCoverage(ds, date, out)})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].day < days[j].day })
+	return days
+}
+
+// dayCoverage returns the share of the members working on date who are in,
+// given who is out. Members count with their weight in the roster, so that
+// the only one with a skill can weigh as much as several others.
+func dayCoverage(ds *ooo.Dataset, date time.Time, out []string) float64 {
+	absent := make(map[string]bool, len(out))
+	for _, person := range out {
+		absent[person] = true
+	}
+	var working, in float64
+	for _, member := range ds.WorkingMembers(date) {
+		working += ds.Roster.Weight(member)
+		if !absent[member] {
+			in += ds.Roster.Weight(member)
+		}
+	}
+	if working == 0 {
+		return 0
+	}
+	return in / working
+}
+
+// check returns a *thresholdError describing every working day in the
+// dataset that violates the thresholds, or nil.
+// With --output set, the results are also reported there.
+func (t *thresholds) check(ds *ooo.Dataset, loc *time.Location) error {
+	if t.handoverDir != "" && t.minCoverage == 0 {
+		return usageErrorf("--handover-invites needs --fail-if-coverage-below")
+	}
+	if (t.minCoverage == 0 && !t.failOnConflicts && t.output == "") || len(ds.Members) == 0 {
+		return nil
+	}
+
+	days := coverage(ds, loc)
+	var violations []string
+	var gaps []coverageDay
+	for _, d := range days {
+		if t.minCoverage > 0 && d.coverage < float64(t.minCoverage) {
+			violations = append(violations, fmt.Sprintf("%s coverage %.0f%% is below %s", d.day, d.coverage*100, t.minCoverage.String()))
+			gaps = append(gaps, d)
+		}
+		if t.failOnConflicts && len(d.out) > 1 {
+			violations = append(violations, fmt.Sprintf("%s conflict: %s", d.day, strings.Join(d.out, ", ")))
+		}
+	}
+	if t.output == outputGitHubActions {
+		if err := writeGitHubActions(os.Stdout, ds, days, violations); err != nil {
+			return err
+		}
+	}
+	if t.handoverDir != "" {
+		if err := writeHandoverInvites(t.handoverDir, planHandovers(ds, loc, gaps, *t.handovers, time.Now())); err != nil {
+			return err
+		}
+	}
+	if len(violations) > 0 {
+		return &thresholdError{violations: violations}
+	}
+	return nil
+}
+
+// checkSections is check for each section of the members, like a team,
+// with the violations named after their section. With --output set, the
+// results for the whole dataset are reported there.
+func (t *thresholds) checkSections(ds *ooo.Dataset, loc *time.Location, sections map[string][]string) error {
+	if t.output == outputGitHubActions {
+		output := &thresholds{output: t.output}
+		if err := output.check(ds, loc); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	limits := &thresholds{minCoverage: t.minCoverage, failOnConflicts: t.failOnConflicts, handoverDir: t.handoverDir, handovers: t.handovers}
+	var violations []string
+	for _, name := range names {
+		var thresholdErr *thresholdError
+		if err := limits.check(ds.Subset(sections[name]), loc); errors.As(err, &thresholdErr) {
+			for _, v := range thresholdErr.violations {
+				violations = append(violations, name+": "+v)
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &thresholdError{violations: violations}
+	}
+	return nil
+}
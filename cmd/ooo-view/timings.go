@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type timing struct {
+	name     string
+	duration time.Duration
+}
+
+// runTimings records how long each phase of a run takes, plus the event
+// fetch of every person. A nil *runTimings records nothing, so callers don't
+// need to check whether --timings is set.
+type runTimings struct {
+	mu      sync.Mutex
+	phases  []timing
+	fetches []timing
+}
+
+// Phase starts timing the named phase and returns a func that stops it.
+func (t *runTimings) Phase(name string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.phases = append(t.phases, timing{name, time.Since(start)})
+	}
+}
+
+// Fetch records the duration of one person's event fetch.
+func (t *runTimings) Fetch(person string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fetches = append(t.fetches, timing{person, d})
+}
+
+// Print writes the phases in the order they ran, followed by the per-person
+// fetches from slowest to fastest.
+func (t *runTimings) Print(w io.Writer) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintln(w, "Timings:")
+	for _, p := range t.phases {
+		fmt.Fprintf(w, "  %-36s %10s\n", p.name, p.duration.Round(time.Millisecond))
+	}
+
+	if len(t.fetches) > 0 {
+		fetches := append([]timing(nil), t.fetches...)
+		sort.Slice(fetches, func(i, j int) bool {
+			return fetches[i].duration > fetches[j].duration
+		})
+		fmt.Fprintf(w, "  %-36s\n", "event fetch per person:")
+		for _, f := range fetches {
+			fmt.Fprintf(w, "    %-34s %10s\n", f.name, f.duration.Round(time.Millisecond))
+		}
+	}
+}
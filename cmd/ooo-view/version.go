@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Build metadata, set by release builds with
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// Values left empty are filled in from the module's debug.BuildInfo.
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+// releasesURL is the GitHub API endpoint for the latest release.
+const releasesURL = "https://api.github.com/repos/klaasmeinke/ooo-view/releases/latest"
+
+// versionInfo describes the running binary.
+type versionInfo struct {
+	Version   string
+	Commit    string
+	BuildDate string
+	Modified  bool
+	GoVersion string
+	Platform  string
+}
+
+// currentVersion combines the ldflags values with what the Go toolchain
+// recorded in the binary, e.g. for `go install ...@v1.2.3` builds.
+func currentVersion() versionInfo {
+	info := versionInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// release is the part of a GitHub release that ooo-view uses.
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// latestRelease fetches the latest published release from GitHub.
+func latestRelease(ctx context.Context, client *http.Client) (*release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check for updates: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to check for updates: %s", resp.Status)
+	}
+
+	var r release
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("unable to parse release: %v", err)
+	}
+	return &r, nil
+}
+
+// newerVersion reports whether semantic version a is newer than b. Versions
+// that don't parse, like "dev", are never newer.
+func newerVersion(a, b string) bool {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA {
+		return false
+	}
+	if !okB {
+		return true
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return pa[i] > pb[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	// Ignore pre-release and build suffixes
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+func versionCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	check := fs.Bool("check", false, "Check GitHub for a newer release")
+	printInstallScript := fs.Bool("print-install-script", false, "Print a script that installs this version, for sharing with people without Go")
+	platform := fs.String("platform", "", "With --print-install-script, the platform to install on, e.g. darwin/arm64 or windows/amd64 (default: this one)")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("version takes no arguments")
+		}
+		if *printInstallScript {
+			return writeInstallScript(os.Stdout, *platform)
+		}
+		if *platform != "" {
+			return usageErrorf("--platform needs --print-install-script")
+		}
+
+		info := currentVersion()
+		fmt.Printf("ooo-view %s\n", info.Version)
+		if info.Commit != "" {
+			modified := ""
+			if info.Modified {
+				modified = " (modified)"
+			}
+			fmt.Printf("  commit:     %s%s\n", info.Commit, modified)
+		}
+		if info.BuildDate != "" {
+			fmt.Printf("  built:      %s\n", info.BuildDate)
+		}
+		fmt.Printf("  go version: %s\n", info.GoVersion)
+		fmt.Printf("  platform:   %s\n", info.Platform)
+
+		if !*check {
+			return nil
+		}
+		client := &http.Client{Timeout: 15 * time.Second}
+		latest, err := latestRelease(ctx, client)
+		if err != nil {
+			return err
+		}
+		if newerVersion(latest.TagName, info.Version) {
+			fmt.Printf("\nA newer version is available: %s\n%s\n", latest.TagName, latest.HTMLURL)
+		} else {
+			fmt.Println("\nYou are running the latest version.")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// highlightCycles is how many refreshes a changed day stays highlighted
+// with --watch.
+const highlightCycles = 3
+
+// watchGroup redraws the group's calendar every interval until ctx is
+// cancelled, highlighting the days that changed in the last
+// highlightCycles refreshes. A failed refresh keeps the last data on
+// screen. SIGHUP reloads the config file and refreshes right away.
+func watchGroup(ctx context.Context, s *session, group string, interval time.Duration) error {
+	reload := reloadSignals(ctx)
+	var shown *ooo.Dataset
+	var recent []render.Changes // oldest first
+	for {
+		ds, err := s.loadGroup(ctx, group)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil && shown == nil:
+			return err
+		case err != nil:
+			logFor("watch").Warn("refresh failed, showing the last data", "error", err)
+			recent = append(recent, nil)
+		default:
+			if shown != nil {
+				recent = append(recent, render.Diff(shown, ds))
+			}
+			shown = ds
+		}
+		if len(recent) > highlightCycles {
+			recent = recent[len(recent)-highlightCycles:]
+		}
+
+		// Clear the screen and draw from the top
+		fmt.Print("\033[H\033[2J")
+		render.StaleBanner(os.Stdout, shown)
+		switch s.cfg.GroupBy {
+		case groupByLocation:
+			render.GridByOffice(os.Stdout, shown)
+		case groupByTeam:
+			render.GridByTeam(os.Stdout, shown)
+		case groupByManager:
+			render.GridByManager(os.Stdout, shown)
+		default:
+			render.GridHighlighted(os.Stdout, shown, mergeChanges(recent))
+		}
+		fmt.Printf("Updated %s, refreshing every %s. Press Ctrl+C to stop.\n", render.Clock(time.Now()), interval)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		case <-reload:
+			if err := s.reloadConfig(); err != nil {
+				logFor("watch").Warn("could not reload the config file, keeping the last one", "error", err)
+			}
+		}
+	}
+}
+
+// mergeChanges combines the changes of several refreshes, oldest first, with
+// the latest change of a day winning.
+func mergeChanges(recent []render.Changes) render.Changes {
+	merged := make(render.Changes)
+	for _, changes := range recent {
+		for date, people := range changes {
+			if merged[date] == nil {
+				merged[date] = make(map[string]render.CellChange)
+			}
+			for person, change := range people {
+				merged[date][person] = change
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/klaasmeinke/ooo-view/pkg/auth"
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+var (
+	stdinOnce  sync.Once
+	stdinLines chan string
+	stdinErr   error
+)
+
+// readLine returns the next line typed on stdin. A single goroutine reads
+// stdin for the whole run, so prompts and the pasted OAuth redirect don't
+// compete for input.
+func readLine(ctx context.Context) (string, error) {
+	stdinOnce.Do(func() {
+		stdinLines = make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				stdinLines <- scanner.Text()
+			}
+			stdinErr = scanner.Err()
+			if stdinErr == nil {
+				stdinErr = io.EOF
+			}
+			close(stdinLines)
+		}()
+	})
+
+	select {
+	case line, ok := <-stdinLines:
+		if !ok {
+			return "", fmt.Errorf("unable to read input: %v", stdinErr)
+		}
+		return line, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("operation cancelled")
+	}
+}
+
+// prompter asks questions on stderr, keeping stdout for command output.
+type prompter struct {
+	out io.Writer
+}
+
+func newPrompter() *prompter {
+	return &prompter{out: os.Stderr}
+}
+
+// ask returns the answer to question, or def if the answer is empty.
+func (p *prompter) ask(ctx context.Context, question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	answer, err := readLine(ctx)
+	if err != nil {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// choose lists the options and returns the index of the one picked.
+func (p *prompter) choose(ctx context.Context, question string, options []string, def int) (int, error) {
+	fmt.Fprintln(p.out, question)
+	for i, option := range options {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, option)
+	}
+	for {
+		answer, err := p.ask(ctx, "Choice", strconv.Itoa(def+1))
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+		fmt.Fprintf(p.out, "Please enter a number from 1 to %d.\n", len(options))
+	}
+}
+
+// confirm asks a yes/no question.
+func (p *prompter) confirm(ctx context.Context, question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		answer, err := p.ask(ctx, question+" ("+hint+")", "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// promptClientSecret walks the user through creating an OAuth client,
+// validates the client secret they provide and stores it in creds.
+func promptClientSecret(ctx context.Context, p *prompter, creds *auth.CredentialStore) (string, error) {
+	fmt.Fprintln(p.out, "ooo-view needs a Google OAuth client to read calendars:")
+	fmt.Fprintln(p.out, "1. Go to https://console.cloud.google.com")
+	fmt.Fprintln(p.out, "2. Create a new project or select an existing one")
+	fmt.Fprintln(p.out, "3. Enable the Google Calendar API")
+	fmt.Fprintln(p.out, "4. Go to Credentials and create an OAuth 2.0 Client ID of type Desktop app, with http://127.0.0.1 as the redirect URI")
+	fmt.Fprintln(p.out, "5. Download the client secret JSON file")
+	fmt.Fprintln(p.out)
+
+	method, err := p.choose(ctx, "How do you want to provide the client secret?", []string{
+		"Enter the path of the downloaded client_secret.json file",
+		"Paste the contents of client_secret.json",
+	}, 0)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		var secret string
+		if method == 0 {
+			path, err := p.ask(ctx, "Path to client_secret.json", "")
+			if err != nil {
+				return "", err
+			}
+			data, err := os.ReadFile(expandHome(path))
+			if err != nil {
+				fmt.Fprintf(p.out, "Unable to read the file: %v\n", err)
+				continue
+			}
+			secret = string(data)
+		} else {
+			fmt.Fprintln(p.out, "Paste the contents of your client_secret.json file and press Enter:")
+			if secret, err = readLine(ctx); err != nil {
+				return "", err
+			}
+		}
+
+		if err := auth.ValidateClientSecret(secret); err != nil {
+			fmt.Fprintf(p.out, "%v\n", err)
+			continue
+		}
+		if err := creds.Set(auth.ClientSecretKey, secret); err != nil {
+			return "", fmt.Errorf("failed to store client secret: %v", err)
+		}
+		fmt.Fprintf(p.out, "Client secret stored in %s.\n", creds.Where(auth.ClientSecretKey))
+		return secret, nil
+	}
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+func setupCommand(fs *flag.FlagSet, cfg *Config) runFunc {
+	addHTTPFlags(fs, cfg)
+	addAuthFlags(fs, cfg)
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("setup takes no arguments")
+		}
+		return runSetup(ctx, s, newPrompter())
+	}
+}
+
+// runSetup is the interactive first-run wizard: it stores the client
+// secret, checks it with a test API call and writes the chosen defaults to
+// the config file.
+func runSetup(ctx context.Context, s *session, p *prompter) error {
+	fmt.Fprintln(p.out, "Welcome to ooo-view setup.")
+	fmt.Fprintln(p.out)
+
+	// Credentials
+	creds, err := newCredentialStore(s.cfg)
+	if err != nil {
+		return err
+	}
+	replace := true
+	if _, err := creds.Get(auth.ClientSecretKey); err == nil {
+		var err error
+		if replace, err = p.confirm(ctx, "A client secret is already stored. Replace it?", false); err != nil {
+			return err
+		}
+	}
+	if replace {
+		if _, err := promptClientSecret(ctx, p, creds); err != nil {
+			return err
+		}
+		// A token from another client can't be refreshed
+		creds.Delete(auth.TokenKey)
+	}
+
+	fmt.Fprintln(p.out, "\nSigning in to check the credentials...")
+	provider, err := s.googleProvider(ctx)
+	if err != nil {
+		return err
+	}
+	calendars, err := provider.Calendars(ctx)
+	if err != nil {
+		return fmt.Errorf("the credentials don't work: %v", err)
+	}
+	fmt.Fprintf(p.out, "Credentials work: %d calendars are visible to you.\n\n", len(calendars))
+
+	fc, err := readConfigFile(s.cfg.ConfigPath, false)
+	if err != nil {
+		return err
+	}
+
+	// Default group
+	var groups []string
+	timezone := s.cfg.TimeZone
+	for _, entry := range calendars {
+		if entry.Primary {
+			if entry.TimeZone != "" {
+				timezone = entry.TimeZone
+			}
+			continue
+		}
+		// Skip holiday, imported and secondary calendars
+		if strings.HasSuffix(entry.Id, ".calendar.google.com") {
+			continue
+		}
+		groups = append(groups, entry.Id)
+	}
+	aliases := make([]string, 0, len(fc.Groups))
+	for alias := range fc.Groups {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	groups = append(groups, aliases...)
+	options := append(append([]string{}, groups...), "Enter another group email", "No default group")
+	choice, err := p.choose(ctx, "Which group should be shown when none is given?", options, len(options)-1)
+	if err != nil {
+		return err
+	}
+	switch {
+	case choice < len(groups):
+		fc.DefaultGroup = &groups[choice]
+	case choice == len(groups):
+		group, err := p.ask(ctx, "Group email", "")
+		if err != nil {
+			return err
+		}
+		if group != "" {
+			fc.DefaultGroup = &group
+		}
+	default:
+		fc.DefaultGroup = nil
+	}
+
+	// Timezone
+	for {
+		answer, err := p.ask(ctx, "Time zone", timezone)
+		if err != nil {
+			return err
+		}
+		if _, err := time.LoadLocation(answer); err != nil {
+			fmt.Fprintf(p.out, "Unknown time zone '%s'. Use an IANA name like Europe/Amsterdam.\n", answer)
+			continue
+		}
+		fc.TimeZone = &answer
+		break
+	}
+
+	// Output defaults
+	for {
+		answer, err := p.ask(ctx, "Weeks to show", strconv.Itoa(s.cfg.WeeksAhead))
+		if err != nil {
+			return err
+		}
+		weeks, err := strconv.Atoi(answer)
+		if err != nil || weeks < 0 {
+			fmt.Fprintln(p.out, "Please enter a number of weeks.")
+			continue
+		}
+		fc.Weeks = &weeks
+		break
+	}
+	for {
+		answer, err := p.ask(ctx, "Minimum duration of OOO events to show", formatDuration(s.cfg.MinDuration))
+		if err != nil {
+			return err
+		}
+		d, err := ooo.ParseDuration(answer)
+		if err != nil {
+			fmt.Fprintln(p.out, "Please enter a duration like 1d, 3d or 4h.")
+			continue
+		}
+		minDuration := duration(d)
+		fc.MinDuration = &minDuration
+		break
+	}
+
+	if err := writeConfigFile(s.cfg.ConfigPath, fc); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "\nWrote %s. Run 'ooo-view' to see your team's calendar.\n", s.cfg.ConfigPath)
+	return nil
+}
+
+// writeConfigFile saves fc as YAML at path, creating its directory.
+// Comments in an existing file are not preserved.
+func writeConfigFile(path string, fc *fileConfig) error {
+	data, err := yaml.Marshal(fc)
+	if err != nil {
+		return fmt.Errorf("unable to marshal config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create config directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write config file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// OpenBrowser opens url in the user's browser. It tries, in order, the
+// commands in $BROWSER, the Windows host's browser under WSL, and the
+// platform's default opener. The caller falls back to printing the URL when
+// it fails.
+func OpenBrowser(url string) error {
+	var candidates [][]string
+	// BROWSER is a list of commands like the one used by sensible-browser;
+	// %s marks where the URL goes
+	for _, browser := range filepath.SplitList(os.Getenv("BROWSER")) {
+		fields := strings.Fields(browser)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.Contains(browser, "%s") {
+			for i := range fields {
+				fields[i] = strings.ReplaceAll(fields[i], "%s", url)
+			}
+		} else {
+			fields = append(fields, url)
+		}
+		candidates = append(candidates, fields)
+	}
+
+	switch {
+	case runtime.GOOS == "linux" && IsWSL():
+		candidates = append(candidates,
+			[]string{"wslview", url},
+			[]string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "Start-Process '" + strings.ReplaceAll(url, "'", "''") + "'"},
+			[]string{"xdg-open", url},
+		)
+	case runtime.GOOS == "windows":
+		// rundll32 url.dll truncates long URLs like the OAuth one
+		candidates = append(candidates,
+			[]string{"powershell", "-NoProfile", "-NonInteractive", "-Command", "Start-Process '" + strings.ReplaceAll(url, "'", "''") + "'"},
+			[]string{"rundll32", "url.dll,FileProtocolHandler", url},
+		)
+	case runtime.GOOS == "darwin":
+		candidates = append(candidates, []string{"open", url})
+	default:
+		candidates = append(candidates, []string{"xdg-open", url})
+	}
+
+	var errs []string
+	for _, args := range candidates {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := exec.Command(path, args[1:]...).Start(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", args[0], err))
+			continue
+		}
+		logFor("auth").Debug("opened browser", "command", args[0])
+		return nil
+	}
+	return fmt.Errorf("no browser could be started (%s)", strings.Join(errs, "; "))
+}
+
+// IsWSL reports whether ooo-view runs inside the Windows Subsystem for Linux,
+// where xdg-open usually has no browser to open and the clipboard is the
+// Windows one.
+func IsWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
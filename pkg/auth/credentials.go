@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+)
+
+// ErrNotStored is returned by CredentialStore.Get and Delete for a missing
+// credential.
+var ErrNotStored = errors.New("not stored")
+
+// credentialFiles names the files used for each credential with NoKeyring.
+var credentialFiles = map[string]string{
+	ClientSecretKey: "client_secret.json",
+	TokenKey:        "token.json",
+}
+
+// CredentialStore keeps the OAuth client secret and token in the system
+// keyring, or in files readable only by the user.
+type CredentialStore struct {
+	// NoKeyring keeps both credentials in files in Dir
+	NoKeyring bool
+	Dir       string
+	// TokenFile, if set, holds the token regardless of NoKeyring
+	TokenFile string
+}
+
+// File returns the file holding key, or "" if it is kept in the keyring.
+func (c *CredentialStore) File(key string) string {
+	if key == TokenKey && c.TokenFile != "" {
+		return c.TokenFile
+	}
+	if c.NoKeyring {
+		return filepath.Join(c.Dir, credentialFiles[key])
+	}
+	return ""
+}
+
+// Where describes the location of key for messages.
+func (c *CredentialStore) Where(key string) string {
+	if path := c.File(key); path != "" {
+		return path
+	}
+	return "the system keyring"
+}
+
+// Get returns the credential stored under key.
+func (c *CredentialStore) Get(key string) (string, error) {
+	path := c.File(key)
+	if path == "" {
+		value, err := keyring.Get(KeyringService, key)
+		if errors.Is(err, keyring.ErrNotFound) {
+			return "", ErrNotStored
+		}
+		return value, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotStored
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	return string(data), nil
+}
+
+// Set stores value under key.
+func (c *CredentialStore) Set(key, value string) error {
+	path := c.File(key)
+	if path == "" {
+		return keyring.Set(KeyringService, key, value)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(value), 0600); err != nil {
+		return fmt.Errorf("unable to write %s: %v", path, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("unable to restrict access to %s: %v", path, err)
+	}
+	return nil
+}
+
+// Delete removes the credential stored under key.
+func (c *CredentialStore) Delete(key string) error {
+	path := c.File(key)
+	if path == "" {
+		err := keyring.Delete(KeyringService, key)
+		if errors.Is(err, keyring.ErrNotFound) {
+			return ErrNotStored
+		}
+		return err
+	}
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotStored
+	}
+	return err
+}
@@ -0,0 +1,297 @@
+// Package auth signs in to Google with an installed-app OAuth client and
+// keeps the client secret and token in the system keyring or in files.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/cloudidentity/v1"
+)
+
+// Keyring entries, under the KeyringService service.
+const (
+	KeyringService  = "ooo-view"
+	ClientSecretKey = "client-secret"
+	TokenKey        = "oauth-token"
+)
+
+// Scopes are requested when signing in: calendars to read absences, and
+// groups for listing the ones the user belongs to.
+var Scopes = []string{calendar.CalendarReadonlyScope, cloudidentity.CloudIdentityGroupsReadonlyScope}
+
+// FreebusyScope only allows free/busy queries, without access to events.
+const FreebusyScope = "https://www.googleapis.com/auth/calendar.freebusy"
+
+// FreebusyScopes are requested instead of Scopes in free/busy-only mode.
+var FreebusyScopes = []string{FreebusyScope}
+
+// SheetsScope is requested in addition to allow writing to spreadsheets.
+const SheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// DirectoryScope is requested in addition to read people's work locations
+// from the Directory.
+const DirectoryScope = "https://www.googleapis.com/auth/admin.directory.user.readonly"
+
+// ParseClientSecret reads the client_secret.json of an OAuth client into a
+// config requesting scopes, or Scopes if none are given.
+func ParseClientSecret(secret string, scopes ...string) (*oauth2.Config, error) {
+	if len(scopes) == 0 {
+		scopes = Scopes
+	}
+	config, err := google.ConfigFromJSON([]byte(secret), scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret: %v", err)
+	}
+	return config, nil
+}
+
+// ValidateClientSecret checks that secret is the JSON of an OAuth client,
+// with hints for the common mistakes.
+func ValidateClientSecret(secret string) error {
+	// Validate that the input is valid JSON
+	var jsonCheck map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &jsonCheck); err != nil {
+		return fmt.Errorf("invalid JSON format: %v\nPlease make sure you're using the entire client_secret.json file", err)
+	}
+	// Try to create config to validate it's a proper client secret
+	if _, err := google.ConfigFromJSON([]byte(secret), Scopes...); err != nil {
+		return fmt.Errorf("invalid client secret format: %v\nPlease make sure you're using the correct client_secret.json file", err)
+	}
+	return nil
+}
+
+func logFor(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+func generateRandomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// TokenOptions control the interactive part of Token.
+type TokenOptions struct {
+	// NoBrowser prints the authorization URL instead of opening a browser
+	NoBrowser bool
+	// Out receives the authorization URL and instructions
+	Out io.Writer
+	// ReadLine, if set, reads the redirect address pasted back by the user
+	// when the URL was printed
+	ReadLine func(ctx context.Context) (string, error)
+	// MaxIdle, if set, deletes a stored token that hasn't been used for
+	// that long, so the user has to sign in again
+	MaxIdle time.Duration
+}
+
+// StoredToken is the token as stored in a CredentialStore, with the time it
+// was last handed out by Token.
+type StoredToken struct {
+	oauth2.Token
+	LastUsed time.Time `json:"last_used,omitempty"`
+}
+
+// LoadToken reads the token stored in creds.
+func LoadToken(creds *CredentialStore) (*StoredToken, error) {
+	tokenJSON, err := creds.Get(TokenKey)
+	if err != nil {
+		return nil, err
+	}
+	var stored StoredToken
+	if err := json.Unmarshal([]byte(tokenJSON), &stored); err != nil {
+		return nil, fmt.Errorf("unable to parse stored token: %v", err)
+	}
+	return &stored, nil
+}
+
+// saveToken stores tok in creds, last used now.
+func saveToken(creds *CredentialStore, tok *oauth2.Token) error {
+	tokenBytes, err := json.Marshal(StoredToken{Token: *tok, LastUsed: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("unable to marshal token: %v", err)
+	}
+	if err := creds.Set(TokenKey, string(tokenBytes)); err != nil {
+		return fmt.Errorf("unable to store token: %v", err)
+	}
+	return nil
+}
+
+// Token returns the token stored in creds, or runs the OAuth flow in the
+// browser and stores the new token. With opts.NoBrowser, or if the browser
+// can't be launched, the authorization URL is printed instead. A stored token
+// unused for longer than opts.MaxIdle is deleted first.
+func Token(ctx context.Context, config *oauth2.Config, creds *CredentialStore, opts TokenOptions) (*oauth2.Token, error) {
+	noBrowser := opts.NoBrowser
+	out := opts.Out
+	if out == nil {
+		out = os.Stderr
+	}
+
+	// Generate random state parameter
+	state, err := generateRandomState()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate state parameter: %v", err)
+	}
+
+	// Try to get the stored token
+	if stored, err := LoadToken(creds); err == nil {
+		idle := time.Since(stored.LastUsed)
+		switch {
+		case opts.MaxIdle > 0 && !stored.LastUsed.IsZero() && idle > opts.MaxIdle:
+			logFor("auth").Warn("the stored token wasn't used for too long and was deleted; sign in again", "last_used", stored.LastUsed.Local().Format(time.DateTime), "max_idle", opts.MaxIdle)
+			if err := creds.Delete(TokenKey); err != nil {
+				return nil, fmt.Errorf("unable to delete expired token: %v", err)
+			}
+		case stored.Expiry.After(time.Now()):
+			// Record the use, which MaxIdle is measured from
+			if err := saveToken(creds, &stored.Token); err != nil {
+				logFor("auth").Warn("could not record token use", "error", err)
+			}
+			return &stored.Token, nil
+		}
+	}
+
+	// Create a channel to receive the auth code
+	codeChan := make(chan string)
+	errChan := make(chan error)
+
+	// Get a random port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get random port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	// Update config with the correct redirect URI
+	config.RedirectURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	// Create a server with a custom handler
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Verify state parameter
+		if r.URL.Query().Get("state") != state {
+			errChan <- fmt.Errorf("invalid state parameter")
+			return
+		}
+
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			errChan <- fmt.Errorf("no code received")
+			return
+		}
+		codeChan <- code
+		w.Write([]byte("Authorization successful! You can close this window."))
+	})
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf("127.0.0.1:%d", port),
+		Handler: otelhttp.NewHandler(http.TimeoutHandler(mux, 30*time.Second, "Request timeout"), "oauth-callback"),
+	}
+
+	// Start server in a goroutine
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- fmt.Errorf("server error: %v", err)
+		}
+	}()
+
+	// Generate auth URL and open browser
+	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	if !noBrowser {
+		logFor("auth").Info("opening browser for authorization")
+		if err := OpenBrowser(authURL); err != nil {
+			logFor("auth").Warn("unable to open browser", "error", err)
+			noBrowser = true
+		}
+	}
+	if noBrowser {
+		printAuthURL(out, authURL, opts.ReadLine != nil)
+	}
+	if noBrowser && opts.ReadLine != nil {
+		// A browser on another machine can't reach the callback server, so
+		// also accept the redirected address pasted back in
+		go func() {
+			for {
+				line, err := opts.ReadLine(ctx)
+				if err != nil {
+					return
+				}
+				redirect, err := url.Parse(strings.TrimSpace(line))
+				if err != nil || redirect.Query().Get("code") == "" {
+					continue
+				}
+				if redirect.Query().Get("state") != state {
+					errChan <- fmt.Errorf("invalid state parameter")
+					return
+				}
+				codeChan <- redirect.Query().Get("code")
+				return
+			}
+		}()
+	}
+
+	// Wait for auth code or context cancellation
+	var authCode string
+	select {
+	case authCode = <-codeChan:
+		logFor("auth").Info("authorization code received, exchanging for token")
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// Exchange code for token
+	tok, err := config.Exchange(ctx, authCode)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve token from web: %v", err)
+	}
+	logFor("auth").Info("token received")
+
+	// Save the token for the next run
+	if err := saveToken(creds, tok); err != nil {
+		return nil, err
+	}
+
+	// Shutdown server in background
+	go func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	return tok, nil
+}
+
+// printAuthURL shows the authorization URL as text and as a QR code for
+// opening it on another device.
+func printAuthURL(w io.Writer, authURL string, pasteBack bool) {
+	fmt.Fprintln(w, "Open this URL in a browser to authorize ooo-view:")
+	fmt.Fprintf(w, "\n%s\n\n", authURL)
+	if err := printQRCode(w, authURL); err != nil {
+		logFor("auth").Debug("unable to render QR code", "error", err)
+	}
+	if pasteBack {
+		fmt.Fprintln(w, "\nIf the browser runs on another machine, the final redirect to 127.0.0.1 fails to load.")
+		fmt.Fprintln(w, "Copy the address of that page from the browser and paste it here:")
+	}
+}
@@ -0,0 +1,66 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Access is how much of a calendar the user can read.
+type Access string
+
+const (
+	// AccessEvents calendars have their events read, including out of
+	// office events
+	AccessEvents Access = "events"
+	// AccessFreebusy calendars only show when their owner is busy, so
+	// absences can only be inferred with FreebusyOnly
+	AccessFreebusy Access = "freebusy"
+	// AccessNone calendars aren't shared with the user at all
+	AccessNone Access = "none"
+)
+
+// CheckAccess finds out how much of calendarId the user can read, by
+// listing one of its events in the window and, if that's refused, querying
+// its free/busy. The role returned is the calendar's access role for the
+// user, e.g. "reader", if the events could be listed.
+func (p *GoogleProvider) CheckAccess(ctx context.Context, calendarId string, timeMin, timeMax time.Time) (Access, string, error) {
+	var page *calendar.Events
+	err := p.do(ctx, "events.list", calendarId, func(ctx context.Context) error {
+		var err error
+		page, err = p.srv.Events.List(calendarId).
+			TimeMin(timeMin.Format(time.RFC3339)).
+			TimeMax(timeMax.Format(time.RFC3339)).
+			MaxResults(1).
+			Context(ctx).Do()
+		return err
+	})
+	if err == nil {
+		if page.AccessRole == "freeBusyReader" {
+			return AccessFreebusy, page.AccessRole, nil
+		}
+		return AccessEvents, page.AccessRole, nil
+	}
+	fetchErr := classify(err, calendarId, ErrCalendarNotFound)
+	if fetchErr == nil {
+		return "", "", fmt.Errorf("unable to list events of %s: %w", calendarId, err)
+	}
+	if errors.Is(fetchErr, ErrAuthExpired) {
+		return "", "", fetchErr
+	}
+
+	resp, err := p.queryFreebusy(ctx, []string{calendarId}, timeMin, timeMax, "")
+	if err != nil {
+		if fetchErr := classify(err, calendarId, ErrCalendarNotFound); fetchErr != nil {
+			return "", "", fetchErr
+		}
+		return "", "", fmt.Errorf("unable to query freebusy: %w", err)
+	}
+	if cal, ok := resp.Calendars[calendarId]; !ok || len(cal.Errors) > 0 {
+		return AccessNone, "", nil
+	}
+	return AccessFreebusy, "", nil
+}
@@ -0,0 +1,246 @@
+package ooo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// BatchMax is the most Events.List calls combined into one batch
+	// request. The API allows more, but recommends at most 50.
+	BatchMax = 50
+	// batchWait is how long a call waits for others to share its batch
+	batchWait = 5 * time.Millisecond
+)
+
+// errNotBatched is returned for calls the batch couldn't answer, which are
+// then made on their own.
+var errNotBatched = errors.New("not answered by the batch request")
+
+// errNotModified is returned for conditional requests whose ETag still
+// matches.
+var errNotModified = errors.New("not modified")
+
+// eventBatcher combines the first pages of concurrent Events.List calls
+// for out-of-office events into requests to the batch endpoint, so a fetch
+// with n workers makes about one round trip where it made n.
+type eventBatcher struct {
+	p      *GoogleProvider
+	client *http.Client
+
+	mu      sync.Mutex
+	pending []*batchCall
+	timer   *time.Timer
+}
+
+// batchCall is one Events.List call waiting for its batch.
+type batchCall struct {
+	calendarId       string
+	timeMin, timeMax time.Time
+	etag             string
+
+	done chan struct{}
+	page *calendar.Events
+	err  error
+}
+
+// list returns the first page of calendarId's out-of-office events,
+// sent in a batch with the calls made around the same time. With etag set,
+// it returns errNotModified if the page still matches it.
+func (b *eventBatcher) list(ctx context.Context, calendarId string, timeMin, timeMax time.Time, etag string) (*calendar.Events, error) {
+	call := &batchCall{calendarId: calendarId, timeMin: timeMin, timeMax: timeMax, etag: etag, done: make(chan struct{})}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, call)
+	switch {
+	case len(b.pending) >= BatchMax:
+		b.flushLocked()
+	case b.timer == nil:
+		b.timer = time.AfterFunc(batchWait, b.flush)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.page, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *eventBatcher) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked()
+}
+
+// flushLocked sends the pending calls. b.mu must be held.
+func (b *eventBatcher) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	calls := b.pending
+	b.pending = nil
+	if len(calls) > 0 {
+		// The batch outlives a caller that gives up; the HTTP client's
+		// timeout bounds it
+		go b.send(context.Background(), calls)
+	}
+}
+
+// send makes one batch request for calls and hands each its page or error.
+// Calls the batch didn't answer get errNotBatched.
+func (b *eventBatcher) send(ctx context.Context, calls []*batchCall) {
+	defer func() {
+		for _, call := range calls {
+			if call.page == nil && call.err == nil {
+				call.err = errNotBatched
+			}
+			close(call.done)
+		}
+	}()
+
+	ids := make([]string, len(calls))
+	for i, call := range calls {
+		ids[i] = call.calendarId
+	}
+	var responses map[string]*http.Response
+	err := b.p.do(ctx, "events.batch", strings.Join(ids, ","), func(ctx context.Context) error {
+		var err error
+		responses, err = b.post(ctx, calls)
+		return err
+	})
+	if err != nil {
+		logFor("api").Debug("batch request failed, fetching calendars one by one", "calendars", len(calls), "error", err)
+		return
+	}
+
+	for i, call := range calls {
+		resp, ok := responses["response-"+strconv.Itoa(i)]
+		if !ok {
+			continue
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			call.err = errNotModified
+			continue
+		}
+		if err := googleapi.CheckResponse(resp); err != nil {
+			// Rate limits and server errors are retried on their own
+			if isRetryable(err) {
+				continue
+			}
+			if fetchErr := classify(err, call.calendarId, ErrCalendarNotFound); fetchErr != nil {
+				call.err = fetchErr
+			} else {
+				call.err = fmt.Errorf("unable to retrieve events: %w", err)
+			}
+			continue
+		}
+		var page calendar.Events
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			logFor("api").Debug("invalid batch response part", "person", call.calendarId, "error", err)
+			continue
+		}
+		call.page = &page
+	}
+}
+
+// post sends calls to the batch endpoint and returns the responses by
+// Content-ID.
+func (b *eventBatcher) post(ctx context.Context, calls []*batchCall) (map[string]*http.Response, error) {
+	base, err := url.Parse(b.p.srv.BasePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API base path: %v", err)
+	}
+	endpoint := &url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/batch/calendar/v3"}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, call := range calls {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {"<" + strconv.Itoa(i) + ">"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		query := url.Values{
+			"timeMin":      {call.timeMin.Format(time.RFC3339)},
+			"timeMax":      {call.timeMax.Format(time.RFC3339)},
+			"singleEvents": {"true"},
+			"orderBy":      {"startTime"},
+			"maxResults":   {strconv.Itoa(EventsPageSize)},
+			"eventTypes":   {"outOfOffice"},
+			"alt":          {"json"},
+		}
+		path := base.Path + "calendars/" + url.PathEscape(call.calendarId) + "/events?" + query.Encode()
+		fmt.Fprintf(part, "GET %s HTTP/1.1\r\n", path)
+		if call.etag != "" {
+			fmt.Fprintf(part, "If-None-Match: %s\r\n", call.etag)
+		}
+		fmt.Fprint(part, "\r\n")
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("unexpected batch response type %q", resp.Header.Get("Content-Type"))
+	}
+	responses := make(map[string]*http.Response, len(calls))
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return responses, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read batch response: %v", err)
+		}
+		id := strings.Trim(part.Header.Get("Content-Id"), "<>")
+		inner, err := http.ReadResponse(bufio.NewReader(part), nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read batch response part %s: %v", id, err)
+		}
+		// Keep the body past the next part
+		data, err := io.ReadAll(inner.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read batch response part %s: %v", id, err)
+		}
+		inner.Body = io.NopCloser(bytes.NewReader(data))
+		responses[id] = inner
+	}
+}
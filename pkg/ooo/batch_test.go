@@ -0,0 +1,193 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// batchCalendars are the calendars of the calls in a test batch
+var batchCalendars = []string{"a", "b", "c", "d", "e"}
+
+func TestEventBatcherSend(t *testing.T) {
+	// part is a batch response part answering a call with status and body
+	part := func(status int, body string) string {
+		return fmt.Sprintf("HTTP/1.1 %d %s\r\nContent-Type: application/json; charset=UTF-8\r\n\r\n%s", status, http.StatusText(status), body)
+	}
+	apiError := func(code int, reason string) string {
+		return part(code, fmt.Sprintf(`{"error": {"code": %d, "message": %q, "errors": [{"reason": %q}]}}`, code, reason, reason))
+	}
+	// multipartReply replies to the batch with parts, by call index
+	multipartReply := func(parts map[int]string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mw := multipart.NewWriter(w)
+			w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+			for i := range batchCalendars {
+				p, ok := parts[i]
+				if !ok {
+					continue
+				}
+				pw, _ := mw.CreatePart(textproto.MIMEHeader{
+					"Content-Type": {"application/http"},
+					"Content-Id":   {fmt.Sprintf("<response-%d>", i)},
+				})
+				io.WriteString(pw, p)
+			}
+			mw.Close()
+		}
+	}
+
+	tests := []struct {
+		name  string
+		reply http.HandlerFunc
+		want  string
+	}{
+		{
+			name: "mixed responses",
+			reply: multipartReply(map[int]string{
+				0: part(http.StatusOK, `{"etag": "\"p1\"", "items": [{"id": "e1", "eventType": "outOfOffice"}]}`),
+				1: part(http.StatusNotModified, ""),
+				2: apiError(http.StatusNotFound, "notFound"),
+				3: apiError(http.StatusTooManyRequests, "rateLimitExceeded"),
+				4: apiError(http.StatusForbidden, "forbidden"),
+			}),
+			want: "a: 1 events, b: not modified, c: calendar not found, d: not batched, e: no access",
+		},
+		{
+			name: "missing parts",
+			reply: multipartReply(map[int]string{
+				1: part(http.StatusOK, `{"items": []}`),
+				3: part(http.StatusOK, `not json`),
+			}),
+			want: "a: not batched, b: 0 events, c: not batched, d: not batched, e: not batched",
+		},
+		{
+			name: "error reply",
+			reply: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error": {"code": 400, "message": "invalid batch"}}`)
+			},
+			want: "a: not batched, b: not batched, c: not batched, d: not batched, e: not batched",
+		},
+		{
+			name: "reply that isn't multipart",
+			reply: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				io.WriteString(w, "<html>sign in</html>")
+			},
+			want: "a: not batched, b: not batched, c: not batched, d: not batched, e: not batched",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests = append(requests, batchRequestParts(t, r)...)
+				mu.Unlock()
+				tt.reply(w, r)
+			}))
+			defer server.Close()
+
+			srv, err := calendar.NewService(context.Background(), option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/calendar/v3/"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := &eventBatcher{p: NewGoogleProvider(srv, nil), client: server.Client()}
+
+			timeMin := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+			var calls []*batchCall
+			for _, id := range batchCalendars {
+				call := &batchCall{calendarId: id, timeMin: timeMin, timeMax: timeMin.AddDate(0, 0, 14), done: make(chan struct{})}
+				if id == "b" {
+					call.etag = `"p1"`
+				}
+				calls = append(calls, call)
+			}
+			b.send(context.Background(), calls)
+
+			var got []string
+			for _, call := range calls {
+				<-call.done
+				got = append(got, call.calendarId+": "+describeBatchResult(call))
+			}
+			if strings.Join(got, ", ") != tt.want {
+				t.Errorf("send() = %q, want %q", strings.Join(got, ", "), tt.want)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(requests) != len(calls) {
+				t.Fatalf("batch request has %d parts, want %d: %q", len(requests), len(calls), requests)
+			}
+			if !strings.HasPrefix(requests[0], "GET /calendar/v3/calendars/a/events?") || !strings.Contains(requests[0], "eventTypes=outOfOffice") {
+				t.Errorf("first part requests %q", requests[0])
+			}
+			if !strings.Contains(requests[1], "\r\nIf-None-Match: \"p1\"\r\n") {
+				t.Errorf("part of the call with an ETag doesn't send it: %q", requests[1])
+			}
+			if strings.Contains(requests[0], "If-None-Match") {
+				t.Errorf("part of the call without an ETag sends one: %q", requests[0])
+			}
+		})
+	}
+}
+
+// batchRequestParts returns the parts of the batch request r, failing the
+// test if it isn't one.
+func batchRequestParts(t *testing.T, r *http.Request) []string {
+	t.Helper()
+	if r.Method != http.MethodPost || r.URL.Path != "/batch/calendar/v3" {
+		t.Errorf("batch sent as %s %s", r.Method, r.URL.Path)
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Errorf("batch sent as %q", r.Header.Get("Content-Type"))
+		return nil
+	}
+	var parts []string
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			return parts
+		}
+		data, _ := io.ReadAll(p)
+		parts = append(parts, string(data))
+	}
+}
+
+// describeBatchResult describes what a call got from its batch.
+func describeBatchResult(call *batchCall) string {
+	switch {
+	case call.page != nil:
+		return fmt.Sprintf("%d events", len(call.page.Items))
+	case errors.Is(call.err, errNotBatched):
+		return "not batched"
+	case errors.Is(call.err, errNotModified):
+		return "not modified"
+	case errors.Is(call.err, ErrCalendarNotFound), errors.Is(call.err, ErrNoAccess):
+		var fetchErr *FetchError
+		errors.As(call.err, &fetchErr)
+		if fetchErr.Subject != call.calendarId {
+			return fmt.Sprintf("error about %s", fetchErr.Subject)
+		}
+		return fetchErr.Kind.Error()
+	}
+	return fmt.Sprintf("unexpected %v", call.err)
+}
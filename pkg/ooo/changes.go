@@ -0,0 +1,173 @@
+package ooo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// ChangeKind is how an absence changed between two fetches.
+type ChangeKind string
+
+const (
+	ChangeAdded     ChangeKind = "added"
+	ChangeModified  ChangeKind = "modified"
+	ChangeCancelled ChangeKind = "cancelled"
+)
+
+// Change is a change to a person's OOO event, detected when a fetch of
+// their calendar differed from the events stored before.
+type Change struct {
+	DetectedAt time.Time
+	Person     string
+	Kind       ChangeKind
+	// Event is the event after the change, or the cancelled one; Previous
+	// is the event before a modification
+	Event    *calendar.Event
+	Previous *calendar.Event
+}
+
+// detectChanges compares the events just fetched for the person and window
+// with the stored ones, and records the differences in the changes table.
+// Events are only reported as added if they fall in a window fetched
+// before, so that widening the window or a first fetch isn't mistaken for
+// new absences.
+func detectChanges(tx *sql.Tx, person, lo, hi string, events []*calendar.Event, now string) error {
+	var windows [][2]string
+	rows, err := tx.Query(`SELECT time_min, time_max FROM fetches WHERE person = ?`, person)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var w [2]string
+		if err := rows.Scan(&w[0], &w[1]); err != nil {
+			rows.Close()
+			return err
+		}
+		windows = append(windows, w)
+	}
+	rows.Close()
+	if len(windows) == 0 {
+		return nil
+	}
+
+	stored := make(map[string]*calendar.Event)
+	rows, err = tx.Query(`SELECT raw FROM events WHERE person = ? AND start_time < ? AND end_time > ?`, person, hi, lo)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			rows.Close()
+			return err
+		}
+		var event calendar.Event
+		if json.Unmarshal([]byte(raw), &event) == nil {
+			stored[event.Id] = &event
+		}
+	}
+	rows.Close()
+
+	record := func(kind ChangeKind, event, previous *calendar.Event) error {
+		raw, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		previousRaw := []byte{}
+		if previous != nil {
+			if previousRaw, err = json.Marshal(previous); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(`INSERT INTO changes (detected_at, person, event_id, change, raw, previous_raw) VALUES (?, ?, ?, ?, ?, ?)`,
+			now, person, event.Id, string(kind), string(raw), string(previousRaw))
+		return err
+	}
+
+	for _, event := range events {
+		if event.Status == "cancelled" {
+			continue
+		}
+		old, ok := stored[event.Id]
+		delete(stored, event.Id)
+		switch {
+		case ok && !sameEvent(old, event):
+			if err := record(ChangeModified, event, old); err != nil {
+				return err
+			}
+		case !ok && fetchedBefore(event, windows):
+			if err := record(ChangeAdded, event, nil); err != nil {
+				return err
+			}
+		}
+	}
+	// What's left was in the window before but isn't anymore
+	for _, old := range stored {
+		if err := record(ChangeCancelled, old, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameEvent reports whether a and b have the same times and title.
+func sameEvent(a, b *calendar.Event) bool {
+	aStart, aEnd, errA := eventInterval(a)
+	bStart, bEnd, errB := eventInterval(b)
+	if errA != nil || errB != nil {
+		return errA != nil && errB != nil
+	}
+	return aStart.Equal(bStart) && aEnd.Equal(bEnd) && a.Summary == b.Summary
+}
+
+// fetchedBefore reports whether event overlaps one of the windows.
+func fetchedBefore(event *calendar.Event, windows [][2]string) bool {
+	start, end, err := eventInterval(event)
+	if err != nil {
+		return false
+	}
+	lo, hi := formatStoreTime(start), formatStoreTime(end)
+	return slices.ContainsFunc(windows, func(w [2]string) bool {
+		return lo < w[1] && hi > w[0]
+	})
+}
+
+// Changes returns the changes detected since the given time, oldest first,
+// limited to people unless it's nil.
+func (s *Store) Changes(since time.Time, people []string) ([]Change, error) {
+	rows, err := s.db.Query(`SELECT detected_at, person, change, raw, previous_raw FROM changes WHERE detected_at >= ? ORDER BY detected_at, rowid`,
+		formatStoreTime(since))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read changes: %v", err)
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var detectedAt, person, kind, raw, previousRaw string
+		if err := rows.Scan(&detectedAt, &person, &kind, &raw, &previousRaw); err != nil {
+			return nil, fmt.Errorf("unable to read changes: %v", err)
+		}
+		if people != nil && !slices.Contains(people, person) {
+			continue
+		}
+		c := Change{Person: person, Kind: ChangeKind(kind), Event: &calendar.Event{}}
+		c.DetectedAt, _ = time.Parse(storeTimeFormat, detectedAt)
+		if err := json.Unmarshal([]byte(raw), c.Event); err != nil {
+			return nil, fmt.Errorf("unable to parse stored change: %v", err)
+		}
+		if previousRaw != "" {
+			c.Previous = &calendar.Event{}
+			if err := json.Unmarshal([]byte(previousRaw), c.Previous); err != nil {
+				return nil, fmt.Errorf("unable to parse stored change: %v", err)
+			}
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
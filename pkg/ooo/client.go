@@ -0,0 +1,183 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Client fetches the absences of one group and renders them, for embedding
+// ooo-view in other programs:
+//
+//	client, err := ooo.New(ooo.WithProvider(p), ooo.WithGroup("team@example.com"), ooo.WithMinDuration(48*time.Hour))
+//	if err != nil { ... }
+//	if _, err := client.Fetch(ctx); err != nil { ... }
+//	err = client.Render(w, "grid")
+type Client struct {
+	provider Provider
+	group    string
+	timeMin  time.Time
+	timeMax  time.Time
+	opts     FetchOptions
+
+	dataset *Dataset
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithProvider sets where absences are fetched from. It's required.
+func WithProvider(p Provider) Option {
+	return func(c *Client) { c.provider = p }
+}
+
+// WithGroup sets the group whose members are fetched. It's required.
+func WithGroup(groupEmail string) Option {
+	return func(c *Client) { c.group = groupEmail }
+}
+
+// WithWindow sets the range fetched. It defaults to Window(time.Now(), 8),
+// like the CLI.
+func WithWindow(from, to time.Time) Option {
+	return func(c *Client) { c.timeMin, c.timeMax = from, to }
+}
+
+// WithMinDuration drops absences shorter than d. It defaults to 24h.
+func WithMinDuration(d time.Duration) Option {
+	return func(c *Client) { c.opts.MinDuration = d }
+}
+
+// WithResponseStatuses keeps only the absences the person responded to with
+// one of statuses, besides those they aren't invited to. It defaults to all
+// of them.
+func WithResponseStatuses(statuses ...string) Option {
+	return func(c *Client) { c.opts.ResponseStatuses = statuses }
+}
+
+// WithKinds keeps only the absences of one of kinds, e.g. KindOutOfOffice
+// to leave out busy blocks. It defaults to all of them.
+func WithKinds(kinds ...Kind) Option {
+	return func(c *Client) { c.opts.Kinds = kinds }
+}
+
+// WithWorkWeeks fetches the days each member works, if the provider knows
+// them.
+func WithWorkWeeks() Option {
+	return func(c *Client) { c.opts.WorkWeeks = true }
+}
+
+// WithPersonTimeZones buckets each member's absences into days in their own
+// time zone, if the provider knows it, rather than the shared one.
+func WithPersonTimeZones() Option {
+	return func(c *Client) { c.opts.PersonTimeZones = true }
+}
+
+// WithOffices fetches the office of each member, if the provider knows it;
+// the Google provider needs its Directory set.
+func WithOffices() Option {
+	return func(c *Client) { c.opts.Offices = true }
+}
+
+// WithIdentities also fetches the other addresses of the members in ids,
+// and shows each person once under the address ids maps them to.
+func WithIdentities(ids Identities) Option {
+	return func(c *Client) { c.opts.Identities = ids }
+}
+
+// WithRedactedSummaries removes the titles of the absences from the
+// dataset and everything rendered from it.
+func WithRedactedSummaries() Option {
+	return func(c *Client) { c.opts.RedactSummaries = true }
+}
+
+// WithTimeZone sets the IANA time zone all-day absences are placed in. It
+// defaults to the local time zone.
+func WithTimeZone(name string) Option {
+	return func(c *Client) { c.opts.TimeZone = name }
+}
+
+// WithConcurrency sets how many calendars are fetched in parallel. It
+// defaults to 10.
+func WithConcurrency(n int) Option {
+	return func(c *Client) { c.opts.Concurrency = n }
+}
+
+// WithRequestTimeout bounds the fetch of each calendar. It defaults to 30s.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(c *Client) { c.opts.RequestTimeout = d }
+}
+
+// WithFetchDeadline gives up on the calendars not fetched within d, listing
+// them in Dataset.Unavailable, so one slow calendar doesn't hold up the rest.
+// By default the fetch waits for every calendar.
+func WithFetchDeadline(d time.Duration) Option {
+	return func(c *Client) { c.opts.Deadline = d }
+}
+
+// New returns a client configured by opts.
+func New(opts ...Option) (*Client, error) {
+	c := &Client{
+		opts: FetchOptions{
+			Concurrency:    10,
+			RequestTimeout: 30 * time.Second,
+			MinDuration:    24 * time.Hour,
+			TimeZone:       time.Local.String(),
+		},
+	}
+	c.timeMin, c.timeMax = Window(time.Now(), 8)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.provider == nil {
+		return nil, errors.New("no provider set; use WithProvider")
+	}
+	if c.group == "" {
+		return nil, errors.New("no group set; use WithGroup")
+	}
+	if !c.timeMin.Before(c.timeMax) {
+		return nil, fmt.Errorf("invalid window: %s is not before %s", c.timeMin.Format(time.RFC3339), c.timeMax.Format(time.RFC3339))
+	}
+	if _, err := time.LoadLocation(c.opts.TimeZone); err != nil {
+		return nil, fmt.Errorf("invalid timezone: %v", err)
+	}
+	for _, status := range c.opts.ResponseStatuses {
+		if !slices.Contains(ResponseStatuses, status) {
+			return nil, fmt.Errorf("invalid response status '%s'; use one of %s", status, strings.Join(ResponseStatuses, ", "))
+		}
+	}
+	return c, nil
+}
+
+// Fetch fetches the group's absences and keeps the dataset for Render. A
+// dataset cut short by cancelling ctx is kept as well, and returned with a
+// *PartialError.
+func (c *Client) Fetch(ctx context.Context) (*Dataset, error) {
+	ds, err := FetchGroup(ctx, c.provider, c.group, c.timeMin, c.timeMax, c.opts)
+	if ds != nil {
+		c.dataset = ds
+	}
+	return ds, err
+}
+
+// Dataset returns the last fetched dataset, or nil before the first Fetch.
+func (c *Client) Dataset() *Dataset {
+	return c.dataset
+}
+
+// Render writes the last fetched dataset in the registered format, e.g.
+// "grid" or "json".
+func (c *Client) Render(w io.Writer, format string) error {
+	if c.dataset == nil {
+		return errors.New("nothing to render; call Fetch first")
+	}
+	r, ok := LookupRenderer(format)
+	if !ok {
+		return fmt.Errorf("unknown format '%s'; import package render for the built-in formats", format)
+	}
+	return r.Render(w, c.dataset)
+}
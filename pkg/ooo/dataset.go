@@ -0,0 +1,645 @@
+// Package ooo fetches the out-of-office events of a Google Group's members
+// from the Calendar API and keeps them in a local store.
+package ooo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Dataset is the complete result fetched for a group: its members and their
+// absences within the window.
+type Dataset struct {
+	Group     string    `json:"group"`
+	TimeMin   time.Time `json:"time_min"`
+	TimeMax   time.Time `json:"time_max"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Members   []string  `json:"members"`
+	Absences  []Absence `json:"absences"`
+	// Fetched, if set, holds the absences as fetched, before submitted
+	// ones were added and they were reconciled and filtered, so that they
+	// can be filtered again
+	Fetched []Absence `json:"-"`
+	// WorkWeeks holds the work week of the members known to work other
+	// days than DefaultWorkWeek
+	WorkWeeks map[string]WorkWeek `json:"work_weeks,omitempty"`
+	// Location is the timezone all-day absences were placed in, and the one
+	// days are bucketed in for display
+	Location *time.Location `json:"-"`
+
+	// Stale is set when the data came from the store instead of the API
+	Stale bool `json:"stale,omitempty"`
+	// Partial is set when the fetch was cancelled before every member's
+	// calendar was fetched; Members only lists those that were
+	Partial bool `json:"partial,omitempty"`
+	// Unavailable lists the members whose calendars couldn't be fetched
+	// before the fetch deadline; they aren't in Members
+	Unavailable []string `json:"unavailable,omitempty"`
+
+	// NoData holds the members whose calendars couldn't be read, e.g.
+	// because they aren't shared with the user, with the reason; they
+	// aren't in Members
+	NoData map[string]string `json:"no_data,omitempty"`
+
+	// TimeZones, if set, holds the members' own time zones, which their
+	// timed absences are bucketed into days in instead of Location
+	TimeZones map[string]*time.Location `json:"-"`
+
+	// Offices, if set, holds the offices of the members known to work in
+	// one
+	Offices map[string]Office `json:"offices,omitempty"`
+
+	// Milestones, if set, are the dates overlaid on the absences for
+	// planning, like releases and freezes
+	Milestones []Milestone `json:"milestones,omitempty"`
+
+	// Holidays, if set, are the public holidays within the window, in
+	// order
+	Holidays []Holiday `json:"holidays,omitempty"`
+
+	// Roster, if set, describes the teams, roles and managers of the
+	// members, for grouping them
+	Roster Roster `json:"-"`
+}
+
+// Kind classifies an absence.
+type Kind string
+
+const (
+	// KindOutOfOffice is an out-of-office event on the person's calendar.
+	KindOutOfOffice Kind = "out_of_office"
+	// KindBusy is a busy block long enough to count as an absence,
+	// inferred from free/busy information without reading the events.
+	KindBusy Kind = "busy"
+)
+
+// kindNames are the short names of the kinds, e.g. for command-line flags.
+var kindNames = map[string]Kind{"ooo": KindOutOfOffice, "busy": KindBusy}
+
+// notKinds explains the names that look like kinds but aren't: holidays are
+// a layer of their own, and focus time and working locations aren't read.
+var notKinds = map[string]string{
+	"holiday":         "holidays aren't absences; they show with --holiday-calendars",
+	"holidays":        "holidays aren't absences; they show with --holiday-calendars",
+	"focus":           "focus time isn't read from the calendars",
+	"focustime":       "focus time isn't read from the calendars",
+	"wfh":             "working locations aren't read from the calendars",
+	"workinglocation": "working locations aren't read from the calendars",
+}
+
+// ParseKind parses a kind by its short name, "ooo" or "busy", or its value.
+func ParseKind(name string) (Kind, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if kind, ok := kindNames[name]; ok {
+		return kind, nil
+	}
+	for _, kind := range kindNames {
+		if name == string(kind) {
+			return kind, nil
+		}
+	}
+	if reason, ok := notKinds[name]; ok {
+		return "", fmt.Errorf("invalid absence type '%s': %s; use ooo or busy", name, reason)
+	}
+	return "", fmt.Errorf("invalid absence type '%s'; use ooo or busy", name)
+}
+
+// BusyEventType marks the events made up from busy blocks by free/busy-only
+// providers. It isn't one of the Calendar API's event types.
+const BusyEventType = "ooo-view.busy"
+
+// BusyDetailsHidden is the title of the busy blocks read instead of the
+// events of a calendar that can't be read, with FreebusyFallback.
+const BusyDetailsHidden = "Busy, details hidden"
+
+// SourceGoogleCalendar marks absences read from Google Calendar.
+const SourceGoogleCalendar = "google_calendar"
+
+// SourceAssumed marks hypothetical absences added with AddAssumed.
+const SourceAssumed = "assumed"
+
+// SourceManual marks absences submitted by other systems, like an HR tool,
+// and added with SetManual.
+const SourceManual = "manual"
+
+// SourceProperty is the private extended property of an event that names
+// the source of its absence, for providers like MultiProvider that pass on
+// the events of others.
+const SourceProperty = "ooo-view.source"
+
+// Absence is one period a person is away, independent of the provider it
+// came from.
+type Absence struct {
+	Person string    `json:"person"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	// AllDay absences start and end at midnight in the dataset's timezone,
+	// with an exclusive end
+	AllDay  bool   `json:"all_day"`
+	Kind    Kind   `json:"kind"`
+	Summary string `json:"summary,omitempty"`
+	// Source names the provider and Ref the absence's ID there, e.g. the
+	// calendar event ID
+	Source string `json:"source"`
+	Ref    string `json:"ref,omitempty"`
+	// ResponseStatus is the person's response to the event if they're one
+	// of its attendees: needsAction, declined, tentative or accepted
+	ResponseStatus string `json:"response_status,omitempty"`
+	// AutoDecline is how the out-of-office event answers invitations
+	// during it, one of the AutoDecline values, or empty if unknown; and
+	// DeclineMessage the reply sent with the declines
+	AutoDecline    string `json:"auto_decline,omitempty"`
+	DeclineMessage string `json:"decline_message,omitempty"`
+}
+
+// The values of Absence.AutoDecline, as set in Google Calendar.
+const (
+	// AutoDeclineAll declines every meeting during the absence, including
+	// those already accepted
+	AutoDeclineAll = "declineAllConflictingInvitations"
+	// AutoDeclineNew declines only invitations received after the absence
+	// was set
+	AutoDeclineNew = "declineOnlyNewConflictingInvitations"
+	// AutoDeclineNone leaves invitations unanswered
+	AutoDeclineNone = "declineNone"
+)
+
+// Declines reports whether the absence automatically declines meetings,
+// telling a hard absence from one that may still allow for a call.
+func (a Absence) Declines() bool {
+	return a.AutoDecline == AutoDeclineAll || a.AutoDecline == AutoDeclineNew
+}
+
+// ResponseStatuses are the values of Absence.ResponseStatus.
+var ResponseStatuses = []string{"needsAction", "declined", "tentative", "accepted"}
+
+// Duration returns how long the absence lasts.
+func (a Absence) Duration() time.Duration {
+	return a.End.Sub(a.Start)
+}
+
+// Days returns the dates the absence covers, at midnight in loc. The end is
+// exclusive: an all-day absence ending on the 20th covers up to the 19th, and
+// a timed one ending at midnight doesn't touch the next day. All-day
+// absences keep their calendar dates whatever loc is, while timed ones are
+// converted to loc first, so a trip from 22:00 to 02:00 covers two days.
+func (a Absence) Days(loc *time.Location) []time.Time {
+	if !a.Start.Before(a.End) {
+		return nil
+	}
+	first, end := a.Start.In(loc), a.End
+	if a.AllDay {
+		first = a.Start
+		end = time.Date(a.End.Year(), a.End.Month(), a.End.Day(), 0, 0, 0, 0, loc)
+	}
+
+	var days []time.Time
+	for i := 0; ; i++ {
+		// Counting days from the first date rather than adding 24h keeps
+		// every day at midnight across DST changes
+		d := time.Date(first.Year(), first.Month(), first.Day()+i, 0, 0, 0, 0, loc)
+		if !d.Before(end) {
+			return days
+		}
+		days = append(days, d)
+	}
+}
+
+// NewDataset normalizes the calendar events of a group's members, with
+// all-day events placed in loc. Cancelled events and events without valid
+// times are dropped.
+func NewDataset(group string, timeMin, timeMax time.Time, eventsByPerson map[string][]*calendar.Event, loc *time.Location) *Dataset {
+	ds := emptyDataset(group, timeMin, timeMax, loc)
+	for person, events := range eventsByPerson {
+		ds.addMember(person, events)
+	}
+	sort.Strings(ds.Members)
+	SortAbsences(ds.Absences)
+	return ds
+}
+
+// NewDatasetFromStream is NewDataset for the calendars received from
+// stream. Each calendar's events are turned into absences as they arrive,
+// so the raw events of the whole group are never held at once. It returns
+// the stream's error; with a *PartialError or *UnavailableError, the
+// dataset of the calendars fetched comes with it.
+func NewDatasetFromStream(group string, timeMin, timeMax time.Time, stream *EventStream, loc *time.Location) (*Dataset, error) {
+	ds := emptyDataset(group, timeMin, timeMax, loc)
+	for pe := range stream.C {
+		if pe.Err != nil {
+			ds.addNoData(pe.Person, pe.Err)
+			continue
+		}
+		ds.addMember(pe.Person, pe.Events)
+	}
+	err := stream.Err()
+	var partialErr *PartialError
+	var unavailableErr *UnavailableError
+	if err != nil && !errors.As(err, &partialErr) && !errors.As(err, &unavailableErr) {
+		return nil, err
+	}
+	sort.Strings(ds.Members)
+	SortAbsences(ds.Absences)
+	return ds, err
+}
+
+func emptyDataset(group string, timeMin, timeMax time.Time, loc *time.Location) *Dataset {
+	return &Dataset{
+		Group:     group,
+		TimeMin:   timeMin,
+		TimeMax:   timeMax,
+		FetchedAt: time.Now(),
+		Members:   []string{},
+		Location:  loc,
+	}
+}
+
+// NoDataMembers returns the members in NoData, sorted.
+func (ds *Dataset) NoDataMembers() []string {
+	people := make([]string, 0, len(ds.NoData))
+	for person := range ds.NoData {
+		people = append(people, person)
+	}
+	sort.Strings(people)
+	return people
+}
+
+// WithoutData returns the members whose absences are unknown: those
+// Unavailable, then those in NoData.
+func (ds *Dataset) WithoutData() []string {
+	return append(append([]string(nil), ds.Unavailable...), ds.NoDataMembers()...)
+}
+
+// addNoData records that person's calendar couldn't be read because of err.
+func (ds *Dataset) addNoData(person string, err error) {
+	if ds.NoData == nil {
+		ds.NoData = make(map[string]string)
+	}
+	reason := err.Error()
+	switch {
+	case errors.Is(err, ErrCalendarNotFound):
+		reason = "calendar not found or not shared with you"
+	case errors.Is(err, ErrNoAccess):
+		reason = "no access to the calendar"
+	}
+	ds.NoData[person] = reason
+}
+
+// addMember adds person and the absences of their events. Members and
+// absences have to be sorted afterwards.
+func (ds *Dataset) addMember(person string, events []*calendar.Event) {
+	ds.Members = append(ds.Members, person)
+	for _, event := range events {
+		if event.Status == "cancelled" {
+			continue
+		}
+		if a, err := FromEvent(person, event, ds.Location); err == nil {
+			ds.Absences = append(ds.Absences, a)
+		}
+	}
+}
+
+// FromEvent converts a calendar event of person into an absence.
+func FromEvent(person string, event *calendar.Event, loc *time.Location) (Absence, error) {
+	start, end, err := EventTimes(event, loc)
+	if err != nil {
+		return Absence{}, err
+	}
+	a := Absence{
+		Person:  person,
+		Start:   start,
+		End:     end,
+		AllDay:  event.Start.DateTime == "",
+		Kind:    KindOutOfOffice,
+		Summary: event.Summary,
+		Source:  SourceGoogleCalendar,
+		Ref:     event.Id,
+	}
+	if event.ExtendedProperties != nil && event.ExtendedProperties.Private[SourceProperty] != "" {
+		a.Source = event.ExtendedProperties.Private[SourceProperty]
+	}
+	if event.EventType == BusyEventType {
+		a.Kind = KindBusy
+	}
+	for _, attendee := range event.Attendees {
+		if strings.EqualFold(attendee.Email, person) {
+			a.ResponseStatus = attendee.ResponseStatus
+		}
+	}
+	if props := event.OutOfOfficeProperties; props != nil {
+		a.AutoDecline, a.DeclineMessage = props.AutoDeclineMode, props.DeclineMessage
+	}
+	return a, nil
+}
+
+// SortAbsences orders absences by person, then start.
+func SortAbsences(absences []Absence) {
+	sort.SliceStable(absences, func(i, j int) bool {
+		if absences[i].Person != absences[j].Person {
+			return absences[i].Person < absences[j].Person
+		}
+		return absences[i].Start.Before(absences[j].Start)
+	})
+}
+
+// SetSource sets the source of every absence. An empty source leaves them
+// as they are.
+func (ds *Dataset) SetSource(source string) {
+	if source == "" {
+		return
+	}
+	for i := range ds.Absences {
+		ds.Absences[i].Source = source
+	}
+}
+
+// Sources returns the providers the absences came from, sorted, leaving
+// out assumed and submitted ones, which no provider reports.
+func (ds *Dataset) Sources() []string {
+	var sources []string
+	for _, a := range ds.Absences {
+		if a.Source != SourceAssumed && a.Source != SourceManual && !slices.Contains(sources, a.Source) {
+			sources = append(sources, a.Source)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// ByPerson groups the absences by person. Members without absences are
+// left out.
+func (ds *Dataset) ByPerson() map[string][]Absence {
+	byPerson := make(map[string][]Absence)
+	for _, a := range ds.Absences {
+		byPerson[a.Person] = append(byPerson[a.Person], a)
+	}
+	return byPerson
+}
+
+// FilterByMinDuration drops the absences shorter than minDuration. All-day
+// absences count as 24 hours per day, so that a day off is a day even when
+// the clocks change.
+func (ds *Dataset) FilterByMinDuration(minDuration time.Duration) {
+	kept := ds.Absences[:0]
+	for _, a := range ds.Absences {
+		length := a.Duration()
+		if a.AllDay {
+			length = time.Duration(calendarDays(a.Start, a.End)) * 24 * time.Hour
+		}
+		if length >= minDuration {
+			kept = append(kept, a)
+		}
+	}
+	ds.Absences = kept
+}
+
+// calendarDays returns the number of dates from start's to end's.
+func calendarDays(start, end time.Time) int {
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from) / (24 * time.Hour))
+}
+
+// ParseDuration parses a Go duration like "36h", or a number of days or
+// weeks like "3d" or "1w".
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.ParseFloat(n, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid duration '%s'", s)
+			}
+			return time.Duration(v * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s', expected e.g. 36h, 3d or 1w", s)
+	}
+	return d, nil
+}
+
+// RedactSummaries removes the titles of the absences, which can be personal,
+// like "surgery" or "interview at X", and their decline messages.
+func (ds *Dataset) RedactSummaries() {
+	for i := range ds.Absences {
+		ds.Absences[i].Summary = ""
+		ds.Absences[i].DeclineMessage = ""
+	}
+}
+
+// FilterByKinds keeps the absences of one of kinds. An empty kinds keeps
+// every absence.
+func (ds *Dataset) FilterByKinds(kinds []Kind) {
+	if len(kinds) == 0 {
+		return
+	}
+	kept := ds.Absences[:0]
+	for _, a := range ds.Absences {
+		if slices.Contains(kinds, a.Kind) {
+			kept = append(kept, a)
+		}
+	}
+	ds.Absences = kept
+}
+
+// FilterByResponseStatus keeps the absences the person responded to with one
+// of statuses, and those they aren't an attendee of, like their own OOO
+// events. An empty statuses keeps every absence.
+func (ds *Dataset) FilterByResponseStatus(statuses []string) {
+	if len(statuses) == 0 {
+		return
+	}
+	kept := ds.Absences[:0]
+	for _, a := range ds.Absences {
+		if a.ResponseStatus == "" || slices.Contains(statuses, a.ResponseStatus) {
+			kept = append(kept, a)
+		}
+	}
+	ds.Absences = kept
+}
+
+// DayLocation returns the time zone person's days are bucketed in: their
+// own if it's in TimeZones, or else loc.
+func (ds *Dataset) DayLocation(person string, loc *time.Location) *time.Location {
+	if zone, ok := ds.TimeZones[person]; ok {
+		return zone
+	}
+	return loc
+}
+
+// AddAssumed adds a hypothetical all-day absence of person from the date of
+// first to that of last, both included, to explore a scenario. person is
+// made a member if they aren't one.
+func (ds *Dataset) AddAssumed(person string, first, last time.Time) {
+	if !slices.Contains(ds.Members, person) {
+		ds.Members = append(ds.Members, person)
+		sort.Strings(ds.Members)
+		delete(ds.NoData, person)
+	}
+	ds.Absences = append(ds.Absences, Absence{
+		Person:  person,
+		Start:   first,
+		End:     last.AddDate(0, 0, 1),
+		AllDay:  true,
+		Kind:    KindOutOfOffice,
+		Summary: "Assumed",
+		Source:  SourceAssumed,
+	})
+	SortAbsences(ds.Absences)
+}
+
+// SetManual replaces the SourceManual absences of the dataset with
+// absences. Those of people who aren't members, or outside the window, are
+// left out.
+func (ds *Dataset) SetManual(absences []Absence) {
+	kept := ds.Absences[:0]
+	for _, a := range ds.Absences {
+		if a.Source != SourceManual {
+			kept = append(kept, a)
+		}
+	}
+	for _, a := range absences {
+		if !slices.Contains(ds.Members, a.Person) || !a.End.After(ds.TimeMin) || !a.Start.Before(ds.TimeMax) {
+			continue
+		}
+		a.Source = SourceManual
+		kept = append(kept, a)
+	}
+	ds.Absences = kept
+	SortAbsences(ds.Absences)
+}
+
+// Subset returns the dataset of the members in people alone, sharing the
+// absences' fields with ds.
+func (ds *Dataset) Subset(people []string) *Dataset {
+	in := make(map[string]bool, len(people))
+	for _, person := range people {
+		in[person] = true
+	}
+	sub := *ds
+	sub.Members = make([]string, 0, len(people))
+	for _, person := range ds.Members {
+		if in[person] {
+			sub.Members = append(sub.Members, person)
+		}
+	}
+	sub.Absences = nil
+	for _, a := range ds.Absences {
+		if in[a.Person] {
+			sub.Absences = append(sub.Absences, a)
+		}
+	}
+	sub.Unavailable = nil
+	for _, person := range ds.Unavailable {
+		if in[person] {
+			sub.Unavailable = append(sub.Unavailable, person)
+		}
+	}
+	sub.NoData = nil
+	for person, reason := range ds.NoData {
+		if in[person] {
+			if sub.NoData == nil {
+				sub.NoData = make(map[string]string)
+			}
+			sub.NoData[person] = reason
+		}
+	}
+	return &sub
+}
+
+// WorkWeek returns the days person works.
+func (ds *Dataset) WorkWeek(person string) WorkWeek {
+	if week, ok := ds.WorkWeeks[person]; ok {
+		return week
+	}
+	return DefaultWorkWeek
+}
+
+// WorkingMembers returns the members who work on date's weekday, in order.
+// Those without data (see WithoutData) aren't members, so coverage is the
+// share of these that are in, and missing data is neither in nor out.
+func (ds *Dataset) WorkingMembers(date time.Time) []string {
+	var working []string
+	for _, member := range ds.Members {
+		if ds.WorkWeek(member).Works(date.Weekday()) {
+			working = append(working, member)
+		}
+	}
+	return working
+}
+
+// Window returns the range covered for weeksAhead: from the start of the
+// current week (Monday, UTC) to the end of the Sunday weeksAhead weeks later.
+func Window(now time.Time, weeksAhead int) (time.Time, time.Time) {
+	start := now.UTC()
+	for start.Weekday() != time.Monday {
+		start = start.AddDate(0, 0, -1)
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+
+	// Move to the end of the last week (Sunday)
+	end := start.AddDate(0, 0, weeksAhead*7)
+	for end.Weekday() != time.Sunday {
+		end = end.AddDate(0, 0, 1)
+	}
+	end = time.Date(end.Year(), end.Month(), end.Day(), 23, 59, 59, 0, end.Location())
+	return start, end
+}
+
+// EventTimes returns the start and end of an event. All-day events start and
+// end at midnight in loc.
+func EventTimes(event *calendar.Event, loc *time.Location) (time.Time, time.Time, error) {
+	parse := func(dt *calendar.EventDateTime) (time.Time, error) {
+		if dt == nil {
+			return time.Time{}, fmt.Errorf("missing event time")
+		}
+		if dt.DateTime != "" {
+			return time.Parse(time.RFC3339, dt.DateTime)
+		}
+		return time.ParseInLocation("2006-01-02", dt.Date, loc)
+	}
+
+	start, err := parse(event.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := parse(event.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
+}
+
+// AbsentDays returns, per person with at least one absence, the days they
+// work (see Dataset.WorkWeek) within the dataset's window that an OOO event
+// touches.
+// Days are keyed by date in loc, or the person's own time zone if the
+// dataset has it, formatted as 2006-01-02.
+func AbsentDays(ds *Dataset, loc *time.Location) map[string]map[string]bool {
+	firstDay := ds.TimeMin.Format("2006-01-02")
+	lastDay := ds.TimeMax.Format("2006-01-02")
+
+	daysOut := make(map[string]map[string]bool)
+	for _, a := range ds.Absences {
+		for _, d := range a.Days(ds.DayLocation(a.Person, loc)) {
+			key := d.Format("2006-01-02")
+			if key < firstDay || key > lastDay || !ds.WorkWeek(a.Person).Works(d.Weekday()) {
+				continue
+			}
+			if daysOut[a.Person] == nil {
+				daysOut[a.Person] = make(map[string]bool)
+			}
+			daysOut[a.Person][key] = true
+		}
+	}
+	return daysOut
+}
@@ -0,0 +1,115 @@
+package ooo_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+func TestAbsenceDays(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(loc *time.Location, year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name    string
+		absence ooo.Absence
+		loc     *time.Location
+		want    string
+	}{
+		{
+			name:    "all-day over spring forward in Amsterdam",
+			absence: ooo.Absence{AllDay: true, Start: date(amsterdam, 2024, 3, 30, 0, 0), End: date(amsterdam, 2024, 4, 1, 0, 0)},
+			loc:     amsterdam,
+			want:    "2024-03-30 00:00 CET, 2024-03-31 00:00 CET",
+		},
+		{
+			name:    "timed over spring forward in Amsterdam",
+			absence: ooo.Absence{Start: date(amsterdam, 2024, 3, 30, 22, 0), End: date(amsterdam, 2024, 3, 31, 10, 0)},
+			loc:     amsterdam,
+			want:    "2024-03-30 00:00 CET, 2024-03-31 00:00 CET",
+		},
+		{
+			name:    "all-day over fall back in Amsterdam",
+			absence: ooo.Absence{AllDay: true, Start: date(amsterdam, 2024, 10, 26, 0, 0), End: date(amsterdam, 2024, 10, 29, 0, 0)},
+			loc:     amsterdam,
+			want:    "2024-10-26 00:00 CEST, 2024-10-27 00:00 CEST, 2024-10-28 00:00 CET",
+		},
+		{
+			name:    "timed into the repeated hour in Amsterdam",
+			absence: ooo.Absence{Start: date(amsterdam, 2024, 10, 26, 23, 0), End: time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC)},
+			loc:     amsterdam,
+			want:    "2024-10-26 00:00 CEST, 2024-10-27 00:00 CEST",
+		},
+		{
+			name:    "all-day over spring forward in New York",
+			absence: ooo.Absence{AllDay: true, Start: date(newYork, 2024, 3, 9, 0, 0), End: date(newYork, 2024, 3, 11, 0, 0)},
+			loc:     newYork,
+			want:    "2024-03-09 00:00 EST, 2024-03-10 00:00 EST",
+		},
+		{
+			name:    "timed over the skipped hour in New York",
+			absence: ooo.Absence{Start: date(newYork, 2024, 3, 10, 1, 0), End: date(newYork, 2024, 3, 10, 4, 0)},
+			loc:     newYork,
+			want:    "2024-03-10 00:00 EST",
+		},
+		{
+			name:    "all-day on the 25-hour fall back day in New York",
+			absence: ooo.Absence{AllDay: true, Start: date(newYork, 2024, 11, 3, 0, 0), End: date(newYork, 2024, 11, 4, 0, 0)},
+			loc:     newYork,
+			want:    "2024-11-03 00:00 EDT",
+		},
+		{
+			name:    "timed over fall back in New York",
+			absence: ooo.Absence{Start: date(newYork, 2024, 11, 2, 23, 0), End: date(newYork, 2024, 11, 3, 23, 30)},
+			loc:     newYork,
+			want:    "2024-11-02 00:00 EDT, 2024-11-03 00:00 EDT",
+		},
+		{
+			name:    "all-day ending at the next midnight",
+			absence: ooo.Absence{AllDay: true, Start: date(amsterdam, 2024, 6, 10, 0, 0), End: date(amsterdam, 2024, 6, 11, 0, 0)},
+			loc:     amsterdam,
+			want:    "2024-06-10 00:00 CEST",
+		},
+		{
+			name:    "all-day keeps its dates in another time zone",
+			absence: ooo.Absence{AllDay: true, Start: date(time.UTC, 2024, 6, 10, 0, 0), End: date(time.UTC, 2024, 6, 12, 0, 0)},
+			loc:     newYork,
+			want:    "2024-06-10 00:00 EDT, 2024-06-11 00:00 EDT",
+		},
+		{
+			name:    "timed ending at midnight",
+			absence: ooo.Absence{Start: date(newYork, 2024, 6, 10, 9, 0), End: date(newYork, 2024, 6, 11, 0, 0)},
+			loc:     newYork,
+			want:    "2024-06-10 00:00 EDT",
+		},
+		{
+			name:    "empty",
+			absence: ooo.Absence{Start: date(newYork, 2024, 6, 10, 9, 0), End: date(newYork, 2024, 6, 10, 9, 0)},
+			loc:     newYork,
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range tt.absence.Days(tt.loc) {
+				got = append(got, d.Format("2006-01-02 15:04 MST"))
+			}
+			if strings.Join(got, ", ") != tt.want {
+				t.Errorf("Days() = %q, want %q", strings.Join(got, ", "), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package ooo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Failure modes callers can test for with errors.Is. Providers wrap them in
+// a *FetchError naming the group or calendar concerned.
+var (
+	// ErrGroupNotFound means the group doesn't exist or isn't visible to the
+	// user.
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrCalendarNotFound means a member's calendar doesn't exist or isn't
+	// shared with the user.
+	ErrCalendarNotFound = errors.New("calendar not found")
+	// ErrNoAccess means the user isn't allowed to read the group or
+	// calendar, e.g. because the token lacks a scope.
+	ErrNoAccess = errors.New("no access")
+	// ErrAuthExpired means the token has expired or was revoked and can't be
+	// refreshed, so the user has to sign in again.
+	ErrAuthExpired = errors.New("authorization expired")
+	// ErrUnsupported means the provider can't answer that kind of request,
+	// like a plugin that doesn't know work weeks.
+	ErrUnsupported = errors.New("not supported by the provider")
+)
+
+// FetchError is a failed request for a group or calendar, classified as
+// one of the Err values above.
+type FetchError struct {
+	// Kind is ErrGroupNotFound, ErrCalendarNotFound, ErrNoAccess,
+	// ErrAuthExpired or ErrUnsupported
+	Kind error
+	// Subject is the group or calendar the request was for
+	Subject string
+	// Err is the error returned by the API, if any
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	var msg string
+	switch e.Kind {
+	case ErrGroupNotFound:
+		msg = fmt.Sprintf("group '%s' not found or you don't have access to it. Please check if the email address is correct", e.Subject)
+	case ErrCalendarNotFound:
+		msg = fmt.Sprintf("calendar of '%s' not found or not shared with you", e.Subject)
+	case ErrNoAccess:
+		msg = fmt.Sprintf("no access to '%s'", e.Subject)
+	case ErrAuthExpired:
+		msg = "the authorization has expired or was revoked"
+	default:
+		msg = fmt.Sprintf("%s: %v", e.Subject, e.Kind)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is matches the error's Kind.
+func (e *FetchError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError is returned, along with the events fetched so far, when the
+// caller's context is cancelled in the middle of a fetch.
+type PartialError struct {
+	Fetched int
+	Total   int
+	// Err is the context's error
+	Err error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("fetch interrupted after %d of %d calendars: %v", e.Fetched, e.Total, e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// UnavailableError is returned, along with the events fetched, when a fetch
+// with FetchOptions.Deadline gave up on some calendars.
+type UnavailableError struct {
+	// People are the calendars given up on, sorted
+	People   []string
+	Deadline time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%d calendars unavailable within %s: %s", len(e.People), e.Deadline, strings.Join(e.People, ", "))
+}
+
+// classify wraps a Calendar API error for subject in a *FetchError when it
+// matches a known failure mode, using notFound for 404 responses. It returns
+// nil for other errors.
+func classify(err error, subject string, notFound error) *FetchError {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+		return &FetchError{Kind: ErrAuthExpired, Subject: subject, Err: err}
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+	switch {
+	case apiErr.Code == http.StatusUnauthorized:
+		return &FetchError{Kind: ErrAuthExpired, Subject: subject, Err: err}
+	case apiErr.Code == http.StatusNotFound:
+		return &FetchError{Kind: notFound, Subject: subject, Err: err}
+	case apiErr.Code == http.StatusForbidden && !isQuotaError(err):
+		return &FetchError{Kind: ErrNoAccess, Subject: subject, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/calendar/v3"
+)
+
+// FetchOptions control how the events of a group's members are fetched.
+type FetchOptions struct {
+	// Concurrency is the maximum number of calendars fetched in parallel
+	Concurrency int
+	// RequestTimeout bounds the fetch of a single calendar
+	RequestTimeout time.Duration
+	// Deadline, if set, bounds the whole fetch. Calendars not fetched by
+	// then, and those that time out or fail transiently, are given up on
+	// and reported as unavailable instead of failing the fetch.
+	Deadline time.Duration
+	// MinDuration drops shorter absences from FetchGroup's dataset
+	MinDuration time.Duration
+	// ResponseStatuses, if set, drops from FetchGroup's dataset the
+	// absences the person responded to otherwise, e.g. declined ones
+	ResponseStatuses []string
+	// Kinds, if set, drops the absences of other kinds from FetchGroup's
+	// dataset
+	Kinds []Kind
+	// RedactSummaries removes the titles of the absences in FetchGroup's
+	// dataset
+	RedactSummaries bool
+	// WorkWeeks asks providers implementing WorkWeekProvider for the days
+	// each member works
+	WorkWeeks bool
+	// PersonTimeZones asks providers implementing TimeZoneProvider for the
+	// time zone of each member, to bucket their days in
+	PersonTimeZones bool
+	// Offices asks providers implementing OfficeProvider for the office of
+	// each member
+	Offices bool
+	// TimeZone places all-day events and is passed to the free/busy query
+	TimeZone string
+	// Identities has FetchGroup also fetch the members' other addresses
+	// and show each person once
+	Identities Identities
+
+	// OnFetch, if set, is called after every calendar's fetch, e.g. to
+	// report progress
+	OnFetch func(person string, took time.Duration, err error)
+}
+
+// FetchEvents fetches the OOO events of every person using a pool of at most
+// opts.Concurrency workers. The first error cancels the remaining fetches.
+// If ctx is cancelled, the events fetched so far are returned with a
+// *PartialError. With opts.Deadline, the events fetched are returned with an
+// *UnavailableError naming the calendars given up on.
+func FetchEvents(ctx context.Context, p Provider, people []string, timeMin, timeMax time.Time, opts FetchOptions) (map[string][]*calendar.Event, error) {
+	stream := StreamEvents(ctx, p, people, timeMin, timeMax, opts)
+	eventsByPerson := make(map[string][]*calendar.Event)
+	for pe := range stream.C {
+		if pe.Err == nil {
+			eventsByPerson[pe.Person] = pe.Events
+		}
+	}
+	err := stream.Err()
+	var partialErr *PartialError
+	var unavailableErr *UnavailableError
+	if err != nil && !errors.As(err, &partialErr) && !errors.As(err, &unavailableErr) {
+		return nil, err
+	}
+	return eventsByPerson, err
+}
+
+// PersonEvents are the OOO events of one calendar, as sent by StreamEvents.
+type PersonEvents struct {
+	Person string
+	Events []*calendar.Event
+	// Err is set, with no events, if the calendar can't be read, e.g.
+	// because it isn't shared with the user
+	Err error
+}
+
+// EventStream is a fetch started by StreamEvents. Receive from C until it's
+// closed, then call Err.
+type EventStream struct {
+	C <-chan PersonEvents
+
+	done chan struct{}
+	err  error
+}
+
+// Err waits for the fetch to end and returns its error, as FetchEvents
+// would. C has to be drained first.
+func (s *EventStream) Err() error {
+	<-s.done
+	return s.err
+}
+
+// StreamEvents fetches like FetchEvents, but sends every calendar's events
+// on C as soon as they're fetched instead of collecting them, so that large
+// groups can be processed, or shown, as they come in. C buffers at most
+// opts.Concurrency calendars; the workers wait while the receiver falls
+// behind, which keeps memory flat however large the group.
+func StreamEvents(ctx context.Context, p Provider, people []string, timeMin, timeMax time.Time, opts FetchOptions) *EventStream {
+	workers := max(opts.Concurrency, 1)
+	out := make(chan PersonEvents, workers)
+	stream := &EventStream{C: out, done: make(chan struct{})}
+	go func() {
+		defer close(stream.done)
+		stream.err = streamEvents(ctx, p, people, timeMin, timeMax, opts, workers, out)
+	}()
+	return stream
+}
+
+// streamEvents runs the workers of StreamEvents, closing out when they're
+// done.
+func streamEvents(ctx context.Context, p Provider, people []string, timeMin, timeMax time.Time, opts FetchOptions, workers int, out chan<- PersonEvents) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Past the deadline, the remaining calendars are given up on
+	fetchCtx := ctx
+	if opts.Deadline > 0 {
+		var cancelDeadline context.CancelFunc
+		fetchCtx, cancelDeadline = context.WithTimeout(ctx, opts.Deadline)
+		defer cancelDeadline()
+	}
+
+	fetched := make(map[string]bool)
+	var unavailable []string
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(people))
+	jobs := make(chan string)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for email := range jobs {
+				start := time.Now()
+				reqCtx, reqCancel := fetchCtx, context.CancelFunc(func() {})
+				if opts.RequestTimeout > 0 {
+					reqCtx, reqCancel = context.WithTimeout(fetchCtx, opts.RequestTimeout)
+				}
+				reqCtx, span := tracer.Start(reqCtx, "fetch person", trace.WithAttributes(attribute.String("person", email)))
+				events, err := p.OutOfOfficeEvents(reqCtx, email, timeMin, timeMax)
+				endSpan(span, err)
+				reqCancel()
+				if opts.OnFetch != nil {
+					opts.OnFetch(email, time.Since(start), err)
+				}
+				if err != nil && opts.Deadline > 0 && parent.Err() == nil && (errors.Is(err, context.DeadlineExceeded) || IsUnavailable(err)) {
+					logFor("fetch").Warn("calendar unavailable", "person", email, "duration", time.Since(start), "error", err)
+					mu.Lock()
+					unavailable = append(unavailable, email)
+					mu.Unlock()
+					continue
+				}
+				// A calendar that isn't shared doesn't stop the others
+				if errors.Is(err, ErrCalendarNotFound) || errors.Is(err, ErrNoAccess) {
+					logFor("fetch").Warn("calendar can't be read", "person", email, "error", err)
+					mu.Lock()
+					fetched[email] = true
+					mu.Unlock()
+					out <- PersonEvents{Person: email, Err: err}
+					continue
+				}
+				if err != nil {
+					logFor("fetch").Debug("fetch failed", "person", email, "duration", time.Since(start), "error", err)
+					// A *FetchError already names the calendar
+					var fetchErr *FetchError
+					if !errors.As(err, &fetchErr) {
+						err = fmt.Errorf("%s: %w", email, err)
+					}
+					errChan <- err
+					cancel()
+					continue
+				}
+				logFor("fetch").Debug("fetched events", "person", email, "events", len(events), "duration", time.Since(start))
+				mu.Lock()
+				fetched[email] = true
+				mu.Unlock()
+				out <- PersonEvents{Person: email, Events: events}
+			}
+		}()
+	}
+
+	// Feed the workers until every calendar is queued or the fetch is cancelled
+feed:
+	for _, email := range people {
+		select {
+		case jobs <- email:
+		case <-fetchCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+
+	// Wait for all workers to complete or an error to occur
+	wg.Wait()
+	close(errChan)
+	close(out)
+
+	// Calendars that failed because of the cancellation are left out
+	if err := parent.Err(); err != nil {
+		return &PartialError{Fetched: len(fetched), Total: len(people), Err: err}
+	}
+
+	// Check for errors
+	if err := <-errChan; err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Calendars still queued at the deadline weren't fetched either
+	if opts.Deadline > 0 {
+		for _, email := range people {
+			if !fetched[email] && !slices.Contains(unavailable, email) {
+				unavailable = append(unavailable, email)
+			}
+		}
+	}
+	if len(unavailable) > 0 {
+		sort.Strings(unavailable)
+		return &UnavailableError{People: unavailable, Deadline: opts.Deadline}
+	}
+	return nil
+}
+
+// FetchGroup resolves the group's members and fetches their absences within
+// the window. If ctx is cancelled during the fetch, it returns the partial
+// dataset, marked as such, with a *PartialError.
+func FetchGroup(ctx context.Context, p Provider, groupEmail string, timeMin, timeMax time.Time, opts FetchOptions) (*Dataset, error) {
+	loc, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %v", err)
+	}
+	people, err := p.Members(ctx, groupEmail, timeMin, timeMax, opts.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	people = opts.Identities.Expand(people)
+	ds, err := NewDatasetFromStream(groupEmail, timeMin, timeMax, StreamEvents(ctx, p, people, timeMin, timeMax, opts), loc)
+	if ds == nil {
+		return nil, err
+	}
+	// A partial dataset is returned with its error, marked as partial
+	var partialErr *PartialError
+	isPartial := errors.As(err, &partialErr)
+	// Calendars given up on at the deadline don't fail the fetch
+	var unavailableErr *UnavailableError
+	if errors.As(err, &unavailableErr) {
+		ds.Unavailable = unavailableErr.People
+		err = nil
+	}
+	ds.SetSource(SourceOf(p))
+	ds.MergeIdentities(opts.Identities)
+	if opts.WorkWeeks {
+		ds.WorkWeeks = FetchWorkWeeks(ctx, p, ds.Members)
+	}
+	if opts.PersonTimeZones {
+		ds.TimeZones = FetchTimeZones(ctx, p, ds.Members)
+	}
+	if opts.Offices {
+		ds.Offices = FetchOffices(ctx, p, ds.Members)
+	}
+	ds.FilterByMinDuration(opts.MinDuration)
+	ds.FilterByResponseStatus(opts.ResponseStatuses)
+	ds.FilterByKinds(opts.Kinds)
+	if opts.RedactSummaries {
+		ds.RedactSummaries()
+	}
+	ds.Partial = isPartial
+	return ds, err
+}
@@ -0,0 +1,167 @@
+package ooo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Holiday is a public holiday, on which nobody is expected to work.
+type Holiday struct {
+	// Date is at midnight in the dataset's timezone
+	Date time.Time `json:"date"`
+	Name string    `json:"name"`
+}
+
+// HolidayProvider is implemented by providers that can read a calendar of
+// public holidays, like Google's "Holidays in the Netherlands".
+type HolidayProvider interface {
+	// Holidays returns the holidays on the calendar within the window, with
+	// their dates at midnight in loc
+	Holidays(ctx context.Context, calendarID string, timeMin, timeMax time.Time, loc *time.Location) ([]Holiday, error)
+}
+
+// FetchHolidays asks p for the holidays on each of calendars, if p
+// supports HolidayProvider. Calendars that can't be read are left out.
+func FetchHolidays(ctx context.Context, p Provider, calendars []string, timeMin, timeMax time.Time, loc *time.Location) []Holiday {
+	if !Supports[HolidayProvider](p) {
+		return nil
+	}
+	hp := p.(HolidayProvider)
+	var holidays []Holiday
+	for _, id := range calendars {
+		found, err := hp.Holidays(ctx, id, timeMin, timeMax, loc)
+		if err != nil {
+			logFor("fetch").Warn("could not fetch holidays", "calendar", id, "error", err)
+			continue
+		}
+		holidays = append(holidays, found...)
+	}
+	return holidays
+}
+
+// Holidays returns the all-day events on the holiday calendar with ID
+// calendarID, e.g. "en.dutch#holiday@group.v.calendar.google.com", one per
+// day they cover.
+func (p *GoogleProvider) Holidays(ctx context.Context, calendarID string, timeMin, timeMax time.Time, loc *time.Location) ([]Holiday, error) {
+	events, err := p.listEvents(ctx, calendarID, "", timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	var holidays []Holiday
+	for _, event := range events {
+		if event.Status == "cancelled" || event.Start == nil || event.Start.Date == "" {
+			continue
+		}
+		a, err := FromEvent("", event, loc)
+		if err != nil {
+			continue
+		}
+		for _, d := range a.Days(loc) {
+			holidays = append(holidays, Holiday{Date: d, Name: event.Summary})
+		}
+	}
+	return holidays, nil
+}
+
+// AddHolidays adds holidays to the dataset, keeping one per date.
+func (ds *Dataset) AddHolidays(holidays []Holiday) {
+	seen := make(map[string]bool, len(ds.Holidays))
+	for _, h := range ds.Holidays {
+		seen[h.Date.Format("2006-01-02")] = true
+	}
+	for _, h := range holidays {
+		if key := h.Date.Format("2006-01-02"); !seen[key] {
+			seen[key] = true
+			ds.Holidays = append(ds.Holidays, h)
+		}
+	}
+	sort.Slice(ds.Holidays, func(i, j int) bool { return ds.Holidays[i].Date.Before(ds.Holidays[j].Date) })
+}
+
+// Holiday returns the holiday on the date of day, if any.
+func (ds *Dataset) Holiday(day time.Time) (Holiday, bool) {
+	key := day.Format("2006-01-02")
+	for _, h := range ds.Holidays {
+		if h.Date.Format("2006-01-02") == key {
+			return h, true
+		}
+	}
+	return Holiday{}, false
+}
+
+// BridgeDay is a working day between a holiday and a weekend or another
+// holiday, which many take off to make a long weekend, and the people out
+// on it.
+type BridgeDay struct {
+	Date time.Time
+	// Holiday is the holiday it bridges to
+	Holiday Holiday
+	// Out are the members out that day, sorted, of the Working members
+	// who work it
+	Out     []string
+	Working int
+}
+
+// BridgeDays returns the bridge days within the dataset's window, in
+// order. A day is one if DefaultWorkWeek works it and both the day before
+// and the day after are holidays or days it doesn't work, at least one
+// being a holiday. Days are keyed by date in loc, like AbsentDays.
+func BridgeDays(ds *Dataset, loc *time.Location) []BridgeDay {
+	if len(ds.Holidays) == 0 {
+		return nil
+	}
+	free := func(d time.Time) bool {
+		_, holiday := ds.Holiday(d)
+		return holiday || !DefaultWorkWeek.Works(d.Weekday())
+	}
+	daysOut := AbsentDays(ds, loc)
+	lastDay := ds.TimeMax.Format("2006-01-02")
+
+	var bridges []BridgeDay
+	first := time.Date(ds.TimeMin.Year(), ds.TimeMin.Month(), ds.TimeMin.Day(), 0, 0, 0, 0, loc)
+	for d := first; d.Format("2006-01-02") <= lastDay; d = d.AddDate(0, 0, 1) {
+		if free(d) {
+			continue
+		}
+		before, after := d.AddDate(0, 0, -1), d.AddDate(0, 0, 1)
+		if !free(before) || !free(after) {
+			continue
+		}
+		holiday, ok := ds.Holiday(before)
+		if !ok {
+			if holiday, ok = ds.Holiday(after); !ok {
+				continue
+			}
+		}
+		bridge := BridgeDay{Date: d, Holiday: holiday}
+		key := d.Format("2006-01-02")
+		for _, person := range ds.Members {
+			if !ds.WorkWeek(person).Works(d.Weekday()) {
+				continue
+			}
+			bridge.Working++
+			if daysOut[person][key] {
+				bridge.Out = append(bridge.Out, person)
+			}
+		}
+		sort.Strings(bridge.Out)
+		bridges = append(bridges, bridge)
+	}
+	return bridges
+}
+
+// ParseHolidays parses holidays given as a name by date, like 2025-12-25,
+// with the dates at midnight in loc.
+func ParseHolidays(byDate map[string]string, loc *time.Location) ([]Holiday, error) {
+	holidays := make([]Holiday, 0, len(byDate))
+	for date, name := range byDate {
+		d, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday date '%s', expected a date like 2025-12-25", date)
+		}
+		holidays = append(holidays, Holiday{Date: d, Name: name})
+	}
+	return holidays, nil
+}
@@ -0,0 +1,163 @@
+package ooo
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Identities maps the other addresses of people, e.g. of an old domain or a
+// personal calendar, to the address they're shown as.
+type Identities map[string]string
+
+// ParseIdentities builds Identities from each person's address and the list
+// of their other addresses. An address can't belong to two people.
+func ParseIdentities(aliases map[string][]string) (Identities, error) {
+	ids := make(Identities)
+	for person, others := range aliases {
+		person = strings.ToLower(person)
+		for _, alias := range others {
+			alias = strings.ToLower(alias)
+			if canonical, ok := ids[alias]; ok && canonical != person {
+				return nil, fmt.Errorf("%s is an alias of both %s and %s", alias, canonical, person)
+			}
+			if _, ok := aliases[alias]; ok {
+				return nil, fmt.Errorf("%s is both a person and an alias of %s", alias, person)
+			}
+			ids[alias] = person
+		}
+	}
+	return ids, nil
+}
+
+// Canonical returns the address person is shown as.
+func (ids Identities) Canonical(person string) string {
+	if canonical, ok := ids[strings.ToLower(person)]; ok {
+		return canonical
+	}
+	return person
+}
+
+// Expand returns people with every other address of each of them added, so
+// that the calendars of all of them are fetched.
+func (ids Identities) Expand(people []string) []string {
+	if len(ids) == 0 {
+		return people
+	}
+	seen := make(map[string]bool, len(people))
+	for _, person := range people {
+		seen[person] = true
+	}
+	wanted := make(map[string]bool)
+	for _, person := range people {
+		wanted[ids.Canonical(person)] = true
+	}
+	expanded := append([]string(nil), people...)
+	add := func(person string) {
+		if !seen[person] {
+			seen[person] = true
+			expanded = append(expanded, person)
+		}
+	}
+	for alias, canonical := range ids {
+		if wanted[canonical] {
+			add(canonical)
+			add(alias)
+		}
+	}
+	sort.Strings(expanded[len(people):])
+	return expanded
+}
+
+// MergeIdentities shows the members under the address ids maps them to, so
+// that a person fetched under several addresses is one member. An absence
+// found on more than one of their calendars is kept once. A person is only
+// without data if none of their calendars could be read.
+func (ds *Dataset) MergeIdentities(ids Identities) {
+	if len(ids) == 0 {
+		return
+	}
+
+	seen := make(map[string]bool, len(ds.Members))
+	members := ds.Members[:0]
+	for _, person := range ds.Members {
+		person = ids.Canonical(person)
+		if !seen[person] {
+			seen[person] = true
+			members = append(members, person)
+		}
+	}
+	sort.Strings(members)
+	ds.Members = members
+
+	type key struct {
+		person     string
+		start, end time.Time
+		allDay     bool
+		kind       Kind
+		source     string
+	}
+	found := make(map[key]bool, len(ds.Absences))
+	absences := ds.Absences[:0]
+	for _, a := range ds.Absences {
+		a.Person = ids.Canonical(a.Person)
+		k := key{a.Person, a.Start.UTC(), a.End.UTC(), a.AllDay, a.Kind, a.Source}
+		if !found[k] {
+			found[k] = true
+			absences = append(absences, a)
+		}
+	}
+	SortAbsences(absences)
+	ds.Absences = absences
+
+	// The canonical address's own settings win over its aliases'
+	for alias, canonical := range ids {
+		if week, ok := ds.WorkWeeks[alias]; ok {
+			if _, ok := ds.WorkWeeks[canonical]; !ok {
+				ds.WorkWeeks[canonical] = week
+			}
+			delete(ds.WorkWeeks, alias)
+		}
+		if zone, ok := ds.TimeZones[alias]; ok {
+			if _, ok := ds.TimeZones[canonical]; !ok {
+				ds.TimeZones[canonical] = zone
+			}
+			delete(ds.TimeZones, alias)
+		}
+		if office, ok := ds.Offices[alias]; ok {
+			if _, ok := ds.Offices[canonical]; !ok {
+				ds.Offices[canonical] = office
+			}
+			delete(ds.Offices, alias)
+		}
+	}
+
+	for person, reason := range ds.NoData {
+		canonical := ids.Canonical(person)
+		if canonical == person {
+			continue
+		}
+		delete(ds.NoData, person)
+		if _, ok := ds.NoData[canonical]; !ok {
+			ds.NoData[canonical] = reason
+		}
+	}
+	for person := range ds.NoData {
+		if seen[person] {
+			delete(ds.NoData, person)
+		}
+	}
+	var unavailable []string
+	for _, person := range ds.Unavailable {
+		person = ids.Canonical(person)
+		if !seen[person] && !slices.Contains(unavailable, person) {
+			unavailable = append(unavailable, person)
+		}
+	}
+	ds.Unavailable = unavailable
+	for _, person := range ds.Unavailable {
+		delete(ds.NoData, person)
+	}
+}
@@ -0,0 +1,95 @@
+package ooo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// ManagerProvider is implemented by providers that know whom each person
+// reports to.
+type ManagerProvider interface {
+	// Manager returns the email of person's manager, or "" if they have
+	// none
+	Manager(ctx context.Context, person string) (string, error)
+}
+
+// ReportsOf returns the people among people who report to manager: directly
+// with direct, or else through any chain of managers. Managers are looked up
+// with p, if it supports ManagerProvider, following the chain beyond
+// people as far as needed; those that can't be fetched end it.
+func ReportsOf(ctx context.Context, p Provider, people []string, manager string, direct bool) ([]string, error) {
+	if !Supports[ManagerProvider](p) {
+		return nil, fmt.Errorf("the provider doesn't know managers")
+	}
+	mp := p.(ManagerProvider)
+	managers := make(map[string]string)
+	managerOf := func(person string) string {
+		if m, ok := managers[person]; ok {
+			return m
+		}
+		m, err := mp.Manager(ctx, person)
+		if err != nil {
+			logFor("fetch").Warn("could not fetch manager", "person", person, "error", err)
+		}
+		managers[person] = strings.ToLower(m)
+		return managers[person]
+	}
+
+	var reports []string
+	for _, person := range people {
+		seen := map[string]bool{person: true}
+		for m := managerOf(person); m != "" && !seen[m]; m = managerOf(m) {
+			if m == manager {
+				reports = append(reports, person)
+				break
+			}
+			if direct {
+				break
+			}
+			seen[m] = true
+		}
+	}
+	sort.Strings(reports)
+	return reports, nil
+}
+
+// Manager returns the manager of person in the Directory. It needs
+// Directory to be set.
+func (p *GoogleProvider) Manager(ctx context.Context, person string) (string, error) {
+	if p.Directory == nil {
+		return "", fmt.Errorf("no access to the Directory")
+	}
+	var user *admin.User
+	err := p.do(ctx, "users.get", person, func(ctx context.Context) error {
+		var err error
+		user, err = p.Directory.Users.Get(person).ViewType("domain_public").Fields("relations").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		if fetchErr := classify(err, person, ErrCalendarNotFound); fetchErr != nil {
+			return "", fetchErr
+		}
+		return "", fmt.Errorf("unable to get user: %w", err)
+	}
+
+	// The generated client leaves the relations undecoded
+	data, err := json.Marshal(user.Relations)
+	if err != nil {
+		return "", fmt.Errorf("unable to read relations: %v", err)
+	}
+	var relations []admin.UserRelation
+	if err := json.Unmarshal(data, &relations); err != nil {
+		return "", fmt.Errorf("unable to read relations: %v", err)
+	}
+	for _, r := range relations {
+		if r.Type == "manager" {
+			return r.Value, nil
+		}
+	}
+	return "", nil
+}
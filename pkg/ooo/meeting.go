@@ -0,0 +1,129 @@
+package ooo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Meeting is an event on a shared calendar, like a team's meeting series,
+// with the people expected at it.
+type Meeting struct {
+	ID      string
+	Summary string
+	Start   time.Time
+	End     time.Time
+	// Organizer is the email of whoever runs the meeting
+	Organizer string
+	// Required are the attendees who are neither optional nor declined
+	Required []string
+	// RecurringID is the ID of the series the meeting is an occurrence of,
+	// if any
+	RecurringID string
+	Link        string
+}
+
+// MeetingProvider is implemented by providers that can list the meetings
+// on a calendar.
+type MeetingProvider interface {
+	// Meetings returns the meetings on the calendar within the window, one
+	// per occurrence of recurring ones
+	Meetings(ctx context.Context, calendarID string, timeMin, timeMax time.Time) ([]Meeting, error)
+}
+
+// Meetings returns the timed events on the calendar with ID calendarID,
+// leaving out cancelled ones and those that aren't meetings, like
+// out-of-office or focus time.
+func (p *GoogleProvider) Meetings(ctx context.Context, calendarID string, timeMin, timeMax time.Time) ([]Meeting, error) {
+	events, err := p.listEvents(ctx, calendarID, "default", timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	var meetings []Meeting
+	for _, event := range events {
+		if event.Status == "cancelled" || event.Start == nil || event.Start.DateTime == "" {
+			continue
+		}
+		start, end, err := EventTimes(event, time.UTC)
+		if err != nil {
+			continue
+		}
+		meetings = append(meetings, meetingFromEvent(event, start, end))
+	}
+	return meetings, nil
+}
+
+// meetingFromEvent returns the meeting of a calendar event.
+func meetingFromEvent(event *calendar.Event, start, end time.Time) Meeting {
+	m := Meeting{
+		ID:          event.Id,
+		Summary:     event.Summary,
+		Start:       start,
+		End:         end,
+		RecurringID: event.RecurringEventId,
+		Link:        event.HtmlLink,
+	}
+	if event.Organizer != nil {
+		m.Organizer = strings.ToLower(event.Organizer.Email)
+	}
+	for _, attendee := range event.Attendees {
+		if attendee.Optional || attendee.Resource || attendee.ResponseStatus == "declined" {
+			continue
+		}
+		m.Required = append(m.Required, strings.ToLower(attendee.Email))
+	}
+	return m
+}
+
+// MeetingConflict is a meeting that people expected at it are out for.
+type MeetingConflict struct {
+	Meeting Meeting
+	// OrganizerOut is set if the organizer is among Out
+	OrganizerOut bool
+	// Out are the organizer and required attendees with an absence during
+	// the meeting, sorted
+	Out []string
+}
+
+// MeetingConflicts returns the meetings whose organizer or required
+// attendees are out during them, by the absences in the dataset, in order.
+// Addresses are matched after ids. People who aren't members of the
+// dataset aren't known to be out.
+func MeetingConflicts(ds *Dataset, meetings []Meeting, ids Identities) []MeetingConflict {
+	byPerson := ds.ByPerson()
+	outDuring := func(person string, m Meeting) bool {
+		for _, a := range byPerson[person] {
+			if a.Start.Before(m.End) && a.End.After(m.Start) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var conflicts []MeetingConflict
+	for _, m := range meetings {
+		organizer := ids.Canonical(m.Organizer)
+		seen := make(map[string]bool)
+		c := MeetingConflict{Meeting: m}
+		for _, person := range append([]string{organizer}, m.Required...) {
+			person = ids.Canonical(person)
+			if person == "" || seen[person] {
+				continue
+			}
+			seen[person] = true
+			if outDuring(person, m) {
+				c.Out = append(c.Out, person)
+				c.OrganizerOut = c.OrganizerOut || person == organizer
+			}
+		}
+		if len(c.Out) > 0 {
+			sort.Strings(c.Out)
+			conflicts = append(conflicts, c)
+		}
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool { return conflicts[i].Meeting.Start.Before(conflicts[j].Meeting.Start) })
+	return conflicts
+}
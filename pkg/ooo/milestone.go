@@ -0,0 +1,89 @@
+package ooo
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// Milestone is a date, or a window of dates like a code freeze, that
+// matters for planning, with the people key to it.
+type Milestone struct {
+	Name string `json:"name"`
+	// First and Last are the milestone's first and last dates, both
+	// included, at midnight in the dataset's timezone
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	// People are the members key to the milestone; empty means every
+	// member
+	People []string `json:"people,omitempty"`
+	// Role names the key people in reports, e.g. "reviewers"
+	Role string `json:"role,omitempty"`
+}
+
+// MilestoneDay is a working day of a milestone and the key people out on
+// it.
+type MilestoneDay struct {
+	Date time.Time
+	// Out are the key people out that day, sorted, of the Key members key
+	// to the milestone
+	Out []string
+	Key int
+}
+
+// KeyPeople returns the members of ds key to m.
+func (m Milestone) KeyPeople(ds *Dataset) []string {
+	if len(m.People) == 0 {
+		return ds.Members
+	}
+	var key []string
+	for _, person := range ds.Members {
+		if slices.Contains(m.People, person) {
+			key = append(key, person)
+		}
+	}
+	return key
+}
+
+// Days returns the days of m within the dataset's window that one of its
+// key people works, with those out (see AbsentDays), keyed by date in loc.
+func (m Milestone) Days(ds *Dataset, loc *time.Location) []MilestoneDay {
+	key := m.KeyPeople(ds)
+	daysOut := AbsentDays(ds, loc)
+	firstDay, lastDay := ds.TimeMin.Format("2006-01-02"), ds.TimeMax.Format("2006-01-02")
+
+	var days []MilestoneDay
+	for d := m.First; !d.After(m.Last); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		if date < firstDay || date > lastDay {
+			continue
+		}
+		day := MilestoneDay{Date: d}
+		for _, person := range key {
+			if !ds.WorkWeek(person).Works(d.Weekday()) {
+				continue
+			}
+			day.Key++
+			if daysOut[person][date] {
+				day.Out = append(day.Out, person)
+			}
+		}
+		if day.Key > 0 {
+			sort.Strings(day.Out)
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// WorstDay returns the day of days with the most key people out, the first
+// if there's a tie. days must not be empty.
+func WorstDay(days []MilestoneDay) MilestoneDay {
+	worst := days[0]
+	for _, d := range days {
+		if len(d.Out) > len(worst.Out) {
+			worst = d
+		}
+	}
+	return worst
+}
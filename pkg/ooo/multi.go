@@ -0,0 +1,199 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// MultiProvider combines the absences of several providers, e.g. Google
+// Calendar and an HR system, tagging each with the source it came from so
+// that they aren't silently merged. Groups are resolved by the first
+// provider. Work weeks, time zones, offices and the other optional methods
+// are asked of each provider that implements them in turn, until one
+// answers, so it implements every optional interface; Supports tells
+// whether any of its providers does.
+type MultiProvider struct {
+	Providers []Provider
+}
+
+var (
+	_ WorkWeekProvider = (*MultiProvider)(nil)
+	_ TimeZoneProvider = (*MultiProvider)(nil)
+	_ OfficeProvider   = (*MultiProvider)(nil)
+	_ HolidayProvider  = (*MultiProvider)(nil)
+	_ ManagerProvider  = (*MultiProvider)(nil)
+	_ MeetingProvider  = (*MultiProvider)(nil)
+)
+
+// Supports reports whether p implements the optional provider interface T,
+// like WorkWeekProvider, or, for a MultiProvider, whether one of its
+// providers does.
+func Supports[T any](p Provider) bool {
+	if m, ok := p.(*MultiProvider); ok {
+		return slices.ContainsFunc(m.Providers, Supports[T])
+	}
+	_, ok := p.(T)
+	return ok
+}
+
+// nextError returns the error to report after a provider failed with err,
+// when the previous ones failed with last: the first one other than
+// ErrUnsupported.
+func nextError(last, err error) error {
+	if last == nil || errors.Is(last, ErrUnsupported) {
+		return err
+	}
+	return last
+}
+
+// unsupported is the error of a MultiProvider none of whose providers
+// implements what.
+func unsupported(what string) error {
+	return fmt.Errorf("none of the providers knows %s: %w", what, ErrUnsupported)
+}
+
+// Source is empty: the absences keep the sources of the providers they came
+// from.
+func (m *MultiProvider) Source() string {
+	return ""
+}
+
+// Members asks the first provider for the group's members.
+func (m *MultiProvider) Members(ctx context.Context, groupEmail string, timeMin, timeMax time.Time, timezone string) ([]string, error) {
+	return m.Providers[0].Members(ctx, groupEmail, timeMin, timeMax, timezone)
+}
+
+// OutOfOfficeEvents returns the events of person from every provider, with
+// their source in SourceProperty. An error of the first provider fails the
+// fetch; the others are logged and their events left out.
+func (m *MultiProvider) OutOfOfficeEvents(ctx context.Context, person string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	var all []*calendar.Event
+	for i, p := range m.Providers {
+		events, err := p.OutOfOfficeEvents(ctx, person, timeMin, timeMax)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			logFor("fetch").Warn("could not fetch events", "person", person, "provider", SourceOf(p), "error", err)
+			continue
+		}
+		for _, event := range events {
+			all = append(all, withSource(event, SourceOf(p)))
+		}
+	}
+	return all, nil
+}
+
+// withSource returns a copy of event naming source in SourceProperty,
+// unless it names one already. Events can be shared with a cache, so event
+// itself is left alone.
+func withSource(event *calendar.Event, source string) *calendar.Event {
+	if event.ExtendedProperties != nil && event.ExtendedProperties.Private[SourceProperty] != "" {
+		return event
+	}
+	tagged := *event
+	props := calendar.EventExtendedProperties{Private: map[string]string{SourceProperty: source}}
+	if event.ExtendedProperties != nil {
+		props.Shared = event.ExtendedProperties.Shared
+		for k, v := range event.ExtendedProperties.Private {
+			props.Private[k] = v
+		}
+	}
+	tagged.ExtendedProperties = &props
+	return &tagged
+}
+
+// WorkWeek asks the providers that know work weeks in turn.
+func (m *MultiProvider) WorkWeek(ctx context.Context, person string) (WorkWeek, error) {
+	err := unsupported("work weeks")
+	for _, p := range m.Providers {
+		if wp, ok := p.(WorkWeekProvider); ok {
+			week, pErr := wp.WorkWeek(ctx, person)
+			if pErr == nil {
+				return week, nil
+			}
+			err = nextError(err, pErr)
+		}
+	}
+	return nil, err
+}
+
+// TimeZone asks the providers that know time zones in turn.
+func (m *MultiProvider) TimeZone(ctx context.Context, person string) (string, error) {
+	err := unsupported("time zones")
+	for _, p := range m.Providers {
+		if tp, ok := p.(TimeZoneProvider); ok {
+			zone, pErr := tp.TimeZone(ctx, person)
+			if pErr == nil {
+				return zone, nil
+			}
+			err = nextError(err, pErr)
+		}
+	}
+	return "", err
+}
+
+// Office asks the providers that know offices in turn.
+func (m *MultiProvider) Office(ctx context.Context, person string) (Office, error) {
+	err := unsupported("offices")
+	for _, p := range m.Providers {
+		if op, ok := p.(OfficeProvider); ok {
+			office, pErr := op.Office(ctx, person)
+			if pErr == nil {
+				return office, nil
+			}
+			err = nextError(err, pErr)
+		}
+	}
+	return Office{}, err
+}
+
+// Holidays asks the providers that know holidays in turn.
+func (m *MultiProvider) Holidays(ctx context.Context, calendarID string, timeMin, timeMax time.Time, loc *time.Location) ([]Holiday, error) {
+	err := unsupported("holidays")
+	for _, p := range m.Providers {
+		if hp, ok := p.(HolidayProvider); ok {
+			holidays, pErr := hp.Holidays(ctx, calendarID, timeMin, timeMax, loc)
+			if pErr == nil {
+				return holidays, nil
+			}
+			err = nextError(err, pErr)
+		}
+	}
+	return nil, err
+}
+
+// Manager asks the providers that know managers in turn.
+func (m *MultiProvider) Manager(ctx context.Context, person string) (string, error) {
+	err := unsupported("managers")
+	for _, p := range m.Providers {
+		if mp, ok := p.(ManagerProvider); ok {
+			manager, pErr := mp.Manager(ctx, person)
+			if pErr == nil {
+				return manager, nil
+			}
+			err = nextError(err, pErr)
+		}
+	}
+	return "", err
+}
+
+// Meetings asks the providers that can list meetings in turn.
+func (m *MultiProvider) Meetings(ctx context.Context, calendarID string, timeMin, timeMax time.Time) ([]Meeting, error) {
+	err := unsupported("meetings")
+	for _, p := range m.Providers {
+		if mp, ok := p.(MeetingProvider); ok {
+			meetings, pErr := mp.Meetings(ctx, calendarID, timeMin, timeMax)
+			if pErr == nil {
+				return meetings, nil
+			}
+			err = nextError(err, pErr)
+		}
+	}
+	return nil, err
+}
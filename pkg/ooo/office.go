@@ -0,0 +1,94 @@
+package ooo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// Office is where a person works, for grouping members by location.
+type Office struct {
+	// Name is the office's building ID, or the free-text area of the
+	// person's location when they have no building
+	Name string `json:"name"`
+	// Region groups offices, e.g. for follow-the-sun planning; it's the
+	// office's name unless set otherwise
+	Region string `json:"region,omitempty"`
+}
+
+// OfficeProvider is implemented by providers that know the office each
+// person works in.
+type OfficeProvider interface {
+	// Office returns the office of person
+	Office(ctx context.Context, person string) (Office, error)
+}
+
+// FetchOffices asks p for the office of every person, if p supports
+// OfficeProvider. People whose office can't be fetched are left out.
+func FetchOffices(ctx context.Context, p Provider, people []string) map[string]Office {
+	if !Supports[OfficeProvider](p) {
+		return nil
+	}
+	op := p.(OfficeProvider)
+	offices := make(map[string]Office)
+	for _, person := range people {
+		office, err := op.Office(ctx, person)
+		if errors.Is(err, ErrUnsupported) {
+			logFor("fetch").Warn("the provider doesn't know offices", "error", err)
+			break
+		}
+		if err != nil {
+			logFor("fetch").Warn("could not fetch office", "person", person, "error", err)
+			continue
+		}
+		if office.Name != "" {
+			offices[person] = office
+		}
+	}
+	return offices
+}
+
+// Office returns the office of person's primary work location in the
+// Directory. It needs Directory to be set.
+func (p *GoogleProvider) Office(ctx context.Context, person string) (Office, error) {
+	if p.Directory == nil {
+		return Office{}, fmt.Errorf("no access to the Directory")
+	}
+	var user *admin.User
+	err := p.do(ctx, "users.get", person, func(ctx context.Context) error {
+		var err error
+		user, err = p.Directory.Users.Get(person).ViewType("domain_public").Fields("locations").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		if fetchErr := classify(err, person, ErrCalendarNotFound); fetchErr != nil {
+			return Office{}, fetchErr
+		}
+		return Office{}, fmt.Errorf("unable to get user: %w", err)
+	}
+
+	// The generated client leaves the locations undecoded
+	data, err := json.Marshal(user.Locations)
+	if err != nil {
+		return Office{}, fmt.Errorf("unable to read locations: %v", err)
+	}
+	var locations []admin.UserLocation
+	if err := json.Unmarshal(data, &locations); err != nil {
+		return Office{}, fmt.Errorf("unable to read locations: %v", err)
+	}
+	for _, l := range locations {
+		if l.Type != "desk" && l.Type != "default" && l.Type != "" {
+			continue
+		}
+		switch {
+		case l.BuildingId != "":
+			return Office{Name: l.BuildingId, Region: l.BuildingId}, nil
+		case l.Area != "":
+			return Office{Name: l.Area, Region: l.Area}, nil
+		}
+	}
+	return Office{}, nil
+}
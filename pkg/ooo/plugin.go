@@ -0,0 +1,291 @@
+package ooo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// PluginPrefix starts the name of every provider plugin executable: the
+// plugin "hr" is the program ooo-view-provider-hr on the PATH.
+const PluginPrefix = "ooo-view-provider-"
+
+// PluginProtocolVersion is sent with every request to a plugin.
+const PluginProtocolVersion = 1
+
+// PluginRequest is written as JSON to a plugin's stdin. Method is "members"
+// to list the members of Group, "events" to list the absences of Person
+// overlapping the window, "work_week" for the days Person works, or
+// "office" for the office Person works in.
+type PluginRequest struct {
+	Version  int       `json:"version"`
+	Method   string    `json:"method"`
+	Group    string    `json:"group,omitempty"`
+	Person   string    `json:"person,omitempty"`
+	TimeMin  time.Time `json:"time_min"`
+	TimeMax  time.Time `json:"time_max"`
+	TimeZone string    `json:"timezone,omitempty"`
+}
+
+// PluginResponse is read as JSON from a plugin's stdout. A non-empty Error
+// fails the request. Code optionally classifies the error as one of
+// "group_not_found", "calendar_not_found", "no_access", "auth_expired" or
+// "unsupported", for a method the plugin doesn't implement.
+type PluginResponse struct {
+	Members []string      `json:"members,omitempty"`
+	Events  []PluginEvent `json:"events,omitempty"`
+	// WorkWeek holds day names like "mon" or "monday"
+	WorkWeek []string `json:"work_week,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Code     string   `json:"code,omitempty"`
+
+	Office *Office `json:"office,omitempty"`
+}
+
+// pluginErrorCodes maps PluginResponse.Code to the errors it stands for.
+var pluginErrorCodes = map[string]error{
+	"group_not_found":    ErrGroupNotFound,
+	"calendar_not_found": ErrCalendarNotFound,
+	"no_access":          ErrNoAccess,
+	"auth_expired":       ErrAuthExpired,
+	"unsupported":        ErrUnsupported,
+}
+
+// PluginEvent is one absence returned by a plugin. Start and End are dates
+// (2006-01-02, with an exclusive end) for all-day absences and RFC 3339
+// timestamps otherwise.
+type PluginEvent struct {
+	ID      string `json:"id"`
+	Summary string `json:"summary,omitempty"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// ExecProvider is a Provider backed by a plugin executable. The plugin is
+// run once per request, with a PluginRequest on stdin, and must print a
+// PluginResponse on stdout.
+type ExecProvider struct {
+	Name string
+	Path string
+
+	// RateLimit, if positive, is the most requests a minute the plugin is
+	// run for, to stay within the quotas of the API behind it, which are
+	// often much stricter than Google's
+	RateLimit int
+	// CacheTTL, if positive, is how long the plugin's answers are reused
+	// for the same request, e.g. across the refreshes of serve
+	CacheTTL time.Duration
+
+	mu      sync.Mutex
+	limiter *rateLimiter
+	cache   map[string]cachedResponse
+}
+
+// cachedResponse is a plugin's answer and when it stops being reused.
+type cachedResponse struct {
+	resp    *PluginResponse
+	expires time.Time
+}
+
+var _ Provider = (*ExecProvider)(nil)
+
+// FindPlugin looks up the plugin called name on the PATH.
+func FindPlugin(name string) (*ExecProvider, error) {
+	path, err := exec.LookPath(PluginPrefix + name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find provider plugin '%s': %v", name, err)
+	}
+	return &ExecProvider{Name: name, Path: path}, nil
+}
+
+// Plugins returns the plugins found on the PATH, sorted by name. A plugin
+// earlier on the PATH hides one of the same name later on.
+func Plugins() []*ExecProvider {
+	seen := make(map[string]bool)
+	var plugins []*ExecProvider
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), PluginPrefix)
+			name = strings.TrimSuffix(name, ".exe")
+			if !ok || name == "" || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if _, err := exec.LookPath(path); err != nil {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, &ExecProvider{Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// Source names the plugin as the source of its absences, e.g. "plugin:hr".
+func (p *ExecProvider) Source() string {
+	return "plugin:" + p.Name
+}
+
+// Members asks the plugin for the group's members.
+func (p *ExecProvider) Members(ctx context.Context, groupEmail string, timeMin, timeMax time.Time, timezone string) ([]string, error) {
+	resp, err := p.call(ctx, PluginRequest{Method: "members", Group: groupEmail, TimeMin: timeMin, TimeMax: timeMax, TimeZone: timezone})
+	if err != nil {
+		return nil, err
+	}
+	members := append([]string(nil), resp.Members...)
+	sort.Strings(members)
+	return members, nil
+}
+
+// OutOfOfficeEvents asks the plugin for person's absences, returned as
+// calendar events so they go through the same normalization as Google's.
+func (p *ExecProvider) OutOfOfficeEvents(ctx context.Context, person string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	resp, err := p.call(ctx, PluginRequest{Method: "events", Person: person, TimeMin: timeMin, TimeMax: timeMax})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*calendar.Event, 0, len(resp.Events))
+	for _, e := range resp.Events {
+		event := &calendar.Event{
+			Id:        e.ID,
+			Summary:   e.Summary,
+			EventType: "outOfOffice",
+			Start:     &calendar.EventDateTime{DateTime: e.Start},
+			End:       &calendar.EventDateTime{DateTime: e.End},
+		}
+		if len(e.Start) == len("2006-01-02") {
+			event.Start = &calendar.EventDateTime{Date: e.Start}
+			event.End = &calendar.EventDateTime{Date: e.End}
+		}
+		if _, _, err := EventTimes(event, time.UTC); err != nil {
+			return nil, fmt.Errorf("plugin %s returned an invalid event %s: %v", p.Name, e.ID, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// WorkWeek asks the plugin for the days person works.
+func (p *ExecProvider) WorkWeek(ctx context.Context, person string) (WorkWeek, error) {
+	resp, err := p.call(ctx, PluginRequest{Method: "work_week", Person: person})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.WorkWeek) == 0 {
+		return nil, fmt.Errorf("plugin %s returned no work week", p.Name)
+	}
+	week, err := ParseWorkWeek(resp.WorkWeek)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s returned an invalid work week: %v", p.Name, err)
+	}
+	return week, nil
+}
+
+// Office asks the plugin for the office person works in.
+func (p *ExecProvider) Office(ctx context.Context, person string) (Office, error) {
+	resp, err := p.call(ctx, PluginRequest{Method: "office", Person: person})
+	if err != nil {
+		return Office{}, err
+	}
+	if resp.Office == nil || resp.Office.Name == "" {
+		return Office{}, fmt.Errorf("plugin %s returned no office", p.Name)
+	}
+	office := *resp.Office
+	if office.Region == "" {
+		office.Region = office.Name
+	}
+	return office, nil
+}
+
+// call runs the plugin for one request, or answers it from the cache.
+func (p *ExecProvider) call(ctx context.Context, req PluginRequest) (*PluginResponse, error) {
+	req.Version = PluginProtocolVersion
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode plugin request: %v", err)
+	}
+
+	key := string(input)
+	p.mu.Lock()
+	if cached, ok := p.cache[key]; ok && time.Now().Before(cached.expires) {
+		p.mu.Unlock()
+		logFor("plugin").Debug("reused provider plugin answer", "plugin", p.Name, "method", req.Method)
+		return cached.resp, nil
+	}
+	if p.limiter == nil && p.RateLimit > 0 {
+		p.limiter = newRateLimiter(p.RateLimit)
+	}
+	limiter := p.limiter
+	p.mu.Unlock()
+	if err := limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.run(ctx, req, input)
+	if err != nil || p.CacheTTL <= 0 {
+		return resp, err
+	}
+	p.mu.Lock()
+	if p.cache == nil {
+		p.cache = make(map[string]cachedResponse)
+	}
+	now := time.Now()
+	for k, cached := range p.cache {
+		if !now.Before(cached.expires) {
+			delete(p.cache, k)
+		}
+	}
+	p.cache[key] = cachedResponse{resp: resp, expires: now.Add(p.CacheTTL)}
+	p.mu.Unlock()
+	return resp, nil
+}
+
+// run runs the plugin with input, the encoded req.
+func (p *ExecProvider) run(ctx context.Context, req PluginRequest, input []byte) (*PluginResponse, error) {
+	start := time.Now()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	logFor("plugin").Debug("ran provider plugin", "plugin", p.Name, "method", req.Method, "duration", time.Since(start), "error", err)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed: %v: %s", p.Name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s returned invalid JSON: %v", p.Name, err)
+	}
+	if resp.Error != "" {
+		err := fmt.Errorf("plugin %s: %s", p.Name, resp.Error)
+		if kind, ok := pluginErrorCodes[resp.Code]; ok {
+			subject := req.Group
+			if req.Method != "members" {
+				subject = req.Person
+			}
+			return nil, &FetchError{Kind: kind, Subject: subject, Err: err}
+		}
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,645 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// EventsPageSize is the number of events requested per Events.List page.
+// It's the API maximum, since most people have few OOO events and a single
+// page per calendar keeps the number of round trips down.
+const EventsPageSize = 2500
+
+// Provider resolves the members of a group and fetches a person's OOO
+// events. GoogleProvider is the implementation used by ooo-view; package
+// providertest has a fake for tests.
+type Provider interface {
+	Members(ctx context.Context, groupEmail string, timeMin, timeMax time.Time, timezone string) ([]string, error)
+	OutOfOfficeEvents(ctx context.Context, person string, timeMin, timeMax time.Time) ([]*calendar.Event, error)
+}
+
+// SourceOf returns the name recorded as the source of p's absences:
+// p.Source() if p has that method, or SourceGoogleCalendar.
+func SourceOf(p Provider) string {
+	if s, ok := p.(interface{ Source() string }); ok {
+		return s.Source()
+	}
+	return SourceGoogleCalendar
+}
+
+// GoogleProvider resolves group members and fetches their OOO events from
+// the Google Calendar API, going through the local event store when one is
+// set.
+type GoogleProvider struct {
+	// SecondaryCalendars names calendars, e.g. "Absences", whose events
+	// count as absences of the members who are their attendees, or their
+	// organizer, not of whoever created them, who may be someone in HR.
+	// They're looked up by name in the user's calendar list, so the user
+	// has to have added them. Set it before the first fetch.
+	SecondaryCalendars []string
+	// FreebusyOnly infers absences from busy blocks instead of reading
+	// events, for tokens that only have the free/busy scope. Set it before
+	// the first fetch.
+	FreebusyOnly bool
+	// FreebusyFallback reads the busy blocks of calendars whose events
+	// can't be read, titled BusyDetailsHidden, instead of failing them, so
+	// that a fetch combines both for the group. Set it before the first
+	// fetch.
+	FreebusyFallback bool
+	// OnRequest, if set, is called after every Calendar API request,
+	// including retries. Set it before the first fetch.
+	OnRequest func(operation, calendarId string, err error)
+	// BatchClient, if set, is the authenticated client that sends the
+	// first pages of concurrent Events.List calls together to the batch
+	// endpoint, instead of one request each. Set it before the first fetch.
+	BatchClient *http.Client
+	// Directory, if set, is the Directory API the members' offices are
+	// read from
+	Directory *admin.Service
+
+	srv     *calendar.Service
+	store   *Store
+	quota   *quotaTracker
+	metrics *apiMetrics
+
+	secondaryMu sync.Mutex
+	secondary   map[string]*secondaryEvents // by window
+
+	busyMu sync.Mutex
+	busy   map[string]keptBusy // by calendar and window, see busyKey
+
+	batcherOnce sync.Once
+	batcher     *eventBatcher
+}
+
+// secondaryEvents are the events of the secondary calendars within one
+// window, by attendee and organizer.
+type secondaryEvents struct {
+	fetchedAt time.Time
+	byPerson  map[string][]*calendar.Event
+}
+
+// secondaryMaxAge is how long the events of the secondary calendars are
+// reused without a store to take the TTL from, or with it refreshing: long
+// enough for the members of one fetch.
+const secondaryMaxAge = time.Minute
+
+// keptBusy are the busy blocks of a calendar returned with a group's
+// members.
+type keptBusy struct {
+	keptAt  time.Time
+	periods []*calendar.TimePeriod
+}
+
+// busyMaxAge is how long busy blocks returned with a group's members are
+// used instead of querying them again, long enough for a fetch of the
+// group. Fetches that run longer query the rest one by one.
+const busyMaxAge = 10 * time.Minute
+
+// busyKey is the key of a calendar's busy blocks within a window in
+// GoogleProvider.busy. Concurrent fetches of other groups or windows, like
+// those of a proxy, keep their own.
+func busyKey(calendarId string, timeMin, timeMax time.Time) string {
+	return calendarId + "|" + secondaryWindow(timeMin, timeMax)
+}
+
+// NewGoogleProvider returns a provider using srv. store may be nil to always
+// query the API.
+func NewGoogleProvider(srv *calendar.Service, store *Store) *GoogleProvider {
+	return &GoogleProvider{srv: srv, store: store, quota: newQuotaTracker(), metrics: newAPIMetrics()}
+}
+
+// Requests returns the number of API requests sent so far, retries
+// included.
+func (p *GoogleProvider) Requests() int {
+	return p.quota.Requests()
+}
+
+// UsageSummary describes the provider's API usage so far.
+func (p *GoogleProvider) UsageSummary() string {
+	return p.quota.Summary()
+}
+
+// do runs an API call with retries in a span named after the operation,
+// accounting every attempt against the run's quota and metrics.
+func (p *GoogleProvider) do(ctx context.Context, operation string, calendarId string, call func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, operation, trace.WithAttributes(attribute.String("calendar.id", calendarId)))
+	attempts := 0
+	err := withRetry(ctx, func() error {
+		if err := p.quota.Wait(ctx); err != nil {
+			return err
+		}
+		attempts++
+		start := time.Now()
+		err := call(ctx)
+		p.metrics.Record(ctx, operation, time.Since(start), err)
+		logFor("api").Debug("api request", "operation", operation, "calendar", calendarId, "attempt", attempts, "duration", time.Since(start), "error", err)
+		p.quota.Record(err)
+		if p.OnRequest != nil {
+			p.OnRequest(operation, calendarId, err)
+		}
+		return err
+	})
+	span.SetAttributes(attribute.Int("attempts", attempts))
+	endSpan(span, err)
+	return err
+}
+
+const (
+	// GroupExpansionMax is the API limit on members returned for a group.
+	GroupExpansionMax = 100
+	// FreebusyBatchSize is the API limit on calendars per freebusy query.
+	FreebusyBatchSize = 50
+)
+
+// Members expands the group through a freebusy query and returns the
+// calendars of its members, sorted. Members beyond the per-query calendar
+// limit are fetched in additional batches and merged in.
+func (p *GoogleProvider) Members(ctx context.Context, groupEmail string, timeMin, timeMax time.Time, timezone string) ([]string, error) {
+	resp, err := p.queryFreebusy(ctx, []string{groupEmail}, timeMin, timeMax, timezone)
+	if err != nil {
+		if fetchErr := classify(err, groupEmail, ErrGroupNotFound); fetchErr != nil {
+			return nil, fetchErr
+		}
+		return nil, fmt.Errorf("unable to query freebusy: %w", err)
+	}
+
+	// A new fetch of the group sees changes to the secondary calendars
+	p.dropSecondaryEvents(timeMin, timeMax)
+
+	calendars := make(map[string]calendar.FreeBusyCalendar, len(resp.Calendars))
+	for id, cal := range resp.Calendars {
+		calendars[id] = cal
+	}
+	defer p.keepBusy(calendars, timeMin, timeMax)
+
+	if group, ok := resp.Groups[groupEmail]; ok {
+		for _, e := range group.Errors {
+			switch e.Reason {
+			case "groupTooBig":
+				logFor("api").Warn("group is too big, only its first members are shown", "group", groupEmail, "shown", GroupExpansionMax)
+			case "notFound":
+				return nil, &FetchError{Kind: ErrGroupNotFound, Subject: groupEmail}
+			}
+		}
+
+		// Query the members the group expansion didn't cover in batches
+		var missing []string
+		for _, id := range group.Calendars {
+			if _, ok := calendars[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		for len(missing) > 0 {
+			n := min(len(missing), FreebusyBatchSize)
+			batch, err := p.queryFreebusy(ctx, missing[:n], timeMin, timeMax, timezone)
+			if err != nil {
+				return nil, fmt.Errorf("unable to query freebusy: %w", err)
+			}
+			for id, cal := range batch.Calendars {
+				calendars[id] = cal
+			}
+			missing = missing[n:]
+		}
+	}
+
+	if len(calendars) == 0 {
+		return nil, fmt.Errorf("no calendars found for group '%s'. You might not have access to view the group's calendars", groupEmail)
+	}
+
+	people := make([]string, 0, len(calendars))
+	for id := range calendars {
+		people = append(people, id)
+	}
+	sort.Strings(people)
+	return people, nil
+}
+
+func (p *GoogleProvider) queryFreebusy(ctx context.Context, ids []string, timeMin, timeMax time.Time, timezone string) (*calendar.FreeBusyResponse, error) {
+	body := &calendar.FreeBusyRequest{
+		TimeMin:              timeMin.Format(time.RFC3339),
+		TimeMax:              timeMax.Format(time.RFC3339),
+		TimeZone:             timezone,
+		GroupExpansionMax:    GroupExpansionMax,
+		CalendarExpansionMax: FreebusyBatchSize,
+	}
+	for _, id := range ids {
+		body.Items = append(body.Items, &calendar.FreeBusyRequestItem{Id: id})
+	}
+
+	var resp *calendar.FreeBusyResponse
+	err := p.do(ctx, "freebusy.query", strings.Join(ids, ","), func(ctx context.Context) error {
+		var err error
+		resp, err = p.srv.Freebusy.Query(body).Context(ctx).Do()
+		return err
+	})
+	return resp, err
+}
+
+// OutOfOfficeEvents returns all OOO events on the calendar within the
+// window, served from the store if they were fetched within the TTL, along
+// with the person's events on the secondary calendars.
+func (p *GoogleProvider) OutOfOfficeEvents(ctx context.Context, calendarId string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	if p.FreebusyOnly {
+		return p.busyEvents(ctx, calendarId, timeMin, timeMax)
+	}
+	events, err := p.primaryEvents(ctx, calendarId, timeMin, timeMax)
+	if p.FreebusyFallback && errors.Is(err, ErrNoAccess) {
+		events, err = p.freebusyFallback(ctx, calendarId, timeMin, timeMax, err)
+	}
+	if err != nil || len(p.SecondaryCalendars) == 0 {
+		return events, err
+	}
+	secondary, err := p.secondaryEvents(ctx, timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	return append(events, secondary[strings.ToLower(calendarId)]...), nil
+}
+
+// primaryEvents returns the OOO events on the calendar, from the store if
+// they're within the TTL. Expired events with an ETag are confirmed with a
+// conditional request, which returns no events if nothing changed.
+func (p *GoogleProvider) primaryEvents(ctx context.Context, calendarId string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	etag := ""
+	var stored []*calendar.Event
+	if p.store != nil {
+		if events, ok := p.store.Get(calendarId, timeMin, timeMax); ok {
+			logFor("store").Debug("using cached events", "person", calendarId, "events", len(events))
+			return events, nil
+		}
+		etag, stored, _ = p.store.Revalidate(calendarId, timeMin, timeMax)
+	}
+
+	events, newTag, err := p.outOfOfficeEvents(ctx, calendarId, timeMin, timeMax, etag)
+	if errors.Is(err, errNotModified) {
+		logFor("store").Debug("cached events unchanged", "person", calendarId, "events", len(stored))
+		p.quota.RecordUnchanged()
+		if err := p.store.Touch(calendarId, timeMin, timeMax); err != nil {
+			logFor("store").Warn("could not store events", "person", calendarId, "error", err)
+		}
+		return stored, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.store != nil {
+		if err := p.store.PutTagged(calendarId, timeMin, timeMax, events, newTag); err != nil {
+			logFor("store").Warn("could not store events", "person", calendarId, "error", err)
+		}
+	}
+	return events, nil
+}
+
+// freebusyFallback returns the busy blocks of a calendar whose events can't
+// be read because of err, titled BusyDetailsHidden, or err if its free/busy
+// can't be read either.
+func (p *GoogleProvider) freebusyFallback(ctx context.Context, calendarId string, timeMin, timeMax time.Time, err error) ([]*calendar.Event, error) {
+	events, busyErr := p.busyEvents(ctx, calendarId, timeMin, timeMax)
+	if busyErr != nil {
+		logFor("fetch").Debug("free/busy can't be read either", "person", calendarId, "error", busyErr)
+		return nil, err
+	}
+	logFor("fetch").Info("events can't be read, using free/busy", "person", calendarId, "blocks", len(events))
+	for _, event := range events {
+		event.Summary = BusyDetailsHidden
+	}
+	return events, nil
+}
+
+// secondaryWindow is the key of a window in GoogleProvider.secondary.
+func secondaryWindow(timeMin, timeMax time.Time) string {
+	return timeMin.UTC().Format(time.RFC3339) + "|" + timeMax.UTC().Format(time.RFC3339)
+}
+
+// secondaryTTL is how long the events of the secondary calendars are
+// reused: as long as the store's events.
+func (p *GoogleProvider) secondaryTTL() time.Duration {
+	if p.store == nil || p.store.refresh {
+		return secondaryMaxAge
+	}
+	return p.store.ttl
+}
+
+// dropSecondaryEvents forgets the events of the secondary calendars within
+// the window, and those past their TTL.
+func (p *GoogleProvider) dropSecondaryEvents(timeMin, timeMax time.Time) {
+	p.secondaryMu.Lock()
+	defer p.secondaryMu.Unlock()
+	delete(p.secondary, secondaryWindow(timeMin, timeMax))
+	for key, s := range p.secondary {
+		if time.Since(s.fetchedAt) >= p.secondaryTTL() {
+			delete(p.secondary, key)
+		}
+	}
+}
+
+// secondaryEvents fetches the events of the secondary calendars once per
+// window and TTL and returns them by the lowercased emails of their
+// attendees, except those who declined, and their organizer. Out-of-office
+// events only exist on primary calendars, so every event on a secondary
+// calendar counts.
+func (p *GoogleProvider) secondaryEvents(ctx context.Context, timeMin, timeMax time.Time) (map[string][]*calendar.Event, error) {
+	p.secondaryMu.Lock()
+	defer p.secondaryMu.Unlock()
+	key := secondaryWindow(timeMin, timeMax)
+	if s, ok := p.secondary[key]; ok && time.Since(s.fetchedAt) < p.secondaryTTL() {
+		return s.byPerson, nil
+	}
+	fetchedAt := time.Now()
+
+	entries, err := p.Calendars(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list calendars: %w", err)
+	}
+	wanted := make(map[string]bool, len(p.SecondaryCalendars))
+	for _, name := range p.SecondaryCalendars {
+		wanted[strings.ToLower(name)] = true
+	}
+
+	byPerson := make(map[string][]*calendar.Event)
+	found := 0
+	for _, entry := range entries {
+		if entry.Primary || (!wanted[strings.ToLower(entry.Summary)] && !wanted[strings.ToLower(entry.SummaryOverride)]) {
+			continue
+		}
+		found++
+		events, err := p.listEvents(ctx, entry.Id, "", timeMin, timeMax)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if event.Status == "cancelled" {
+				continue
+			}
+			for _, person := range secondaryPeople(event) {
+				byPerson[person] = append(byPerson[person], event)
+			}
+		}
+	}
+	if found == 0 {
+		logFor("api").Warn("none of the secondary calendars is in your calendar list", "calendars", strings.Join(p.SecondaryCalendars, ", "))
+	}
+
+	if p.secondary == nil {
+		p.secondary = make(map[string]*secondaryEvents)
+	}
+	p.secondary[key] = &secondaryEvents{fetchedAt: fetchedAt, byPerson: byPerson}
+	return byPerson, nil
+}
+
+// secondaryPeople returns the lowercased emails an event on a secondary
+// calendar is an absence of: its attendees, except rooms and those who
+// declined, and its organizer. Only those that are members of a group are
+// ever asked for.
+func secondaryPeople(event *calendar.Event) []string {
+	var people []string
+	add := func(email string) {
+		email = strings.ToLower(email)
+		if email != "" && !slices.Contains(people, email) {
+			people = append(people, email)
+		}
+	}
+	for _, attendee := range event.Attendees {
+		if !attendee.Resource && attendee.ResponseStatus != "declined" {
+			add(attendee.Email)
+		}
+	}
+	if event.Organizer != nil {
+		add(event.Organizer.Email)
+	}
+	return people
+}
+
+// outOfOfficeEvents lists calendarId's out-of-office events, with the first
+// page in a batch request if BatchClient is set. With etag set, the first
+// page is requested only if it changed, and errNotModified returned
+// otherwise. The ETag returned is that of the response, if it had a single
+// page.
+func (p *GoogleProvider) outOfOfficeEvents(ctx context.Context, calendarId string, timeMin, timeMax time.Time, etag string) ([]*calendar.Event, string, error) {
+	var page *calendar.Events
+	err := errNotBatched
+	if p.BatchClient != nil {
+		p.batcherOnce.Do(func() {
+			p.batcher = &eventBatcher{p: p, client: p.BatchClient}
+		})
+		page, err = p.batcher.list(ctx, calendarId, timeMin, timeMax, etag)
+	}
+	if errors.Is(err, errNotBatched) {
+		page, err = p.firstPage(ctx, calendarId, timeMin, timeMax, etag)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if page.NextPageToken != "" {
+		events, err := p.listEventsFrom(ctx, calendarId, "outOfOffice", timeMin, timeMax, page.Items, page.NextPageToken)
+		return events, "", err
+	}
+	return page.Items, page.Etag, nil
+}
+
+// firstPage requests the first page of calendarId's out-of-office events,
+// or returns errNotModified if etag is set and still matches it.
+func (p *GoogleProvider) firstPage(ctx context.Context, calendarId string, timeMin, timeMax time.Time, etag string) (*calendar.Events, error) {
+	var page *calendar.Events
+	notModified := false
+	err := p.do(ctx, "events.list", calendarId, func(ctx context.Context) error {
+		call := p.eventsList(calendarId, "outOfOffice", timeMin, timeMax).Context(ctx)
+		if etag != "" {
+			call = call.IfNoneMatch(etag)
+		}
+		var err error
+		page, err = call.Do()
+		// Not an error, as far as retries and quota are concerned
+		if googleapi.IsNotModified(err) {
+			notModified = true
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		if fetchErr := classify(err, calendarId, ErrCalendarNotFound); fetchErr != nil {
+			return nil, fetchErr
+		}
+		return nil, fmt.Errorf("unable to retrieve events: %w", err)
+	}
+	if notModified {
+		return nil, errNotModified
+	}
+	return page, nil
+}
+
+// listEvents pages through Events.List until NextPageToken is exhausted,
+// retrying each page independently. eventType restricts the events listed,
+// e.g. to "outOfOffice"; an empty eventType lists all of them.
+func (p *GoogleProvider) listEvents(ctx context.Context, calendarId, eventType string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	return p.listEventsFrom(ctx, calendarId, eventType, timeMin, timeMax, nil, "")
+}
+
+// listEventsFrom continues listEvents from pageToken, appending to items.
+func (p *GoogleProvider) listEventsFrom(ctx context.Context, calendarId, eventType string, timeMin, timeMax time.Time, items []*calendar.Event, pageToken string) ([]*calendar.Event, error) {
+	for {
+		var page *calendar.Events
+		err := p.do(ctx, "events.list", calendarId, func(ctx context.Context) error {
+			call := p.eventsList(calendarId, eventType, timeMin, timeMax).Context(ctx)
+			if pageToken != "" {
+				call = call.PageToken(pageToken)
+			}
+			var err error
+			page, err = call.Do()
+			return err
+		})
+		if err != nil {
+			if fetchErr := classify(err, calendarId, ErrCalendarNotFound); fetchErr != nil {
+				return nil, fetchErr
+			}
+			return nil, fmt.Errorf("unable to retrieve events: %w", err)
+		}
+
+		items = append(items, page.Items...)
+		if page.NextPageToken == "" {
+			return items, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// eventsList returns an Events.List call for the window, restricted to
+// eventType unless it's empty.
+func (p *GoogleProvider) eventsList(calendarId, eventType string, timeMin, timeMax time.Time) *calendar.EventsListCall {
+	call := p.srv.Events.List(calendarId).
+		TimeMin(timeMin.Format(time.RFC3339)).
+		TimeMax(timeMax.Format(time.RFC3339)).
+		SingleEvents(true).
+		OrderBy("startTime").
+		MaxResults(EventsPageSize)
+	if eventType != "" {
+		call = call.EventTypes(eventType)
+	}
+	return call
+}
+
+// Calendars returns the entries of the user's calendar list.
+func (p *GoogleProvider) Calendars(ctx context.Context) ([]*calendar.CalendarListEntry, error) {
+	var items []*calendar.CalendarListEntry
+	pageToken := ""
+	for {
+		var page *calendar.CalendarList
+		err := p.do(ctx, "calendarList.list", "", func(ctx context.Context) error {
+			call := p.srv.CalendarList.List().Context(ctx)
+			if pageToken != "" {
+				call = call.PageToken(pageToken)
+			}
+			var err error
+			page, err = call.Do()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if page.NextPageToken == "" {
+			return items, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// keepBusy remembers the busy blocks within the window returned with the
+// group's members, so that FreebusyOnly fetches, and FreebusyFallback ones,
+// don't have to query them again. Blocks past busyMaxAge are dropped.
+func (p *GoogleProvider) keepBusy(calendars map[string]calendar.FreeBusyCalendar, timeMin, timeMax time.Time) {
+	if !p.FreebusyOnly && !p.FreebusyFallback {
+		return
+	}
+	p.busyMu.Lock()
+	defer p.busyMu.Unlock()
+	if p.busy == nil {
+		p.busy = make(map[string]keptBusy, len(calendars))
+	}
+	now := time.Now()
+	for key, kept := range p.busy {
+		if now.Sub(kept.keptAt) >= busyMaxAge {
+			delete(p.busy, key)
+		}
+	}
+	for id, cal := range calendars {
+		if len(cal.Errors) == 0 {
+			p.busy[busyKey(id, timeMin, timeMax)] = keptBusy{keptAt: now, periods: cal.Busy}
+		}
+	}
+}
+
+// busyEvents turns the calendar's busy blocks within the window into events
+// of type BusyEventType, merging blocks that touch or overlap. The blocks are
+// taken from a recent Members call for the window if it covered the
+// calendar.
+func (p *GoogleProvider) busyEvents(ctx context.Context, calendarId string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	p.busyMu.Lock()
+	kept, ok := p.busy[busyKey(calendarId, timeMin, timeMax)]
+	p.busyMu.Unlock()
+	periods := kept.periods
+	if !ok || time.Since(kept.keptAt) >= busyMaxAge {
+		resp, err := p.queryFreebusy(ctx, []string{calendarId}, timeMin, timeMax, "")
+		if err != nil {
+			if fetchErr := classify(err, calendarId, ErrCalendarNotFound); fetchErr != nil {
+				return nil, fetchErr
+			}
+			return nil, fmt.Errorf("unable to query freebusy: %w", err)
+		}
+		cal := resp.Calendars[calendarId]
+		for _, e := range cal.Errors {
+			if e.Reason == "notFound" {
+				return nil, &FetchError{Kind: ErrCalendarNotFound, Subject: calendarId}
+			}
+			return nil, &FetchError{Kind: ErrNoAccess, Subject: calendarId, Err: fmt.Errorf("%s", e.Reason)}
+		}
+		periods = cal.Busy
+	}
+
+	type block struct{ start, end time.Time }
+	var blocks []block
+	for _, period := range periods {
+		start, err := time.Parse(time.RFC3339, period.Start)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(time.RFC3339, period.End)
+		if err != nil {
+			continue
+		}
+		blocks = append(blocks, block{start, end})
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].start.Before(blocks[j].start) })
+
+	var events []*calendar.Event
+	for i := 0; i < len(blocks); {
+		b := blocks[i]
+		for i++; i < len(blocks) && !blocks[i].start.After(b.end); i++ {
+			if blocks[i].end.After(b.end) {
+				b.end = blocks[i].end
+			}
+		}
+		events = append(events, &calendar.Event{
+			Id:        "busy-" + b.start.UTC().Format("20060102T150405Z"),
+			EventType: BusyEventType,
+			Start:     &calendar.EventDateTime{DateTime: b.start.Format(time.RFC3339)},
+			End:       &calendar.EventDateTime{DateTime: b.end.Format(time.RFC3339)},
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,69 @@
+package providertest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+// UpdateEnv names the environment variable that makes Golden rewrite the
+// golden files instead of comparing, e.g. UPDATE_GOLDEN=1 go test ./...
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// Golden compares got with testdata/<name>.golden in the test's package
+// directory, reporting the first line that differs.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatalf("unable to create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("unable to update %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("%s is missing; run the test with %s=1 to create it", path, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", path, err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+	gotLines := strings.Split(string(got), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < max(len(gotLines), len(wantLines)); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Errorf("output differs from %s at line %d:\n got: %q\nwant: %q\nRun the test with %s=1 to accept the new output.", path, i+1, g, w, UpdateEnv)
+			return
+		}
+	}
+}
+
+// RenderGolden renders SampleDataset with r and compares the output with
+// testdata/<name>.golden.
+func RenderGolden(t testing.TB, r render.Renderer, name string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.Render(&buf, SampleDataset(t)); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	Golden(t, name, buf.Bytes())
+}
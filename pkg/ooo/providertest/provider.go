@@ -0,0 +1,145 @@
+// Package providertest provides a deterministic in-memory ooo.Provider, a
+// fixed sample dataset and golden-file helpers, for testing renderers and
+// analyzers without calling Google.
+package providertest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// Provider is a fake ooo.Provider serving groups and events from memory.
+// It's safe for concurrent use once populated.
+type Provider struct {
+	// Groups maps a group email to its members
+	Groups map[string][]string
+	// Events maps a person to their OOO events
+	Events map[string][]*calendar.Event
+	// Errors maps a person to the error returned for their calendar
+	Errors map[string]error
+	// WorkWeeks maps a person to the days they work, if not Monday to
+	// Friday
+	WorkWeeks map[string]ooo.WorkWeek
+
+	mu    sync.Mutex
+	calls []string
+}
+
+var _ ooo.Provider = (*Provider)(nil)
+
+// NewProvider returns an empty provider.
+func NewProvider() *Provider {
+	return &Provider{
+		Groups:    make(map[string][]string),
+		Events:    make(map[string][]*calendar.Event),
+		Errors:    make(map[string]error),
+		WorkWeeks: make(map[string]ooo.WorkWeek),
+	}
+}
+
+// AddEvent adds an event to person's calendar, making them a member of
+// group.
+func (p *Provider) AddEvent(group, person string, event *calendar.Event) {
+	p.AddMember(group, person)
+	p.Events[person] = append(p.Events[person], event)
+}
+
+// AddMember adds person to group once.
+func (p *Provider) AddMember(group, person string) {
+	for _, member := range p.Groups[group] {
+		if member == person {
+			return
+		}
+	}
+	p.Groups[group] = append(p.Groups[group], person)
+}
+
+// Members returns the group's members, sorted.
+func (p *Provider) Members(ctx context.Context, groupEmail string, timeMin, timeMax time.Time, timezone string) ([]string, error) {
+	p.record("members " + groupEmail)
+	members, ok := p.Groups[groupEmail]
+	if !ok {
+		return nil, &ooo.FetchError{Kind: ooo.ErrGroupNotFound, Subject: groupEmail}
+	}
+	members = append([]string(nil), members...)
+	sort.Strings(members)
+	return members, nil
+}
+
+// OutOfOfficeEvents returns person's events overlapping the window, or the
+// error set in Errors.
+func (p *Provider) OutOfOfficeEvents(ctx context.Context, person string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	p.record("events " + person)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := p.Errors[person]; err != nil {
+		return nil, err
+	}
+	var events []*calendar.Event
+	for _, event := range p.Events[person] {
+		start, end, err := ooo.EventTimes(event, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event %s: %v", event.Id, err)
+		}
+		if start.Before(timeMax) && end.After(timeMin) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// WorkWeek returns the days person works.
+func (p *Provider) WorkWeek(ctx context.Context, person string) (ooo.WorkWeek, error) {
+	p.record("work_week " + person)
+	if week, ok := p.WorkWeeks[person]; ok {
+		return week, nil
+	}
+	return ooo.DefaultWorkWeek, nil
+}
+
+// Calls returns the requests made so far, e.g. "members team@example.com"
+// or "events a@example.com", sorted.
+func (p *Provider) Calls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calls := append([]string(nil), p.calls...)
+	sort.Strings(calls)
+	return calls
+}
+
+func (p *Provider) record(call string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, call)
+}
+
+// AllDay returns an all-day OOO event from start to the exclusive end date,
+// both formatted as 2006-01-02.
+func AllDay(id, summary, start, end string) *calendar.Event {
+	return &calendar.Event{
+		Id:        id,
+		Summary:   summary,
+		EventType: "outOfOffice",
+		Start:     &calendar.EventDateTime{Date: start},
+		End:       &calendar.EventDateTime{Date: end},
+	}
+}
+
+// Timed returns an OOO event between two instants.
+func Timed(id, summary string, start, end time.Time) *calendar.Event {
+	return &calendar.Event{
+		Id:        id,
+		Summary:   summary,
+		EventType: "outOfOffice",
+		Start:     &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+		End:       &calendar.EventDateTime{DateTime: end.Format(time.RFC3339)},
+	}
+}
@@ -0,0 +1,55 @@
+package providertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// SampleGroup is the group served by SampleProvider.
+const SampleGroup = "team@example.com"
+
+// SampleWindow is the three weeks covered by SampleDataset, from Monday
+// March 4 to Sunday March 24 2024.
+var SampleWindow = struct{ Min, Max time.Time }{
+	Min: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+	Max: time.Date(2024, 3, 24, 23, 59, 59, 0, time.UTC),
+}
+
+// SampleFetchedAt is the fetch time recorded in SampleDataset.
+var SampleFetchedAt = time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+
+// SampleProvider returns a provider for SampleGroup with a mix of the cases
+// renderers have to handle: all-day and timed absences, an absence running
+// past the window, overlapping absences, a long address and a member who is
+// never out.
+func SampleProvider() *Provider {
+	p := NewProvider()
+	cet := time.FixedZone("CET", 60*60)
+	p.AddEvent(SampleGroup, "alice@example.com", AllDay("a1", "Vacation", "2024-03-05", "2024-03-09"))
+	p.AddEvent(SampleGroup, "alice@example.com", AllDay("a2", "Conference; Berlin", "2024-03-21", "2024-03-23"))
+	p.AddEvent(SampleGroup, "bob@example.com", Timed("b1", "Dentist", time.Date(2024, 3, 12, 13, 0, 0, 0, cet), time.Date(2024, 3, 12, 17, 0, 0, 0, cet)))
+	p.AddEvent(SampleGroup, "carol@example.com", AllDay("c1", "Parental leave, part 1", "2024-03-18", "2024-04-01"))
+	p.AddEvent(SampleGroup, "erin.with-a-long-address@example.com", AllDay("e1", "", "2024-03-07", "2024-03-08"))
+	p.AddMember(SampleGroup, "dave@example.com")
+	// Outside the window
+	p.AddEvent(SampleGroup, "dave@example.com", AllDay("d1", "Ski trip", "2024-02-19", "2024-02-24"))
+	return p
+}
+
+// SampleDataset fetches SampleGroup from SampleProvider over SampleWindow,
+// with all-day events in UTC. The result is the same on every call.
+func SampleDataset(t testing.TB) *ooo.Dataset {
+	t.Helper()
+	ds, err := ooo.FetchGroup(context.Background(), SampleProvider(), SampleGroup, SampleWindow.Min, SampleWindow.Max, ooo.FetchOptions{
+		Concurrency: 2,
+		TimeZone:    "UTC",
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch sample dataset: %v", err)
+	}
+	ds.FetchedAt = SampleFetchedAt
+	return ds
+}
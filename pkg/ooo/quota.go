@@ -0,0 +1,174 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// perUserQuotaPerMinute is the Calendar API's default per-user quota.
+	perUserQuotaPerMinute = 600
+	// quotaHeadroom is the share of the per-minute quota used before
+	// requests are held back proactively.
+	quotaHeadroom = 0.9
+
+	minThrottleDelay = 100 * time.Millisecond
+	maxThrottleDelay = 10 * time.Second
+)
+
+// quotaTracker counts the API requests of a run and slows callers down when
+// they approach the per-user quota or start seeing quota errors. The delay
+// doubles on every quota error and halves on every success.
+type quotaTracker struct {
+	mu          sync.Mutex
+	requests    int
+	quotaErrors int
+	unchanged   int // conditional requests answered with 304 Not Modified
+	delay       time.Duration
+	recent      []time.Time // request start times within the last minute
+}
+
+func newQuotaTracker() *quotaTracker {
+	return &quotaTracker{}
+}
+
+// Wait blocks until the next request may be sent: first for the current
+// throttle delay, then for as long as the last minute's requests are at the
+// quota headroom.
+func (q *quotaTracker) Wait(ctx context.Context) error {
+	q.mu.Lock()
+	delay := q.delay
+	q.mu.Unlock()
+	if err := sleepCtx(ctx, delay); err != nil {
+		return err
+	}
+
+	limit := int(perUserQuotaPerMinute * quotaHeadroom)
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		for len(q.recent) > 0 && now.Sub(q.recent[0]) >= time.Minute {
+			q.recent = q.recent[1:]
+		}
+		if len(q.recent) < limit {
+			q.recent = append(q.recent, now)
+			q.requests++
+			q.mu.Unlock()
+			return nil
+		}
+		wait := time.Minute - now.Sub(q.recent[0])
+		q.mu.Unlock()
+
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Record adjusts the throttle delay based on the outcome of a request.
+func (q *quotaTracker) Record(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if isQuotaError(err) {
+		q.quotaErrors++
+		q.delay = min(max(q.delay*2, minThrottleDelay), maxThrottleDelay)
+		return
+	}
+	if err == nil {
+		q.delay /= 2
+		if q.delay < minThrottleDelay {
+			q.delay = 0
+		}
+	}
+}
+
+// RecordUnchanged counts a conditional request the API answered with 304
+// Not Modified.
+func (q *quotaTracker) RecordUnchanged() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.unchanged++
+}
+
+// Requests returns the number of API requests sent so far.
+func (q *quotaTracker) Requests() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.requests
+}
+
+// Summary describes the API usage of the run so far.
+func (q *quotaTracker) Summary() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return fmt.Sprintf("API requests: %d, unchanged: %d, quota errors: %d, throttle delay: %v", q.requests, q.unchanged, q.quotaErrors, q.delay)
+}
+
+// isQuotaError reports whether err is a rate limit response from the API.
+func isQuotaError(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == http.StatusTooManyRequests {
+		return true
+	}
+	if apiErr.Code == http.StatusForbidden {
+		for _, item := range apiErr.Errors {
+			if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rateLimiter spaces requests evenly, so that at most perMinute are sent in
+// any minute. A nil rateLimiter doesn't limit.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the caller's turn to send a request.
+func (r *rateLimiter) Wait(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	now := time.Now()
+	at := r.next
+	if at.Before(now) {
+		at = now
+	}
+	r.next = at.Add(r.interval)
+	r.mu.Unlock()
+	return sleepCtx(ctx, at.Sub(now))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
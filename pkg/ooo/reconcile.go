@@ -0,0 +1,119 @@
+package ooo
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReconcilePolicy says what to do with an absence that several sources
+// report with different dates, e.g. a holiday booked in the HR system as
+// Monday to Friday but blocked in the calendar from Tuesday.
+type ReconcilePolicy string
+
+const (
+	// ReconcileFlag keeps the absences of every source, leaving the days
+	// they disagree on to be flagged as discrepancies.
+	ReconcileFlag ReconcilePolicy = "flag"
+	// ReconcileLongest keeps the absences of the source that has the person
+	// out for longest.
+	ReconcileLongest ReconcilePolicy = "longest"
+	// ReconcilePrefer keeps the absences of one source, like the HR system,
+	// when it has any.
+	ReconcilePrefer ReconcilePolicy = "prefer"
+)
+
+// Reconciliation is a ReconcilePolicy with the source ReconcilePrefer
+// prefers.
+type Reconciliation struct {
+	Policy ReconcilePolicy
+	Source string
+}
+
+// ParseReconciliation parses "flag", "longest" or "prefer:SOURCE", e.g.
+// "prefer:plugin:hr".
+func ParseReconciliation(s string) (Reconciliation, error) {
+	s = strings.TrimSpace(s)
+	if source, ok := strings.CutPrefix(s, string(ReconcilePrefer)+":"); ok && source != "" {
+		return Reconciliation{Policy: ReconcilePrefer, Source: source}, nil
+	}
+	switch policy := ReconcilePolicy(strings.ToLower(s)); policy {
+	case "", ReconcileFlag:
+		return Reconciliation{Policy: ReconcileFlag}, nil
+	case ReconcileLongest:
+		return Reconciliation{Policy: ReconcileLongest}, nil
+	}
+	return Reconciliation{}, fmt.Errorf("invalid reconciliation '%s'; use flag, longest or prefer:SOURCE", s)
+}
+
+// Reconcile resolves the absences of a person that overlap but come from
+// different sources according to r, keeping the absences of one source for
+// each run of overlapping ones. Assumed absences are left alone, and with
+// ReconcileFlag, or a preferred source that has no absence in the run,
+// every absence is kept.
+func (ds *Dataset) Reconcile(r Reconciliation) {
+	if r.Policy == "" || r.Policy == ReconcileFlag {
+		return
+	}
+	SortAbsences(ds.Absences)
+	var kept, run []Absence
+	var runEnd time.Time
+	flush := func() {
+		kept = append(kept, r.resolve(run)...)
+		run = nil
+	}
+	for _, a := range ds.Absences {
+		if a.Source == SourceAssumed {
+			kept = append(kept, a)
+			continue
+		}
+		if len(run) > 0 && (a.Person != run[0].Person || !a.Start.Before(runEnd)) {
+			flush()
+		}
+		if len(run) == 0 || a.End.After(runEnd) {
+			runEnd = a.End
+		}
+		run = append(run, a)
+	}
+	flush()
+	SortAbsences(kept)
+	ds.Absences = kept
+}
+
+// resolve returns the absences to keep of a run of overlapping ones.
+func (r Reconciliation) resolve(run []Absence) []Absence {
+	bySource := make(map[string][]Absence)
+	var sources []string
+	for _, a := range run {
+		if !slices.Contains(sources, a.Source) {
+			sources = append(sources, a.Source)
+		}
+		bySource[a.Source] = append(bySource[a.Source], a)
+	}
+	if len(sources) < 2 {
+		return run
+	}
+	switch r.Policy {
+	case ReconcilePrefer:
+		if preferred, ok := bySource[r.Source]; ok {
+			return preferred
+		}
+	case ReconcileLongest:
+		// Ties go to the source first in alphabetical order
+		sort.Strings(sources)
+		best, bestLength := sources[0], time.Duration(-1)
+		for _, source := range sources {
+			var length time.Duration
+			for _, a := range bySource[source] {
+				length += a.Duration()
+			}
+			if length > bestLength {
+				best, bestLength = source, length
+			}
+		}
+		return bySource[best]
+	}
+	return run
+}
@@ -0,0 +1,121 @@
+package ooo_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+func TestReconcile(t *testing.T) {
+	// absence is an all-day absence of person in June 2025, from day first
+	// up to, not including, day next
+	absence := func(person, source string, first, next int) ooo.Absence {
+		return ooo.Absence{
+			Person: person,
+			Start:  time.Date(2025, 6, first, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2025, 6, next, 0, 0, 0, 0, time.UTC),
+			AllDay: true,
+			Kind:   ooo.KindOutOfOffice,
+			Source: source,
+		}
+	}
+	const (
+		google    = ooo.SourceGoogleCalendar
+		hr        = "plugin:hr"
+		pagerduty = "plugin:pagerduty"
+	)
+
+	tests := []struct {
+		name     string
+		policy   string
+		absences []ooo.Absence
+		want     string
+	}{
+		{
+			name:     "flag keeps every source",
+			policy:   "flag",
+			absences: []ooo.Absence{absence("alice", google, 2, 7), absence("alice", hr, 3, 7)},
+			want:     "alice google_calendar 06-02..06-07, alice plugin:hr 06-03..06-07",
+		},
+		{
+			name:     "longest keeps the longer source",
+			policy:   "longest",
+			absences: []ooo.Absence{absence("alice", hr, 3, 7), absence("alice", google, 2, 7)},
+			want:     "alice google_calendar 06-02..06-07",
+		},
+		{
+			name:     "longest sums a source's absences in the run",
+			policy:   "longest",
+			absences: []ooo.Absence{absence("alice", google, 2, 6), absence("alice", hr, 3, 5), absence("alice", hr, 5, 8)},
+			want:     "alice plugin:hr 06-03..06-05, alice plugin:hr 06-05..06-08",
+		},
+		{
+			name:     "longest tie goes to the first source alphabetically",
+			policy:   "longest",
+			absences: []ooo.Absence{absence("alice", hr, 3, 6), absence("alice", google, 2, 5)},
+			want:     "alice google_calendar 06-02..06-05",
+		},
+		{
+			name:     "prefer keeps the preferred source",
+			policy:   "prefer:" + hr,
+			absences: []ooo.Absence{absence("alice", google, 2, 7), absence("alice", hr, 3, 7)},
+			want:     "alice plugin:hr 06-03..06-07",
+		},
+		{
+			name:     "prefer keeps every source when the preferred one is missing",
+			policy:   "prefer:" + hr,
+			absences: []ooo.Absence{absence("alice", google, 2, 7), absence("alice", pagerduty, 3, 4)},
+			want:     "alice google_calendar 06-02..06-07, alice plugin:pagerduty 06-03..06-04",
+		},
+		{
+			name:     "prefer decides each run on its own",
+			policy:   "prefer:" + hr,
+			absences: []ooo.Absence{absence("alice", google, 2, 4), absence("alice", hr, 3, 4), absence("alice", google, 16, 18), absence("alice", pagerduty, 17, 18)},
+			want:     "alice plugin:hr 06-03..06-04, alice google_calendar 06-16..06-18, alice plugin:pagerduty 06-17..06-18",
+		},
+		{
+			name:     "assumed absences pass through",
+			policy:   "longest",
+			absences: []ooo.Absence{absence("alice", google, 2, 7), absence("alice", hr, 3, 7), absence("alice", ooo.SourceAssumed, 3, 4)},
+			want:     "alice google_calendar 06-02..06-07, alice assumed 06-03..06-04",
+		},
+		{
+			name:     "assumed absences don't join a run",
+			policy:   "prefer:" + hr,
+			absences: []ooo.Absence{absence("alice", google, 2, 7), absence("alice", ooo.SourceAssumed, 3, 4)},
+			want:     "alice google_calendar 06-02..06-07, alice assumed 06-03..06-04",
+		},
+		{
+			name:     "absences that don't overlap are kept",
+			policy:   "longest",
+			absences: []ooo.Absence{absence("alice", google, 2, 3), absence("alice", hr, 3, 4)},
+			want:     "alice google_calendar 06-02..06-03, alice plugin:hr 06-03..06-04",
+		},
+		{
+			name:     "other people's absences don't overlap",
+			policy:   "longest",
+			absences: []ooo.Absence{absence("bob", hr, 3, 4), absence("alice", google, 2, 7)},
+			want:     "alice google_calendar 06-02..06-07, bob plugin:hr 06-03..06-04",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ooo.ParseReconciliation(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ds := &ooo.Dataset{Absences: tt.absences}
+			ds.Reconcile(r)
+			var got []string
+			for _, a := range ds.Absences {
+				got = append(got, fmt.Sprintf("%s %s %s..%s", a.Person, a.Source, a.Start.Format("01-02"), a.End.Format("01-02")))
+			}
+			if strings.Join(got, ", ") != tt.want {
+				t.Errorf("Reconcile(%s) = %q, want %q", tt.policy, strings.Join(got, ", "), tt.want)
+			}
+		})
+	}
+}
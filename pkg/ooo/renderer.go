@@ -0,0 +1,63 @@
+package ooo
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Renderer writes a dataset in one output format. The built-in formats are
+// registered by package render, so programs rendering by name import it,
+// if only for its side effects.
+type Renderer interface {
+	Render(w io.Writer, ds *Dataset) error
+}
+
+// RendererFunc adapts a function to the Renderer interface.
+type RendererFunc func(w io.Writer, ds *Dataset) error
+
+// Render calls f(w, ds).
+func (f RendererFunc) Render(w io.Writer, ds *Dataset) error {
+	return f(w, ds)
+}
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{}
+)
+
+// RegisterRenderer makes a renderer available under name, e.g. from an init
+// function of the package adding the format. It panics if name is already
+// taken or r is nil.
+func RegisterRenderer(name string, r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	if r == nil {
+		panic("ooo: RegisterRenderer renderer is nil")
+	}
+	if _, dup := renderers[name]; dup {
+		panic(fmt.Sprintf("ooo: RegisterRenderer called twice for renderer %q", name))
+	}
+	renderers[name] = r
+}
+
+// LookupRenderer returns the renderer registered under name.
+func LookupRenderer(name string) (Renderer, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	r, ok := renderers[name]
+	return r, ok
+}
+
+// RendererNames returns the names of the registered renderers, sorted.
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,95 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	maxRetries     = 5
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+// withRetry calls fn until it succeeds, returns a non-retryable error, or
+// maxRetries is exhausted. Waits grow exponentially with jitter, unless the
+// server asks for a specific delay via Retry-After.
+func withRetry(ctx context.Context, fn func() error) error {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= maxRetries || !isRetryable(err) {
+			return err
+		}
+
+		wait := retryAfter(err)
+		if wait <= 0 {
+			// Full jitter in [backoff/2, backoff)
+			wait = backoff/2 + time.Duration(mrand.Int63n(int64(backoff/2)))
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// isRetryable reports whether err is a transient Calendar API error: rate
+// limiting (403 rateLimitExceeded, 429) or a server-side failure (5xx).
+func isRetryable(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code >= 500 || isQuotaError(err)
+}
+
+// retryAfter returns the delay requested by the server's Retry-After header,
+// or zero if there is none.
+func retryAfter(err error) time.Duration {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Header == nil {
+		return 0
+	}
+
+	value := apiErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// IsUnavailable reports whether err means the network or the Calendar API
+// could not be reached, as opposed to a problem with the request itself.
+func IsUnavailable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrAuthExpired) {
+		return false
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,112 @@
+package ooo
+
+import (
+	"sort"
+	"strings"
+)
+
+// Prefixes of the groups resolved by a Roster instead of the provider:
+// team:NAME is a team's members, and reports:EMAIL everyone reporting to
+// a manager, directly or not.
+const (
+	RosterTeamPrefix    = "team:"
+	RosterReportsPrefix = "reports:"
+)
+
+// RosterEntry describes a person: the team they're on, their role and whom
+// they report to.
+type RosterEntry struct {
+	Name    string `json:"name,omitempty"`
+	Team    string `json:"team,omitempty"`
+	Role    string `json:"role,omitempty"`
+	Manager string `json:"manager,omitempty"`
+	// Weight is how much the person counts toward coverage, e.g. 3 for the
+	// only one in a critical role; 0 counts as 1
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// Roster describes the people of an organization by email, so that teams
+// and reporting lines can decide who is shown together rather than group
+// membership alone.
+type Roster map[string]RosterEntry
+
+// Team returns the people on team, sorted. Team names are matched without
+// regard to case.
+func (r Roster) Team(team string) []string {
+	var people []string
+	for person, entry := range r {
+		if strings.EqualFold(entry.Team, team) {
+			people = append(people, person)
+		}
+	}
+	sort.Strings(people)
+	return people
+}
+
+// Weight returns how much person counts toward coverage: their weight in
+// the roster, or 1.
+func (r Roster) Weight(person string) float64 {
+	if w := r[person].Weight; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// DirectReports returns the people whose manager is manager, sorted.
+func (r Roster) DirectReports(manager string) []string {
+	var people []string
+	for person, entry := range r {
+		if entry.Manager == manager {
+			people = append(people, person)
+		}
+	}
+	sort.Strings(people)
+	return people
+}
+
+// Reports returns the people reporting to manager, directly or through
+// other managers, sorted.
+func (r Roster) Reports(manager string) []string {
+	var people []string
+	seen := map[string]bool{manager: true}
+	queue := []string{manager}
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		for person, entry := range r {
+			if entry.Manager == m && !seen[person] {
+				seen[person] = true
+				people = append(people, person)
+				queue = append(queue, person)
+			}
+		}
+	}
+	sort.Strings(people)
+	return people
+}
+
+// Teams returns the names of the teams in the roster, sorted.
+func (r Roster) Teams() []string {
+	var teams []string
+	seen := make(map[string]bool)
+	for _, entry := range r {
+		if entry.Team != "" && !seen[entry.Team] {
+			seen[entry.Team] = true
+			teams = append(teams, entry.Team)
+		}
+	}
+	sort.Strings(teams)
+	return teams
+}
+
+// Group returns the people of a group named with RosterTeamPrefix or
+// RosterReportsPrefix, and whether group is one.
+func (r Roster) Group(group string) ([]string, bool) {
+	if team, ok := strings.CutPrefix(group, RosterTeamPrefix); ok {
+		return r.Team(team), true
+	}
+	if manager, ok := strings.CutPrefix(group, RosterReportsPrefix); ok {
+		return r.Reports(strings.ToLower(manager)), true
+	}
+	return nil, false
+}
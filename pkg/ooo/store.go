@@ -0,0 +1,402 @@
+package ooo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/api/calendar/v3"
+)
+
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS fetches (
+	person     TEXT NOT NULL,
+	time_min   TEXT NOT NULL,
+	time_max   TEXT NOT NULL,
+	fetched_at TEXT NOT NULL,
+	PRIMARY KEY (person, time_min, time_max)
+);
+CREATE TABLE IF NOT EXISTS events (
+	person     TEXT NOT NULL,
+	event_id   TEXT NOT NULL,
+	start_time TEXT NOT NULL,
+	end_time   TEXT NOT NULL,
+	raw        TEXT NOT NULL,
+	fetched_at TEXT NOT NULL,
+	PRIMARY KEY (person, event_id)
+);
+CREATE INDEX IF NOT EXISTS events_by_range ON events (person, start_time, end_time);
+CREATE TABLE IF NOT EXISTS group_members (
+	group_email TEXT NOT NULL,
+	person      TEXT NOT NULL,
+	PRIMARY KEY (group_email, person)
+);
+CREATE TABLE IF NOT EXISTS etags (
+	person   TEXT NOT NULL,
+	time_min TEXT NOT NULL,
+	time_max TEXT NOT NULL,
+	etag     TEXT NOT NULL,
+	PRIMARY KEY (person, time_min, time_max)
+);
+CREATE TABLE IF NOT EXISTS changes (
+	detected_at  TEXT NOT NULL,
+	person       TEXT NOT NULL,
+	event_id     TEXT NOT NULL,
+	change       TEXT NOT NULL,
+	raw          TEXT NOT NULL,
+	previous_raw TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS changes_by_time ON changes (detected_at);
+CREATE TABLE IF NOT EXISTS group_fetches (
+	group_email TEXT PRIMARY KEY,
+	time_min    TEXT NOT NULL,
+	time_max    TEXT NOT NULL,
+	fetched_at  TEXT NOT NULL
+);
+`
+
+// storeTimeFormat is used for every timestamp column. All values are UTC so
+// that range queries can compare them as strings.
+const storeTimeFormat = time.RFC3339
+
+// Store persists every fetched event in a local SQLite database, keyed
+// by person and date range. It serves as the TTL cache for repeated runs and
+// keeps the last complete dataset of each group for offline use.
+type Store struct {
+	db      *sql.DB
+	path    string
+	ttl     time.Duration
+	refresh bool // skip cache reads but still write fresh results
+}
+
+// StoreStats summarizes the contents of the event store.
+type StoreStats struct {
+	Path        string
+	SizeBytes   int64
+	People      int
+	Events      int
+	Fetches     int
+	Groups      int
+	Changes     int
+	OldestFetch time.Time
+	NewestFetch time.Time
+}
+
+// OpenStore opens the SQLite database at path, creating it and its directory
+// if needed. Cached events are reused for ttl; with refresh they are never
+// read but still written.
+func OpenStore(path string, ttl time.Duration, refresh bool) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create cache directory: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open event store: %v", err)
+	}
+	// SQLite allows a single writer; serialize the fetch workers through one
+	// connection instead of failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to initialize event store: %v", err)
+	}
+	// The database holds other people's events
+	if err := os.Chmod(path, 0600); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to restrict event store permissions: %v", err)
+	}
+
+	return &Store{db: db, path: path, ttl: ttl, refresh: refresh}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the stored events for the person and window if they were
+// fetched for exactly that window within the TTL.
+func (s *Store) Get(person string, timeMin, timeMax time.Time) ([]*calendar.Event, bool) {
+	if s.refresh {
+		return nil, false
+	}
+
+	var fetchedAt string
+	err := s.db.QueryRow(`SELECT fetched_at FROM fetches WHERE person = ? AND time_min = ? AND time_max = ?`,
+		person, formatStoreTime(timeMin), formatStoreTime(timeMax)).Scan(&fetchedAt)
+	if err != nil {
+		return nil, false
+	}
+	t, err := time.Parse(storeTimeFormat, fetchedAt)
+	if err != nil || time.Since(t) > s.ttl {
+		return nil, false
+	}
+
+	events, err := s.events(person, timeMin, timeMax)
+	if err != nil {
+		return nil, false
+	}
+	return events, true
+}
+
+// Revalidate returns the events stored for the person and window, however
+// old, along with the ETag of the response they came from, so they can be
+// confirmed with a conditional request. It returns false if there is no
+// ETag for the window.
+func (s *Store) Revalidate(person string, timeMin, timeMax time.Time) (string, []*calendar.Event, bool) {
+	if s.refresh {
+		return "", nil, false
+	}
+	var etag string
+	err := s.db.QueryRow(`SELECT e.etag FROM etags e JOIN fetches f USING (person, time_min, time_max) WHERE person = ? AND time_min = ? AND time_max = ?`,
+		person, formatStoreTime(timeMin), formatStoreTime(timeMax)).Scan(&etag)
+	if err != nil {
+		return "", nil, false
+	}
+	events, err := s.events(person, timeMin, timeMax)
+	if err != nil {
+		return "", nil, false
+	}
+	return etag, events, true
+}
+
+// Touch marks the person's events in the window as fetched now, after the
+// API confirmed that they haven't changed.
+func (s *Store) Touch(person string, timeMin, timeMax time.Time) error {
+	_, err := s.db.Exec(`UPDATE fetches SET fetched_at = ? WHERE person = ? AND time_min = ? AND time_max = ?`,
+		formatStoreTime(time.Now()), person, formatStoreTime(timeMin), formatStoreTime(timeMax))
+	if err != nil {
+		return fmt.Errorf("unable to record fetch: %v", err)
+	}
+	return nil
+}
+
+// Put replaces the person's stored events within the window with the
+// freshly fetched ones.
+func (s *Store) Put(person string, timeMin, timeMax time.Time, events []*calendar.Event) error {
+	return s.PutTagged(person, timeMin, timeMax, events, "")
+}
+
+// PutTagged is Put for events listed in a response with an ETag, which
+// Revalidate returns for later conditional requests.
+func (s *Store) PutTagged(person string, timeMin, timeMax time.Time, events []*calendar.Event, etag string) error {
+	now := formatStoreTime(time.Now())
+	lo, hi := formatStoreTime(timeMin), formatStoreTime(timeMax)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := detectChanges(tx, person, lo, hi, events, now); err != nil {
+		return fmt.Errorf("unable to record changes: %v", err)
+	}
+	// Events that disappeared from the window have been deleted or moved
+	if _, err := tx.Exec(`DELETE FROM events WHERE person = ? AND start_time < ? AND end_time > ?`, person, hi, lo); err != nil {
+		return fmt.Errorf("unable to update events: %v", err)
+	}
+	for _, event := range events {
+		start, end, err := eventInterval(event)
+		if err != nil {
+			continue
+		}
+		raw, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("unable to marshal event: %v", err)
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO events (person, event_id, start_time, end_time, raw, fetched_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			person, event.Id, formatStoreTime(start), formatStoreTime(end), string(raw), now); err != nil {
+			return fmt.Errorf("unable to store event: %v", err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO fetches (person, time_min, time_max, fetched_at) VALUES (?, ?, ?, ?)`,
+		person, lo, hi, now); err != nil {
+		return fmt.Errorf("unable to record fetch: %v", err)
+	}
+	// Only the ETag of the window fetched last is kept, as the events of
+	// overlapping windows were just replaced
+	if _, err := tx.Exec(`DELETE FROM etags WHERE person = ?`, person); err != nil {
+		return fmt.Errorf("unable to update ETags: %v", err)
+	}
+	if etag != "" {
+		if _, err := tx.Exec(`INSERT INTO etags (person, time_min, time_max, etag) VALUES (?, ?, ?, ?)`, person, lo, hi, etag); err != nil {
+			return fmt.Errorf("unable to store ETag: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PutGroup records the group's current members and the window of its last
+// complete fetch.
+func (s *Store) PutGroup(group string, timeMin, timeMax time.Time, members []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM group_members WHERE group_email = ?`, group); err != nil {
+		return fmt.Errorf("unable to update group members: %v", err)
+	}
+	for _, person := range members {
+		if _, err := tx.Exec(`INSERT INTO group_members (group_email, person) VALUES (?, ?)`, group, person); err != nil {
+			return fmt.Errorf("unable to store group member: %v", err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO group_fetches (group_email, time_min, time_max, fetched_at) VALUES (?, ?, ?, ?)`,
+		group, formatStoreTime(timeMin), formatStoreTime(timeMax), formatStoreTime(time.Now())); err != nil {
+		return fmt.Errorf("unable to record group fetch: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// LastDataset returns the group's last complete dataset regardless of its
+// age, with all-day events placed in loc.
+func (s *Store) LastDataset(group string, loc *time.Location) (*Dataset, error) {
+	var lo, hi, fetchedAt string
+	err := s.db.QueryRow(`SELECT time_min, time_max, fetched_at FROM group_fetches WHERE group_email = ?`, group).Scan(&lo, &hi, &fetchedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no cached data for '%s'. Run ooo-view online at least once first", group)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cached dataset: %v", err)
+	}
+
+	timeMin, _ := time.Parse(storeTimeFormat, lo)
+	timeMax, _ := time.Parse(storeTimeFormat, hi)
+
+	members, err := s.GroupMembers(group)
+	if err != nil {
+		return nil, err
+	}
+	eventsByPerson := make(map[string][]*calendar.Event)
+	for _, person := range members {
+		events, err := s.events(person, timeMin, timeMax)
+		if err != nil {
+			return nil, err
+		}
+		eventsByPerson[person] = events
+	}
+	ds := NewDataset(group, timeMin, timeMax, eventsByPerson, loc)
+	ds.FetchedAt, _ = time.Parse(storeTimeFormat, fetchedAt)
+	ds.Stale = true
+	return ds, nil
+}
+
+// GroupMembers returns the members recorded by the group's last complete
+// fetch.
+func (s *Store) GroupMembers(group string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT person FROM group_members WHERE group_email = ? ORDER BY person`, group)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read group members: %v", err)
+	}
+	defer rows.Close()
+	var members []string
+	for rows.Next() {
+		var person string
+		if err := rows.Scan(&person); err != nil {
+			return nil, fmt.Errorf("unable to read group members: %v", err)
+		}
+		members = append(members, person)
+	}
+	return members, rows.Err()
+}
+
+// Stats reports the size and contents of the store.
+func (s *Store) Stats() (*StoreStats, error) {
+	stats := &StoreStats{Path: s.path}
+	if info, err := os.Stat(s.path); err == nil {
+		stats.SizeBytes = info.Size()
+	}
+
+	counts := []struct {
+		query string
+		dest  *int
+	}{
+		{`SELECT COUNT(DISTINCT person) FROM events`, &stats.People},
+		{`SELECT COUNT(*) FROM events`, &stats.Events},
+		{`SELECT COUNT(*) FROM fetches`, &stats.Fetches},
+		{`SELECT COUNT(*) FROM group_fetches`, &stats.Groups},
+		{`SELECT COUNT(*) FROM changes`, &stats.Changes},
+	}
+	for _, c := range counts {
+		if err := s.db.QueryRow(c.query).Scan(c.dest); err != nil {
+			return nil, fmt.Errorf("unable to read store stats: %v", err)
+		}
+	}
+
+	var oldest, newest sql.NullString
+	if err := s.db.QueryRow(`SELECT MIN(fetched_at), MAX(fetched_at) FROM fetches`).Scan(&oldest, &newest); err != nil {
+		return nil, fmt.Errorf("unable to read store stats: %v", err)
+	}
+	stats.OldestFetch, _ = time.Parse(storeTimeFormat, oldest.String)
+	stats.NewestFetch, _ = time.Parse(storeTimeFormat, newest.String)
+	return stats, nil
+}
+
+// Vacuum rebuilds the database file to reclaim unused space.
+func (s *Store) Vacuum() error {
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("unable to vacuum event store: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) events(person string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	rows, err := s.db.Query(`SELECT raw FROM events WHERE person = ? AND start_time < ? AND end_time > ? ORDER BY start_time`,
+		person, formatStoreTime(timeMax), formatStoreTime(timeMin))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []*calendar.Event
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("unable to read events: %v", err)
+		}
+		var event calendar.Event
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			return nil, fmt.Errorf("unable to parse stored event: %v", err)
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func formatStoreTime(t time.Time) string {
+	return t.UTC().Format(storeTimeFormat)
+}
+
+// eventInterval returns the start and end of an event. All-day events are
+// taken as UTC days, which is precise enough for range lookups.
+func eventInterval(event *calendar.Event) (time.Time, time.Time, error) {
+	parse := func(dt *calendar.EventDateTime) (time.Time, error) {
+		if dt == nil {
+			return time.Time{}, fmt.Errorf("missing event time")
+		}
+		if dt.DateTime != "" {
+			return time.Parse(time.RFC3339, dt.DateTime)
+		}
+		return time.Parse("2006-01-02", dt.Date)
+	}
+
+	start, err := parse(event.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := parse(event.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
+}
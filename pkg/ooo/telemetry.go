@@ -0,0 +1,74 @@
+package ooo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/klaasmeinke/ooo-view"
+
+// tracer and meter delegate to the global OpenTelemetry providers, so they
+// are no-ops unless the program installed an exporter.
+var (
+	tracer = otel.Tracer(instrumentationName)
+	meter  = otel.Meter(instrumentationName)
+)
+
+// logFor returns the default logger tagged with a component.
+func logFor(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+// apiMetrics holds the instruments recorded for every Calendar API attempt.
+type apiMetrics struct {
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newAPIMetrics() *apiMetrics {
+	requests, err := meter.Int64Counter("ooo_view.api.requests",
+		metric.WithDescription("Calendar API requests, including retries"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	duration, err := meter.Float64Histogram("ooo_view.api.duration",
+		metric.WithDescription("Duration of Calendar API requests"),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return &apiMetrics{requests: requests, duration: duration}
+}
+
+// Record adds one API attempt for the operation to the metrics.
+func (m *apiMetrics) Record(ctx context.Context, operation string, d time.Duration, err error) {
+	outcome := "ok"
+	switch {
+	case isQuotaError(err):
+		outcome = "quota_exceeded"
+	case err != nil:
+		outcome = "error"
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("operation", operation),
+		attribute.String("outcome", outcome),
+	)
+	m.requests.Add(ctx, 1, attrs)
+	m.duration.Record(ctx, d.Seconds(), attrs)
+}
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
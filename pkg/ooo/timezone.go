@@ -0,0 +1,65 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// TimeZoneProvider is implemented by providers that know the time zone each
+// person works in.
+type TimeZoneProvider interface {
+	// TimeZone returns the IANA name of person's time zone
+	TimeZone(ctx context.Context, person string) (string, error)
+}
+
+// FetchTimeZones asks p for the time zone of every person, if p supports
+// TimeZoneProvider. People whose time zone can't be fetched are left out and
+// have their days bucketed in the dataset's time zone.
+func FetchTimeZones(ctx context.Context, p Provider, people []string) map[string]*time.Location {
+	if !Supports[TimeZoneProvider](p) {
+		return nil
+	}
+	tp := p.(TimeZoneProvider)
+	zones := make(map[string]*time.Location)
+	for _, person := range people {
+		name, err := tp.TimeZone(ctx, person)
+		if errors.Is(err, ErrUnsupported) {
+			logFor("fetch").Warn("the provider doesn't know time zones", "error", err)
+			break
+		}
+		if err == nil {
+			var loc *time.Location
+			if loc, err = time.LoadLocation(name); err == nil {
+				zones[person] = loc
+				continue
+			}
+		}
+		logFor("fetch").Warn("could not fetch time zone", "person", person, "error", err)
+	}
+	return zones
+}
+
+// TimeZone returns the time zone of person's primary calendar, as set in
+// their Calendar settings.
+func (p *GoogleProvider) TimeZone(ctx context.Context, person string) (string, error) {
+	var cal *calendar.Calendar
+	err := p.do(ctx, "calendars.get", person, func(ctx context.Context) error {
+		var err error
+		cal, err = p.srv.Calendars.Get(person).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		if fetchErr := classify(err, person, ErrCalendarNotFound); fetchErr != nil {
+			return "", fetchErr
+		}
+		return "", fmt.Errorf("unable to get calendar: %w", err)
+	}
+	if cal.TimeZone == "" {
+		return "", fmt.Errorf("no time zone set")
+	}
+	return cal.TimeZone, nil
+}
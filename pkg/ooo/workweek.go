@@ -0,0 +1,82 @@
+package ooo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WorkWeek lists the weekdays a person works.
+type WorkWeek []time.Weekday
+
+// DefaultWorkWeek is assumed for people without a known work week.
+var DefaultWorkWeek = WorkWeek{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// ParseWorkWeek parses day names like "mon" or "Monday".
+func ParseWorkWeek(days []string) (WorkWeek, error) {
+	week := make(WorkWeek, 0, len(days))
+	for _, day := range days {
+		d, ok := parseWeekday(day)
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday '%s'", day)
+		}
+		week = append(week, d)
+	}
+	return week, nil
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 3 {
+		return 0, false
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if strings.HasPrefix(name, s) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Works reports whether day is in the work week.
+func (w WorkWeek) Works(day time.Weekday) bool {
+	for _, d := range w {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkWeekProvider is implemented by providers that know the days each
+// person works.
+type WorkWeekProvider interface {
+	WorkWeek(ctx context.Context, person string) (WorkWeek, error)
+}
+
+// FetchWorkWeeks asks p for the work week of every person, if p supports
+// WorkWeekProvider. People whose work week can't be fetched are left out and
+// get DefaultWorkWeek.
+func FetchWorkWeeks(ctx context.Context, p Provider, people []string) map[string]WorkWeek {
+	if !Supports[WorkWeekProvider](p) {
+		return nil
+	}
+	wp := p.(WorkWeekProvider)
+	weeks := make(map[string]WorkWeek)
+	for _, person := range people {
+		week, err := wp.WorkWeek(ctx, person)
+		if errors.Is(err, ErrUnsupported) {
+			logFor("fetch").Warn("the provider doesn't know work weeks", "error", err)
+			break
+		}
+		if err != nil {
+			logFor("fetch").Warn("could not fetch work week", "person", person, "error", err)
+			continue
+		}
+		weeks[person] = week
+	}
+	return weeks
+}
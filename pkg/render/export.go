@@ -0,0 +1,156 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// Event is one OOO event in the exported formats. Start and End are dates
+// for all-day events and RFC 3339 timestamps otherwise.
+type Event struct {
+	Person  string `json:"person"`
+	Summary string `json:"summary,omitempty"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	AllDay  bool   `json:"all_day"`
+	ID      string `json:"id"`
+	// Kind is "out_of_office", or "busy" for absences inferred from
+	// free/busy information
+	Kind ooo.Kind `json:"kind"`
+	// Source names where the absence came from, e.g. "google_calendar" or
+	// "plugin:hr"
+	Source string `json:"source,omitempty"`
+	// AutoDecline is how the OOO event answers invitations, like
+	// "declineAllConflictingInvitations", and DeclineMessage its reply
+	AutoDecline    string `json:"auto_decline,omitempty"`
+	DeclineMessage string `json:"decline_message,omitempty"`
+}
+
+// Events flattens the dataset, ordered by person and start.
+func Events(ds *ooo.Dataset) []Event {
+	absences := append([]ooo.Absence(nil), ds.Absences...)
+	ooo.SortAbsences(absences)
+	events := make([]Event, 0, len(absences))
+	for _, a := range absences {
+		e := Event{
+			Person:         a.Person,
+			Summary:        a.Summary,
+			Start:          a.Start.Format(time.RFC3339),
+			End:            a.End.Format(time.RFC3339),
+			AllDay:         a.AllDay,
+			ID:             a.Ref,
+			Kind:           a.Kind,
+			Source:         a.Source,
+			AutoDecline:    a.AutoDecline,
+			DeclineMessage: a.DeclineMessage,
+		}
+		if a.AllDay {
+			e.Start, e.End = a.Start.Format("2006-01-02"), a.End.Format("2006-01-02")
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// JSON writes the dataset as an object with the group, window and events,
+// as described by JSONSchema.
+func JSON(w io.Writer, ds *ooo.Dataset) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(struct {
+		SchemaVersion int               `json:"schema_version"`
+		Group         string            `json:"group"`
+		TimeMin       time.Time         `json:"time_min"`
+		TimeMax       time.Time         `json:"time_max"`
+		FetchedAt     time.Time         `json:"fetched_at"`
+		Events        []Event           `json:"events"`
+		Unavailable   []string          `json:"unavailable,omitempty"`
+		Members       []string          `json:"members,omitempty"`
+		NoData        map[string]string `json:"no_data,omitempty"`
+		Milestones    []milestoneJSON   `json:"milestones,omitempty"`
+	}{SchemaVersion, ds.Group, ds.TimeMin, ds.TimeMax, ds.FetchedAt, Events(ds), ds.Unavailable, ds.Members, ds.NoData, milestonesJSON(ds)})
+	if err != nil {
+		return fmt.Errorf("unable to write JSON: %v", err)
+	}
+	return nil
+}
+
+// CSV writes one row per event.
+func CSV(w io.Writer, ds *ooo.Dataset) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"person", "start", "end", "all_day", "summary", "kind", "source"})
+	for _, e := range Events(ds) {
+		cw.Write([]string{e.Person, e.Start, e.End, strconv.FormatBool(e.AllDay), e.Summary, string(e.Kind), e.Source})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("unable to write CSV: %v", err)
+	}
+	return nil
+}
+
+// ICS writes the events as an iCalendar (RFC 5545) feed, one VEVENT per
+// absence with the person in the summary.
+func ICS(w io.Writer, ds *ooo.Dataset) error {
+	var b strings.Builder
+	line := func(format string, a ...any) {
+		fmt.Fprintf(&b, format+"\r\n", a...)
+	}
+
+	stamp := ds.FetchedAt.UTC().Format("20060102T150405Z")
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//ooo-view//EN")
+	line("X-WR-CALNAME:%s", icsEscape("OOO: "+ds.Group))
+	for _, e := range Events(ds) {
+		summary := e.Summary
+		switch {
+		case e.Kind == ooo.KindBusy:
+			summary = "Busy (inferred from free/busy)"
+		case summary == "":
+			summary = "Out of office"
+		}
+		dtStart := "DTSTART;VALUE=DATE:" + strings.ReplaceAll(e.Start, "-", "")
+		dtEnd := "DTEND;VALUE=DATE:" + strings.ReplaceAll(e.End, "-", "")
+		if !e.AllDay {
+			start, err := time.Parse(time.RFC3339, e.Start)
+			if err != nil {
+				continue
+			}
+			end, err := time.Parse(time.RFC3339, e.End)
+			if err != nil {
+				continue
+			}
+			dtStart = "DTSTART:" + start.UTC().Format("20060102T150405Z")
+			dtEnd = "DTEND:" + end.UTC().Format("20060102T150405Z")
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:%s", icsEscape(e.Person+"/"+e.ID+"@ooo-view"))
+		line("DTSTAMP:%s", stamp)
+		line("%s", dtStart)
+		line("%s", dtEnd)
+		line("SUMMARY:%s", icsEscape(e.Person+": "+summary))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("unable to write iCalendar: %v", err)
+	}
+	return nil
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
@@ -0,0 +1,93 @@
+package render
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateStyle is how dates are written in week headers and absence dates.
+type DateStyle string
+
+const (
+	// DateStyleUS writes "Sep 1 - Sep 7".
+	DateStyleUS DateStyle = "us"
+	// DateStyleEU writes "1 Sep - 7 Sep".
+	DateStyleEU DateStyle = "eu"
+	// DateStyleISO writes "2025-09-01 – 2025-09-07".
+	DateStyleISO DateStyle = "iso"
+)
+
+// ParseDateStyle parses us, eu or iso.
+func ParseDateStyle(s string) (DateStyle, error) {
+	switch style := DateStyle(s); style {
+	case DateStyleUS, DateStyleEU, DateStyleISO:
+		return style, nil
+	}
+	return "", fmt.Errorf("invalid date style '%s'; use %s, %s or %s", s, DateStyleUS, DateStyleEU, DateStyleISO)
+}
+
+// Formats are the date and time formats of the text output.
+type Formats struct {
+	Dates DateStyle
+	// Clock12h writes times like 3:04 PM instead of 15:04
+	Clock12h bool
+	// TimeZones appends the time zone's abbreviation to times, like
+	// 15:04 CEST
+	TimeZones bool
+}
+
+// formats are the formats set with SetFormats.
+var formats = Formats{Dates: DateStyleUS}
+
+// SetFormats sets the date and time formats of the text output. It isn't
+// safe to call while rendering.
+func SetFormats(f Formats) {
+	if f.Dates == "" {
+		f.Dates = DateStyleUS
+	}
+	formats = f
+}
+
+// Date writes a date without weekday or year, like "Sep 1", in the date
+// style.
+func Date(t time.Time) string {
+	switch formats.Dates {
+	case DateStyleEU:
+		return t.Format("2 Jan")
+	case DateStyleISO:
+		return t.Format("2006-01-02")
+	}
+	return t.Format("Jan 2")
+}
+
+// WeekRange writes the days from first to last, like "Sep 1 - Sep 7", in
+// the date style.
+func WeekRange(first, last time.Time) string {
+	if formats.Dates == DateStyleISO {
+		return Date(first) + " – " + Date(last)
+	}
+	return Date(first) + " - " + Date(last)
+}
+
+// Clock writes the time of day of t, like "15:04", "3:04 PM" or
+// "15:04 CEST".
+func Clock(t time.Time) string {
+	layout := "15:04"
+	if formats.Clock12h {
+		layout = "3:04 PM"
+	}
+	if formats.TimeZones {
+		layout += " MST"
+	}
+	return t.Format(layout)
+}
+
+// Day writes a date with its weekday, like "Mon Sep 1".
+func Day(t time.Time) string {
+	return t.Format("Mon") + " " + Date(t)
+}
+
+// DateTime writes t with its weekday, like "Mon Sep 1 15:04".
+func DateTime(t time.Time) string {
+	return Day(t) + " " + Clock(t)
+}
@@ -0,0 +1,379 @@
+// Package render writes datasets of OOO events as text, JSON, CSV and
+// iCalendar.
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// Grid writes a week-by-week table with a row for each person who is out
+// that week. For people with a known work week, the days they don't work are
+// shaded with "-" rather than flagged.
+func Grid(w io.Writer, ds *ooo.Dataset) {
+	GridHighlighted(w, ds, nil)
+}
+
+// CellChange is a day of a person that changed since an earlier fetch.
+type CellChange struct {
+	// Added is set if the person is now out that day; otherwise Was is the
+	// kind of the absence they no longer have
+	Added bool
+	Was   ooo.Kind
+}
+
+// Changes holds the changed days of a grid, by date as 2006-01-02 and
+// person.
+type Changes map[string]map[string]CellChange
+
+// ANSI escape sequences of the highlighted cells of GridHighlighted.
+const (
+	ansiAdded   = "\033[1;32m"
+	ansiRemoved = "\033[9;31m"
+	ansiReset   = "\033[0m"
+)
+
+// GridHighlighted writes a Grid with the days in changes highlighted with
+// ANSI colors: days people are newly out on in green, and those they're no
+// longer out on struck through in red with the marker they had.
+func GridHighlighted(w io.Writer, ds *ooo.Dataset, changes Changes) {
+	loc := ds.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	eventsByDate := gridDays(ds, loc) // date -> person -> kind
+	// The markers shown, for the legend
+	used := make(map[string]bool)
+
+	startDate, lastDay := weeks(ds, loc)
+	disputed := disputedDays(ds, loc)
+	bridges := ooo.BridgeDays(ds, loc)
+
+	// Print calendar by weeks
+	currentDate := startDate
+	for currentDate.Format("2006-01-02") <= lastDay {
+		// Print week header
+		weekEnd := currentDate.AddDate(0, 0, 6)
+		fmt.Fprintln(w)
+		label := WeekRange(currentDate, weekEnd)
+		// Labels too wide for the name column go on a line of their own
+		if utf8.RuneCountInString(label) > 20 {
+			fmt.Fprintln(w, label)
+			label = ""
+		}
+		fmt.Fprintf(w, "%-20s | Mon | Tue | Wed | Thu | Fri | Sat | Sun |\n", label)
+		fmt.Fprintln(w, "----------------------------------------------------------------")
+
+		// Get people with OOO events this week, or whose cancelled ones are
+		// highlighted
+		peopleThisWeek := make(map[string]bool)
+		for i := 0; i < 7; i++ {
+			dateKey := currentDate.AddDate(0, 0, i).Format("2006-01-02")
+			for person := range eventsByDate[dateKey] {
+				peopleThisWeek[person] = true
+			}
+			for person := range changes[dateKey] {
+				peopleThisWeek[person] = true
+			}
+		}
+
+		// Sort people alphabetically
+		people := make([]string, 0, len(peopleThisWeek))
+		for person := range peopleThisWeek {
+			people = append(people, person)
+		}
+		sort.Strings(people)
+
+		// Print each person's row or "No OOO Events" if empty
+		if len(people) == 0 && len(ds.Unavailable) == 0 && len(ds.NoData) == 0 {
+			fmt.Fprintln(w, "No OOO Events")
+		} else {
+			for _, person := range people {
+				fmt.Fprintf(w, "%-20s |", displayName(person))
+				for i := 0; i < 7; i++ {
+					dateKey := currentDate.AddDate(0, 0, i).Format("2006-01-02")
+					// Days only some sources have them out on are flagged
+					flag := " "
+					if _, ok := disputed[dateKey][person]; ok && eventsByDate[dateKey][person] != "" {
+						flag = sourceMarker
+						used[sourceMarker] = true
+					}
+					var cell string
+					kind := eventsByDate[dateKey][person]
+					switch {
+					case kind != "":
+						cell = " " + kindMarker(kind) + flag
+						used[kindMarker(kind)] = true
+					case offDay(ds, person, currentDate.AddDate(0, 0, i)):
+						cell = "  -  "
+						used["-"] = true
+					default:
+						cell = "     "
+					}
+					if change, ok := changes[dateKey][person]; ok {
+						switch {
+						case change.Added && kind != "":
+							cell = ansiAdded + cell + ansiReset
+						case !change.Added && kind == "":
+							cell = " " + ansiRemoved + kindMarker(change.Was) + ansiReset + " "
+						}
+					}
+					fmt.Fprint(w, cell+"|")
+				}
+				fmt.Fprintln(w)
+			}
+			for _, person := range ds.Unavailable {
+				fmt.Fprintf(w, "%-20s |", displayName(person))
+				fmt.Fprint(w, strings.Repeat(" n/a |", 7))
+				fmt.Fprintln(w)
+			}
+			for _, person := range ds.NoDataMembers() {
+				fmt.Fprintf(w, "%-20s |", displayName(person))
+				fmt.Fprint(w, strings.Repeat(" ??? |", 7))
+				fmt.Fprintln(w)
+			}
+		}
+		fmt.Fprintln(w, "----------------------------------------------------------------")
+		holidayLines(w, ds, bridges, currentDate, currentDate.AddDate(0, 0, 7))
+		milestoneLines(w, ds, loc, currentDate, currentDate.AddDate(0, 0, 7))
+
+		// Move to next week
+		currentDate = currentDate.AddDate(0, 0, 7)
+	}
+
+	sparkline(w, ds, startDate, lastDay)
+	used["n/a"] = len(ds.Unavailable) > 0
+	used["???"] = len(ds.NoData) > 0
+	for _, marker := range legend {
+		if used[marker.marker] {
+			fmt.Fprintf(w, "%3s: %s\n", marker.marker, marker.meaning)
+		}
+	}
+	for _, person := range ds.NoDataMembers() {
+		fmt.Fprintf(w, "     %s: %s\n", person, ds.NoData[person])
+	}
+	if used[sourceMarker] {
+		sourceFootnotes(w, ds, disputed, startDate, lastDay)
+	}
+	fmt.Fprintln(w)
+}
+
+// gridDays returns the kind of absence shown for each person on each day
+// they work, by date as 2006-01-02 and person.
+func gridDays(ds *ooo.Dataset, loc *time.Location) map[string]map[string]ooo.Kind {
+	days := make(map[string]map[string]ooo.Kind)
+	// Add each absence to every day it spans
+	for _, a := range ds.Absences {
+		for _, d := range a.Days(ds.DayLocation(a.Person, loc)) {
+			if offDay(ds, a.Person, d) {
+				continue
+			}
+			dateKey := d.Format("2006-01-02")
+			if days[dateKey] == nil {
+				days[dateKey] = make(map[string]ooo.Kind)
+			}
+			kind := a.Kind
+			if a.Source == ooo.SourceAssumed {
+				kind = kindAssumed
+			}
+			// An OOO event wins over a busy block or an assumed absence on
+			// the same day
+			if days[dateKey][a.Person] != ooo.KindOutOfOffice {
+				days[dateKey][a.Person] = kind
+			}
+		}
+	}
+	return days
+}
+
+// Diff returns the days of the grid of after that changed since before:
+// those someone is now out on, and those they no longer are.
+func Diff(before, after *ooo.Dataset) Changes {
+	loc := after.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	was, now := gridDays(before, loc), gridDays(after, loc)
+	changes := make(Changes)
+	add := func(date, person string, change CellChange) {
+		if changes[date] == nil {
+			changes[date] = make(map[string]CellChange)
+		}
+		changes[date][person] = change
+	}
+	for date, people := range now {
+		for person := range people {
+			if was[date][person] == "" {
+				add(date, person, CellChange{Added: true})
+			}
+		}
+	}
+	for date, people := range was {
+		for person, kind := range people {
+			if now[date][person] == "" {
+				add(date, person, CellChange{Was: kind})
+			}
+		}
+	}
+	return changes
+}
+
+// kindAssumed marks the days of absences added with --assume in the grid.
+const kindAssumed ooo.Kind = "assumed"
+
+// kindMarker returns the marker of the grid for a day of kind.
+func kindMarker(kind ooo.Kind) string {
+	switch kind {
+	case ooo.KindBusy:
+		return "BSY"
+	case kindAssumed:
+		return "ooo"
+	default:
+		return "OOO"
+	}
+}
+
+// legend explains the markers of the grid, in the order they're listed.
+var legend = []struct{ marker, meaning string }{
+	{"OOO", "out of office"},
+	{"ooo", "assumed out with --assume, not on the calendar"},
+	{"BSY", "busy for long enough to count as away, inferred from free/busy"},
+	{"-", "a day they don't work"},
+	{"n/a", "data unavailable, the calendar wasn't fetched before the deadline"},
+	{"???", "no data, the calendar can't be read:"},
+}
+
+// sparkBlocks are the levels of the sparkline, from nobody in to everyone.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// weeks returns the Monday the grid starts on and the last day it covers.
+// The weeks follow the window's dates, whatever the display timezone.
+func weeks(ds *ooo.Dataset, loc *time.Location) (time.Time, string) {
+	start := time.Date(ds.TimeMin.Year(), ds.TimeMin.Month(), ds.TimeMin.Day(), 0, 0, 0, 0, loc)
+	for start.Weekday() != time.Monday {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start, ds.TimeMax.Format("2006-01-02")
+}
+
+// headcountDay is how many members are in on a working day, and their
+// coverage: the share of the working members in, by their weight in the
+// roster.
+type headcountDay struct {
+	date             time.Time
+	present, working int
+	coverage         float64
+}
+
+// headcount counts the members in on each day from start to lastDay that
+// someone works.
+func headcount(ds *ooo.Dataset, start time.Time, lastDay string) []headcountDay {
+	loc := start.Location()
+	daysOut := ooo.AbsentDays(ds, loc)
+
+	var days []headcountDay
+	for d := start; d.Format("2006-01-02") <= lastDay; d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		working, present := 0, 0
+		var weightWorking, weightPresent float64
+		for _, member := range ds.WorkingMembers(d) {
+			working++
+			weightWorking += ds.Roster.Weight(member)
+			if !daysOut[member][key] {
+				present++
+				weightPresent += ds.Roster.Weight(member)
+			}
+		}
+		if working > 0 {
+			days = append(days, headcountDay{d, present, working, weightPresent / weightWorking})
+		}
+	}
+	return days
+}
+
+// fewest returns the day of days with the fewest members in, the first if
+// there's a tie.
+func fewest(days []headcountDay) headcountDay {
+	least := days[0]
+	for _, d := range days {
+		if d.present < least.present {
+			least = d
+		}
+	}
+	return least
+}
+
+// lowestCoverage returns the day of days with the lowest coverage, the
+// first if there's a tie.
+func lowestCoverage(days []headcountDay) headcountDay {
+	least := days[0]
+	for _, d := range days {
+		if d.coverage < least.coverage {
+			least = d
+		}
+	}
+	return least
+}
+
+// sparkline writes a line with a block for each working day from start to
+// lastDay, as high as the number of members in that day, and the day with
+// the fewest if anyone is out. Days nobody works are left out and weeks are separated by a
+// space.
+func sparkline(w io.Writer, ds *ooo.Dataset, start time.Time, lastDay string) {
+	if len(ds.Members) == 0 {
+		return
+	}
+	days := headcount(ds, start, lastDay)
+	if len(days) == 0 {
+		return
+	}
+	most := 0
+	for _, d := range days {
+		most = max(most, d.working)
+	}
+
+	var line strings.Builder
+	for i, d := range days {
+		if i > 0 && d.date.Weekday() <= days[i-1].date.Weekday() {
+			line.WriteRune(' ')
+		}
+		level := (d.present*(len(sparkBlocks)-1) + most/2) / most
+		line.WriteRune(sparkBlocks[level])
+	}
+	fmt.Fprintf(w, "%-20s   %s\n", "In office", line.String())
+	if least := fewest(days); least.present < least.working {
+		fmt.Fprintf(w, "%-20s   fewest %d of %d on %s\n", "", least.present, least.working, least.date.Format("Mon Jan 2"))
+	}
+}
+
+// displayName truncates person to fit the first column.
+func displayName(person string) string {
+	if len(person) > 20 {
+		return person[:17] + "..."
+	}
+	return person
+}
+
+// offDay reports whether person has a known work week without day.
+func offDay(ds *ooo.Dataset, person string, day time.Time) bool {
+	week, ok := ds.WorkWeeks[person]
+	return ok && !week.Works(day.Weekday())
+}
+
+// StaleBanner notes when the dataset came from the store rather than a fresh
+// fetch, or when the fetch was cancelled before it completed.
+func StaleBanner(w io.Writer, ds *ooo.Dataset) {
+	if ds.Stale {
+		fmt.Fprintf(w, "\nOFFLINE: showing cached data from %s\n", DateTime(ds.FetchedAt.Local()))
+	}
+	if ds.Partial {
+		fmt.Fprintf(w, "\nPARTIAL (cancelled): only %d calendars were fetched before the interruption\n", len(ds.Members))
+	}
+}
@@ -0,0 +1,71 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Handover is a meeting for someone going on leave to hand their work over
+// to the colleague covering for them.
+type Handover struct {
+	// From is who goes on leave and To who covers, by email, with their
+	// names for the summary
+	From, To         string
+	FromName, ToName string
+	// Start and End are the meeting's
+	Start, End time.Time
+	// LeaveFirst and LeaveLast are the first and last day of the leave
+	LeaveFirst, LeaveLast time.Time
+	// Reason is why the handover was planned, like a coverage gap
+	Reason string
+}
+
+// Summary returns the invite's title, like "Handover: Alice → Bob before
+// Alice's leave".
+func (h Handover) Summary() string {
+	return fmt.Sprintf("Handover: %s → %s before %s's leave", h.FromName, h.ToName, h.FromName)
+}
+
+// HandoverInvite writes h as an iCalendar (RFC 5545) invite, organized by
+// the person going on leave and sent to both. Its UID stays the same for
+// the same leave, so an updated invite replaces the earlier one.
+func HandoverInvite(w io.Writer, h Handover) error {
+	var b strings.Builder
+	line := func(format string, a ...any) {
+		fmt.Fprintf(&b, format+"\r\n", a...)
+	}
+
+	description := fmt.Sprintf("%s is out %s.", h.FromName, WeekRange(h.LeaveFirst, h.LeaveLast))
+	if h.Reason != "" {
+		description += "\n" + h.Reason
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//ooo-view//EN")
+	line("METHOD:REQUEST")
+	line("BEGIN:VEVENT")
+	line("UID:%s", icsEscape("handover/"+h.From+"/"+h.LeaveFirst.Format("2006-01-02")+"@ooo-view"))
+	line("DTSTAMP:%s", time.Now().UTC().Format("20060102T150405Z"))
+	line("DTSTART:%s", h.Start.UTC().Format("20060102T150405Z"))
+	line("DTEND:%s", h.End.UTC().Format("20060102T150405Z"))
+	line("SUMMARY:%s", icsEscape(h.Summary()))
+	line("DESCRIPTION:%s", icsEscape(description))
+	line("ORGANIZER;CN=%s:mailto:%s", icsParam(h.FromName), h.From)
+	line("ATTENDEE;CN=%s;ROLE=REQ-PARTICIPANT;PARTSTAT=ACCEPTED:mailto:%s", icsParam(h.FromName), h.From)
+	line("ATTENDEE;CN=%s;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:%s", icsParam(h.ToName), h.To)
+	line("STATUS:CONFIRMED")
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("unable to write iCalendar: %v", err)
+	}
+	return nil
+}
+
+// icsParam quotes a parameter value, which can't hold double quotes.
+func icsParam(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
@@ -0,0 +1,34 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// holidayLines writes a line for each holiday and bridge day from start to
+// end, excluded, with how many are out on the bridge days.
+func holidayLines(w io.Writer, ds *ooo.Dataset, bridges []ooo.BridgeDay, start, end time.Time) {
+	for _, h := range ds.Holidays {
+		if !h.Date.Before(start) && h.Date.Before(end) {
+			fmt.Fprintf(w, "%-20s   %s %s\n", "Holiday", h.Date.Format("Mon Jan 2"), h.Name)
+		}
+	}
+	for _, b := range bridges {
+		if !b.Date.Before(start) && b.Date.Before(end) {
+			fmt.Fprintf(w, "%-20s   %s\n", "Bridge day", BridgeSummary(b))
+		}
+	}
+}
+
+// BridgeSummary describes a bridge day, e.g. "Fri May 30, after Ascension
+// Day: 4 of 9 out".
+func BridgeSummary(b ooo.BridgeDay) string {
+	relation := "after"
+	if b.Holiday.Date.After(b.Date) {
+		relation = "before"
+	}
+	return fmt.Sprintf("%s, %s %s: %d of %d out", b.Date.Format("Mon Jan 2"), relation, b.Holiday.Name, len(b.Out), b.Working)
+}
@@ -0,0 +1,126 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// JSONProvider is an ooo.Provider serving the events of a JSON export, so a
+// dataset fetched elsewhere can be filtered and rendered again without
+// calling Google, e.g. in the browser by the js/wasm build.
+type JSONProvider struct {
+	Group     string
+	TimeMin   time.Time
+	TimeMax   time.Time
+	FetchedAt time.Time
+	// Unavailable lists the members the export has no data for
+	Unavailable []string
+	// NoData holds the members whose calendars couldn't be read, with the
+	// reason
+	NoData map[string]string
+
+	members []string
+	events  map[string][]*calendar.Event
+}
+
+var _ ooo.Provider = (*JSONProvider)(nil)
+
+// ParseJSON reads a JSON export, as written by JSON, after validating it
+// against JSONSchema.
+func ParseJSON(data []byte) (*JSONProvider, error) {
+	if err := ValidateJSON(data); err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Group       string            `json:"group"`
+		TimeMin     time.Time         `json:"time_min"`
+		TimeMax     time.Time         `json:"time_max"`
+		FetchedAt   time.Time         `json:"fetched_at"`
+		Events      []Event           `json:"events"`
+		Unavailable []string          `json:"unavailable"`
+		Members     []string          `json:"members"`
+		NoData      map[string]string `json:"no_data"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse JSON export: %v", err)
+	}
+
+	p := &JSONProvider{
+		Group:       doc.Group,
+		TimeMin:     doc.TimeMin,
+		TimeMax:     doc.TimeMax,
+		FetchedAt:   doc.FetchedAt,
+		Unavailable: doc.Unavailable,
+		NoData:      doc.NoData,
+		members:     doc.Members,
+		events:      make(map[string][]*calendar.Event),
+	}
+	for _, e := range doc.Events {
+		event := &calendar.Event{
+			Id:        e.ID,
+			Summary:   e.Summary,
+			EventType: "outOfOffice",
+			Start:     &calendar.EventDateTime{DateTime: e.Start},
+			End:       &calendar.EventDateTime{DateTime: e.End},
+		}
+		if e.Kind == ooo.KindBusy {
+			event.EventType = ooo.BusyEventType
+		}
+		if e.Source != "" {
+			event.ExtendedProperties = &calendar.EventExtendedProperties{Private: map[string]string{ooo.SourceProperty: e.Source}}
+		}
+		if e.AutoDecline != "" || e.DeclineMessage != "" {
+			event.OutOfOfficeProperties = &calendar.EventOutOfOfficeProperties{AutoDeclineMode: e.AutoDecline, DeclineMessage: e.DeclineMessage}
+		}
+		if e.AllDay {
+			event.Start = &calendar.EventDateTime{Date: e.Start}
+			event.End = &calendar.EventDateTime{Date: e.End}
+		}
+		p.events[e.Person] = append(p.events[e.Person], event)
+	}
+	return p, nil
+}
+
+// Source is empty: the absences keep the sources recorded in the export.
+func (p *JSONProvider) Source() string {
+	return ""
+}
+
+// Members returns the members listed in the export, or for older exports
+// the people with at least one event in it, sorted.
+func (p *JSONProvider) Members(ctx context.Context, groupEmail string, timeMin, timeMax time.Time, timezone string) ([]string, error) {
+	if groupEmail != p.Group {
+		return nil, &ooo.FetchError{Kind: ooo.ErrGroupNotFound, Subject: groupEmail}
+	}
+	if p.members != nil {
+		return append([]string(nil), p.members...), nil
+	}
+	members := make([]string, 0, len(p.events))
+	for person := range p.events {
+		members = append(members, person)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// OutOfOfficeEvents returns person's events overlapping the window.
+func (p *JSONProvider) OutOfOfficeEvents(ctx context.Context, person string, timeMin, timeMax time.Time) ([]*calendar.Event, error) {
+	var events []*calendar.Event
+	for _, event := range p.events[person] {
+		start, end, err := ooo.EventTimes(event, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event %s: %v", event.Id, err)
+		}
+		if start.Before(timeMax) && end.After(timeMin) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
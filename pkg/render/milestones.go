@@ -0,0 +1,92 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// milestoneRole is how the key people of a milestone without a role are
+// called.
+const milestoneRole = "key people"
+
+// milestoneLines writes a line for each milestone with days from start to
+// end, excluded, saying how many of its key people are out on its worst day.
+func milestoneLines(w io.Writer, ds *ooo.Dataset, loc *time.Location, start, end time.Time) {
+	for _, m := range ds.Milestones {
+		var days []ooo.MilestoneDay
+		for _, d := range m.Days(ds, loc) {
+			if !d.Date.Before(start) && d.Date.Before(end) {
+				days = append(days, d)
+			}
+		}
+		if len(days) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%-20s   %s\n", "Milestone", MilestoneSummary(m, days))
+	}
+}
+
+// MilestoneSummary describes the key people out on the worst of days of m,
+// e.g. "Code freeze Fri Sep 12: 3 of 5 reviewers out (alice@example.com,
+// ...)". days must not be empty.
+func MilestoneSummary(m ooo.Milestone, days []ooo.MilestoneDay) string {
+	role := m.Role
+	if role == "" {
+		role = milestoneRole
+	}
+	first, last := days[0].Date, days[len(days)-1].Date
+	dates := first.Format("Mon Jan 2")
+	if !last.Equal(first) {
+		dates += " – " + last.Format("Mon Jan 2")
+	}
+	worst := ooo.WorstDay(days)
+	if len(worst.Out) == 0 {
+		return fmt.Sprintf("%s %s: all %d %s in", m.Name, dates, worst.Key, role)
+	}
+	summary := fmt.Sprintf("%s %s: %d of %d %s out", m.Name, dates, len(worst.Out), worst.Key, role)
+	if len(days) > 1 {
+		summary += " on " + worst.Date.Format("Mon Jan 2")
+	}
+	return summary + " (" + strings.Join(worst.Out, ", ") + ")"
+}
+
+// milestoneJSON is a milestone in the JSON export, with its key people out
+// by date.
+type milestoneJSON struct {
+	Name   string              `json:"name"`
+	First  string              `json:"first"`
+	Last   string              `json:"last"`
+	Role   string              `json:"role,omitempty"`
+	People []string            `json:"people,omitempty"`
+	Out    map[string][]string `json:"out"`
+}
+
+// milestonesJSON returns the milestones of ds for the JSON export.
+func milestonesJSON(ds *ooo.Dataset) []milestoneJSON {
+	loc := ds.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	var milestones []milestoneJSON
+	for _, m := range ds.Milestones {
+		mj := milestoneJSON{
+			Name:   m.Name,
+			First:  m.First.Format("2006-01-02"),
+			Last:   m.Last.Format("2006-01-02"),
+			Role:   m.Role,
+			People: m.People,
+			Out:    make(map[string][]string),
+		}
+		for _, d := range m.Days(ds, loc) {
+			if len(d.Out) > 0 {
+				mj.Out[d.Date.Format("2006-01-02")] = d.Out
+			}
+		}
+		milestones = append(milestones, mj)
+	}
+	return milestones
+}
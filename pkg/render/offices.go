@@ -0,0 +1,84 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// unknownOffice heads the members whose office isn't known.
+const unknownOffice = "Unknown office"
+
+// GridByOffice writes a Grid for the members of each office in
+// ds.Offices, under a header per office, followed by the coverage of each
+// region: the day with the lowest share of its members in, by their weight
+// in the roster.
+func GridByOffice(w io.Writer, ds *ooo.Dataset) {
+	loc := ds.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	byOffice := make(map[string][]string)
+	byRegion := make(map[string][]string)
+	for _, person := range append(append([]string(nil), ds.Members...), ds.WithoutData()...) {
+		office, ok := ds.Offices[person]
+		if !ok {
+			office = ooo.Office{Name: unknownOffice, Region: unknownOffice}
+		}
+		byOffice[office.Name] = append(byOffice[office.Name], person)
+		byRegion[office.Region] = append(byRegion[office.Region], person)
+	}
+
+	for _, name := range sortedSections(byOffice, unknownOffice) {
+		people := byOffice[name]
+		header := name
+		if region := ds.Offices[people[0]].Region; region != "" && region != name {
+			header += " (" + region + ")"
+		}
+		fmt.Fprintf(w, "\n== %s: %d %s ==\n", header, len(people), plural(len(people), "member", "members"))
+		Grid(w, ds.Subset(people))
+	}
+
+	start, lastDay := weeks(ds, loc)
+	fmt.Fprintln(w, "Coverage by region:")
+	for _, region := range sortedSections(byRegion, unknownOffice) {
+		days := headcount(ds.Subset(byRegion[region]), start, lastDay)
+		if len(days) == 0 {
+			continue
+		}
+		least := lowestCoverage(days)
+		if least.present == least.working {
+			fmt.Fprintf(w, "  %-20s everyone in\n", region)
+			continue
+		}
+		fmt.Fprintf(w, "  %-20s lowest %.0f%%, %d of %d in on %s\n", region, least.coverage*100, least.present, least.working, least.date.Format("Mon Jan 2"))
+	}
+	fmt.Fprintln(w)
+}
+
+// sortedSections returns the names of the offices, regions or teams in
+// order, with the unknown one last.
+func sortedSections(groups map[string][]string, unknown string) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != unknown {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := groups[unknown]; ok {
+		names = append(names, unknown)
+	}
+	return names
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,34 @@
+package render_test
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo/providertest"
+	"github.com/klaasmeinke/ooo-view/pkg/render"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata instead of comparing with them")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *update {
+		os.Setenv(providertest.UpdateEnv, "1")
+	}
+	os.Exit(m.Run())
+}
+
+// TestRenderGolden renders the sample dataset in every built-in format and
+// compares the output with testdata/sample.<format>.golden.
+func TestRenderGolden(t *testing.T) {
+	for _, name := range []string{"grid", "json", "csv", "ics"} {
+		t.Run(name, func(t *testing.T) {
+			r, ok := render.Lookup(name)
+			if !ok {
+				t.Fatalf("renderer %s isn't registered", name)
+			}
+			providertest.RenderGolden(t, r, "sample."+name)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package render
+
+import (
+	"io"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// Renderer writes a dataset in one output format.
+type Renderer = ooo.Renderer
+
+// RendererFunc adapts a function to the Renderer interface.
+type RendererFunc = ooo.RendererFunc
+
+func init() {
+	Register("grid", RendererFunc(func(w io.Writer, ds *ooo.Dataset) error {
+		Grid(w, ds)
+		return nil
+	}))
+	Register("json", RendererFunc(JSON))
+	Register("csv", RendererFunc(CSV))
+	Register("ics", RendererFunc(ICS))
+}
+
+// Register makes a renderer available under name, e.g. from an init
+// function of the package adding the format. It panics if name is already
+// taken or r is nil.
+func Register(name string, r Renderer) {
+	ooo.RegisterRenderer(name, r)
+}
+
+// Lookup returns the renderer registered under name.
+func Lookup(name string) (Renderer, bool) {
+	return ooo.LookupRenderer(name)
+}
+
+// Names returns the names of the registered renderers, sorted.
+func Names() []string {
+	return ooo.RendererNames()
+}
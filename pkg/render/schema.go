@@ -0,0 +1,185 @@
+package render
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON export format, written as its
+// schema_version field. The schema allows no unknown fields, so adding one
+// takes a new version.
+const SchemaVersion = 2
+
+// schemas holds schema/export-v<N>.json for every version up to
+// SchemaVersion, so that older exports still validate.
+//
+//go:embed schema/export-v*.json
+var schemas embed.FS
+
+// JSONSchema returns the JSON Schema (draft 2020-12) of the JSON format.
+func JSONSchema() []byte {
+	schema, _ := JSONSchemaVersion(SchemaVersion)
+	return schema
+}
+
+// JSONSchemaVersion returns the JSON Schema of version of the JSON format,
+// and whether there is one.
+func JSONSchemaVersion(version int) ([]byte, bool) {
+	schema, err := schemas.ReadFile(fmt.Sprintf("schema/export-v%d.json", version))
+	return schema, err == nil
+}
+
+// SchemaError lists every way a document violates the schema.
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return "document doesn't match the schema:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// ValidateJSON checks a JSON export against the embedded schema of its
+// schema_version, or of SchemaVersion if it has none. It returns a
+// *SchemaError listing the violations, or an error if data isn't JSON.
+func ValidateJSON(data []byte) error {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	version := DocumentVersion(data)
+	if version == 0 {
+		version = SchemaVersion
+	}
+	raw, ok := JSONSchemaVersion(version)
+	if !ok {
+		return &SchemaError{Problems: []string{fmt.Sprintf("$.schema_version: unknown version %d, expected 1 to %d", version, SchemaVersion)}}
+	}
+	var schema any
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return fmt.Errorf("invalid embedded schema: %v", err)
+	}
+	var problems []string
+	validate(schema.(map[string]any), doc, "$", &problems)
+	if len(problems) > 0 {
+		return &SchemaError{Problems: problems}
+	}
+	return nil
+}
+
+// DocumentVersion returns the schema_version of a JSON export, or 0 if it
+// has none.
+func DocumentVersion(data []byte) int {
+	var doc struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	json.Unmarshal(data, &doc)
+	return doc.SchemaVersion
+}
+
+// validate checks value against the subset of JSON Schema used by the
+// embedded schema: type, const, format, anyOf, required, properties,
+// additionalProperties and items.
+func validate(schema map[string]any, value any, path string, problems *[]string) {
+	fail := func(format string, a ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, a...))
+	}
+
+	if want, ok := schema["const"]; ok && !reflect.DeepEqual(want, value) {
+		fail("must be %v", want)
+		return
+	}
+	if options, ok := schema["anyOf"].([]any); ok {
+		for _, option := range options {
+			var optionProblems []string
+			validate(option.(map[string]any), value, path, &optionProblems)
+			if len(optionProblems) == 0 {
+				return
+			}
+		}
+		fail("doesn't match any of the allowed forms")
+		return
+	}
+	if typ, ok := schema["type"].(string); ok && !hasType(value, typ) {
+		fail("must be of type %s", typ)
+		return
+	}
+	if format, ok := schema["format"].(string); ok {
+		if s, ok := value.(string); ok && !hasFormat(s, format) {
+			fail("must be a %s", format)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				fail("missing required field %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := properties[name].(map[string]any)
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					fail("unknown field %q", name)
+				}
+				continue
+			}
+			validate(property, v[name], path+"."+name, problems)
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func hasFormat(s, format string) bool {
+	var err error
+	switch format {
+	case "date":
+		_, err = time.Parse("2006-01-02", s)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	}
+	return err == nil
+}
@@ -0,0 +1,100 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// sourceMarker flags the days in the grid that only some of the sources of
+// a combined dataset have someone out on.
+const sourceMarker = "*"
+
+// disputedDays returns the days on which someone is out according to some
+// of the sources that have any absence of theirs but not all, with the
+// sources that have them out, by date and person. A source that has none of
+// a person's absences, like an HR system without the contractors, isn't
+// taken to dispute them. It's empty unless the absences come from more than
+// one source.
+func disputedDays(ds *ooo.Dataset, loc *time.Location) map[string]map[string][]string {
+	disputed := make(map[string]map[string][]string)
+	if len(ds.Sources()) < 2 {
+		return disputed
+	}
+	reporting := make(map[string][]string) // person -> sources with an absence of theirs
+	for _, a := range ds.Absences {
+		if a.Source == ooo.SourceAssumed || a.Source == ooo.SourceManual {
+			continue
+		}
+		if !slices.Contains(reporting[a.Person], a.Source) {
+			reporting[a.Person] = append(reporting[a.Person], a.Source)
+		}
+		for _, d := range a.Days(ds.DayLocation(a.Person, loc)) {
+			key := d.Format("2006-01-02")
+			if disputed[key] == nil {
+				disputed[key] = make(map[string][]string)
+			}
+			if sources := disputed[key][a.Person]; !slices.Contains(sources, a.Source) {
+				disputed[key][a.Person] = append(sources, a.Source)
+			}
+		}
+	}
+	for key, people := range disputed {
+		for person, sources := range people {
+			if len(sources) == len(reporting[person]) {
+				delete(people, person)
+				continue
+			}
+			sort.Strings(sources)
+		}
+		if len(people) == 0 {
+			delete(disputed, key)
+		}
+	}
+	return disputed
+}
+
+// sourceFootnotes lists the sources of the grid and, for each run of days
+// marked with sourceMarker, the sources that have the person out. Only
+// days shown in the grid, from start to lastDay, are listed.
+func sourceFootnotes(w io.Writer, ds *ooo.Dataset, disputed map[string]map[string][]string, start time.Time, lastDay string) {
+	fmt.Fprintf(w, "%3s: reported by only some of the sources: %s\n", sourceMarker, strings.Join(ds.Sources(), ", "))
+
+	type run struct {
+		person      string
+		first, last time.Time
+		sources     string
+	}
+	var runs []run
+	open := make(map[string]int) // person -> index of the run that may continue
+	for d := start; d.Format("2006-01-02") <= lastDay; d = d.AddDate(0, 0, 1) {
+		people := disputed[d.Format("2006-01-02")]
+		for person, sources := range people {
+			joined := strings.Join(sources, ", ")
+			if i, ok := open[person]; ok && runs[i].sources == joined && runs[i].last.AddDate(0, 0, 1).Equal(d) {
+				runs[i].last = d
+				continue
+			}
+			open[person] = len(runs)
+			runs = append(runs, run{person, d, d, joined})
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].person != runs[j].person {
+			return runs[i].person < runs[j].person
+		}
+		return runs[i].first.Before(runs[j].first)
+	})
+	for _, r := range runs {
+		dates := r.first.Format("Mon Jan 2")
+		if !r.last.Equal(r.first) {
+			dates += " – " + r.last.Format("Mon Jan 2")
+		}
+		fmt.Fprintf(w, "     %s %s: only %s\n", r.person, dates, r.sources)
+	}
+}
@@ -0,0 +1,89 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klaasmeinke/ooo-view/pkg/ooo"
+)
+
+// Sections of the members not in the roster, or without a manager in it.
+const (
+	unknownTeam = "Not in the roster"
+	noManager   = "No manager in the roster"
+)
+
+// TeamSections returns the members, and those without data, by their team
+// in ds.Roster.
+func TeamSections(ds *ooo.Dataset) map[string][]string {
+	return sections(ds, func(entry ooo.RosterEntry) string { return entry.Team }, unknownTeam)
+}
+
+// ManagerSections returns the members, and those without data, by the
+// manager they report to directly in ds.Roster.
+func ManagerSections(ds *ooo.Dataset) map[string][]string {
+	return sections(ds, func(entry ooo.RosterEntry) string { return entry.Manager }, noManager)
+}
+
+func sections(ds *ooo.Dataset, key func(ooo.RosterEntry) string, unknown string) map[string][]string {
+	byKey := make(map[string][]string)
+	for _, person := range append(append([]string(nil), ds.Members...), ds.WithoutData()...) {
+		name := unknown
+		if entry, ok := ds.Roster[person]; ok && key(entry) != "" {
+			name = key(entry)
+		}
+		byKey[name] = append(byKey[name], person)
+	}
+	return byKey
+}
+
+// GridByTeam writes a Grid for the members of each team in ds.Roster, under
+// a header per team, followed by the coverage of each team: the day with
+// the lowest share of its members in, by their weight in the roster.
+func GridByTeam(w io.Writer, ds *ooo.Dataset) {
+	gridSections(w, ds, TeamSections(ds), unknownTeam, func(team string) string { return team })
+}
+
+// GridByManager writes a Grid for the direct reports of each manager in
+// ds.Roster, like GridByTeam.
+func GridByManager(w io.Writer, ds *ooo.Dataset) {
+	gridSections(w, ds, ManagerSections(ds), noManager, func(manager string) string {
+		if manager == noManager {
+			return manager
+		}
+		return "Reports to " + manager
+	})
+}
+
+// gridSections writes a Grid per section, headed by header(name), and the
+// coverage of each.
+func gridSections(w io.Writer, ds *ooo.Dataset, bySection map[string][]string, unknown string, header func(string) string) {
+	loc := ds.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	names := sortedSections(bySection, unknown)
+	for _, name := range names {
+		people := bySection[name]
+		fmt.Fprintf(w, "\n== %s: %d %s ==\n", header(name), len(people), plural(len(people), "member", "members"))
+		Grid(w, ds.Subset(people))
+	}
+
+	start, lastDay := weeks(ds, loc)
+	fmt.Fprintln(w, "Coverage:")
+	for _, name := range names {
+		days := headcount(ds.Subset(bySection[name]), start, lastDay)
+		if len(days) == 0 {
+			continue
+		}
+		least := lowestCoverage(days)
+		if least.present == least.working {
+			fmt.Fprintf(w, "  %-30s everyone in\n", header(name))
+			continue
+		}
+		fmt.Fprintf(w, "  %-30s lowest %.0f%%, %d of %d in on %s\n", header(name), least.coverage*100, least.present, least.working, least.date.Format("Mon Jan 2"))
+	}
+	fmt.Fprintln(w)
+}